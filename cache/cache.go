@@ -0,0 +1,218 @@
+// Package cache keeps session credentials on disk between runs, in the JSON form that
+// an AWS credential_process must print, until they expire.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// ProcessVersion is the version of the credential_process JSON format that we write
+	// unless told otherwise
+	ProcessVersion = 1
+
+	// The permissions given to the cache directory and the files in it; they hold
+	// secrets so only the owner should be able to get at them
+	dirMode  os.FileMode = 0700
+	fileMode os.FileMode = 0600
+)
+
+// Entry holds cached session credentials, with the field names and JSON form of the
+// output that the AWS SDKs expect from a credential_process.
+type Entry struct {
+	Version         int        `json:"Version"`
+	AccessKeyID     string     `json:"AccessKeyId"`
+	SecretAccessKey string     `json:"SecretAccessKey"`
+	SessionToken    string     `json:"SessionToken"`
+	Expiration      *time.Time `json:"Expiration,omitempty"` // Entries without an expiration are never cached
+}
+
+var (
+	// The directory that cache files are kept in; if empty, mafia in the OS specific user
+	// cache directory. Set via SetDir(..) and cleared by ResetPackageDefaults(..).
+	cacheDir string
+
+	// The function that tells the package what time it is, overridden by unit tests that
+	// need to freeze time. Set via SetNowFunc(..) and restored by ResetPackageDefaults(..).
+	nowFunc func() time.Time
+
+	// The characters that may not appear in a cache file name
+	unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9_.@-]`)
+)
+
+// Load time initialization
+func init() {
+	ResetPackageDefaults()
+}
+
+// Load returns the entry cached under the given key, or nil if there is none or the one
+// there has expired. Expiration is respected strictly: an entry is only returned if the
+// current time is before its expiration time. A cache file that cannot be made sense of
+// is treated as missing.
+func Load(key string) (*Entry, error) {
+
+	// Find and read the file, not having one being perfectly normal
+	path, err := filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read cache file %s: %v", path, err)
+	}
+
+	// Only hand it back if it is complete and still good
+	var entry Entry
+	if err = json.Unmarshal(contents, &entry); err != nil {
+		return nil, nil
+	}
+	if entry.Expiration == nil || !nowFunc().Before(*entry.Expiration) ||
+		len(entry.AccessKeyID) == 0 || len(entry.SecretAccessKey) == 0 || len(entry.SessionToken) == 0 {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// Store caches the given entry under the given key, replacing any entry already there.
+// Entries without an expiration time cannot be trusted to still be good later, so
+// they are not cached. The file is written to a temporary file and renamed into place so
+// that a concurrent Load(..) never sees a half written entry.
+func Store(key string, entry *Entry) error {
+
+	// Nothing to do if we would never hand it back
+	if entry.Expiration == nil {
+		return nil
+	}
+
+	// Make sure that the directory is there and private
+	path, err := filePath(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return fmt.Errorf("Could not create cache directory %s: %v", filepath.Dir(path), err)
+	}
+
+	// Write the entry alongside its final resting place, then move it there
+	contents, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Could not write cache file %s: %v", path, err)
+	}
+	defer os.Remove(temp.Name()) // Fails harmlessly once renamed
+	_, err = temp.Write(contents)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), fileMode)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not write cache file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Remove removes the entry cached under the given key, if there is one.
+func Remove(key string) error {
+	path, err := filePath(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Could not remove cache file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Keys returns the keys of all the entries in the cache, expired or not, in the form
+// given by FileKey(..). A cache directory that does not exist yet holds no entries.
+func Keys() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read cache directory %s: %v", dir, err)
+	}
+	var keys []string
+	for _, file := range files {
+		if name := file.Name(); file.Mode().IsRegular() && filepath.Ext(name) == ".json" {
+			keys = append(keys, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	return keys, nil
+}
+
+// FileKey returns the key as it appears in the name of its cache file, and so in the
+// list returned by Keys(..): with any characters that are not safe in a file name replaced.
+func FileKey(key string) string {
+	return unsafeKeyChars.ReplaceAllString(key, "_")
+}
+
+// Dir returns the directory that cache files are kept in: mafia in the OS specific user
+// cache directory, e.g. $HOME/.cache/mafia on Linux, unless SetDir(..) says otherwise.
+func Dir() (string, error) {
+	if len(cacheDir) != 0 {
+		return cacheDir, nil
+	}
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("Could not find the user cache directory: %v", err)
+	}
+	return filepath.Join(userCacheDir, "mafia"), nil
+}
+
+// SetDir overrides the directory that cache files are kept in. An empty string restores
+// the default.
+func SetDir(dir string) {
+	cacheDir = dir
+}
+
+// SetNowFunc allows unit tests to substitute a function of their own for time.Now(..)
+// so that expiration can be tested deterministically.
+func SetNowFunc(f func() time.Time) {
+	nowFunc = f
+}
+
+// ResetPackageDefaults establishes or reestablishes the normal package global values.
+// This is called during package initialization and also by unit tests needing to
+// leave the package as they found it.
+func ResetPackageDefaults() {
+	cacheDir = ""
+	nowFunc = time.Now
+}
+
+// filePath returns the path of the cache file for the given key, with any characters
+// in the key that are not safe in a file name replaced.
+func filePath(key string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileKey(key)+".json"), nil
+}
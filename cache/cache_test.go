@@ -0,0 +1,140 @@
+package cache
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See cache.go for overall package documentation. This file contains
+// unit tests for the cache.go functions.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// useTempDir points the cache at a fresh temporary directory, returning a function that
+// removes it and restores the package defaults.
+func useTempDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "mafia-cache")
+	require.Nil(t, err, "could not create a temporary cache directory")
+	SetDir(filepath.Join(dir, "mafia"))
+	return func() {
+		os.RemoveAll(dir)
+		ResetPackageDefaults()
+	}
+}
+
+// TestStoreAndLoad confirms that a stored entry is loaded back for as long as it has not
+// expired, and not a moment longer.
+func TestStoreAndLoad(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer useTempDir(t)()
+
+	// Freeze time half an hour before the credentials expire
+	expiration := time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	now := expiration.Add(-30 * time.Minute)
+	SetNowFunc(func() time.Time { return now })
+
+	// Store an entry and read it back
+	entry := &Entry{Version: ProcessVersion, AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token", Expiration: &expiration}
+	require.Nil(t, Store("default", entry), "there should not have been an error")
+	loaded, err := Load("default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, entry, loaded, "the entry should have been loaded intact")
+
+	// Other keys should not see it
+	loaded, err = Load("other")
+	require.Nil(t, err, "there should not have been an error")
+	require.Nil(t, loaded, "there should have been no entry for another key")
+
+	// And at the moment of expiration it should be gone
+	now = expiration
+	loaded, err = Load("default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Nil(t, loaded, "an expired entry should not have been loaded")
+}
+
+// TestStorePermissions confirms that cache files and their directory are private.
+func TestStorePermissions(t *testing.T) {
+
+	// Unix permissions are meaningless on Windows
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not checked on Windows")
+	}
+
+	// Put the package back into its normal state after we are done with the test
+	defer useTempDir(t)()
+
+	expiration := time.Now().Add(time.Hour)
+	require.Nil(t, Store("default", &Entry{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token", Expiration: &expiration}), "there should not have been an error")
+	dir, _ := Dir()
+	info, err := os.Stat(dir)
+	require.Nil(t, err, "the cache directory should exist")
+	require.Equal(t, dirMode, info.Mode().Perm(), "the cache directory should be private")
+	info, err = os.Stat(filepath.Join(dir, "default.json"))
+	require.Nil(t, err, "the cache file should exist")
+	require.Equal(t, fileMode, info.Mode().Perm(), "the cache file should be private")
+}
+
+// TestStoreWithoutExpiration confirms that entries that might never expire are not cached.
+func TestStoreWithoutExpiration(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer useTempDir(t)()
+
+	require.Nil(t, Store("default", &Entry{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token"}), "there should not have been an error")
+	loaded, err := Load("default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Nil(t, loaded, "an entry without an expiration should not have been cached")
+}
+
+// TestLoadCorrupt confirms that an unreadable cache file is treated as missing, and that
+// keys are made safe for use as file names.
+func TestLoadCorrupt(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer useTempDir(t)()
+
+	dir, _ := Dir()
+	require.Nil(t, os.MkdirAll(dir, dirMode), "could not create the cache directory")
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "a_b.json"), []byte("{not json"), fileMode), "could not write the cache file")
+	loaded, err := Load("a/b")
+	require.Nil(t, err, "there should not have been an error")
+	require.Nil(t, loaded, "a corrupt entry should not have been loaded")
+}
+
+// TestKeysAndRemove confirms that the keys of cached entries are listed, expired or not,
+// and that entries can be removed one at a time.
+func TestKeysAndRemove(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer useTempDir(t)()
+
+	// An empty cache is not an error, even before its directory exists
+	keys, err := Keys()
+	require.Nil(t, err, "there should not have been an error")
+	require.Empty(t, keys, "there should have been no keys")
+	require.Nil(t, Remove("default"), "removing a missing entry should not be an error")
+
+	// Store a current entry and an expired one
+	current, expired := time.Now().Add(time.Hour), time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	require.Nil(t, Store("default", &Entry{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token", Expiration: &current}), "there should not have been an error")
+	require.Nil(t, Store("work/dev", &Entry{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token", Expiration: &expired}), "there should not have been an error")
+	keys, err = Keys()
+	require.Nil(t, err, "there should not have been an error")
+	require.ElementsMatch(t, []string{"default", FileKey("work/dev")}, keys)
+	require.Equal(t, "work_dev", FileKey("work/dev"), "unsafe characters should have been replaced")
+
+	// Remove one of them and the other remains
+	require.Nil(t, Remove("work/dev"), "there should not have been an error")
+	keys, err = Keys()
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, []string{"default"}, keys)
+}
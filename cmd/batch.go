@@ -0,0 +1,145 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the batch subcommand, which obtains and saves sessions for many profiles at once.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// batchCmd represents the batch subcommand
+var batchCmd = &cobra.Command{
+	Use:   "batch codes-file",
+	Short: "Obtain and save session credentials for each profile and MFA code listed in a file",
+	Long: `Reads a file in which each line is a profile and an MFA code for its device, separated
+by a comma, and obtains and saves session credentials for each in turn, as
+mafia --save --profile <profile> <code> would, printing whether each line succeeded.
+Blank lines and lines starting with # are ignored. The other flags apply to every
+line, but the defaults of the ~/.mafia.yaml file are only those of the --profile
+profile. The exit status is non-zero if any line failed. For example, with a
+codes.txt file of:
+
+   work,123456
+   personal,654321
+
+run:
+
+   mafia batch codes.txt`,
+	Args: cobra.ExactArgs(1),
+
+	// RunE works through the file, line by line
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateLogFormat(); err != nil {
+			return err
+		}
+		if err := validateStore(); err != nil {
+			return err
+		}
+		if err := validateEncrypt(); err != nil {
+			return err
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			return newConfigError(fmt.Errorf("could not open the batch file: %w", err))
+		}
+		defer file.Close()
+		return runBatch(cmd.OutOrStdout(), file, args[0])
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the batch subcommand to the root command
+	rootCmd.AddCommand(batchCmd)
+}
+
+// runBatch obtains and saves session credentials for each profile and MFA code read from
+// the given reader, reporting on each to the given writer, and returns an error naming how
+// many failed if any did. The --profile and --save flag values are put back afterwards.
+func runBatch(w io.Writer, r io.Reader, name string) error {
+	defer func(p string, s bool) { profile, saveCredentials = p, s }(profile, saveCredentials)
+
+	// Work through the lines that are not blank or comments
+	lines, failures := 0, 0
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines++
+		batchProfile, err := saveBatchLine(line)
+		if err != nil {
+			failures++
+			fmt.Fprintf(w, "line %d, %s: FAILED: %s\n", number, batchProfile, maskSecrets(err.Error()))
+			continue
+		}
+		fmt.Fprintf(w, "line %d, %s: saved in the [%s] section\n", number, batchProfile, saveOptions().SectionName())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read the batch file %s: %w", name, err)
+	}
+
+	// Sum it all up
+	fmt.Fprintf(w, "%d of %d profiles saved\n", lines-failures, lines)
+	if failures != 0 {
+		return fmt.Errorf("%d of %d profiles in %s failed", failures, lines, name)
+	}
+	return nil
+}
+
+// saveBatchLine obtains and saves session credentials for the profile and MFA code given
+// by one line of a batch file, returning the profile, or the line itself if it does not
+// have the profile,code form.
+func saveBatchLine(line string) (string, error) {
+
+	// Pull the line apart
+	fields := strings.Split(line, ",")
+	if len(fields) != 2 || len(strings.TrimSpace(fields[0])) == 0 {
+		return line, newConfigError(errors.New("expected a profile and an MFA code separated by a comma"))
+	}
+	profile, saveCredentials = strings.TrimSpace(fields[0]), true
+
+	// Obtain the session and save it, as the root command would
+	_, err := obtainAndSaveSession(strings.TrimSpace(fields[1]))
+	return profile, err
+}
+
+// obtainAndSaveSession obtains session credentials for the --profile profile with the given
+// MFA code, generating one if it is empty, and saves them, logging each step as the root
+// command would. It returns when the saved session expires, if that is known.
+func obtainAndSaveSession(mfaToken string) (*time.Time, error) {
+	logEvent(logRecord{Event: eventAuthAttempt, Profile: profile, RoleARN: roleARN})
+	credentials, err := fetchSessionCredentials(mfaToken)
+	if err != nil {
+		logEvent(logRecord{Event: eventAuthFailure, Profile: profile, RoleARN: roleARN,
+			Class: exitClassNames[exitCodeFor(err)], Error: err.Error()})
+		return nil, err
+	}
+	defer credentials.Wipe()
+	rememberSecrets(credentials)
+	logEvent(logRecord{Event: eventAuthSuccess, Profile: profile, RoleARN: roleARN,
+		Expiration: logTime(credentials.Expiration)})
+	result, err := saveSessionCredentials(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if result.Written {
+		logEvent(logRecord{Event: eventSave, Profile: result.Section, Expiration: logTime(credentials.Expiration),
+			File: result.Filepath, Outcome: saveOutcome(result)})
+	}
+	return credentials.Expiration, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the batch.go functions.
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// Where the tests write their batch files
+	testBatchFilePath = "./batch.test"
+)
+
+// TestBatch confirms that the batch subcommand saves a session for each good line of
+// the file, reports on every line, and fails if any line did.
+func TestBatch(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(testBatchFilePath)
+
+	// Give the fake credentials file a second profile with an MFA device of its own
+	mockChildPackages()
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	work := cfg.Section("work")
+	work.NewKey(mfile.AccessKeyIDKey, fakeAccessKeyID)
+	work.NewKey(mfile.SecretAccessKeyKey, fakeSecretAccessKey)
+	work.NewKey(mfile.MfaDeviceIDKey, fakeMFADeviceID)
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "error writing the test credentials file")
+
+	// Both good profiles are saved, and the bad lines reported
+	contents := "# bootstrap\ndefault,123456\n\nwork, 654321\nmissing,111111\nnonsense\n"
+	require.Nil(t, ioutil.WriteFile(testBatchFilePath, []byte(contents), 0600), "could not write the batch file")
+	output := executeCommand("batch", testBatchFilePath)
+	require.NotNil(t, executeError, "the bad lines should have failed the batch")
+	require.Equal(t, "2 of 4 profiles in "+testBatchFilePath+" failed", executeError.Error())
+	require.Contains(t, output, "line 2, default: saved in the [default-session] section\n")
+	require.Contains(t, output, "line 4, work: saved in the [work-session] section\n")
+	require.Contains(t, output, "line 5, missing: FAILED: ")
+	require.Contains(t, output, "line 6, nonsense: FAILED: expected a profile and an MFA code separated by a comma\n")
+	require.Contains(t, output, "2 of 4 profiles saved\n")
+	cfg, err = ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	require.Equal(t, accessKey, cfg.Section("work-session").Key(mfile.AccessKeyIDKey).Value(), "the work session was not saved")
+	require.Equal(t, accessKey, cfg.Section(mfile.SessionSectionName).Key(mfile.AccessKeyIDKey).Value(), "the default session was not saved")
+
+	// A batch without failures succeeds
+	require.Nil(t, ioutil.WriteFile(testBatchFilePath, []byte("work,222222\n"), 0600), "could not write the batch file")
+	output = executeCommand("batch", testBatchFilePath)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "1 of 1 profiles saved\n")
+
+	// And a missing file is a configuration error
+	executeCommand("batch", "./no-such-batch.test")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
@@ -0,0 +1,92 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the cache subcommand and its clear subcommand.
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/mikebway/mafia/cache"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache subcommand, which only gathers its own subcommands
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the session credentials cached for --credential-process",
+	Long: `Manages the session credentials that --credential-process caches between runs so
+that the AWS CLI and SDKs need not be given a new MFA code every time.`,
+	Args: cobra.NoArgs,
+}
+
+// cacheClearCmd represents the cache clear subcommand
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove cached --credential-process session credentials",
+	Long: `Removes the session credentials cached by --credential-process: those of every
+profile, or with --profile, only those of that profile, whatever roles they were for.
+The next --credential-process run then asks for an MFA code again, which is what you
+want after rotating your keys or changing the role a profile assumes. For example:
+
+   mafia cache clear --profile work`,
+	Args: cobra.NoArgs,
+
+	// RunE removes the cached entries asked for
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keys, err := cache.Keys()
+		if err != nil {
+			return err
+		}
+		onlyProfile := cmd.Flags().Changed("profile")
+		removed := 0
+		for _, key := range keys {
+			if onlyProfile && !isProcessCacheKeyFor(key, profile) {
+				continue
+			}
+			if err = cache.Remove(key); err != nil {
+				return err
+			}
+			removed++
+		}
+
+		// Say what was done, and where
+		dir, _ := cache.Dir() // Cannot fail, Keys() having found it
+		switch {
+		case removed == 0 && onlyProfile:
+			fmt.Fprintf(cmd.OutOrStdout(), "There were no cached session credentials for the %s profile in %s\n", profile, dir)
+		case removed == 0:
+			fmt.Fprintf(cmd.OutOrStdout(), "There were no cached session credentials in %s\n", dir)
+		default:
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %d cached session credentials from %s\n", removed, dir)
+		}
+		return nil
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the cache subcommand to the root command, and clear to it
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
+
+// isProcessCacheKeyFor reports whether the given key, as listed by cache.Keys(), is one
+// that processCacheKey() could have returned for the given profile, for any --role-arn.
+func isProcessCacheKeyFor(key, profile string) bool {
+	profileKey := cache.FileKey(profile)
+	if key == profileKey {
+		return true
+	}
+	if !strings.HasPrefix(key, profileKey+"-") {
+		return false
+	}
+	digest, err := hex.DecodeString(key[len(profileKey)+1:])
+	return err == nil && len(digest) == processCacheDigestLength
+}
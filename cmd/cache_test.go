@@ -0,0 +1,56 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the cache.go functions.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikebway/mafia/cache"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCacheClear confirms that cache clear removes the cached entries of one profile,
+// whatever their role, or of every profile.
+func TestCacheClear(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer useTempCache(t)()
+
+	// Cache entries for the default profile, plain and for a role, and for another profile
+	mockChildPackages()
+	later := time.Now().Add(time.Hour)
+	entry := &cache.Entry{AccessKeyID: accessKey, SecretAccessKey: secret, SessionToken: token, Expiration: &later}
+	profile, roleARN = "default", "arn:aws:iam::210987654321:role/admin"
+	roleKey := processCacheKey()
+	for _, key := range []string{"default", roleKey, "default-other", "work"} {
+		require.Nil(t, cache.Store(key, entry), "could not cache an entry")
+	}
+	dir, _ := cache.Dir()
+
+	// Clearing the default profile leaves the other profiles alone
+	output := executeCommand("cache", "clear", "--profile", "default")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "Removed 2 cached session credentials from "+dir+"\n", output)
+	keys, err := cache.Keys()
+	require.Nil(t, err, "there should not have been an error")
+	require.ElementsMatch(t, []string{"default-other", "work"}, keys)
+
+	// There is no more to clear for it
+	output = executeCommand("cache", "clear", "--profile", "default")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "There were no cached session credentials for the default profile in "+dir+"\n", output)
+
+	// Without --profile, everything goes
+	output = executeCommand("cache", "clear")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "Removed 2 cached session credentials from "+dir+"\n", output)
+	output = executeCommand("cache", "clear")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "There were no cached session credentials in "+dir+"\n", output)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the check subcommand.
+
+import (
+	"fmt"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// The --format value that has the check subcommand print a Unix timestamp
+	checkFormatEpoch = "epoch"
+)
+
+var (
+	// The check subcommand's own --format flag value, the root command's being a template
+	checkFormat string
+)
+
+// checkCmd represents the check subcommand
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Print when the saved session expires, for monitoring scripts",
+	Long: `Prints when the session saved for the profile expires, or that it has expired or
+that there is none. With --format epoch, prints nothing but the expiration as a Unix
+timestamp, or 0 if the session has expired or there is none, for a monitoring check to
+alert on before the session lapses. As with the remaining subcommand, only the saved
+expiration time is read and AWS is not called. For example:
+
+   mafia check --profile work --format epoch`,
+	Args: cobra.NoArgs,
+
+	// RunE prints the expiration in the format asked for
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(checkFormat) != 0 && checkFormat != checkFormatEpoch {
+			return newConfigError(fmt.Errorf("the check subcommand's --format must be %s, not %q", checkFormatEpoch, checkFormat))
+		}
+
+		// Judge the session by the creds package clock, which unit tests can stop
+		expiration := savedExpiration()
+		session := &creds.SessionCredentials{Expiration: expiration}
+		live := expiration != nil && !session.Expired()
+
+		// Monitoring wants a number, and nothing but
+		if checkFormat == checkFormatEpoch {
+			epoch := int64(0)
+			if live {
+				epoch = expiration.Unix()
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), epoch)
+			return nil
+		}
+
+		// A person wants a sentence
+		switch {
+		case expiration == nil:
+			fmt.Fprintf(cmd.OutOrStdout(), "There is no saved session for the %s profile\n", profile)
+		case live:
+			fmt.Fprintf(cmd.OutOrStdout(), "The saved session expires at %s, in %s\n", formatExpiry(*expiration), compactDuration(session.Remaining()))
+		default:
+			fmt.Fprintf(cmd.OutOrStdout(), "The saved session expired at %s\n", formatExpiry(*expiration))
+		}
+		return nil
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the check subcommand to the root command, with its flags
+	rootCmd.AddCommand(checkCmd)
+	initCheckFlags()
+}
+
+// initCheckFlags defines the check subcommand's own flags. It is separated from init()
+// so that unit tests can reset the flags, as they do the root command's.
+func initCheckFlags() {
+	checkCmd.Flags().StringVar(&checkFormat, "format", "", "print the expiration as a Unix timestamp, 0 if the session has expired or there is none, with epoch")
+}
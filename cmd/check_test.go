@@ -0,0 +1,93 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the check.go functions.
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCheckEpoch confirms that check --format epoch prints the saved session's expiration
+// as a Unix timestamp, and 0 when there is no live session, without calling AWS.
+func TestCheckEpoch(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Nothing saved, nothing live
+	mockChildPackages()
+	output := executeCommand("check", "--format", "epoch")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "0\n", output, "a missing session should print 0")
+
+	// Save a session that is good for another hour and a half
+	expiration = time.Now().Add(90 * time.Minute).Truncate(time.Second)
+	executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	calls := countSTSCalls()
+	output = executeCommand("check", "--format", "epoch")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, strconv.FormatInt(expiration.Unix(), 10)+"\n", output, "unexpected expiration")
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+	output = executeCommand("check")
+	require.Contains(t, output, "The saved session expires at ", "unexpected description")
+
+	// Once expired, 0 again
+	expiration = time.Now().Add(-time.Minute)
+	executeCommandCapturingStdout("123456", "--save")
+	output = executeCommand("check", "--format", "epoch")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "0\n", output, "an expired session should print 0")
+	output = executeCommand("check")
+	require.Contains(t, output, "The saved session expired at ", "unexpected description")
+}
+
+// TestCheckExpiresNow confirms that, by the creds package clock, a session is live until
+// the very moment that it expires, and not at that moment.
+func TestCheckExpiresNow(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Save a session, then stop the clock a second before it expires
+	mockChildPackages()
+	expiration = time.Now().Add(time.Hour).Truncate(time.Second)
+	executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	creds.SetNowFunc(func() time.Time { return expiration.Add(-time.Second) })
+	output := executeCommand("check")
+	require.Contains(t, output, ", in 1s\n", "the session should have had a second left")
+	output = executeCommand("remaining")
+	require.Equal(t, "1s\n", output, "the session should have had a second left")
+
+	// And then at the moment that it expires
+	creds.SetNowFunc(func() time.Time { return expiration })
+	output = executeCommand("check", "--format", "epoch")
+	require.Equal(t, "0\n", output, "a session expiring now should print 0")
+	output = executeCommand("check")
+	require.Contains(t, output, "The saved session expired at ", "unexpected description")
+	output = executeCommand("remaining")
+	require.Empty(t, output, "no time should have remained")
+}
+
+// TestCheckFormat confirms that the check subcommand knows no formats but epoch.
+func TestCheckFormat(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	executeCommand("check", "--format", "{{.AccessKeyID}}")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
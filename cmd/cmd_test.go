@@ -8,17 +8,24 @@ package cmd
 // Unit tests for the Cobra command line parsers
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/mikebway/mafia/cache"
 	"github.com/mikebway/mafia/creds"
 	"github.com/mikebway/mafia/mfile"
+	"github.com/mikebway/mafia/store"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/ini.v1"
 )
@@ -27,6 +34,7 @@ const (
 	// We create (and recreate) a dummy AWS credentials file that we cna control and
 	// observe without damaging any geniune article
 	fakeCredentialsFilePath = "./credentials.test"
+	fakeConfigFilePath      = "./config.test"
 
 	// The AWS access ID value that we shall populate the fake credentials file with
 	fakeAccessKeyID = "FAKE_ACCESS_KEY_ID"
@@ -35,7 +43,7 @@ const (
 	fakeSecretAccessKey = "FAKE_SECRET_ACCESS_KEY"
 
 	// The AWS MFA device serial number that we sometimes populate the fake credentials file with
-	fakeMFADeviceID = "arn:aws:iam::999999999999:mfa/fake"
+	fakeMFADeviceID = "arn:aws:iam::123456789012:mfa/fake"
 )
 
 var (
@@ -54,13 +62,76 @@ var (
 			Expiration:      &expiration,
 		},
 	}
+
+	// The fake STS client that the creds package has been given, if any
+	installedFakeSTS *fakeSTS
 )
 
+// fakeSTS is a fake AWS STS client, handed to the creds package in place of the real one,
+// whose responses are supplied by the unit tests. By default, it returns the happy path
+// session credentials for session, role, and SAML requests, and fails to identify anyone
+// or to describe any role.
+type fakeSTS struct {
+	getSessionToken    func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error)
+	assumeRole         func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error)
+	assumeRoleWithSAML func(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error)
+	getCallerIdentity  func(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error)
+}
+
+// GetSessionToken is the fake of the AWS STS GetSessionToken(..) function.
+func (f *fakeSTS) GetSessionToken(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+	return f.getSessionToken(input)
+}
+
+// AssumeRole is the fake of the AWS STS AssumeRole(..) function.
+func (f *fakeSTS) AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	return f.assumeRole(input)
+}
+
+// AssumeRoleWithSAML is the fake of the AWS STS AssumeRoleWithSAML(..) function.
+func (f *fakeSTS) AssumeRoleWithSAML(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error) {
+	return f.assumeRoleWithSAML(input)
+}
+
+// GetCallerIdentity is the fake of the AWS STS GetCallerIdentity(..) function.
+func (f *fakeSTS) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return f.getCallerIdentity(input)
+}
+
+// fakeAWS returns the fake STS client that the creds package is using, first handing it
+// one with the default, happy path, behavior if it does not already have one.
+func fakeAWS() *fakeSTS {
+	if installedFakeSTS == nil {
+		installedFakeSTS = &fakeSTS{
+			getSessionToken: func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+				return getSessionTokenOutput, nil
+			},
+			assumeRole: func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+				return &sts.AssumeRoleOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+			},
+			assumeRoleWithSAML: func(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error) {
+				return &sts.AssumeRoleWithSAMLOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+			},
+			getCallerIdentity: func(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+				return nil, awserr.New("InvalidClientTokenId", "The security token included in the request is invalid.", nil)
+			},
+		}
+		creds.SetSTSClient(installedFakeSTS)
+		creds.SetGetRoleFunc(func(awsService *iam.IAM, input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+			return nil, awserr.New("AccessDenied", "User is not authorized to perform: iam:GetRole", nil)
+		})
+	}
+	return installedFakeSTS
+}
+
 // Initialization block
 func init() {
 	// When running unit test on the command line parser, signal that the actual operations
 	// should not be executed, only the parsing.
 	unitTesting = true
+
+	// Nor should a developer's own per-profile defaults get in the way of the tests
+	defaultsFilepath = "./missing-defaults.test"
 }
 
 // executeCommand invokes Execute() while capturing its output
@@ -172,7 +243,729 @@ func TestSaveHappyPath(t *testing.T) {
 
 	// The stdout capure should contain the environment variables form and the ready-to-paste
 	// into credentials file form.
-	require.Contains(t, stdout, "Session credentials saved to file")
+	require.Contains(t, stdout, "Session credentials saved to file "+fakeCredentialsFilePath+", in the [default-session] section\n")
+	require.Contains(t, stdout, "To use them: export AWS_PROFILE='default-session'\n")
+
+	// Saved in place, the profile is where the AWS CLI looks anyway
+	_, stdout = executeCommandCapturingStdout("123456", "--save", "--in-place")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "in the [default] section\n")
+	require.NotContains(t, stdout, "To use them", "there should have been no need to say how to use them")
+}
+
+// TestSaveCustomKeyNames confirms that the key name flags direct where the saved session
+// credentials are written.
+func TestSaveCustomKeyNames(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+
+	// Save with custom key names
+	executeCommandCapturingStdout("123456", "--save", "--access-key-name", "my_key", "--secret-key-name", "my_secret", "--session-token-name", "my_token", "--expiration-name", "x_security_token_expires")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// Confirm that the values were written under those names
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	sessionSection := cfg.Section(mfile.SessionSectionName)
+	require.Equal(t, accessKey, sessionSection.Key("my_key").Value(), "access key not written under custom name")
+	require.Equal(t, secret, sessionSection.Key("my_secret").Value(), "secret not written under custom name")
+	require.Equal(t, token, sessionSection.Key("my_token").Value(), "token not written under custom name")
+	require.Equal(t, expiration.UTC().Format(time.RFC3339), sessionSection.Key("x_security_token_expires").Value(), "expiration not written under custom name")
+	require.False(t, sessionSection.HasKey(mfile.SessionExpirationKey), "expiration should not have been written under the standard name")
+}
+
+// TestSaveInPlace confirms that the --in-place flag has the session credentials written
+// over the long term credentials in the default section.
+func TestSaveInPlace(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+
+	// Save in place, without leaving a backup lying around
+	executeCommandCapturingStdout("123456", "--save", "--in-place", "--no-backup")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// Confirm that the values were written to the default section, keeping the MFA device ID
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	defaultSection := cfg.Section(mfile.DefaultSectionName)
+	require.Equal(t, accessKey, defaultSection.Key(mfile.AccessKeyIDKey).Value(), "access key not written in place")
+	require.Equal(t, token, defaultSection.Key(mfile.SessionTokenKey).Value(), "token not written in place")
+	require.Equal(t, fakeMFADeviceID, defaultSection.Key(mfile.MfaDeviceIDKey).Value(), "the MFA device ID should have been preserved")
+}
+
+// TestSaveInPlaceBackup confirms that an in place save backs up the credentials file
+// unless told not to with --no-backup.
+func TestSaveInPlaceBackup(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	backupPath := fakeCredentialsFilePath + mfile.BackupSuffix
+	defer os.Remove(backupPath)
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+	os.Remove(backupPath)
+
+	// Saving in place with the default settings should leave a backup behind
+	executeCommandCapturingStdout("123456", "--save", "--in-place")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	_, err := os.Stat(backupPath)
+	require.Nil(t, err, "the backup file should have been created")
+
+	// But not if we say no
+	os.Remove(backupPath)
+	mockChildPackages()
+	executeCommandCapturingStdout("123456", "--save", "--in-place", "--no-backup")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	_, err = os.Stat(backupPath)
+	require.True(t, os.IsNotExist(err), "no backup file should have been created")
+}
+
+// TestSaveVerbose confirms that --verbose has the save signal say whether the section was
+// created or updated, where the backup went, and when the file was left alone.
+func TestSaveVerbose(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	backupPath := fakeCredentialsFilePath + mfile.BackupSuffix
+	defer os.Remove(backupPath)
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+
+	// The first save adds the session section
+	_, stdout := executeCommandCapturingStdout("123456", "--save", "--verbose")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "The [default-session] section was created\n")
+	require.NotContains(t, stdout, "backed up", "there should have been no backup")
+
+	// Saving the same credentials again leaves the file alone
+	_, stdout = executeCommandCapturingStdout("123456", "--save", "--verbose")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "The [default-session] section of "+fakeCredentialsFilePath+" already held these session credentials; the file was left alone\n")
+	require.NotContains(t, stdout, "Session credentials saved to file")
+
+	// Saving in place updates the default section, backing up the file first
+	_, stdout = executeCommandCapturingStdout("123456", "--save", "--in-place", "--verbose")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "The [default] section was updated\n")
+	require.Contains(t, stdout, "The previous file was backed up to "+backupPath+"\n")
+}
+
+// TestCredentialsFileFlag confirms that the --credentials-file flag directs where the MFA
+// device ID is read from and the session credentials are saved to.
+func TestCredentialsFileFlag(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend and return happy answers, then move the
+	// fake credentials file somewhere that only the flag will lead to
+	mockChildPackages()
+	otherPath := "./other-credentials.test"
+	require.Nil(t, os.Rename(fakeCredentialsFilePath, otherPath), "could not move the fake credentials file")
+	defer os.Remove(otherPath)
+
+	// Save to the file named by the flag
+	executeCommandCapturingStdout("123456", "--save", "--credentials-file", otherPath)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	cfg, err := ini.Load(otherPath)
+	require.Nil(t, err, "error reading the moved credentials file")
+	require.Equal(t, token, cfg.Section(mfile.SessionSectionName).Key(mfile.SessionTokenKey).Value(), "token not saved to the named file")
+}
+
+// TestSeveralCredentialsFilesSDK confirms that, given several credentials files, the AWS
+// SDK's own provider is pointed at the one holding the profile's long term keys rather
+// than at the list of them, which it would take for a single, missing, file.
+func TestSeveralCredentialsFilesSDK(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// The keys are in the personal file, the MFA device ID in the shared one
+	writeFakeCredentials("")
+	sharedPath := "./shared-credentials.test"
+	shared := "[default]\n" + mfile.MfaDeviceIDKey + " = " + fakeMFADeviceID + "\n"
+	require.Nil(t, ioutil.WriteFile(sharedPath, []byte(shared), 0600), "could not write the shared file")
+	defer os.Remove(sharedPath)
+
+	value, err := fileCredentials(sharedPath+string(os.PathListSeparator)+fakeCredentialsFilePath, mfile.DefaultSectionName).Get()
+	require.Nil(t, err, "the SDK should have read the keys: %v", err)
+	require.Equal(t, fakeAccessKeyID, value.AccessKeyID, "wrong access key ID")
+	require.Equal(t, fakeSecretAccessKey, value.SecretAccessKey, "wrong secret access key")
+}
+
+// TestSeveralCredentialsFiles confirms that the MFA device ID may come from a shared file
+// given by one --credentials-file flag while the keys, and the saved session, live in the
+// personal file given by another.
+func TestSeveralCredentialsFiles(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Take the MFA device ID out of the personal file and put it in a shared one
+	writeFakeCredentials("")
+	sharedPath := "./shared-credentials.test"
+	shared := "[default]\n" + mfile.MfaDeviceIDKey + " = " + fakeMFADeviceID + "\n"
+	require.Nil(t, ioutil.WriteFile(sharedPath, []byte(shared), 0600), "could not write the shared file")
+	defer os.Remove(sharedPath)
+	captured := mockSTSCapturingInput()
+
+	executeCommandCapturingStdout("123456", "--save", "--credentials-file", sharedPath, "--credentials-file", fakeCredentialsFilePath)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, fakeMFADeviceID, *captured.SerialNumber, "the MFA device ID should have come from the shared file")
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the personal credentials file")
+	require.Equal(t, token, cfg.Section(mfile.SessionSectionName).Key(mfile.SessionTokenKey).Value(), "token not saved to the personal file")
+	content, err := ioutil.ReadFile(sharedPath)
+	require.Nil(t, err, "error reading the shared credentials file")
+	require.Equal(t, shared, string(content), "the shared file should not have been touched")
+}
+
+// TestProfileFlag confirms that the --profile flag selects the section that the MFA
+// device ID is read from and names the section that the session is saved to.
+func TestProfileFlag(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, adding a second profile to the fake file
+	mockChildPackages()
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	other := cfg.Section("other")
+	other.NewKey(mfile.AccessKeyIDKey, fakeAccessKeyID)
+	other.NewKey(mfile.SecretAccessKeyKey, fakeSecretAccessKey)
+	other.NewKey(mfile.MfaDeviceIDKey, "arn:aws:iam::123456789012:mfa/other")
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "error writing the test credentials file")
+	captured := mockSTSCapturingInput()
+
+	// Save a session for the other profile
+	executeCommandCapturingStdout("123456", "--save", "--profile", "other")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "arn:aws:iam::123456789012:mfa/other", *captured.SerialNumber, "the other profile's MFA device should have been used")
+	cfg, err = ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	require.Equal(t, token, cfg.Section("other-session").Key(mfile.SessionTokenKey).Value(), "the session should have been saved to other-session")
+
+	// The same should happen without the flag if MAFIA_DEFAULT_PROFILE names the profile
+	defer setTestEnv(defaultProfileEnvVar, "other")()
+	*captured = sts.GetSessionTokenInput{}
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "arn:aws:iam::123456789012:mfa/other", *captured.SerialNumber, "the profile named by "+defaultProfileEnvVar+" should have been used")
+
+	// But the flag should still win
+	executeCommandCapturingStdout("123456", "--profile", mfile.DefaultSectionName)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, fakeMFADeviceID, *captured.SerialNumber, "the --profile flag should have won")
+}
+
+// TestCopyProfileSettings confirms that the --copy-profile-settings flag has the profile's
+// region copied into the saved session section.
+func TestCopyProfileSettings(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, giving the profile a region
+	mockChildPackages()
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	cfg.Section(mfile.DefaultSectionName).NewKey(mfile.RegionKey, "eu-west-1")
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "error writing the test credentials file")
+
+	// Save the session, with and without copying the settings
+	executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	cfg, err = ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	require.False(t, cfg.Section(mfile.SessionSectionName).HasKey(mfile.RegionKey), "the region should not have been copied by default")
+	executeCommandCapturingStdout("123456", "--save", "--copy-profile-settings")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	cfg, err = ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	require.Equal(t, "eu-west-1", cfg.Section(mfile.SessionSectionName).Key(mfile.RegionKey).Value(), "the region should have been copied")
+}
+
+// TestShowDiff confirms that --show-diff displays the changes before the save message.
+func TestShowDiff(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+	_, stdout := executeCommandCapturingStdout("123456", "--save", "--show-diff")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "Changes to [default-session] in "+fakeCredentialsFilePath+":\n+ aws_access_key_id = key\n+ aws_secret_access_key = ****\n")
+	require.Less(t, strings.Index(stdout, "Changes to"), strings.Index(stdout, "Session credentials saved to file"), "the diff should come first")
+
+	// Without changes there is nothing to show
+	_, stdout = executeCommandCapturingStdout("123456", "--save", "--show-diff")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.NotContains(t, stdout, "Changes to", "there should have been no diff")
+}
+
+// TestInPlaceWithoutSave confirms that --in-place is rejected unless --save is also given.
+func TestInPlaceWithoutSave(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+
+	// Ask for in place without saving
+	executeCommandCapturingStdout("123456", "--in-place")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Contains(t, executeError.Error(), "--in-place requires --save")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
+
+// TestFromEnv confirms that, with the --from-env flag, the credentials file is not
+// consulted and the --mfa-serial flag supplies the MFA device ID.
+func TestFromEnv(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Have the mfile package look for a file that is not there and capture the STS request
+	input := mockSTSCapturingInput()
+	mfile.OverrideDefaultCredentialsFilepath("/you/got/no/skin/on/me-cos-i-do-not-exist")
+
+	// Run with credentials from the environment
+	_, stdout := executeCommandCapturingStdout("123456", "--from-env", "--mfa-serial", "arn:aws:iam::123456789012:mfa/env")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "export AWS_ACCESS_KEY_ID=key")
+	require.Equal(t, "arn:aws:iam::123456789012:mfa/env", *input.SerialNumber, "the --mfa-serial flag was not honored")
+}
+
+// TestAutoEnvMode confirms that credentials are taken from the environment when there
+// is no credentials file but the environment has long term credentials.
+func TestAutoEnvMode(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer setTestEnv(accessKeyIDEnvVar, fakeAccessKeyID)()
+	defer setTestEnv(secretAccessKeyEnvVar, fakeSecretAccessKey)()
+
+	// Have the mfile package look for a file that is not there and capture the STS request
+	input := mockSTSCapturingInput()
+	mfile.OverrideDefaultCredentialsFilepath("/you/got/no/skin/on/me-cos-i-do-not-exist")
+
+	// Run without the --from-env flag
+	executeCommandCapturingStdout("123456", "--mfa-serial", "arn:aws:iam::123456789012:mfa/env")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "arn:aws:iam::123456789012:mfa/env", *input.SerialNumber, "the --mfa-serial flag was not honored")
+}
+
+// TestFromEnvSave confirms that credentials from the environment cannot be saved to
+// a credentials file that we have been told to keep away from.
+func TestFromEnvSave(t *testing.T) {
+
+	// Run with credentials from the environment, asking to save them
+	executeCommand("123456", "--from-env", "--save", "--mfa-serial", "arn:aws:iam::123456789012:mfa/env")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, "--save cannot be used with credentials from the environment", executeError.Error(), "not the expected error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
+
+// TestNoLongTermKeys confirms that a profile without long term keys is reported clearly,
+// without troubling AWS.
+func TestNoLongTermKeys(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, then take the secret access key away
+	mockChildPackages()
+	cfg, _ := ini.Load(fakeCredentialsFilePath)
+	cfg.Section(mfile.DefaultSectionName).DeleteKey(mfile.SecretAccessKeyKey)
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "could not rewrite the credentials file")
+	calls := countSTSCalls()
+
+	executeCommand("123456", "--save")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Contains(t, executeError.Error(), "profile default has no aws_secret_access_key")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+}
+
+// TestEmptyLongTermKey confirms that a secret access key line without a value is reported
+// as such, rather than left for AWS to complain of as a signing error.
+func TestEmptyLongTermKey(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, then empty the secret access key
+	mockChildPackages()
+	content, err := ioutil.ReadFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not read the credentials file")
+	emptied := regexp.MustCompile(`(?m)^aws_secret_access_key\s*=.*$`).ReplaceAllString(string(content), "aws_secret_access_key = ")
+	require.Nil(t, ioutil.WriteFile(fakeCredentialsFilePath, []byte(emptied), 0600), "could not rewrite the credentials file")
+	calls := countSTSCalls()
+
+	executeCommand("123456")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Contains(t, executeError.Error(), "aws_secret_access_key is empty in profile default")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+}
+
+// TestVerboseIdentity confirms that --verbose names the account and user that the MFA
+// device belongs to, and that nothing is said without it.
+func TestVerboseIdentity(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { warningOutput = os.Stderr }()
+
+	// Configure our child packages to pretend
+	mockChildPackages()
+	var warnings bytes.Buffer
+	warningOutput = &warnings
+
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, warnings.String(), "nothing should have been said without --verbose")
+
+	executeCommandCapturingStdout("123456", "--verbose")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, warnings.String(), "Authenticating account 123456789012 as fake\n", "expected the identity")
+}
+
+// TestMaxRetries confirms that the --max-retries flag reaches the creds package and
+// that nonsense values are rejected.
+func TestMaxRetries(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Have the fake AWS throttle every request
+	mockChildPackages()
+	calls := 0
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		calls++
+		return nil, awserr.New("Throttling", "Rate exceeded", nil)
+	}
+
+	// With retries disabled, there should only be the one call
+	executeCommand("123456", "--max-retries", "0")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, 1, calls, "there should have been no retries")
+
+	// And a negative number of retries should be refused
+	executeCommand("123456", "--max-retries", "-1")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, "--max-retries cannot be negative: -1", executeError.Error(), "not the expected error")
+}
+
+// TestProxyFlag confirms that the --proxy flag reaches the creds package and that URLs
+// that cannot be used are rejected before AWS is asked for anything.
+func TestProxyFlag(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+	executeCommandCapturingStdout("123456", "--proxy", "http://proxy.example.com:3128")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	executeCommand("123456", "--proxy", "ftp://proxy.example.com")
+	require.NotNil(t, executeError, "an unsupported proxy should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
+
+// TestAssumeRoleHappyPath uses mocking of lower level Mafia packages to prove that the
+// command orchestration will assume a role, with a default role session name, when
+// asked to do so.
+func TestAssumeRoleHappyPath(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend and return happy answers, capturing the
+	// assume role request so that we can examine it
+	mockChildPackages()
+	var captured *sts.AssumeRoleInput
+	fakeAWS().assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		captured = input
+		return &sts.AssumeRoleOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+	}
+
+	// Run the command asking for a role
+	output, stdout := executeCommandCapturingStdout("123456", "--role-arn", "arn:aws:iam::999999999999:role/admin")
+
+	// There should have been no error and the role credentials should have been displayed
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, output, "there should not have been any help output: %s", output)
+	require.Contains(t, stdout, "export AWS_ACCESS_KEY_ID=key")
+
+	// The role session name should default to one that identifies the user
+	require.NotNil(t, captured, "AssumeRole should have been called")
+	require.Equal(t, "arn:aws:iam::999999999999:role/admin", *captured.RoleArn, "role ARN was not passed on")
+	require.True(t, strings.HasPrefix(*captured.RoleSessionName, "mafia-fake-"), "unexpected role session name: %s", *captured.RoleSessionName)
+
+	// Now try again with an explicit role session name
+	executeCommandCapturingStdout("123456", "--role-arn", "arn:aws:iam::999999999999:role/admin", "--role-session-name", "jane-was-here")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "jane-was-here", *captured.RoleSessionName, "role session name flag was ignored")
+	require.Nil(t, captured.ExternalId, "there should have been no external ID")
+
+	// And with an external ID
+	executeCommandCapturingStdout("123456", "--role-arn", "arn:aws:iam::999999999999:role/admin", "--external-id", "ext-123")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "ext-123", *captured.ExternalId, "external ID flag was ignored")
+
+	// Which is meaningless without a role
+	executeCommandCapturingStdout("123456", "--external-id", "ext-123")
+	require.NotNil(t, executeError, "--external-id without a role should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
+
+// TestSessionNameFromHostname confirms that --session-name-from-hostname names the role
+// session after the machine, sanitized, and that an explicit role session name still wins.
+func TestSessionNameFromHostname(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { hostnameFunc = os.Hostname }()
+
+	mockChildPackages()
+	var captured *sts.AssumeRoleInput
+	fakeAWS().assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		captured = input
+		return &sts.AssumeRoleOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+	}
+	hostnameFunc = func() (string, error) { return "ci runner_7.example.com", nil }
+
+	executeCommandCapturingStdout("123456", "--role-arn", "arn:aws:iam::999999999999:role/admin", "--session-name-from-hostname")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.True(t, strings.HasPrefix(*captured.RoleSessionName, "mafia-ci-runner-7.example.com-"), "unexpected role session name: %s", *captured.RoleSessionName)
+
+	executeCommandCapturingStdout("123456", "--role-arn", "arn:aws:iam::999999999999:role/admin", "--session-name-from-hostname", "--role-session-name", "jane-was-here")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "jane-was-here", *captured.RoleSessionName, "role session name flag was ignored")
+}
+
+// TestRoleProfileChain confirms that a role profile in the AWS config file is followed:
+// a session is obtained with the MFA token for the source profile and the role is then
+// assumed with that session.
+func TestRoleProfileChain(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(fakeConfigFilePath)
+
+	// Configure our child packages to pretend and return happy answers, capturing both
+	// the session and the assume role requests
+	mockChildPackages()
+	content := "[profile admin]\nrole_arn = arn:aws:iam::999999999999:role/admin\nsource_profile = default\nrole_session_name = jane-admin\nexternal_id = ext-123\n"
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+	capturedSession := mockSTSCapturingInput()
+	var capturedRole *sts.AssumeRoleInput
+	fakeAWS().assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		capturedRole = input
+		return &sts.AssumeRoleOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+	}
+
+	// Save a session for the role profile
+	executeCommandCapturingStdout("123456", "--save", "--profile", "admin")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// The MFA token should have gone with the session request for the source profile's device
+	require.Equal(t, fakeMFADeviceID, *capturedSession.SerialNumber, "the source profile's MFA device should have been used")
+	require.Equal(t, "123456", *capturedSession.TokenCode, "the MFA token should have been used for the session")
+
+	// And the role assumed without MFA under the profile's session name
+	require.NotNil(t, capturedRole, "AssumeRole should have been called")
+	require.Equal(t, "arn:aws:iam::999999999999:role/admin", *capturedRole.RoleArn, "role ARN was not passed on")
+	require.Equal(t, "jane-admin", *capturedRole.RoleSessionName, "the profile's role session name was ignored")
+	require.Equal(t, "ext-123", *capturedRole.ExternalId, "the profile's external ID was ignored")
+	require.Nil(t, capturedRole.TokenCode, "the MFA token should not have been reused")
+
+	// The role session should have been saved under the role profile's name
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	require.Equal(t, token, cfg.Section("admin-session").Key(mfile.SessionTokenKey).Value(), "the session should have been saved to admin-session")
+
+	// A role profile and --role-arn do not mix
+	executeCommandCapturingStdout("123456", "--profile", "admin", "--role-arn", "arn:aws:iam::999999999999:role/other")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
+
+// TestRoleProfileDuration confirms that a role profile's duration_seconds is honored
+// unless --duration says otherwise.
+func TestRoleProfileDuration(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(fakeConfigFilePath)
+
+	// Configure our child packages to pretend, capturing the assume role requests
+	mockChildPackages()
+	content := "[profile admin]\nrole_arn = arn:aws:iam::999999999999:role/admin\nsource_profile = default\nduration_seconds = 1800\n"
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+	var capturedRole *sts.AssumeRoleInput
+	fakeAWS().assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		capturedRole = input
+		return &sts.AssumeRoleOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+	}
+
+	// The profile's duration by default
+	executeCommandCapturingStdout("123456", "--profile", "admin")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, int64(1800), *capturedRole.DurationSeconds, "the profile's duration_seconds should have been used")
+
+	// The flag's when it is given
+	executeCommandCapturingStdout("123456", "--profile", "admin", "--duration", "20m")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, int64(1200), *capturedRole.DurationSeconds, "--duration should have overridden duration_seconds")
+
+	// The MFA session is no role, so more than the hour of a chained role session may be had
+	content = strings.Replace(content, "1800", "7200", 1)
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+	executeCommandCapturingStdout("123456", "--profile", "admin")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, int64(7200), *capturedRole.DurationSeconds, "the profile's duration_seconds should not have been capped")
+
+	// But no more than the role allows
+	creds.SetGetRoleFunc(func(awsService *iam.IAM, input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+		return &iam.GetRoleOutput{Role: &iam.Role{MaxSessionDuration: aws.Int64(5400)}}, nil
+	})
+	executeCommandCapturingStdout("123456", "--profile", "admin")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, int64(5400), *capturedRole.DurationSeconds, "the duration should have been cut to the role's maximum")
+
+	// AWS would refuse anything shorter than fifteen minutes
+	content = strings.Replace(content, "7200", "60", 1)
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+	executeCommandCapturingStdout("123456", "--profile", "admin")
+	require.NotNil(t, executeError, "a short duration_seconds should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
+
+// TestExampleMFASerial confirms that the documentation's example MFA serial is rejected
+// before AWS is troubled with it.
+func TestExampleMFASerial(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, counting any calls to AWS
+	mockChildPackages()
+	calls := countSTSCalls()
+
+	executeCommandCapturingStdout("123456", "--mfa-serial", "arn:aws:iam::999999999999:mfa/jane")
+	require.True(t, errors.Is(executeError, mfile.ErrExampleMFASerial), "expected the example serial to be rejected: %v", executeError)
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+}
+
+// TestRoleSessionNameWithoutRole confirms that a role session name cannot be given
+// without a role to assume.
+func TestRoleSessionNameWithoutRole(t *testing.T) {
+
+	// Run the command with a session name but no role
+	output := executeCommand("123456", "--role-session-name", "jane-was-here")
+
+	// We should have been told off
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, "--role-session-name requires --role-arn", executeError.Error(), "not the expected error")
+	require.Empty(t, output, "Output for an error condition should have been empty")
+}
+
+// TestResolveSTSEndpoint confirms that the --sts-endpoint flag takes precedence over
+// the AWS_STS_ENDPOINT environment variable.
+func TestResolveSTSEndpoint(t *testing.T) {
+
+	// Make sure that we leave the environment and flags as we found them
+	defer setTestEnv(stsEndpointEnvVar, "")()
+	defer resetCommand()
+
+	// With neither flag nor environment variable, there should be no endpoint
+	resetCommand()
+	require.Empty(t, resolveSTSEndpoint(), "there should have been no endpoint override")
+
+	// The environment variable should be used if there is no flag
+	os.Setenv(stsEndpointEnvVar, "http://localhost:4566")
+	require.Equal(t, "http://localhost:4566", resolveSTSEndpoint(), "the environment variable was not honored")
+
+	// The flag should win over the environment variable
+	rootCmd.PersistentFlags().Set("sts-endpoint", "https://sts.us-gov-west-1.amazonaws.com")
+	require.Equal(t, "https://sts.us-gov-west-1.amazonaws.com", resolveSTSEndpoint(), "the flag was not honored")
+}
+
+// TestFIPS confirms that --fips is refused for regions without a FIPS STS endpoint and
+// alongside an explicit endpoint, and otherwise goes ahead.
+func TestFIPS(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer setTestEnv(stsEndpointEnvVar, "")()
+
+	mockChildPackages()
+	executeCommandCapturingStdout("123456", "--fips", "--region", "us-west-2")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	executeCommandCapturingStdout("123456", "--fips", "--region", "eu-west-1")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, "AWS has no FIPS STS endpoint in the eu-west-1 region", executeError.Error(), "not the expected error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+
+	executeCommandCapturingStdout("123456", "--fips", "--sts-endpoint", "http://localhost:4566")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
+
+// TestResolveRegion confirms the precedence of the region flag, environment variables,
+// and profile region keys.
+func TestResolveRegion(t *testing.T) {
+
+	// Make sure that we leave the environment, flags, and fake files as we found them
+	defer resetChildPackages()
+	defer setTestEnv(regionEnvVar, "")()
+	defer setTestEnv(defaultRegionEnvVar, "")()
+	defer os.Remove(fakeConfigFilePath)
+	defer resetCommand()
+
+	// Give the default profile a region in the config file, and a role profile one too
+	resetCommand()
+	setFakeCredentials()
+	content := "[default]\nregion = us-west-2\n\n[profile admin]\nrole_arn = arn:aws:iam::123456789012:role/admin\nsource_profile = default\n"
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+
+	// The profile region is all that we have, and the role profile falls back on its source
+	require.Equal(t, "us-west-2", resolveRegion(mfile.DefaultSectionName), "the profile region was not honored")
+	rootCmd.PersistentFlags().Set("profile", "admin")
+	require.Equal(t, "us-west-2", resolveRegion(mfile.DefaultSectionName), "the source profile region was not honored")
+
+	// Then the environment variables, most specific first, then the flag
+	os.Setenv(defaultRegionEnvVar, "eu-west-1")
+	require.Equal(t, "eu-west-1", resolveRegion(mfile.DefaultSectionName), "AWS_DEFAULT_REGION was not honored")
+	os.Setenv(regionEnvVar, "eu-central-1")
+	require.Equal(t, "eu-central-1", resolveRegion(mfile.DefaultSectionName), "AWS_REGION was not honored")
+	rootCmd.PersistentFlags().Set("region", "ap-southeast-2")
+	require.Equal(t, "ap-southeast-2", resolveRegion(mfile.DefaultSectionName), "the flag was not honored")
+}
+
+// TestMfaUsername examines the extraction of IAM usernames from MFA device serial numbers.
+func TestMfaUsername(t *testing.T) {
+	require.Equal(t, "jane", mfaUsername("arn:aws:iam::999999999999:mfa/jane"))
+	require.Equal(t, "jane", mfaUsername("arn:aws:iam::999999999999:mfa/some/path/jane"))
+	require.Empty(t, mfaUsername("GAHT12345678"), "hardware serial numbers do not contain a username")
 }
 
 // TestPrepForExecute bumps code coverage by looking at a test prep function that
@@ -249,19 +1042,54 @@ func executeCommandCapturingStdout(args ...string) (string, string) {
 	return output, string(outputBytes)
 }
 
+// setTestEnv sets an environment variable for the duration of a test, unsetting it
+// when given an empty value, and returns a function that restores the original
+// state. Typical usage is:
+//
+//	defer setTestEnv("SOME_VARIABLE", "some value")()
+func setTestEnv(name, value string) func() {
+
+	// Remember how things were before we started
+	original, wasSet := os.LookupEnv(name)
+
+	// Set or clear the variable as requested
+	if len(value) == 0 {
+		os.Unsetenv(name)
+	} else {
+		os.Setenv(name, value)
+	}
+
+	// Return a function to put it all back
+	return func() {
+		if wasSet {
+			os.Setenv(name, original)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
 // mockChildPackages tricks the kids into behaving the way that we want them to,
-// reading the AWS credentials file that we feed them and calling a fake wrapper
-// to the AWS STS GetSessionToken(..) that we control.
+// reading the AWS credentials file that we feed them and calling a fake AWS STS
+// client that we control.
 func mockChildPackages() {
 
 	// Fake an AWS credentials file so that the mfile package will nehave as if it is happy
 	setFakeCredentials()
 
 	// Fake out the creds package into using an apparently credentials response from AWS
-	creds.SetGetSessionTokenFunc(func(awsService *sts.STS, input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
-		return getSessionTokenOutput, nil
-	})
+	fakeAWS()
+}
 
+// mockSTSCapturingInput has the creds package return the happy path session credentials
+// without calling AWS, capturing the request in the returned structure for examination.
+func mockSTSCapturingInput() *sts.GetSessionTokenInput {
+	captured := &sts.GetSessionTokenInput{}
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		*captured = *input
+		return getSessionTokenOutput, nil
+	}
+	return captured
 }
 
 // setFakeCredentials populates a fake AWS credentials file in the current
@@ -269,6 +1097,14 @@ func mockChildPackages() {
 // globals are then manipulated such that this fake file will be used any
 // future test execution.
 func setFakeCredentials() {
+	writeFakeCredentials(fakeMFADeviceID)
+}
+
+// writeFakeCredentials populates a fake AWS credentials file in the current working
+// directory, with the given MFA device serial number / ID unless that is empty. The
+// mfile package globals are then manipulated such that this fake file will be used
+// by any future test execution.
+func writeFakeCredentials(mfaDeviceID string) {
 
 	// Start with an empty configuration file content structure
 	cfg := ini.Empty()
@@ -277,7 +1113,9 @@ func setFakeCredentials() {
 	defaultSection, err := cfg.NewSection(mfile.DefaultSectionName)
 	defaultSection.NewKey(mfile.AccessKeyIDKey, fakeAccessKeyID)
 	defaultSection.NewKey(mfile.SecretAccessKeyKey, fakeSecretAccessKey)
-	defaultSection.NewKey(mfile.MfaDeviceIDKey, fakeMFADeviceID)
+	if len(mfaDeviceID) != 0 {
+		defaultSection.NewKey(mfile.MfaDeviceIDKey, mfaDeviceID)
+	}
 
 	// Write the file
 	err = cfg.SaveTo(fakeCredentialsFilePath)
@@ -289,17 +1127,22 @@ func setFakeCredentials() {
 		os.Exit(999)
 	}
 
-	// All looks good - trick the package into using the fake file we just wrote
+	// All looks good - trick the package into using the fake file we just wrote, and
+	// keep it away from any real AWS config file
 	mfile.OverrideDefaultCredentialsFilepath(fakeCredentialsFilePath)
+	mfile.OverrideDefaultConfigFilepath(fakeConfigFilePath)
 }
 
 // resetChildPackages clears any changes that we made to the normal workings of lower
 // level Mafia packages to make these tests possible.
 func resetChildPackages() {
 
-	// Wash the faces of both dirty kids
+	// Wash the faces of all the dirty kids
 	creds.ResetPackageDefaults()
+	installedFakeSTS = nil
 	mfile.ResetPackageDefaults()
+	cache.ResetPackageDefaults()
+	store.ResetPackageDefaults()
 }
 
 // checkForExpectedSTSCallFailure checks to see whether one of the expected error conditions occurred
@@ -0,0 +1,55 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the functions that color the displays meant for a person.
+
+import (
+	"io"
+	"os"
+)
+
+// The ANSI escape sequences that color text and return it to normal
+const (
+	colorGreen = "\x1b[32m"
+	colorRed   = "\x1b[31m"
+	colorReset = "\x1b[0m"
+)
+
+const (
+	// The environment variable that, set to anything at all, turns color off as --no-color does;
+	// see https://no-color.org
+	noColorEnvVar = "NO_COLOR"
+)
+
+var (
+	// How we tell whether a writer is a terminal; unit tests substitute their own function
+	isTerminalFunc = isTerminal
+)
+
+// painter returns the given text in the given color, or as it is if color is off.
+type painter func(color, text string) string
+
+// painterFor returns the painter for text to be written to the given writer: one that
+// colors it if the writer is a terminal, and neither --no-color nor NO_COLOR turned color
+// off, and one that leaves it alone otherwise, so that piped or logged output is not
+// littered with escape sequences.
+func painterFor(w io.Writer) painter {
+	if noColor || len(os.Getenv(noColorEnvVar)) != 0 || !isTerminalFunc(w) {
+		return func(color, text string) string { return text }
+	}
+	return func(color, text string) string { return color + text + colorReset }
+}
+
+// isTerminal returns true if the given writer is a terminal, rather than a file or a pipe.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
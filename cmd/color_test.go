@@ -0,0 +1,58 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the color.go functions.
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPainter confirms that text is only colored for a terminal, and only if neither
+// --no-color nor NO_COLOR says otherwise.
+func TestPainter(t *testing.T) {
+
+	// Put everything back as it was before we leave the function
+	defer func() { isTerminalFunc = isTerminal }()
+	defer func() { noColor = false }()
+	defer os.Unsetenv(noColorEnvVar)
+
+	var buffer bytes.Buffer
+	require.False(t, isTerminal(&buffer), "a buffer is no terminal")
+	require.Equal(t, "valid", painterFor(&buffer)(colorGreen, "valid"), "there should have been no color for a buffer")
+
+	isTerminalFunc = func(w io.Writer) bool { return true }
+	require.Equal(t, colorGreen+"valid"+colorReset, painterFor(&buffer)(colorGreen, "valid"), "there should have been color for a terminal")
+
+	noColor = true
+	require.Equal(t, "valid", painterFor(&buffer)(colorGreen, "valid"), "--no-color should have turned color off")
+
+	noColor = false
+	os.Setenv(noColorEnvVar, "1")
+	require.Equal(t, "valid", painterFor(&buffer)(colorGreen, "valid"), "NO_COLOR should have turned color off")
+}
+
+// TestNoColorFlag confirms that the doctor's marks are colored on a terminal unless
+// --no-color is given.
+func TestNoColorFlag(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { isTerminalFunc = isTerminal }()
+
+	mockChildPackages()
+	isTerminalFunc = func(w io.Writer) bool { return true }
+	output := executeCommand("doctor")
+	require.Contains(t, output, "["+colorGreen+"PASS"+colorReset+"]", "the marks should have been colored")
+
+	output = executeCommand("doctor", "--no-color")
+	require.Contains(t, output, "[PASS]", "the marks should not have been colored")
+	require.NotContains(t, output, colorReset, "there should have been no escape sequences")
+}
@@ -0,0 +1,72 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the configure subcommand.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+)
+
+// configureCmd represents the configure subcommand
+var configureCmd = &cobra.Command{
+	Use:   "configure",
+	Short: "Store a virtual MFA device secret in the profile so that MFA codes can be generated",
+	Long: `Takes the otpauth://totp/ URL encoded in the QR code that AWS shows when a virtual
+MFA device is set up, given by --otpauth-url, and stores its secret as the
+` + mfile.MfaTOTPSecretKey + ` of the profile in the credentials file. From then on, mafia
+generates the profile's MFA codes itself and none need be given. The code that the
+secret generates now is displayed so that it can be checked against the device.
+AWS is not called. Quote the URL, which holds characters that the shell would
+otherwise take for its own.`,
+	Args: cobra.NoArgs,
+
+	// RunE stores the secret
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return configureProfile(cmd.OutOrStdout())
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the configure subcommand to the root command
+	rootCmd.AddCommand(configureCmd)
+}
+
+// configureProfile stores the secret of the --otpauth-url flag in the profile's section of
+// the credentials file, reporting what it did, and the current code, on the given writer.
+func configureProfile(w io.Writer) error {
+
+	// There is only the one thing that we know how to configure
+	if len(otpauthURL) == 0 {
+		return newConfigError(errors.New("configure requires --otpauth-url"))
+	}
+	secret, err := creds.ParseOTPAuthURL(otpauthURL)
+	if err != nil {
+		return newConfigError(err)
+	}
+
+	// Store it, and show what it makes of the time now
+	path := credentialsFilepath()
+	if err = mfile.SaveProfileTOTPSecretToFile(path, profile, secret); err != nil {
+		return newConfigError(err)
+	}
+	code, err := creds.GenerateTOTPCode(secret)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Virtual MFA device secret saved to file %s, as the %s of the [%s] section\n",
+		mfile.WritableCredentialsPath(path), mfile.MfaTOTPSecretKey, profile)
+	fmt.Fprintf(w, "The current code is %s, which should match the device\n", code)
+	return nil
+}
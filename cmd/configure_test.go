@@ -0,0 +1,58 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the configure.go functions.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConfigureOTPAuthURL confirms that the secret of an otpauth URL is stored in the
+// profile, and that MFA codes are then generated from it.
+func TestConfigureOTPAuthURL(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	creds.SetNowFunc(func() time.Time { return time.Unix(59, 0) })
+	output := executeCommand("configure", "--otpauth-url", "otpauth://totp/AWS:jane?secret="+fakeTOTPSecret+"&issuer=AWS")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "as the "+mfile.MfaTOTPSecretKey+" of the [default] section")
+	require.Contains(t, output, "The current code is 287082")
+	secret, err := mfile.GetProfileTOTPSecretFromFile(fakeCredentialsFilePath, mfile.DefaultSectionName)
+	require.Nil(t, err, "could not read the secret back")
+	require.Equal(t, fakeTOTPSecret, secret, "the secret should have been stored")
+
+	// Now no MFA code need be given
+	captured := mockSTSCapturingInput()
+	executeCommandCapturingStdout()
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "287082", *captured.TokenCode, "the generated code should have been sent")
+}
+
+// TestConfigureErrors confirms that configure insists on a usable otpauth URL.
+func TestConfigureErrors(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	for _, args := range [][]string{
+		{"configure"},
+		{"configure", "--otpauth-url", "https://example.com/?secret=" + fakeTOTPSecret},
+		{"configure", "--otpauth-url", "otpauth://totp/AWS:jane?secret=not-base32"},
+	} {
+		executeCommand(args...)
+		require.NotNil(t, executeError, "there should have been an error for %v", args)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error for %v", args)
+	}
+}
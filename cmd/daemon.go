@@ -0,0 +1,162 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the daemon subcommand, which keeps a profile's saved session fresh.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// How long before the saved session expires that the daemon refreshes it, by default
+	defaultRefreshBefore = 5 * time.Minute
+
+	// How long the daemon waits before trying again after a refresh fails, and the least
+	// that it waits between refreshes, so that a short session cannot have it hammer AWS
+	daemonRetryWait = time.Minute
+)
+
+var (
+	// The daemon subcommand's --token-source flag value, a command that prints an MFA code
+	tokenSource string
+
+	// The daemon subcommand's --refresh-before flag value
+	refreshBefore time.Duration
+
+	// Has the given channel told of the signals that stop the daemon; unit tests substitute
+	// their own function so as to stop it themselves
+	notifySignals = func(c chan<- os.Signal) {
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	}
+
+	// Returns a channel that delivers once the given time has passed; unit tests substitute
+	// their own function rather than wait
+	daemonAfter = time.After
+)
+
+// daemonCmd represents the daemon subcommand
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep the saved session for a profile fresh, refreshing it before each expiry",
+	Long: `Obtains and saves session credentials for the profile, as mafia --save would, and
+then again a few minutes before each session expires, for as long as it runs.
+The MFA code for each refresh is the output of the --token-source command, run
+through the shell, or, without one, is generated from the profile's
+mfa_totp_secret. Each refresh is reported as it happens. The daemon runs in the
+foreground until it is interrupted or sent SIGTERM; run it in the background
+with & or from a service manager. For example:
+
+   mafia daemon --profile work --token-source 'op item get aws-work --otp'`,
+	Args: cobra.NoArgs,
+
+	// RunE refreshes the session until told to stop
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateLogFormat(); err != nil {
+			return err
+		}
+		if err := validateStore(); err != nil {
+			return err
+		}
+		if err := validateEncrypt(); err != nil {
+			return err
+		}
+		if refreshBefore < 0 || refreshBefore >= duration {
+			return newConfigError(fmt.Errorf("--refresh-before must be at least zero and less than the %v --duration, not %v", duration, refreshBefore))
+		}
+		if len(tokenSource) == 0 && !noMFA && !hasTOTPSecret() {
+			return newConfigError(errors.New("the daemon subcommand needs --token-source, a profile with an mfa_totp_secret, or --no-mfa to obtain each session"))
+		}
+		return runDaemon(cmd.OutOrStdout())
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the daemon subcommand to the root command, with its flags
+	rootCmd.AddCommand(daemonCmd)
+	initDaemonFlags()
+}
+
+// initDaemonFlags defines the daemon subcommand's own flags.
+func initDaemonFlags() {
+	daemonCmd.Flags().StringVar(&tokenSource, "token-source", "", "a shell command that prints the MFA code to refresh the session with, in place of the profile's mfa_totp_secret")
+	daemonCmd.Flags().DurationVar(&refreshBefore, "refresh-before", defaultRefreshBefore, "how long before the saved session expires to refresh it")
+}
+
+// runDaemon refreshes the saved session of the --profile profile, reporting each refresh
+// to the given writer, and again before each expiry until a signal says to stop. A failed
+// refresh is tried again a minute later unless it failed for want of the right configuration,
+// which waiting will not fix. The --save flag value is put back afterwards.
+func runDaemon(w io.Writer) error {
+	defer func(s bool) { saveCredentials = s }(saveCredentials)
+	saveCredentials = true
+
+	// Listen for the signals that stop us
+	signals := make(chan os.Signal, 1)
+	notifySignals(signals)
+	defer signal.Stop(signals)
+
+	for {
+		wait := daemonRetryWait
+		expiration, err := refreshSession()
+		switch {
+		case err != nil && exitCodeFor(err) == exitConfigError:
+			return err
+		case err != nil:
+			fmt.Fprintf(w, "%s Could not refresh the session for the %s profile, trying again in %v: %v\n", formatExpiry(time.Now()), profile, wait, err)
+		default:
+			next := expiration.Add(-refreshBefore)
+			if until := time.Until(next); until > wait {
+				wait = until
+			} else {
+				next = time.Now().Add(wait)
+			}
+			fmt.Fprintf(w, "%s Refreshed the session for the %s profile, which expires at %s; the next refresh is at %s\n",
+				formatExpiry(time.Now()), profile, formatExpiry(*expiration), formatExpiry(next))
+		}
+
+		// Sleep until the next refresh is due, unless told to stop first
+		select {
+		case <-daemonAfter(wait):
+		case sig := <-signals:
+			fmt.Fprintf(w, "%s Stopping on %v\n", formatExpiry(time.Now()), sig)
+			return nil
+		}
+	}
+}
+
+// refreshSession obtains and saves a new session for the --profile profile, with an MFA
+// code from the --token-source command if there is one, returning when the session expires.
+func refreshSession() (*time.Time, error) {
+	var mfaToken string
+	if len(tokenSource) != 0 && !noMFA {
+		command := shellCommand(tokenSource)
+		command.Stderr = os.Stderr
+		output, err := command.Output()
+		if err != nil {
+			return nil, fmt.Errorf("the --token-source command failed: %w", err)
+		}
+		if mfaToken = strings.TrimSpace(string(output)); len(mfaToken) == 0 {
+			return nil, errors.New("the --token-source command printed no MFA code")
+		}
+	}
+	expiration, err := obtainAndSaveSession(mfaToken)
+	if err == nil && expiration == nil {
+		err = newConfigError(errors.New("the session has no expiration, so there is no knowing when to refresh it"))
+	}
+	return expiration, err
+}
@@ -0,0 +1,93 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the daemon.go functions.
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+// TestDaemon confirms that the daemon subcommand saves a session with the code printed by
+// the --token-source command, waits until shortly before it expires to refresh it, tries
+// again after a failure, and stops cleanly when signalled.
+func TestDaemon(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(n func(chan<- os.Signal), a func(time.Duration) <-chan time.Time) {
+		notifySignals, daemonAfter = n, a
+	}(notifySignals, daemonAfter)
+
+	// Sessions that last an hour, taking whatever code they are given
+	mockChildPackages()
+	var codes []string
+	failNext := false
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		codes = append(codes, *input.TokenCode)
+		if failNext {
+			failNext = false
+			return nil, errors.New("the network is down")
+		}
+		expires := time.Now().Add(time.Hour)
+		return &sts.GetSessionTokenOutput{Credentials: &sts.Credentials{
+			AccessKeyId: &accessKey, SecretAccessKey: &secret, SessionToken: &token, Expiration: &expires,
+		}}, nil
+	}
+
+	// Rather than sleep, note each wait and, on the third, signal the daemon to stop
+	var signals chan<- os.Signal
+	notifySignals = func(c chan<- os.Signal) { signals = c }
+	var waits []time.Duration
+	daemonAfter = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		switch len(waits) {
+		case 1:
+			failNext = true
+		case 3:
+			signals <- os.Interrupt
+			return nil
+		}
+		ready := make(chan time.Time, 1)
+		ready <- time.Now()
+		return ready
+	}
+
+	// Refresh, fail, refresh, and stop
+	output := executeCommand("daemon", "--token-source", "echo 123456", "--refresh-before", "10m")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, []string{"123456", "123456", "123456"}, codes, "the --token-source code should have been used each time")
+	require.Len(t, waits, 3, "expected a wait after each attempt")
+	require.InDelta(t, float64(50*time.Minute), float64(waits[0]), float64(time.Minute), "should refresh ten minutes before expiry")
+	require.Equal(t, daemonRetryWait, waits[1], "should try again a minute after a failure")
+	require.Contains(t, output, "Refreshed the session for the default profile, which expires at ")
+	require.Contains(t, output, "Could not refresh the session for the default profile, trying again in 1m0s: ")
+	require.Contains(t, output, "Stopping on interrupt\n")
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	require.Equal(t, token, cfg.Section(mfile.SessionSectionName).Key(mfile.SessionTokenKey).Value(), "the session was not saved")
+
+	// A failing token source is tried again too, but bad flags are configuration errors
+	waits, codes = nil, nil
+	executeCommand("daemon", "--token-source", "exit 1")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, codes, "AWS should not have been asked for a session without a code")
+	for _, args := range [][]string{
+		{"daemon"},
+		{"daemon", "--token-source", "echo 123456", "--refresh-before", "1h"},
+		{"daemon", "--token-source", "echo 123456", "--refresh-before", "-1m"},
+	} {
+		executeCommand(args...)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code with %v", args)
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the functions that apply per-profile flag defaults from the ~/.mafia.yaml file.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// The name of the file, in the user's home directory, that holds per-profile flag defaults
+	defaultsFileName = ".mafia.yaml"
+)
+
+var (
+	// The path of the defaults file consulted when the --defaults-file flag is not given;
+	// unit tests point this somewhere harmless so that a developer's own file is not read
+	defaultsFilepath = defaultDefaultsFilepath()
+
+	// Flags that cannot be given defaults, because they decide which defaults apply
+	undefaultableFlags = map[string]bool{"profile": true, "defaults-file": true}
+)
+
+// defaultDefaultsFilepath returns the path of the ~/.mafia.yaml file, or an empty string
+// if the home directory cannot be found.
+func defaultDefaultsFilepath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, defaultsFileName)
+}
+
+// applyProfileDefaults sets the flags of the given command that were not given on the
+// command line to the values that the defaults file holds for the selected profile. The
+// file maps profile names to flag names and values, e.g.
+//
+//	work:
+//	  duration: 8h
+//	  region: eu-west-1
+//	  save: true
+//
+// A missing ~/.mafia.yaml is no more than an absence of defaults, but a file named by the
+// --defaults-file flag must exist.
+func applyProfileDefaults(cmd *cobra.Command) error {
+
+	// Read the file, if there is one
+	path := defaultsFilepath
+	if len(defaultsFile) != 0 {
+		path = mfile.ExpandPath(defaultsFile)
+	}
+	if len(path) == 0 {
+		return nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && len(defaultsFile) == 0 {
+			return nil
+		}
+		return newConfigError(fmt.Errorf("could not read the defaults file %s: %v", path, err))
+	}
+	var profiles map[string]map[string]interface{}
+	if err = yaml.Unmarshal(content, &profiles); err != nil {
+		return newConfigError(fmt.Errorf("could not parse the defaults file %s: %v", path, err))
+	}
+
+	// Apply the profile's defaults, in a predictable order, to the flags that were not given
+	defaults := profiles[profile]
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || undefaultableFlags[name] {
+			return newConfigError(fmt.Errorf("the defaults file %s gives the %s profile a default for %s, which is not a flag that can have one", path, profile, name))
+		}
+		if flag.Changed {
+			continue
+		}
+		if err = cmd.Flags().Set(name, fmt.Sprint(defaults[name])); err != nil {
+			return newConfigError(fmt.Errorf("the defaults file %s gives the %s profile a bad default for %s: %v", path, profile, name, err))
+		}
+	}
+	return nil
+}
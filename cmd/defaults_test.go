@@ -0,0 +1,88 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the defaults.go functions.
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// The per-profile defaults file that we write for the tests
+	fakeDefaultsFilePath = "./mafia.test.yaml"
+)
+
+// writeFakeDefaults writes the given content to the fake defaults file.
+func writeFakeDefaults(t *testing.T, content string) {
+	require.Nil(t, ioutil.WriteFile(fakeDefaultsFilePath, []byte(content), 0600), "could not write the fake defaults file")
+}
+
+// TestProfileDefaults confirms that the selected profile's defaults are applied to the
+// flags that were not given, and that those that were keep their command line values.
+func TestProfileDefaults(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(fakeDefaultsFilePath)
+
+	mockChildPackages()
+	writeFakeDefaults(t, "default:\n  duration: 8h\n  region: eu-west-1\n  save: true\nwork:\n  duration: 2h\n")
+
+	// The default profile's defaults are applied
+	executeCommandCapturingStdout("123456", "--defaults-file", fakeDefaultsFilePath)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 8*time.Hour, duration, "the duration default was not applied")
+	require.Equal(t, "eu-west-1", region, "the region default was not applied")
+	require.True(t, saveCredentials, "the save default was not applied")
+	require.True(t, durationFlag.Changed, "a default should count as given")
+
+	// The command line wins
+	executeCommandCapturingStdout("123456", "--defaults-file", fakeDefaultsFilePath, "--duration", "1h")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, time.Hour, duration, "the flag should have overridden the default")
+	require.Equal(t, "eu-west-1", region, "the region default was not applied")
+
+	// Another profile has defaults of its own, checked here through a subcommand
+	executeCommand("remaining", "--defaults-file", fakeDefaultsFilePath, "--profile", "work")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 2*time.Hour, duration, "the work profile's default was not applied")
+	require.Empty(t, region, "the default profile's defaults should not have been applied")
+}
+
+// TestProfileDefaultsErrors confirms that a defaults file that cannot be read, cannot be
+// parsed, or names flags that do not exist is reported as a configuration error, while
+// the absence of ~/.mafia.yaml is not.
+func TestProfileDefaultsErrors(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(fakeDefaultsFilePath)
+
+	mockChildPackages()
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "a missing ~/.mafia.yaml should not have been an error: ", executeError)
+
+	executeCommandCapturingStdout("123456", "--defaults-file", "./missing.test")
+	require.NotNil(t, executeError, "a missing --defaults-file should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+
+	for _, content := range []string{
+		"default: [not, a, map]\n",
+		"default:\n  no-such-flag: true\n",
+		"default:\n  profile: work\n",
+		"default:\n  duration: forever\n",
+	} {
+		writeFakeDefaults(t, content)
+		executeCommandCapturingStdout("123456", "--defaults-file", fakeDefaultsFilePath)
+		require.NotNil(t, executeError, "%q should have been an error", content)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error for %q", content)
+	}
+}
@@ -0,0 +1,265 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the functions that display the session credentials obtained.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+)
+
+const (
+	// The permissions given to a file named by --output-file; it holds secrets so
+	// only the owner should be able to read it
+	outputFileMode os.FileMode = 0600
+
+	// How the expiry time of the session credentials is shown to a person
+	expiryLayout = "2006-01-02 15:04:05 MST"
+
+	// How many characters --redact leaves showing at each end of a secret, and what
+	// replaces the rest
+	redactShown = 4
+	redactMask  = "****"
+)
+
+var (
+	// Where messages meant for a person go when stdout is kept for the shell or the SDK;
+	// unit tests substitute their own writer for os.Stderr
+	stderrOutput io.Writer = os.Stderr
+)
+
+// formatExpiry formats an expiration time for a person: on the local clock, as people
+// reason about time, unless --utc asks for UTC as STS returns it, with the timezone
+// abbreviation either way.
+func formatExpiry(t time.Time) string {
+	if utc {
+		return t.UTC().Format(expiryLayout)
+	}
+	return t.Local().Format(expiryLayout)
+}
+
+// humanOutput returns where messages meant for a person, rather than for the shell or the
+// SDK, are to be written: stderr if --export, --credential-process, or --human-to-stderr
+// keep stdout for the credentials alone, and stdout otherwise.
+func humanOutput() io.Writer {
+	if export || credentialProcess || humanToStderr {
+		return stderrOutput
+	}
+	return os.Stdout
+}
+
+// credentialsOutput returns where the credentials are to be written when there is no
+// --output-file: stdout, unless --human-to-stderr was given and the standard display,
+// which is for a person to read, is all that there is to write.
+func credentialsOutput() io.Writer {
+	if humanToStderr && !export && !credentialProcess && len(formatTemplate) == 0 {
+		return stderrOutput
+	}
+	return os.Stdout
+}
+
+// outputSessionCredentials displays the session credentials on stdout or, if the
+// --output-file, --output-fd, or --output-fifo flag was given, writes the very same
+// display there. With
+// --human-to-stderr, when the credentials expire is also written to stderr. With
+// --redact, the secret access key and session token are only partly shown.
+func outputSessionCredentials(credentials *creds.SessionCredentials) error {
+	if redact {
+		credentials = redactedCredentials(credentials)
+	}
+	err := writeCredentialsOutput(credentials)
+	if err == nil && humanToStderr {
+		writeExpiryNote(stderrOutput, credentials)
+	}
+	return err
+}
+
+// writeCredentialsOutput writes the session credentials to stdout, or to the file, file
+// descriptor, or named pipe given by the --output-file, --output-fd, or --output-fifo flag.
+func writeCredentialsOutput(credentials *creds.SessionCredentials) error {
+
+	// The simple case, straight to stdout - or stderr
+	if !outputRedirected() {
+		return writeSessionCredentials(credentialsOutput(), credentials)
+	}
+
+	// Open wherever the display is to go, closing it when we are done so that a reader
+	// at the far end of a descriptor or pipe sees the end of it
+	file, name, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Write the display and let the user know where it went
+	if err = writeSessionCredentials(file, credentials); err != nil {
+		return err
+	}
+	fmt.Fprintf(humanOutput(), "Session credentials written to %s\n", name)
+	return nil
+}
+
+// writeExpiryNote tells the user, on the given writer, when the session credentials expire.
+func writeExpiryNote(w io.Writer, credentials *creds.SessionCredentials) {
+	if credentials.Expiration == nil {
+		fmt.Fprintln(w, "Session credentials expiration is not known")
+		return
+	}
+	paint := painterFor(w)
+	if credentials.Expired() {
+		fmt.Fprintln(w, paint(colorRed, "Session credentials expired at "+formatExpiry(*credentials.Expiration)))
+		return
+	}
+	fmt.Fprintln(w, paint(colorGreen, fmt.Sprintf("Session credentials expire at %s, in %v",
+		formatExpiry(*credentials.Expiration), credentials.Remaining().Round(time.Second))))
+}
+
+// writeSessionCredentials writes the session credentials to the given writer as export
+// statements if --export was given, as credential_process JSON if --credential-process
+// was given, rendered through the --format template if one was given, or in the standard
+// display otherwise.
+func writeSessionCredentials(w io.Writer, credentials *creds.SessionCredentials) error {
+
+	// The SDK wants nothing but JSON from a credential_process
+	if credentialProcess {
+		return writeProcessCredentials(w, credentials)
+	}
+
+	// Export statements are all that the shell wants to see
+	if export {
+		writeExportStatements(w, credentials)
+		return nil
+	}
+
+	// Without a template, there is nothing to go wrong
+	tmpl, err := parseFormatTemplate()
+	if err != nil || tmpl == nil {
+		displaySessionCredentials(w, credentials)
+		return err
+	}
+
+	// Render the template, completing the line for it
+	if err = tmpl.Execute(w, newFormatValues(credentials)); err != nil {
+		return newConfigError(fmt.Errorf("could not render the --format template: %v", err))
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// formatValues are what a --format template is rendered with: the session credentials,
+// with the secret values in plain text rather than masked as a creds.Secret displays them.
+type formatValues struct {
+	AccessKeyID     *string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      *time.Time
+}
+
+// newFormatValues returns the values that a --format template renders the given session
+// credentials with.
+func newFormatValues(credentials *creds.SessionCredentials) *formatValues {
+	return &formatValues{
+		AccessKeyID:     credentials.AccessKeyID,
+		SecretAccessKey: credentials.SecretAccessKey.Value(),
+		SessionToken:    credentials.SessionToken.Value(),
+		Expiration:      credentials.Expiration,
+	}
+}
+
+// parseFormatTemplate parses the template given by the --format flag, returning nil if
+// no template was given and a configuration error if it was not valid.
+func parseFormatTemplate() (*template.Template, error) {
+	if len(formatTemplate) == 0 {
+		return nil, nil
+	}
+	tmpl, err := template.New("format").Parse(formatTemplate)
+	if err != nil {
+		return nil, newConfigError(fmt.Errorf("invalid --format template: %v", err))
+	}
+	return tmpl, nil
+}
+
+// displaySessionCredentials shows the, you guessed it, session credentials on the given
+// writer. The display is given twice, once formated for use as environment variables,
+// named with any --prefix, and once ready to copy-nd-paste into the  ~/.aws/credentials
+// file, under the section and key names that --save would write them with, followed by
+// the matching ~/.aws/config stanza if --config-snippet asks for it.
+func displaySessionCredentials(w io.Writer, credentials *creds.SessionCredentials) {
+
+	// Display the results in a form that can be copy-and-pasted to set as environment variables
+	fmt.Fprintf(w, "\nEnvironment Variables\n\n")
+	fmt.Fprintf(w, "export %s%s=%s\n", envPrefix, accessKeyIDEnvVar, *credentials.AccessKeyID)
+	fmt.Fprintf(w, "export %s%s=%s\n", envPrefix, secretAccessKeyEnvVar, credentials.SecretAccessKey.Value())
+	fmt.Fprintf(w, "export %s%s=%s\n", envPrefix, sessionTokenEnvVar, credentials.SessionToken.Value())
+	if !scrubHistory {
+		fmt.Fprintln(w, "history -c # clear shell history immediately after setting secrets")
+	}
+
+	// Display the results in a form that can be copy-and-pasted to set as environment variables
+	fmt.Fprintf(w, "\nTo paste into ~/.aws/credentials\n\n")
+	options := saveOptions()
+	keyNames := options.ResolvedKeyNames()
+	fmt.Fprintf(w, "[%s]\n", options.SectionName())
+	fmt.Fprintf(w, "%s = %s\n", keyNames.AccessKeyID, *credentials.AccessKeyID)
+	fmt.Fprintf(w, "%s = %s\n", keyNames.SecretAccessKey, credentials.SecretAccessKey.Value())
+	fmt.Fprintf(w, "%s = %s\n", keyNames.SessionToken, credentials.SessionToken.Value())
+	if credentials.Expiration != nil {
+		fmt.Fprintf(w, "%s = %s\n", keyNames.Expiration, credentials.Expiration.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintln(w)
+
+	// And, if asked, the config file stanza that makes a complete profile of that section
+	if configSnippet {
+		displayConfigSnippet(w)
+	}
+}
+
+// displayConfigSnippet shows, on the given writer, the ~/.aws/config stanza that goes with
+// the credentials file section of the standard display, naming the same profile and giving
+// the session profile the region in use, if there is one, so that the two make a fully
+// working profile.
+func displayConfigSnippet(w io.Writer) {
+	sourceProfile, _, err := resolveProfiles()
+	if err != nil {
+		sourceProfile = profile
+	}
+	fmt.Fprintf(w, "To paste into ~/.aws/config\n\n")
+	fmt.Fprintf(w, "[%s]\n", mfile.ConfigSectionName(saveOptions().SectionName()))
+	if r := resolveRegion(sourceProfile); len(r) != 0 {
+		fmt.Fprintf(w, "region = %s\n", r)
+	} else {
+		fmt.Fprintf(w, "# no region is configured for the %s profile; add region = ... here\n", profile)
+	}
+	fmt.Fprintln(w)
+}
+
+// redactedCredentials returns a copy of the given session credentials for --redact to
+// display, the secret access key and session token masked by redactValue(..) and the
+// access key ID and expiration left as they are.
+func redactedCredentials(credentials *creds.SessionCredentials) *creds.SessionCredentials {
+	return &creds.SessionCredentials{
+		AccessKeyID:     credentials.AccessKeyID,
+		SecretAccessKey: creds.NewSecret(redactValue(credentials.SecretAccessKey.Value())),
+		SessionToken:    creds.NewSecret(redactValue(credentials.SessionToken.Value())),
+		Expiration:      credentials.Expiration,
+	}
+}
+
+// redactValue returns the given secret with all but its first and last few characters
+// masked, or masked entirely if it is too short for that to hide most of it.
+func redactValue(value string) string {
+	if len(value) <= 3*redactShown {
+		return redactMask
+	}
+	return value[:redactShown] + redactMask + value[len(value)-redactShown:]
+}
@@ -0,0 +1,292 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the display.go functions.
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// Where the tests ask for the credentials display to be written
+	testOutputFilePath = "./output.test"
+)
+
+// TestOutputFile confirms that the --output-file flag sends the credentials display to
+// a file that only its owner can read, rather than stdout.
+func TestOutputFile(t *testing.T) {
+
+	// Wash the faces of our muddy children and tidy up before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(testOutputFilePath)
+
+	// Start with a world readable file to prove that the permissions get tightened
+	err := ioutil.WriteFile(testOutputFilePath, []byte("old content"), 0644)
+	require.Nil(t, err, "could not create the test output file")
+
+	// Run with happy mocks
+	mockChildPackages()
+	_, stdout := executeCommandCapturingStdout("123456", "--output-file", testOutputFilePath)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// Stdout should only say where the credentials went
+	require.NotContains(t, stdout, "export AWS_ACCESS_KEY_ID", "the credentials should not have been on stdout")
+	require.Contains(t, stdout, "Session credentials written to "+testOutputFilePath)
+
+	// The file should have the display and be for the owner's eyes only
+	content, err := ioutil.ReadFile(testOutputFilePath)
+	require.Nil(t, err, "could not read the test output file")
+	require.Contains(t, string(content), "export AWS_ACCESS_KEY_ID=key")
+	require.Contains(t, string(content), "aws_session_token = token")
+	require.NotContains(t, string(content), "old content", "the file should have been truncated")
+	info, err := os.Stat(testOutputFilePath)
+	require.Nil(t, err, "could not stat the test output file")
+	require.Equal(t, outputFileMode, info.Mode().Perm(), "unexpected file permissions")
+}
+
+// TestOutputFileWithSave confirms that the --output-file flag is independent of --save.
+func TestOutputFileWithSave(t *testing.T) {
+
+	// Wash the faces of our muddy children and tidy up before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(testOutputFilePath)
+
+	// Run with happy mocks
+	mockChildPackages()
+	_, stdout := executeCommandCapturingStdout("123456", "--save", "--output-file", testOutputFilePath)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "Session credentials saved to file")
+	require.Contains(t, stdout, "Session credentials written to "+testOutputFilePath)
+}
+
+// TestOutputFileUnwritable confirms that an output file that cannot be written is reported.
+func TestOutputFileUnwritable(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Run with happy mocks but an impossible output file
+	mockChildPackages()
+	executeCommandCapturingStdout("123456", "--output-file", "/you/got/no/skin/on/me-cos-i-do-not-exist")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
+
+// TestFormatTemplate confirms that the --format flag renders the credentials through
+// the given template in place of the standard display.
+func TestFormatTemplate(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Run with happy mocks and a template
+	mockChildPackages()
+	_, stdout := executeCommandCapturingStdout("123456", "--format", "{{.AccessKeyID}} {{.SecretAccessKey}} {{.SessionToken}}")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "key secret token\n", stdout, "unexpected formatted output")
+}
+
+// TestBadFormatTemplate confirms that a template that cannot be parsed or rendered is a
+// configuration error.
+func TestBadFormatTemplate(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// A template that does not parse should be rejected before AWS is asked for anything
+	mockChildPackages()
+	calls := countSTSCalls()
+	executeCommandCapturingStdout("123456", "--format", "{{.AccessKeyID")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+
+	// As should one that refers to a field that does not exist
+	executeCommandCapturingStdout("123456", "--format", "{{.NoSuchField}}")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
+
+// TestHumanToStderr confirms that, with --human-to-stderr, stdout carries nothing but the
+// export statements while the save signal and when the session expires go to stderr, and
+// that the standard display, being for a person, goes to stderr too.
+func TestHumanToStderr(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { stderrOutput = os.Stderr }()
+
+	var human bytes.Buffer
+	stderrOutput = &human
+	mockChildPackages()
+	_, stdout := executeCommandCapturingStdout("123456", "--export", "--save", "--human-to-stderr")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "export AWS_ACCESS_KEY_ID='key'\nexport AWS_SECRET_ACCESS_KEY='secret'\nexport AWS_SESSION_TOKEN='token'\n", stdout, "stdout should only have had the exports")
+	require.Contains(t, human.String(), "Session credentials saved to file")
+	require.Contains(t, human.String(), "Session credentials expire")
+
+	// The standard display has no machine readable form to leave on stdout
+	human.Reset()
+	_, stdout = executeCommandCapturingStdout("123456", "--human-to-stderr")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, stdout, "nothing should have been written to stdout")
+	require.Contains(t, human.String(), "aws_session_token = token")
+	require.Contains(t, human.String(), "Session credentials expire")
+}
+
+// TestExpiryLocalTime confirms that when the session credentials expire is shown on the
+// local clock, with its timezone abbreviation, unless --utc asks for UTC.
+func TestExpiryLocalTime(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { stderrOutput = os.Stderr }()
+	defer func(e time.Time) { expiration = e }(expiration)
+	defer func(l *time.Location) { time.Local = l }(time.Local)
+
+	// Run somewhere five hours behind UTC, with a session that STS says expires in UTC
+	var human bytes.Buffer
+	stderrOutput = &human
+	time.Local = time.FixedZone("EST", -5*60*60)
+	mockChildPackages()
+	expiration = time.Date(2030, time.April, 1, 12, 0, 0, 0, time.UTC)
+	executeCommandCapturingStdout("123456", "--export", "--human-to-stderr")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, human.String(), "Session credentials expire at 2030-04-01 07:00:00 EST, in ")
+
+	// Unless we ask for UTC
+	human.Reset()
+	executeCommandCapturingStdout("123456", "--export", "--human-to-stderr", "--utc")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, human.String(), "Session credentials expire at 2030-04-01 12:00:00 UTC, in ")
+}
+
+// TestRedact confirms that --redact shows only the ends of the secret access key and
+// session token, while --save still saves them in full.
+func TestRedact(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Have AWS hand over credentials long enough to be partly shown
+	mockChildPackages()
+	longSecret, longToken := "wJalrXUtnFEMI/K7MDENG/bPxRfiCY", "FwoGZXIvYXdzEBYaDHexampleToken"
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		return &sts.GetSessionTokenOutput{Credentials: &sts.Credentials{
+			AccessKeyId: &accessKey, SecretAccessKey: &longSecret, SessionToken: &longToken, Expiration: &expiration,
+		}}, nil
+	}
+
+	// The standard display is all there, but with most of the secrets masked
+	_, output := executeCommandCapturingStdout("123456", "--redact")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "export "+secretAccessKeyEnvVar+"=wJal****fiCY\n", "the secret should have been redacted")
+	require.Contains(t, output, "aws_session_token = FwoG****oken\n", "the token should have been redacted")
+	require.Contains(t, output, "aws_access_key_id = "+accessKey+"\n", "the access key ID should have been left alone")
+	require.NotContains(t, output, longSecret, "the secret should not have been shown")
+	require.NotContains(t, output, longToken, "the token should not have been shown")
+
+	// So are the export statements, while the file gets the real values
+	_, output = executeCommandCapturingStdout("123456", "--redact", "--export", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "wJal****fiCY", "the exported secret should have been redacted")
+	require.NotContains(t, output, longSecret, "the secret should not have been exported")
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	require.Equal(t, longToken, cfg.Section(mfile.SessionSectionName).Key(mfile.SessionTokenKey).Value(), "the real token should have been saved")
+
+	// Short values give nothing away at all, and the SDK cannot use redacted credentials
+	require.Equal(t, redactMask, redactValue("secret"), "a short value should have been masked entirely")
+	executeCommandCapturingStdout("123456", "--redact", "--credential-process")
+	require.Equal(t, exitConfigError, exitCode, "--redact should not be allowed with --credential-process")
+}
+
+// TestConfigSnippet confirms that --config-snippet adds the config file stanza, with the
+// region in use, to the standard display, and only to that.
+func TestConfigSnippet(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	mockChildPackages()
+
+	// Nothing extra without the flag
+	_, output := executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.NotContains(t, output, "~/.aws/config", "there should have been no config snippet")
+
+	// With it, the stanza follows the credentials file section
+	_, output = executeCommandCapturingStdout("123456", "--config-snippet", "--region", "eu-west-1")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "aws_session_expiration = "+expiration.UTC().Format(time.RFC3339)+"\n\nTo paste into ~/.aws/config\n\n[profile default-session]\nregion = eu-west-1\n")
+
+	// Without a region, the user is told to choose one
+	_, output = executeCommandCapturingStdout("123456", "--config-snippet")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "[profile default-session]\n# no region is configured for the default profile; add region = ... here\n")
+
+	// And there is no place for it in the other displays
+	executeCommandCapturingStdout("123456", "--config-snippet", "--export")
+	require.Equal(t, exitConfigError, exitCode, "--config-snippet should not be allowed with --export")
+}
+
+// TestPasteSection confirms that the credentials and config file stanzas of the standard
+// display are headed with the section that --save would write to, not always the default's,
+// and that the credentials are given the key names that it would write them under.
+func TestPasteSection(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Give the fake credentials file a second profile with an MFA device of its own
+	mockChildPackages()
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	staging := cfg.Section("staging")
+	staging.NewKey(mfile.AccessKeyIDKey, fakeAccessKeyID)
+	staging.NewKey(mfile.SecretAccessKeyKey, fakeSecretAccessKey)
+	staging.NewKey(mfile.MfaDeviceIDKey, fakeMFADeviceID)
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "error writing the test credentials file")
+
+	// Each profile's session goes in a section of its own
+	for _, test := range []struct {
+		args        []string
+		credentials string
+		config      string
+	}{
+		{[]string{"123456"}, "[default-session]", "[profile default-session]"},
+		{[]string{"123456", "--profile", "staging"}, "[staging-session]", "[profile staging-session]"},
+	} {
+		_, output := executeCommandCapturingStdout(append(test.args, "--config-snippet")...)
+		require.Nil(t, executeError, "there should not have been an error with %v: %v", test.args, executeError)
+		require.Contains(t, output, "To paste into ~/.aws/credentials\n\n"+test.credentials+"\n", "wrong credentials section with %v", test.args)
+		require.Contains(t, output, "To paste into ~/.aws/config\n\n"+test.config+"\n", "wrong config section with %v", test.args)
+	}
+
+	// Under the key names that --save would use
+	_, output := executeCommandCapturingStdout("123456", "--session-token-name", "aws_security_token", "--expiration-name", "x_security_token_expires")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "[default-session]\naws_access_key_id = "+accessKey+"\naws_secret_access_key = "+secret+
+		"\naws_security_token = "+token+"\nx_security_token_expires = "+expiration.UTC().Format(time.RFC3339)+"\n", "wrong key names")
+
+	// Or, with --in-place, in the profile's own, which the default profile's config file
+	// stanza names without the "profile " prefix
+	defer os.Remove(testOutputFilePath)
+	executeCommandCapturingStdout("123456", "--save", "--in-place", "--no-backup", "--config-snippet", "--output-file", testOutputFilePath)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	content, err := ioutil.ReadFile(testOutputFilePath)
+	require.Nil(t, err, "could not read the output file")
+	require.Contains(t, string(content), "To paste into ~/.aws/credentials\n\n[default]\n", "wrong credentials section with --in-place")
+	require.Contains(t, string(content), "To paste into ~/.aws/config\n\n[default]\n", "wrong config section with --in-place")
+}
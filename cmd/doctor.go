@@ -0,0 +1,115 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the doctor subcommand.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd represents the doctor subcommand
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the credentials file for problems, without authenticating",
+	Long: `Inspects the credentials file and the selected profile, displaying a checklist
+of what is in order and what is not: whether the file exists and only its owner
+can read it, whether the long term credentials are present, whether the MFA
+device ID is present and looks like an MFA device ARN, and whether any saved
+session has expired. AWS is not called.`,
+	Args: cobra.NoArgs,
+
+	// RunE works through the checklist
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if failures := runDoctorChecks(cmd.OutOrStdout()); failures != 0 {
+			return newConfigError(fmt.Errorf("%d of the doctor's checks failed", failures))
+		}
+		return nil
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the doctor subcommand to the root command
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// runDoctorChecks writes the doctor's checklist to the given writer, returning the
+// number of checks that failed.
+func runDoctorChecks(w io.Writer) int {
+
+	// Count the failures as we report each check
+	failures, paint := 0, painterFor(w)
+	check := func(pass bool, format string, a ...interface{}) bool {
+		mark := paint(colorGreen, "PASS")
+		if !pass {
+			mark = paint(colorRed, "FAIL")
+			failures++
+		}
+		fmt.Fprintf(w, "[%s] %s\n", mark, fmt.Sprintf(format, a...))
+		return pass
+	}
+
+	// Work out which file and profile we are talking about
+	path := credentialsFilepath()
+	sourceProfile, roleProfile, err := resolveProfiles()
+	if !check(err == nil, "the AWS config file can be read") {
+		return failures
+	}
+	if roleProfile != nil {
+		fmt.Fprintf(w, "[INFO] the %s profile assumes %s using the %s profile\n", profile, roleProfile.RoleARN, sourceProfile)
+	}
+
+	// Do the files exist and are they private?
+	for _, p := range mfile.SplitCredentialsPaths(path) {
+		info, err := os.Stat(p)
+		if !check(err == nil, "the credentials file %s exists", p) {
+			return failures
+		}
+		if runtime.GOOS != "windows" {
+			check(info.Mode().Perm()&0077 == 0, "only its owner can read the credentials file %s (mode %04o, expected 0600)", p, info.Mode().Perm())
+		}
+	}
+
+	// Does the profile have what we need?
+	keys, err := mfile.GetProfileKeysFromFile(path, sourceProfile)
+	if !check(err == nil, "the credentials file has a [%s] section", sourceProfile) {
+		return failures
+	}
+	check(len(keys[mfile.AccessKeyIDKey]) != 0, "[%s] has an %s", sourceProfile, mfile.AccessKeyIDKey)
+	check(len(keys[mfile.SecretAccessKeyKey]) != 0, "[%s] has an %s", sourceProfile, mfile.SecretAccessKeyKey)
+	mfaDeviceID := mfile.MFADeviceIDFromKeys(keys)
+	if roleProfile != nil && len(roleProfile.MFASerial) != 0 {
+		mfaDeviceID = roleProfile.MFASerial
+	}
+	if check(len(mfaDeviceID) != 0, "an %s is configured", mfile.MfaDeviceIDKey) {
+		check(mfile.IsMFASerialARN(mfaDeviceID), "the %s %s looks like an MFA device ARN", mfile.MfaDeviceIDKey, mfaDeviceID)
+		check(!errors.Is(mfile.ValidateMFASerial(mfaDeviceID), mfile.ErrExampleMFASerial), "the %s is not the documentation's example", mfile.MfaDeviceIDKey)
+	}
+
+	// Is there a saved session and, if so, is it still good?
+	options := saveOptions()
+	saved, err := mfile.GetSavedSessionFromFile(path, options)
+	switch {
+	case err != nil || saved == nil:
+		fmt.Fprintf(w, "[INFO] there is no saved session in [%s]\n", options.SectionName())
+	case saved.Expiration == nil:
+		fmt.Fprintf(w, "[INFO] the saved session in [%s] does not record when it expires\n", options.SectionName())
+	default:
+		session := &creds.SessionCredentials{Expiration: saved.Expiration}
+		check(!session.Expired(), "the saved session in [%s] has not expired (expiration %s)", options.SectionName(), formatExpiry(*saved.Expiration))
+	}
+	return failures
+}
@@ -0,0 +1,71 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the doctor.go functions.
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDoctorHealthy confirms that a healthy credentials file passes all of the checks.
+func TestDoctorHealthy(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Save a session that is good for an hour to a private fake file
+	mockChildPackages()
+	expiration = time.Now().Add(time.Hour)
+	executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, os.Chmod(fakeCredentialsFilePath, 0600), "could not make the fake credentials file private")
+
+	// Ask the doctor
+	output := executeCommand("doctor")
+	require.Nil(t, executeError, "there should not have been an error: %v\n%s", executeError, output)
+	require.NotContains(t, output, "[FAIL]")
+	require.Contains(t, output, "[PASS] the saved session in [default-session] has not expired")
+}
+
+// TestDoctorUnhealthy confirms that problems are reported as failures.
+func TestDoctorUnhealthy(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Save an expired session to a world readable fake file with the example MFA serial
+	mockChildPackages()
+	expiration = time.Now().Add(-time.Hour)
+	writeFakeCredentials("arn:aws:iam::999999999999:mfa/jane")
+	executeCommandCapturingStdout("123456", "--save", "--mfa-serial", fakeMFADeviceID)
+	require.Nil(t, os.Chmod(fakeCredentialsFilePath, 0644), "could not make the fake credentials file readable")
+
+	// Ask the doctor
+	output := executeCommand("doctor")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+	require.Contains(t, output, "[FAIL] only its owner can read the credentials file")
+	require.Contains(t, output, "[FAIL] the mfa_device_id is not the documentation's example")
+	require.Contains(t, output, "[FAIL] the saved session in [default-session] has not expired")
+	require.Contains(t, output, "[PASS] [default] has an aws_access_key_id")
+}
+
+// TestDoctorNoFile confirms that a missing credentials file is reported.
+func TestDoctorNoFile(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	output := executeCommand("doctor", "--credentials-file", "/you/got/no/skin/on/me-cos-i-do-not-exist")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Contains(t, output, "[FAIL] the credentials file /you/got/no/skin/on/me-cos-i-do-not-exist exists")
+}
@@ -0,0 +1,164 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the handling of the requested session duration.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+)
+
+const (
+	// The session duration used when the --duration flag is not given
+	defaultDuration = time.Hour
+
+	// The range of session durations that AWS STS will accept
+	minDuration = 15 * time.Minute
+	maxDuration = 36 * time.Hour
+
+	// AWS limits role sessions that are assumed with the credentials of another role, e.g.
+	// SSO role credentials, to an hour
+	maxChainedDuration = time.Hour
+
+	// The least, and the greatest, maximum session duration that a role can be given
+	minRoleMaxDuration = time.Hour
+	maxRoleMaxDuration = 12 * time.Hour
+
+	// How much shorter than requested a session may be before we think it worth a warning,
+	// allowing for the time taken by the request and any clock skew
+	durationSlack = time.Minute
+)
+
+var (
+	// Where --verbose warnings are written; unit tests substitute their own writer for os.Stderr
+	warningOutput io.Writer = os.Stderr
+)
+
+// validateDuration returns a configuration error if the --duration flag value is outside
+// of the range that AWS accepts. Asking for more than a plain session can last gets a hint
+// that the session of a role assumed with --role-arn lasts as long as the role allows.
+// With --no-duration-check, any positive duration is left for the endpoint to judge.
+func validateDuration() error {
+	if noDurationCheck {
+		if duration <= 0 {
+			return newConfigError(fmt.Errorf("--duration must be positive, not %v", duration))
+		}
+		return nil
+	}
+	if duration > maxDuration {
+		return newConfigError(fmt.Errorf("--duration must be between %v and %v, not %v; for a session as long as a role's MaxSessionDuration, of up to %v, assume the role with --role-arn",
+			minDuration, maxDuration, duration, maxRoleMaxDuration))
+	}
+	if duration < minDuration {
+		return newConfigError(fmt.Errorf("--duration must be between %v and %v, not %v", minDuration, maxDuration, duration))
+	}
+	return nil
+}
+
+// validateRequireValidUntil returns a configuration error if the --require-valid-until flag
+// value is negative or longer than the session that is to be asked for, when it could never
+// be met. If the profiles cannot be resolved, that is left to be reported in its turn.
+func validateRequireValidUntil() error {
+	if requireValidUntil < 0 {
+		return newConfigError(fmt.Errorf("--require-valid-until cannot be negative: %v", requireValidUntil))
+	}
+	if requireValidUntil == 0 {
+		return nil
+	}
+	_, roleProfile, err := resolveProfiles()
+	if err != nil {
+		return nil
+	}
+	requested, err := requestedDuration(roleProfile)
+	if err != nil {
+		return err
+	}
+	if requireValidUntil > requested {
+		return newConfigError(fmt.Errorf("--require-valid-until %v is longer than the %v session that is to be asked for", requireValidUntil, requested))
+	}
+	return nil
+}
+
+// requestedDuration returns the session duration to ask AWS for, before any cut to a
+// role's maximum session duration: the --duration flag value or, as the AWS CLI has it, the
+// duration_seconds of the given role profile, if any, unless --duration was given. A role
+// assumed with SSO role credentials chains one role to another, which AWS limits to an hour.
+func requestedDuration(roleProfile *mfile.RoleProfile) (time.Duration, error) {
+	requested := duration
+	if roleProfile != nil && roleProfile.DurationSeconds != 0 && !durationFlag.Changed {
+		requested = time.Duration(roleProfile.DurationSeconds) * time.Second
+		if requested < minDuration && !noDurationCheck {
+			return 0, newConfigError(fmt.Errorf("the %s profile's duration_seconds must be at least %d", profile, durationSeconds(minDuration)))
+		}
+	}
+	if sso && (len(roleARN) != 0 || roleProfile != nil) && requested > maxChainedDuration && !noDurationCheck {
+		requested = maxChainedDuration
+	}
+	return requested, nil
+}
+
+// checkValidUntil returns an error if the --require-valid-until flag was given and the
+// session credentials expire sooner than it demands, e.g. because an IAM policy capped the
+// session duration below that asked for, so that a long job fails before it starts rather
+// than part way through.
+func checkValidUntil(credentials *creds.SessionCredentials) error {
+	if requireValidUntil == 0 {
+		return nil
+	}
+	if credentials.Expiration == nil {
+		return fmt.Errorf("--require-valid-until %v cannot be checked: the session credentials have no expiration", requireValidUntil)
+	}
+	if remaining := credentials.Remaining(); remaining < requireValidUntil {
+		return fmt.Errorf("the session credentials expire at %s, in %v, sooner than the %v that --require-valid-until demands",
+			formatExpiry(*credentials.Expiration), remaining.Round(time.Second), requireValidUntil)
+	}
+	return nil
+}
+
+// roleDuration returns the session duration to ask for when assuming the given role with
+// the long term credentials or an MFA session: the wanted duration or, if the role's maximum
+// session duration is shorter, that maximum, with a warning, since AWS would refuse the
+// request outright. Every role allows at least an hour, so the role is only asked about if
+// more was wanted, and if its maximum cannot be found the wanted duration stands, as it
+// does with --no-duration-check.
+func roleDuration(roleARN string, wanted time.Duration) time.Duration {
+	if wanted <= minRoleMaxDuration || noDurationCheck {
+		return wanted
+	}
+	max, err := creds.RoleMaxSessionDuration(roleARN)
+	if err != nil || max >= wanted {
+		return wanted
+	}
+	fmt.Fprintf(warningOutput, "warning: the %s role allows sessions of no more than %v, so asking for that rather than %v\n", roleARN, max, wanted)
+	return max
+}
+
+// durationSeconds converts a duration into the whole number of seconds that AWS STS expects.
+func durationSeconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+// warnIfShortened writes a warning if the session credentials, requested at the given start
+// time, expire noticeably sooner than the --duration flag asked for. AWS STS quietly grants
+// less than was asked for when the IAM user's or role's policies cap the session duration,
+// so this explains why a "36h" session might only last an hour. Credentials without a
+// known expiration, e.g. reused ones, are not examined.
+func warnIfShortened(started time.Time, credentials *creds.SessionCredentials) {
+	if credentials.Expiration == nil {
+		return
+	}
+	granted := credentials.Expiration.Sub(started)
+	if granted < duration-durationSlack {
+		fmt.Fprintf(warningOutput, "warning: asked for a %v session but AWS granted %v; the session duration must be capped by an IAM policy, the role's maximum session duration, or role chaining\n",
+			duration, granted.Round(time.Second))
+	}
+}
@@ -0,0 +1,221 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the duration.go functions.
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDuration confirms that the --duration flag is passed on to AWS in seconds and that
+// durations that AWS would refuse are rejected before the MFA code is spent.
+func TestDuration(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, capturing the session request
+	mockChildPackages()
+	input := mockSTSCapturingInput()
+
+	// The default should be an hour
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, int64(3600), *input.DurationSeconds, "the default duration should be an hour")
+
+	// And the flag should be respected
+	executeCommandCapturingStdout("123456", "--duration", "36h")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, int64(129600), *input.DurationSeconds, "the duration flag was not passed on")
+
+	// Too short or too long should be refused
+	executeCommand("123456", "--duration", "10m")
+	require.NotNil(t, executeError, "a ten minute duration should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+	executeCommand("123456", "--duration", "37h")
+	require.NotNil(t, executeError, "a 37 hour duration should have been refused")
+	require.Equal(t, "--duration must be between 15m0s and 36h0m0s, not 37h0m0s; for a session as long as a role's MaxSessionDuration, of up to 12h0m0s, assume the role with --role-arn",
+		executeError.Error(), "not the expected error")
+	executeCommand("123456", "--duration", "10m")
+	require.Equal(t, "--duration must be between 15m0s and 36h0m0s, not 10m0s", executeError.Error(), "too short needs no advice")
+}
+
+// TestNoDurationCheck confirms that --no-duration-check passes on durations that AWS would
+// refuse for the endpoint to judge, turning off the SDK's own check too, but not nonsense.
+func TestNoDurationCheck(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, capturing the session request
+	mockChildPackages()
+	input := mockSTSCapturingInput()
+
+	// Both too short and too long go through
+	executeCommandCapturingStdout("123456", "--duration", "1m", "--no-duration-check")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, int64(60), *input.DurationSeconds, "the short duration was not passed on")
+	executeCommandCapturingStdout("123456", "--duration", "48h", "--no-duration-check")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, int64(172800), *input.DurationSeconds, "the long duration was not passed on")
+
+	// But a session has to last for some time
+	executeCommand("123456", "--duration", "0s", "--no-duration-check")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+	require.Equal(t, "--duration must be positive, not 0s", executeError.Error(), "not the expected error")
+}
+
+// TestVerboseDurationWarning confirms that --verbose warns when AWS grants a shorter
+// session than was asked for, and only then.
+func TestVerboseDurationWarning(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { warningOutput = os.Stderr }()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Configure our child packages to pretend, granting an hour whatever is asked for
+	mockChildPackages()
+	var warnings bytes.Buffer
+	warningOutput = &warnings
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		expiration = time.Now().Add(time.Hour)
+		return getSessionTokenOutput, nil
+	}
+
+	// Asking for an hour, there is nothing to warn about
+	executeCommandCapturingStdout("123456", "--verbose")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.NotContains(t, warnings.String(), "warning:", "there should have been no warning")
+	warnings.Reset()
+
+	// Without --verbose, there should still be nothing said about being short changed
+	executeCommandCapturingStdout("123456", "--duration", "36h")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, warnings.String(), "there should have been no warning without --verbose")
+
+	// But with it, we should be told
+	executeCommandCapturingStdout("123456", "--duration", "36h", "--verbose")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, warnings.String(), "warning: asked for a 36h0m0s session but AWS granted 1h0m0s", "expected a warning")
+}
+
+// TestRoleDurationClamp confirms that a role is asked for no more than its maximum session
+// duration, with a warning, and that the --duration flag stands if the maximum is unknown.
+func TestRoleDurationClamp(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { warningOutput = os.Stderr }()
+
+	mockChildPackages()
+	var warnings bytes.Buffer
+	warningOutput = &warnings
+	var asked *sts.AssumeRoleInput
+	fakeAWS().assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		asked = input
+		return &sts.AssumeRoleOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+	}
+
+	// IAM will not say, so we ask for what we were told to
+	executeCommandCapturingStdout("123456", "--role-arn", "arn:aws:iam::999999999999:role/admin", "--duration", "12h")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, int64(12*3600), *asked.DurationSeconds, "the requested duration should have stood")
+	require.Empty(t, warnings.String(), "there should have been no warning")
+
+	// Now it will, and we ask for no more than the role allows
+	var named string
+	creds.SetGetRoleFunc(func(awsService *iam.IAM, input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+		named = *input.RoleName
+		return &iam.GetRoleOutput{Role: &iam.Role{MaxSessionDuration: aws.Int64(4 * 3600)}}, nil
+	})
+	executeCommandCapturingStdout("123456", "--role-arn", "arn:aws:iam::999999999999:role/team/admin", "--duration", "12h")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "admin", named, "the role should have been named without its path")
+	require.Equal(t, int64(4*3600), *asked.DurationSeconds, "the duration should have been clamped")
+	require.Contains(t, warnings.String(), "allows sessions of no more than 4h0m0s, so asking for that rather than 12h0m0s")
+
+	// Within the maximum, nothing changes
+	warnings.Reset()
+	executeCommandCapturingStdout("123456", "--role-arn", "arn:aws:iam::999999999999:role/admin", "--duration", "2h")
+	require.Equal(t, int64(2*3600), *asked.DurationSeconds, "the requested duration should have stood")
+	require.Empty(t, warnings.String(), "there should have been no warning")
+}
+
+// TestRequireValidUntil confirms that --require-valid-until fails, saving nothing, when
+// AWS grants a shorter session than it demands, and that it must be a demand we could meet.
+func TestRequireValidUntil(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Configure our child packages to pretend, granting an hour whatever is asked for
+	mockChildPackages()
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		expiration = time.Now().Add(time.Hour)
+		return getSessionTokenOutput, nil
+	}
+
+	// Half an hour is within what we were granted
+	executeCommandCapturingStdout("123456", "--require-valid-until", "30m")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// Two hours is not, and nothing should be saved
+	writeFakeCredentials(fakeMFADeviceID)
+	executeCommandCapturingStdout("123456", "--duration", "3h", "--require-valid-until", "2h", "--save")
+	require.NotNil(t, executeError, "a short session should have been refused")
+	require.Equal(t, exitFailure, exitCode, "expected a plain failure")
+	require.Contains(t, executeError.Error(), "sooner than the 2h0m0s that --require-valid-until demands")
+	content, err := ioutil.ReadFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not read the fake credentials file")
+	require.NotContains(t, string(content), mfile.SessionSectionName, "the short session should not have been saved")
+
+	// Demanding more than we asked for could never work
+	executeCommand("123456", "--require-valid-until", "2h")
+	require.NotNil(t, executeError, "an impossible demand should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
+
+// TestRequireValidUntilRoleProfile confirms that --require-valid-until is held to the
+// duration_seconds of a role profile, that being what is asked for, not to --duration.
+func TestRequireValidUntilRoleProfile(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(fakeConfigFilePath)
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// A role profile whose sessions last two hours, which AWS grants
+	mockChildPackages()
+	content := "[profile admin]\nrole_arn = arn:aws:iam::999999999999:role/admin\nsource_profile = default\nduration_seconds = 7200\n"
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+	fakeAWS().assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		expiration = time.Now().Add(time.Duration(*input.DurationSeconds) * time.Second)
+		return &sts.AssumeRoleOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+	}
+
+	// Ninety minutes is more than the default --duration, but within the profile's
+	executeCommandCapturingStdout("123456", "--profile", "admin", "--require-valid-until", "90m")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// Three hours is within neither
+	executeCommand("123456", "--profile", "admin", "--require-valid-until", "3h")
+	require.NotNil(t, executeError, "an impossible demand should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+	require.Contains(t, executeError.Error(), "longer than the 2h0m0s session that is to be asked for")
+}
@@ -0,0 +1,139 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// End to end tests that drive the complete command, including genuine (if not
+// genuinely AWS) STS API calls, against a mock STS endpoint served by the tests
+// themselves or, when MAFIA_TEST_STS_ENDPOINT is set, a localstack instance.
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// The environment variable that names a localstack (or similar) STS endpoint to test against
+	testSTSEndpointEnvVar = "MAFIA_TEST_STS_ENDPOINT"
+
+	// The credentials that the mock STS endpoint hands out
+	mockSessionAccessKeyID     = "ASIAMOCKACCESSKEYID"
+	mockSessionSecretAccessKey = "MOCK_SESSION_SECRET"
+	mockSessionToken           = "MOCK_SESSION_TOKEN"
+
+	// The response that the mock STS endpoint returns for a GetSessionToken request
+	mockGetSessionTokenResponse = `<GetSessionTokenResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetSessionTokenResult>
+    <Credentials>
+      <SessionToken>` + mockSessionToken + `</SessionToken>
+      <SecretAccessKey>` + mockSessionSecretAccessKey + `</SecretAccessKey>
+      <Expiration>2020-04-01T12:00:00Z</Expiration>
+      <AccessKeyId>` + mockSessionAccessKeyID + `</AccessKeyId>
+    </Credentials>
+  </GetSessionTokenResult>
+  <ResponseMetadata>
+    <RequestId>58c5dbae-abef-11e0-8cfe-09039844ac7d</RequestId>
+  </ResponseMetadata>
+</GetSessionTokenResponse>`
+)
+
+// TestEndToEndMockSTS runs the full command, saving the credentials, against an STS
+// endpoint served by the test so that the request that reaches the endpoint and the
+// resulting credentials file contents can both be examined.
+func TestEndToEndMockSTS(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Stand up a mock STS endpoint that remembers the form values it was sent
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, mockGetSessionTokenResponse)
+	}))
+	defer server.Close()
+
+	// The SDK needs some long term credentials to sign the request with
+	defer setTestEnv("AWS_ACCESS_KEY_ID", fakeAccessKeyID)()
+	defer setTestEnv("AWS_SECRET_ACCESS_KEY", fakeSecretAccessKey)()
+
+	// Run the command against our fake credentials file and mock endpoint
+	setFakeCredentials()
+	output, stdout := executeCommandCapturingStdout("123456", "--save", "--sts-endpoint", server.URL)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, output, "there should not have been any help output: %s", output)
+	require.Contains(t, stdout, "Session credentials saved to file")
+
+	// The endpoint should have been asked for a session with our MFA device and token
+	require.Equal(t, "GetSessionToken", form.Get("Action"), "unexpected STS action")
+	require.Equal(t, fakeMFADeviceID, form.Get("SerialNumber"), "unexpected MFA serial number")
+	require.Equal(t, "123456", form.Get("TokenCode"), "unexpected MFA token")
+
+	// And what it returned should have been saved
+	verifySavedSession(t, mockSessionAccessKeyID, mockSessionSecretAccessKey, mockSessionToken)
+}
+
+// TestEndToEndLocalstack runs the full command, saving the credentials, against a
+// localstack (or similar) STS endpoint named by the MAFIA_TEST_STS_ENDPOINT environment
+// variable. The test is skipped if that variable is not set.
+func TestEndToEndLocalstack(t *testing.T) {
+
+	// Only run if we have been told where to find localstack
+	endpoint := os.Getenv(testSTSEndpointEnvVar)
+	if len(endpoint) == 0 {
+		t.Skipf("%s is not set", testSTSEndpointEnvVar)
+	}
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Localstack will accept any credentials but the SDK still needs some to sign with
+	defer setTestEnv("AWS_ACCESS_KEY_ID", fakeAccessKeyID)()
+	defer setTestEnv("AWS_SECRET_ACCESS_KEY", fakeSecretAccessKey)()
+
+	// Run the command against our fake credentials file and the localstack endpoint
+	setFakeCredentials()
+	output, stdout := executeCommandCapturingStdout("123456", "--save", "--sts-endpoint", endpoint)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, output, "there should not have been any help output: %s", output)
+	require.Contains(t, stdout, "Session credentials saved to file")
+
+	// We cannot know what values localstack will hand out, only that there must be some
+	verifySavedSession(t, "", "", "")
+}
+
+// verifySavedSession confirms that the fake credentials file contains a session section
+// holding the given values or, where a value is given as an empty string, any non-empty value.
+func verifySavedSession(t *testing.T, accessKeyID, secretAccessKey, sessionToken string) {
+
+	// Load the file and find the session section
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	section, err := cfg.GetSection(mfile.SessionSectionName)
+	require.Nil(t, err, "session section not found in credentials file")
+
+	// Check each of the values
+	expected := map[string]string{
+		mfile.AccessKeyIDKey:     accessKeyID,
+		mfile.SecretAccessKeyKey: secretAccessKey,
+		mfile.SessionTokenKey:    sessionToken,
+	}
+	for key, value := range expected {
+		if len(value) == 0 {
+			require.NotEmpty(t, section.Key(key).Value(), "no %s value was saved", key)
+		} else {
+			require.Equal(t, value, section.Key(key).Value(), "unexpected %s value", key)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the serving of the session credentials as an ECS credential provider endpoint.
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mikebway/mafia/creds"
+)
+
+const (
+	// The environment variables that point the AWS CLI and SDKs at an ECS credential
+	// provider endpoint, and give the token to present to it
+	ecsFullURIEnvVar   = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+	ecsAuthTokenEnvVar = "AWS_CONTAINER_AUTHORIZATION_TOKEN"
+
+	// How many random bytes go into the authorization token
+	ecsTokenBytes = 16
+)
+
+// ecsCredentials is the JSON document that an ECS credential provider endpoint serves.
+type ecsCredentials struct {
+	AccessKeyID     string  `json:"AccessKeyId"`
+	SecretAccessKey string  `json:"SecretAccessKey"`
+	Token           string  `json:"Token"`
+	Expiration      *string `json:"Expiration,omitempty"` // RFC 3339, or left out if unknown
+}
+
+// ecsServer serves session credentials, on the loopback interface, to whoever presents
+// its authorization token, as the ECS container agent serves a task's role credentials.
+type ecsServer struct {
+	url         string       // Where the credentials are served
+	token       string       // What must be presented in the Authorization header
+	server      *http.Server // Serving them
+	credentials *creds.SessionCredentials
+}
+
+// validateECSServer returns a configuration error if --ecs-server is combined with a flag
+// that would display the session credentials, the endpoint being where they are to go.
+func validateECSServer() error {
+	if serveECS && (export || credentialProcess || len(formatTemplate) != 0 || outputRedirected() || configSnippet) {
+		return newConfigError(errors.New("--ecs-server cannot be used with --export, --credential-process, --format, --config-snippet, --output-file, --output-fd, or --output-fifo"))
+	}
+	return nil
+}
+
+// startECSServer starts serving the given session credentials on a port of 127.0.0.1
+// chosen by the operating system, behind a newly generated authorization token.
+func startECSServer(credentials *creds.SessionCredentials) (*ecsServer, error) {
+	random := make([]byte, ecsTokenBytes)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("could not generate the --ecs-server authorization token: %w", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, newConfigError(fmt.Errorf("could not start the --ecs-server endpoint: %w", err))
+	}
+	s := &ecsServer{
+		url:         "http://" + listener.Addr().String() + "/",
+		token:       hex.EncodeToString(random),
+		credentials: credentials,
+	}
+	s.server = &http.Server{Handler: s}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// ServeHTTP answers a request for the credentials, refusing any without the token.
+func (s *ecsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(s.token)) != 1 {
+		http.Error(w, "the authorization token is missing or wrong", http.StatusForbidden)
+		return
+	}
+	document := ecsCredentials{
+		AccessKeyID:     *s.credentials.AccessKeyID,
+		SecretAccessKey: s.credentials.SecretAccessKey.Value(),
+		Token:           s.credentials.SessionToken.Value(),
+	}
+	if s.credentials.Expiration != nil {
+		expiration := s.credentials.Expiration.UTC().Format(time.RFC3339)
+		document.Expiration = &expiration
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(document)
+}
+
+// Close stops serving the credentials.
+func (s *ecsServer) Close() error {
+	return s.server.Close()
+}
+
+// environment returns the given environment with the variables that point the AWS CLI
+// and SDKs at the endpoint set in it, and any credentials that would take precedence over
+// the endpoint removed.
+func (s *ecsServer) environment(environ []string) []string {
+	return setEnvironment(environ, []envVar{
+		{accessKeyIDEnvVar, ""},
+		{secretAccessKeyEnvVar, ""},
+		{sessionTokenEnvVar, ""},
+		{ecsFullURIEnvVar, s.url},
+		{ecsAuthTokenEnvVar, s.token},
+	})
+}
+
+// runECSServer serves the session credentials until a signal says to stop, having written
+// the statements that point the AWS CLI and SDKs at them to the given writer.
+func runECSServer(w io.Writer, credentials *creds.SessionCredentials) error {
+	s, err := startECSServer(credentials)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// Listen for the signals that stop us, and say how to reach us in the meantime
+	signals := make(chan os.Signal, 1)
+	notifySignals(signals)
+	defer signal.Stop(signals)
+	fmt.Fprintf(w, "Serving the session credentials at %s until interrupted; to use them:\n\n", s.url)
+	fmt.Fprintln(w, exportStatement(ecsFullURIEnvVar, s.url))
+	fmt.Fprintln(w, exportStatement(ecsAuthTokenEnvVar, s.token))
+	sig := <-signals
+	fmt.Fprintf(w, "\nStopped serving the session credentials on %v\n", sig)
+	return nil
+}
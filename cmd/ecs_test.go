@@ -0,0 +1,90 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the ecs.go functions.
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials/endpointcreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/mikebway/mafia/creds"
+	"github.com/stretchr/testify/require"
+)
+
+// TestECSServer confirms that the endpoint serves the session credentials, in the form
+// that the SDK's ECS credential provider expects, only to those presenting its token.
+func TestECSServer(t *testing.T) {
+	key := "key"
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	credentials := &creds.SessionCredentials{AccessKeyID: &key, SecretAccessKey: creds.NewSecret("secret"), SessionToken: creds.NewSecret("token"), Expiration: &expires}
+	server, err := startECSServer(credentials)
+	require.Nil(t, err, "there should not have been an error starting the server")
+	defer server.Close()
+	require.True(t, strings.HasPrefix(server.url, "http://127.0.0.1:"), "the server should only listen on the loopback interface: %s", server.url)
+
+	// Without the token, or with another, there is nothing to be had
+	for _, token := range []string{"", "wrong"} {
+		request, err := http.NewRequest(http.MethodGet, server.url, nil)
+		require.Nil(t, err, "could not make the request")
+		request.Header.Set("Authorization", token)
+		response, err := http.DefaultClient.Do(request)
+		require.Nil(t, err, "the request should have been answered")
+		response.Body.Close()
+		require.Equal(t, http.StatusForbidden, response.StatusCode, "the %q token should have been refused", token)
+	}
+
+	// With it, the SDK gets the credentials and when they expire
+	provider := endpointcreds.NewProviderClient(*defaults.Config(), defaults.Handlers(), server.url, func(p *endpointcreds.Provider) {
+		p.AuthorizationToken = server.token
+	})
+	value, err := provider.Retrieve()
+	require.Nil(t, err, "the SDK should have retrieved the credentials: %v", err)
+	require.Equal(t, "key", value.AccessKeyID, "wrong access key ID")
+	require.Equal(t, "secret", value.SecretAccessKey, "wrong secret access key")
+	require.Equal(t, "token", value.SessionToken, "wrong session token")
+	require.False(t, provider.IsExpired(), "the credentials should not have expired")
+
+	// A command run with the endpoint is pointed at it, and none of our credentials get in the way
+	env := server.environment([]string{"HOME=/home/pat", "AWS_ACCESS_KEY_ID=long", "AWS_SECRET_ACCESS_KEY=long"})
+	require.Equal(t, []string{"HOME=/home/pat", ecsFullURIEnvVar + "=" + server.url, ecsAuthTokenEnvVar + "=" + server.token}, env)
+}
+
+// TestECSServerFlag confirms that --ecs-server serves the credentials until interrupted,
+// saying how to reach them, or for as long as the --exec command runs, and displays nothing.
+func TestECSServerFlag(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(n func(chan<- os.Signal)) { notifySignals = n }(notifySignals)
+
+	// Interrupted as soon as it starts, the server has still said where it is
+	mockChildPackages()
+	notifySignals = func(c chan<- os.Signal) { c <- os.Interrupt }
+	_, stdout := executeCommandCapturingStdout("123456", "--ecs-server")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "export "+ecsFullURIEnvVar+"='http://127.0.0.1:")
+	require.Contains(t, stdout, "export "+ecsAuthTokenEnvVar+"='")
+	require.Contains(t, stdout, "Stopped serving the session credentials on interrupt\n")
+	require.NotContains(t, stdout, "aws_session_token", "the credentials should not have been displayed")
+
+	// A command sees the endpoint in place of the credentials
+	if runtime.GOOS != "windows" {
+		_, stdout = executeCommandCapturingStdout("123456", "--ecs-server", "--exec", `printf '%s|%s' "$`+ecsFullURIEnvVar+`" "$AWS_SESSION_TOKEN"`)
+		require.Nil(t, executeError, "there should not have been an error: ", executeError)
+		require.True(t, strings.HasPrefix(stdout, "http://127.0.0.1:"), "the command should have seen the endpoint: %s", stdout)
+		require.True(t, strings.HasSuffix(stdout, "/|"), "the command should not have seen the session token: %s", stdout)
+	}
+
+	// And there is nowhere else for the credentials to go
+	executeCommandCapturingStdout("123456", "--ecs-server", "--export")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
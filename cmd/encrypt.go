@@ -0,0 +1,43 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the encryption of the saved session token with a passphrase.
+
+import (
+	"errors"
+	"os"
+)
+
+const (
+	// The environment variable that holds the passphrase that --encrypt encrypts the saved
+	// session token with, and decrypts it with when the session is reused
+	passphraseEnvVar = "MAFIA_PASSPHRASE"
+)
+
+// validateEncrypt returns a configuration error if --encrypt was given without a passphrase
+// to encrypt with, or with a secret store that does its own encryption.
+func validateEncrypt() error {
+	if !encrypt {
+		return nil
+	}
+	if storeName != storeFile {
+		return newConfigError(errors.New("--encrypt only applies to sessions saved to the credentials file, not to --store " + storeName))
+	}
+	if len(os.Getenv(passphraseEnvVar)) == 0 {
+		return newConfigError(errors.New("--encrypt needs the passphrase in the " + passphraseEnvVar + " environment variable"))
+	}
+	return nil
+}
+
+// sessionPassphrase returns the passphrase that the saved session token is encrypted and
+// decrypted with, or an empty string if --encrypt was not given and it is saved as is.
+func sessionPassphrase() string {
+	if !encrypt {
+		return ""
+	}
+	return os.Getenv(passphraseEnvVar)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the encrypt.go functions.
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+// TestEncrypt confirms that --encrypt saves the session token encrypted with the
+// MAFIA_PASSPHRASE passphrase, that --reuse can only reuse it with the same passphrase,
+// and that there must be a passphrase to encrypt with.
+func TestEncrypt(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+	defer os.Unsetenv(passphraseEnvVar)
+
+	// Without a passphrase, or with the keychain, there is nothing to do
+	mockChildPackages()
+	os.Unsetenv(passphraseEnvVar)
+	executeCommandCapturingStdout("123456", "--save", "--encrypt")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error without a passphrase")
+	require.Contains(t, executeError.Error(), passphraseEnvVar, "the error should say where the passphrase goes")
+	os.Setenv(passphraseEnvVar, "correct horse")
+	executeCommandCapturingStdout("123456", "--save", "--encrypt", "--store", storeKeychain)
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error with the keychain")
+
+	// Save a session that is good for another hour, its token encrypted
+	expiration = time.Now().Add(time.Hour).Truncate(time.Second)
+	_, stdout := executeCommandCapturingStdout("123456", "--save", "--encrypt")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "to use them: mafia --reuse --encrypt --exec '<command>'", "the way to use the session should have been given")
+	require.NotContains(t, stdout, profileEnvVar, "the section is of no use through "+profileEnvVar)
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	section := cfg.Section(mfile.SessionSectionName)
+	require.False(t, section.HasKey(mfile.SessionTokenKey), "the session token should not have been saved as is")
+	encrypted := section.Key(mfile.SessionTokenEncryptedKey).Value()
+	decrypted, err := mfile.DecryptValue("correct horse", encrypted)
+	require.Nil(t, err, "the session token should decrypt with the passphrase")
+	require.Equal(t, token, decrypted, "the wrong session token was encrypted")
+
+	// The saved session is reused with the passphrase, but not without or with another
+	calls := countSTSCalls()
+	_, stdout = executeCommandCapturingStdout("123456", "--reuse", "--encrypt")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+	require.Contains(t, stdout, "aws_session_token = "+token+"\n", "the decrypted session token should have been displayed")
+	executeCommandCapturingStdout("123456", "--reuse")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 1, *calls, "AWS should have been called without the passphrase")
+	os.Setenv(passphraseEnvVar, "battery staple")
+	executeCommandCapturingStdout("123456", "--reuse", "--encrypt")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 2, *calls, "AWS should have been called with the wrong passphrase")
+}
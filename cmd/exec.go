@@ -0,0 +1,120 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the --exec handling, which runs a command with the session credentials in its environment.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/mikebway/mafia/creds"
+)
+
+// commandExitError is returned when the --exec command ran but did not succeed, so that
+// mafia can exit with the command's own exit status, the command having already said why.
+type commandExitError struct {
+	code int // The exit status of the command
+}
+
+// Error describes how the command exited.
+func (e *commandExitError) Error() string {
+	return fmt.Sprintf("the --exec command exited with status %d", e.code)
+}
+
+// validateExec returns a configuration error if --exec is combined with a flag that
+// would display the session credentials, the command being where they are to go.
+func validateExec() error {
+	if len(execCommand) != 0 && (export || credentialProcess || len(formatTemplate) != 0 || outputRedirected()) {
+		return newConfigError(errors.New("--exec cannot be used with --export, --credential-process, --format, --output-file, --output-fd, or --output-fifo"))
+	}
+	return nil
+}
+
+// runWithCredentials runs the --exec command through the shell, sh or, on Windows, cmd,
+// with stdin, stdout, and stderr inherited and the session credentials set in its
+// environment, named with any --prefix, in place of any that we were given ourselves.
+// The credentials are not written anywhere else. With --ecs-server, the command is
+// pointed at an endpoint serving the credentials, for as long as it runs, instead.
+func runWithCredentials(credentials *creds.SessionCredentials) error {
+	env := credentialsEnvironment(os.Environ(), credentials)
+	if serveECS {
+		server, err := startECSServer(credentials)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		env = server.environment(os.Environ())
+	}
+	command := shellCommand(execCommand)
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	command.Env = env
+
+	// Run it, passing on how it exited if it ran at all
+	err := command.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if code <= 0 {
+			code = exitFailure // Killed by a signal
+		}
+		return &commandExitError{code: code}
+	}
+	if err != nil {
+		return newConfigError(fmt.Errorf("could not run the --exec command: %w", err))
+	}
+	return nil
+}
+
+// shellCommand returns a command that runs the given command line through the shell, sh
+// or, on Windows, cmd.
+func shellCommand(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", line)
+	}
+	return exec.Command("sh", "-c", line)
+}
+
+// envVar is an environment variable to be set, or removed if its value is empty.
+type envVar struct{ name, value string }
+
+// credentialsEnvironment returns the given environment with the session credentials set
+// in it, replacing any values that the variables already had.
+func credentialsEnvironment(environ []string, credentials *creds.SessionCredentials) []string {
+	return setEnvironment(environ, []envVar{
+		{envPrefix + accessKeyIDEnvVar, *credentials.AccessKeyID},
+		{envPrefix + secretAccessKeyEnvVar, credentials.SecretAccessKey.Value()},
+		{envPrefix + sessionTokenEnvVar, credentials.SessionToken.Value()},
+	})
+}
+
+// setEnvironment returns the given environment with the given variables set in it,
+// replacing any values that they already had, or removed if their values are empty.
+func setEnvironment(environ []string, vars []envVar) []string {
+	env := make([]string, 0, len(environ)+len(vars))
+	for _, entry := range environ {
+		replaced := false
+		for _, v := range vars {
+			if strings.HasPrefix(entry, v.name+"=") {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			env = append(env, entry)
+		}
+	}
+	for _, v := range vars {
+		if len(v.value) != 0 {
+			env = append(env, v.name+"="+v.value)
+		}
+	}
+	return env
+}
@@ -0,0 +1,60 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the exec.go functions.
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExec confirms that --exec runs the command with the session credentials in its
+// environment, in place of displaying them, and passes on its exit status.
+func TestExec(t *testing.T) {
+
+	// The commands are written for sh
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need sh")
+	}
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// The command sees the session credentials, and nothing is displayed but what it prints
+	mockChildPackages()
+	_, stdout := executeCommandCapturingStdout("123456", "--exec", `printf '%s/%s/%s' "$AWS_ACCESS_KEY_ID" "$AWS_SECRET_ACCESS_KEY" "$AWS_SESSION_TOKEN"`)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "key/secret/token", stdout, "the command should have seen the session credentials")
+
+	// With a prefix, under their prefixed names
+	_, stdout = executeCommandCapturingStdout("123456", "--prefix", "MYAPP_", "--exec", `printf '%s' "$MYAPP_AWS_SESSION_TOKEN"`)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "token", stdout, "the command should have seen the prefixed session token")
+
+	// A command that fails has mafia fail with its exit status
+	executeCommandCapturingStdout("123456", "--exec", "exit 5")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, 5, exitCode, "the command's exit status should have been passed on")
+
+	// And there is nowhere else for the credentials to go
+	executeCommandCapturingStdout("123456", "--exec", "true", "--export")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
+
+// TestCredentialsEnvironment confirms that the session credentials replace any values
+// that the environment already had for them, and that nothing else is disturbed.
+func TestCredentialsEnvironment(t *testing.T) {
+	defer func() { envPrefix = "" }()
+
+	key := "key"
+	credentials := &creds.SessionCredentials{AccessKeyID: &key, SecretAccessKey: creds.NewSecret("secret"), SessionToken: creds.NewSecret("token")}
+	env := credentialsEnvironment([]string{"HOME=/home/pat", "AWS_ACCESS_KEY_ID=long", "AWS_SESSION_TOKEN_X=kept"}, credentials)
+	require.Equal(t, []string{"HOME=/home/pat", "AWS_SESSION_TOKEN_X=kept", "AWS_ACCESS_KEY_ID=key", "AWS_SECRET_ACCESS_KEY=secret", "AWS_SESSION_TOKEN=token"}, env)
+}
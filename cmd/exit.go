@@ -0,0 +1,85 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the mapping of errors to process exit codes.
+
+import (
+	"errors"
+
+	"github.com/mikebway/mafia/creds"
+)
+
+// The process exit codes that distinguish one class of failure from another, allowing
+// scripts to decide what to do next without having to parse error messages.
+const (
+	exitOK           = 0 // All went well
+	exitFailure      = 1 // Something went wrong that does not fit any of the other classes
+	exitConfigError  = 2 // The credentials file, a flag, or some other configuration was missing or invalid
+	exitAuthRejected = 3 // AWS rejected the identity or MFA token that was presented
+	exitNetworkError = 4 // AWS could not be reached, timed out, or was unable to service the request
+)
+
+var (
+	// The names of the exit code classes, as reported with errors on stderr
+	exitClassNames = map[int]string{
+		exitOK:           "ok",
+		exitFailure:      "failure",
+		exitConfigError:  "config_error",
+		exitAuthRejected: "auth_rejected",
+		exitNetworkError: "network_error",
+	}
+)
+
+// configError wraps errors that arise from missing or invalid local configuration,
+// whether in the AWS credentials file or on the command line.
+type configError struct {
+	err error // The underlying error
+}
+
+// Error returns the message of the underlying error.
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+// newConfigError wraps an error as a configError, returning nil if the error is nil.
+func newConfigError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &configError{err: err}
+}
+
+// exitCodeFor maps an error returned from command execution to a process exit code.
+func exitCodeFor(err error) int {
+
+	// Variables for errors.As to populate
+	var cfgErr *configError
+	var credsCfgErr *creds.ConfigError
+	var authErr *creds.AuthError
+	var networkErr *creds.NetworkError
+	var commandErr *commandExitError
+
+	// Work out what kind of error we have been given
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &cfgErr), errors.As(err, &credsCfgErr):
+		return exitConfigError
+	case errors.As(err, &authErr):
+		return exitAuthRejected
+	case errors.As(err, &networkErr):
+		return exitNetworkError
+	case errors.As(err, &commandErr):
+		return commandErr.code
+	}
+	return exitFailure
+}
@@ -0,0 +1,76 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the exit.go functions.
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExitCodeFor confirms the mapping of error types to exit codes, including when
+// the typed errors have been wrapped again on their way up.
+func TestExitCodeFor(t *testing.T) {
+	require.Equal(t, exitOK, exitCodeFor(nil))
+	require.Equal(t, exitFailure, exitCodeFor(errors.New("who knows")))
+	require.Equal(t, exitConfigError, exitCodeFor(newConfigError(errors.New("bad file"))))
+	require.Equal(t, exitConfigError, exitCodeFor(&creds.ConfigError{Err: errors.New("no credentials")}))
+	require.Equal(t, exitAuthRejected, exitCodeFor(&creds.AuthError{Err: errors.New("bad token")}))
+	require.Equal(t, exitNetworkError, exitCodeFor(fmt.Errorf("wrapped: %w", &creds.NetworkError{Err: errors.New("timeout")})))
+	require.Nil(t, newConfigError(nil), "a nil error should stay nil")
+}
+
+// TestExitCodeMissingFile confirms that a missing credentials file is reported as
+// a configuration error.
+func TestExitCodeMissingFile(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Have the mfile package look for a file that is not there
+	mfile.OverrideDefaultCredentialsFilepath("/you/got/no/skin/on/me-cos-i-do-not-exist")
+	executeCommand("123456")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
+
+// TestExitCodeAuthRejected confirms that a token rejected by AWS is reported as such.
+func TestExitCodeAuthRejected(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Have the fake AWS reject the token
+	mockChildPackages()
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		return nil, awserr.New("AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code.", nil)
+	}
+
+	executeCommand("123456")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitAuthRejected, exitCode, "expected an auth rejected exit code")
+	require.Equal(t, "MFA code was rejected — check the digits", executeError.Error(), "expected a clear explanation of the problem")
+}
+
+// TestExitCodeSuccess confirms that the exit code is cleared after a successful run.
+func TestExitCodeSuccess(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Run with happy mocks
+	mockChildPackages()
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, exitOK, exitCode, "expected a success exit code")
+}
@@ -0,0 +1,102 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the export-sessions subcommand.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+)
+
+// exportedSession describes a session section of the credentials file, without its
+// secrets unless --include-secrets was given.
+type exportedSession struct {
+	Profile         string  `json:"profile"`                     // The profile that the session is for
+	Section         string  `json:"section"`                     // The name of the session section
+	Expiration      *string `json:"expiration"`                  // When the session expires, RFC 3339 in UTC, or null if unknown
+	Valid           bool    `json:"valid"`                       // True if the session is known not to have expired
+	AccessKeyID     string  `json:"access_key_id,omitempty"`     // Only with --include-secrets
+	SecretAccessKey string  `json:"secret_access_key,omitempty"` // Only with --include-secrets
+	SessionToken    string  `json:"session_token,omitempty"`     // Only with --include-secrets
+}
+
+// exportSessionsCmd represents the export-sessions subcommand
+var exportSessionsCmd = &cobra.Command{
+	Use:   "export-sessions",
+	Short: "Describe every saved session in the credentials file as a JSON document",
+	Long: `Writes a JSON array describing each -session section of the credentials file: the
+profile that it is for, when it expires, and whether it is still valid. The keys and
+tokens themselves are left out unless --include-secrets is given. Nothing is written
+to the file and AWS is not called. The output is always JSON, so --json may be given
+or not.`,
+	Args: cobra.NoArgs,
+
+	// RunE writes the report
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return exportSessions(cmd.OutOrStdout())
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the export-sessions subcommand to the root command
+	rootCmd.AddCommand(exportSessionsCmd)
+}
+
+// exportSessions writes a JSON array describing each session section of the credentials
+// file to the given writer.
+func exportSessions(w io.Writer) error {
+
+	// Find out what sections there are
+	path := credentialsFilepath()
+	names, err := mfile.GetProfileNamesFromFile(path)
+	if err != nil {
+		return newConfigError(err)
+	}
+
+	// Describe those that hold sessions, in the order that the file has them
+	sessions := []exportedSession{}
+	for _, name := range names {
+		profile := mfile.ProfileNameForSection(name)
+		if profile == name {
+			continue
+		}
+		saved, err := mfile.GetSavedSessionFromFile(path, &mfile.SaveOptions{Profile: profile, KeyNames: saveOptions().KeyNames, Passphrase: sessionPassphrase()})
+		if err != nil {
+			return newConfigError(err)
+		}
+		if saved == nil {
+			continue
+		}
+		session := exportedSession{Profile: profile, Section: name}
+		if saved.Expiration != nil {
+			expiration := saved.Expiration.UTC().Format(time.RFC3339)
+			session.Expiration = &expiration
+			session.Valid = time.Now().Before(*saved.Expiration)
+		}
+		if includeSecrets {
+			session.AccessKeyID = saved.AccessKeyID
+			session.SecretAccessKey = saved.SecretAccessKey
+			session.SessionToken = saved.SessionToken
+		}
+		sessions = append(sessions, session)
+	}
+
+	// And out it goes
+	output, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(output))
+	return err
+}
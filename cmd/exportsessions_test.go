@@ -0,0 +1,75 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the exportsessions.go functions.
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+// TestExportSessions confirms that each session section is described, valid or not, and
+// that secrets are only included when asked for.
+func TestExportSessions(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Nothing saved, an empty array
+	mockChildPackages()
+	output := executeCommand("export-sessions")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "[]\n", output, "expected an empty array")
+
+	// Save an active session and an expired one
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not load the fake credentials file")
+	addSession := func(name string, expiration time.Time) {
+		section, _ := cfg.NewSection(name)
+		section.NewKey(mfile.AccessKeyIDKey, accessKey)
+		section.NewKey(mfile.SecretAccessKeyKey, secret)
+		section.NewKey(mfile.SessionTokenKey, token)
+		section.NewKey(mfile.SessionExpirationKey, expiration.UTC().Format(time.RFC3339))
+	}
+	active := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	addSession(mfile.SessionSectionName, active)
+	addSession("stale-session", time.Now().Add(-time.Hour))
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "could not write the fake credentials file")
+
+	// Without secrets
+	var sessions []exportedSession
+	output = executeCommand("export-sessions", "--json")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Nil(t, json.Unmarshal([]byte(output), &sessions), "the output should have been JSON: %s", output)
+	require.Len(t, sessions, 2, "expected two sessions")
+	require.Equal(t, mfile.DefaultSectionName, sessions[0].Profile)
+	require.Equal(t, mfile.SessionSectionName, sessions[0].Section)
+	require.Equal(t, active.Format(time.RFC3339), *sessions[0].Expiration)
+	require.True(t, sessions[0].Valid, "the default session should have been valid")
+	require.Equal(t, "stale", sessions[1].Profile)
+	require.False(t, sessions[1].Valid, "the stale session should have expired")
+	require.NotContains(t, output, secret, "secrets should not have been included")
+	require.NotContains(t, output, "session_token", "secrets should not have been included")
+
+	// With them
+	sessions = nil
+	output = executeCommand("export-sessions", "--include-secrets")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Nil(t, json.Unmarshal([]byte(output), &sessions), "the output should have been JSON: %s", output)
+	require.Equal(t, accessKey, sessions[0].AccessKeyID)
+	require.Equal(t, secret, sessions[0].SecretAccessKey)
+	require.Equal(t, token, sessions[0].SessionToken)
+
+	// A missing file is an error
+	executeCommand("export-sessions", "--credentials-file", "./missing.test")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
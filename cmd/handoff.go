@@ -0,0 +1,87 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the handing off of the credentials display to an inherited file
+// descriptor or a named pipe, in place of stdout or a regular file.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// The lowest file descriptor that --output-fd accepts; those below are stdin, stdout,
+	// and stderr
+	minOutputFD = 3
+)
+
+// outputRedirected returns true if the credentials display is to go to somewhere other
+// than stdout: the --output-file, --output-fd, or --output-fifo.
+func outputRedirected() bool {
+	return len(outputFile) != 0 || outputFD != 0 || len(outputFIFO) != 0
+}
+
+// validateOutput returns a configuration error if more than one of --output-file,
+// --output-fd, and --output-fifo was given, or if --output-fd names stdin, stdout, or
+// stderr.
+func validateOutput() error {
+	given := 0
+	for _, g := range []bool{len(outputFile) != 0, outputFD != 0, len(outputFIFO) != 0} {
+		if g {
+			given++
+		}
+	}
+	if given > 1 {
+		return newConfigError(errors.New("only one of --output-file, --output-fd, and --output-fifo can be given"))
+	}
+	if outputFD != 0 && outputFD < minOutputFD {
+		return newConfigError(fmt.Errorf("--output-fd must be %d or more, not %d; 0, 1, and 2 are stdin, stdout, and stderr", minOutputFD, outputFD))
+	}
+	return nil
+}
+
+// openOutput opens the --output-file, --output-fd, or --output-fifo for the credentials
+// display, returning it with a description of it for the user. The output file is made
+// private to its owner even if it already existed with looser permissions; the file
+// descriptor must be open, and the named pipe must already exist and be a pipe, so that
+// the credentials never land in a regular file by mistake. Opening the pipe waits for
+// its reader to open it too.
+func openOutput() (*os.File, string, error) {
+	switch {
+	case outputFD != 0:
+		file := os.NewFile(uintptr(outputFD), fmt.Sprintf("file descriptor %d", outputFD))
+		if _, err := file.Stat(); err != nil {
+			return nil, "", newConfigError(fmt.Errorf("could not write to --output-fd %d: %v", outputFD, err))
+		}
+		return file, file.Name(), nil
+
+	case len(outputFIFO) != 0:
+		info, err := os.Stat(outputFIFO)
+		if err != nil {
+			return nil, "", newConfigError(fmt.Errorf("could not open output pipe %s: %v", outputFIFO, err))
+		}
+		if info.Mode()&os.ModeNamedPipe == 0 {
+			return nil, "", newConfigError(fmt.Errorf("%s is not a named pipe; create one with mkfifo", outputFIFO))
+		}
+		file, err := os.OpenFile(outputFIFO, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, "", newConfigError(fmt.Errorf("could not open output pipe %s: %v", outputFIFO, err))
+		}
+		return file, "the pipe " + outputFIFO, nil
+	}
+
+	file, err := os.OpenFile(outputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, outputFileMode)
+	if err != nil {
+		return nil, "", newConfigError(fmt.Errorf("could not open output file %s: %v", outputFile, err))
+	}
+	if err = file.Chmod(outputFileMode); err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	return file, outputFile, nil
+}
@@ -0,0 +1,90 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the handoff.go functions, which need the Unix pipes and
+// named pipes that Windows does without.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestOutputFD confirms that the --output-fd flag sends the credentials display to the
+// inherited file descriptor, closing it so that the reader sees the end of the display.
+func TestOutputFD(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// A pipe to hand the write end of to the command
+	fds := make([]int, 2)
+	require.Nil(t, syscall.Pipe(fds), "could not create a pipe")
+	reader := os.NewFile(uintptr(fds[0]), "pipe")
+	defer reader.Close()
+
+	// The display should come down the pipe, not stdout
+	mockChildPackages()
+	_, stdout := executeCommandCapturingStdout("123456", "--export", "--output-fd", strconv.Itoa(fds[1]))
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.NotContains(t, stdout, secret, "the credentials should not have been written to stdout")
+	content, err := ioutil.ReadAll(reader)
+	require.Nil(t, err, "could not read the pipe")
+	require.Contains(t, string(content), "export AWS_SECRET_ACCESS_KEY='"+secret+"'", "the credentials should have come down the pipe")
+
+	// Descriptors that are not open, or are stdin, stdout, or stderr, are refused
+	for _, fd := range []string{"999", "1"} {
+		executeCommandCapturingStdout("123456", "--output-fd", fd)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code with --output-fd %s", fd)
+	}
+	executeCommandCapturingStdout("123456", "--output-fd", "3", "--output-file", testOutputFilePath)
+	require.Equal(t, "only one of --output-file, --output-fd, and --output-fifo can be given", executeError.Error(), "not the expected error")
+}
+
+// TestOutputFIFO confirms that the --output-fifo flag sends the credentials display to the
+// named pipe, and refuses to write to a regular file in its place.
+func TestOutputFIFO(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// A named pipe, and a reader waiting at the far end of it
+	dir, err := ioutil.TempDir("", "mafia-fifo")
+	require.Nil(t, err, "could not create a temporary directory")
+	defer os.RemoveAll(dir)
+	fifo := filepath.Join(dir, "credentials")
+	require.Nil(t, syscall.Mkfifo(fifo, 0600), "could not create a named pipe")
+	received := make(chan string)
+	go func() {
+		content, _ := ioutil.ReadFile(fifo)
+		received <- string(content)
+	}()
+
+	// The display should come down the pipe, not stdout
+	mockChildPackages()
+	_, stdout := executeCommandCapturingStdout("123456", "--output-fifo", fifo)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.NotContains(t, stdout, secret, "the credentials should not have been written to stdout")
+	require.Contains(t, stdout, "Session credentials written to the pipe "+fifo, "the user should have been told where they went")
+	require.Contains(t, <-received, "aws_secret_access_key = "+secret, "the credentials should have come down the pipe")
+
+	// A regular file is no substitute, nor is nothing at all
+	regular := filepath.Join(dir, "regular")
+	require.Nil(t, ioutil.WriteFile(regular, nil, 0600), "could not create a regular file")
+	executeCommandCapturingStdout("123456", "--output-fifo", regular)
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+	require.Contains(t, executeError.Error(), "is not a named pipe", "not the expected error")
+	executeCommandCapturingStdout("123456", "--output-fifo", filepath.Join(dir, "missing"))
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
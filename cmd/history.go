@@ -0,0 +1,114 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the functions that scrub the command line holding an MFA code from the shell history.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// The environment variable that may name the shell history file
+	histFileEnvVar = "HISTFILE"
+)
+
+var (
+	// The history files, in the home directory, of the shells whose history we can scrub
+	defaultHistoryFiles = map[string]string{shellBash: ".bash_history", shellZsh: ".zsh_history"}
+
+	// The timestamp line that bash writes before each command when HISTTIMEFORMAT is set
+	bashTimestampLine = regexp.MustCompile(`^#\d+$`)
+
+	// The prefix that zsh writes before each command with the EXTENDED_HISTORY option
+	zshExtendedPrefix = regexp.MustCompile(`^: \d+:\d+;`)
+)
+
+// validateScrubHistory returns a configuration error if --scrub-history was given for a
+// shell whose history format we do not know.
+func validateScrubHistory() error {
+	if _, ok := defaultHistoryFiles[shell]; scrubHistory && !ok {
+		return newConfigError(fmt.Errorf("--scrub-history knows the history files of bash and zsh, not %s", shell))
+	}
+	return nil
+}
+
+// historyFilepath returns the path of the shell's history file: that named by the HISTFILE
+// environment variable if it is set, or the shell's default file in the home directory.
+func historyFilepath() (string, error) {
+	if path := os.Getenv(histFileEnvVar); len(path) != 0 {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, defaultHistoryFiles[shell]), nil
+}
+
+// scrubMFACodeFromHistory removes the mafia command lines that hold the given MFA code
+// from the shell's history file, leaving all other history alone. The shell may not yet
+// have written the current command to the file, in which case there is nothing to remove,
+// so any problem is no more than a warning.
+func scrubMFACodeFromHistory(mfaToken string) {
+	path, err := historyFilepath()
+	if err == nil {
+		err = scrubHistoryFile(path, mfaToken)
+	}
+	if err != nil {
+		fmt.Fprintf(warningOutput, "warning: could not scrub the MFA code from the shell history: %v\n", err)
+	}
+}
+
+// scrubHistoryFile rewrites the named history file without the mafia command lines that
+// hold the given MFA code, or the bash timestamp lines that precede them.
+func scrubHistoryFile(path, mfaToken string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Keep every line but those that we are looking for
+	lines := strings.SplitAfter(string(content), "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if !isMafiaCommandWith(line, mfaToken) {
+			kept = append(kept, line)
+			continue
+		}
+		if n := len(kept); n != 0 && bashTimestampLine.MatchString(strings.TrimSpace(kept[n-1])) {
+			kept = kept[:n-1]
+		}
+	}
+
+	// Leave the file untouched if there was nothing to remove
+	if len(kept) == len(lines) {
+		return nil
+	}
+	return ioutil.WriteFile(path, []byte(strings.Join(kept, "")), info.Mode().Perm())
+}
+
+// isMafiaCommandWith returns true if the given history line, in bash or zsh format, is a
+// command line that runs mafia with the given MFA code as one of its arguments.
+func isMafiaCommandWith(line, mfaToken string) bool {
+	line = zshExtendedPrefix.ReplaceAllString(line, "")
+	mafia, code := false, false
+	for _, field := range strings.Fields(line) {
+		field = strings.Trim(field, `'"`)
+		mafia = mafia || strings.Contains(filepath.Base(field), "mafia")
+		code = code || field == mfaToken
+	}
+	return mafia && code
+}
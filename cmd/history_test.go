@@ -0,0 +1,84 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the history.go functions.
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Where the tests keep a fake shell history file
+	testHistoryFilePath = "./history.test"
+)
+
+// TestScrubHistory confirms that --scrub-history removes the mafia command lines holding
+// the MFA code, and their bash timestamps, from the history file and nothing else.
+func TestScrubHistory(t *testing.T) {
+
+	// Wash the faces of our muddy children and tidy up before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(testHistoryFilePath)
+	defer os.Unsetenv(histFileEnvVar)
+
+	history := "ls -l\n#1600000000\nmafia 123456 --save\necho 123456\n#1600000001\n/usr/local/bin/mafia --profile work '123456'\nmafia 654321\n"
+	require.Nil(t, ioutil.WriteFile(testHistoryFilePath, []byte(history), 0600), "could not write the history file")
+	os.Setenv(histFileEnvVar, testHistoryFilePath)
+
+	mockChildPackages()
+	_, stdout := executeCommandCapturingStdout("123456", "--scrub-history", "--shell", shellBash)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	content, err := ioutil.ReadFile(testHistoryFilePath)
+	require.Nil(t, err, "could not read the history file")
+	require.Equal(t, "ls -l\necho 123456\nmafia 654321\n", string(content), "unexpected history")
+	require.NotContains(t, stdout, "history -c", "clearing all history should not have been suggested")
+
+	// Without the flag, the history is left alone
+	require.Nil(t, ioutil.WriteFile(testHistoryFilePath, []byte(history), 0600), "could not rewrite the history file")
+	_, stdout = executeCommandCapturingStdout("123456", "--shell", shellBash)
+	content, _ = ioutil.ReadFile(testHistoryFilePath)
+	require.Equal(t, history, string(content), "the history should not have changed")
+	require.Contains(t, stdout, "history -c", "clearing all history should have been suggested")
+}
+
+// TestScrubZshHistory confirms that zsh extended history lines are recognized.
+func TestScrubZshHistory(t *testing.T) {
+	defer os.Remove(testHistoryFilePath)
+
+	history := ": 1600000000:0;mafia 123456\n: 1600000001:0;git status\n"
+	require.Nil(t, ioutil.WriteFile(testHistoryFilePath, []byte(history), 0600), "could not write the history file")
+	require.Nil(t, scrubHistoryFile(testHistoryFilePath, "123456"), "there should not have been an error")
+	content, _ := ioutil.ReadFile(testHistoryFilePath)
+	require.Equal(t, ": 1600000001:0;git status\n", string(content), "unexpected history")
+}
+
+// TestScrubHistoryProblems confirms that a shell whose history we do not know is a
+// configuration error, and that a missing history file is no more than a warning.
+func TestScrubHistoryProblems(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer os.Unsetenv(histFileEnvVar)
+	defer func() { warningOutput = os.Stderr }()
+
+	mockChildPackages()
+	executeCommandCapturingStdout("123456", "--scrub-history", "--shell", shellFish)
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Contains(t, executeError.Error(), "not fish")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+
+	var warnings bytes.Buffer
+	warningOutput = &warnings
+	os.Setenv(histFileEnvVar, "./missing-history.test")
+	executeCommandCapturingStdout("123456", "--scrub-history", "--shell", shellZsh)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, warnings.String(), "warning: could not scrub the MFA code from the shell history")
+}
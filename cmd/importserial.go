@@ -0,0 +1,85 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the import-serial subcommand.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+)
+
+// importSerialCmd represents the import-serial subcommand
+var importSerialCmd = &cobra.Command{
+	Use:   "import-serial",
+	Short: "Copy the AWS CLI's mfa_serial for the profile into the credentials file as its mfa_device_id",
+	Long: `With --from-cli, reads the mfa_serial that the AWS CLI has for the profile in the
+~/.aws/config file and writes it to the credentials file as the mfa_device_id that
+mafia looks for, so that the MFA device ARN need not be entered twice. For a
+profile that assumes a role, it is written to the section of the source_profile,
+whose long term credentials are used with the device. AWS is not called.`,
+	Args: cobra.NoArgs,
+
+	// RunE copies the serial number across
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return importSerial(cmd.OutOrStdout())
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the import-serial subcommand to the root command
+	rootCmd.AddCommand(importSerialCmd)
+}
+
+// importSerial copies the mfa_serial of the profile in the AWS config file to the
+// mfa_device_id of the profile, or its source profile, in the credentials file, reporting
+// what it did on the given writer.
+func importSerial(w io.Writer) error {
+
+	// The AWS CLI is the only place that we know how to import from
+	if !fromCLI {
+		return newConfigError(errors.New("import-serial requires --from-cli"))
+	}
+
+	// Find what the AWS CLI would use, and check that it is worth having
+	configPath := mfile.ResolveConfigPath()
+	mfaSerial, sourceProfile, err := mfile.GetProfileMFASerialFromFile(configPath, profile)
+	if err != nil {
+		return newConfigError(err)
+	}
+	if len(mfaSerial) == 0 {
+		return newConfigError(fmt.Errorf("the %s profile in %s has no mfa_serial", profile, configPath))
+	}
+	if err = mfile.ValidateMFASerial(mfaSerial); err != nil {
+		return newConfigError(err)
+	}
+
+	// Write it where mafia will look for it, unless it is there already
+	section := profile
+	if len(sourceProfile) != 0 {
+		section = sourceProfile
+	}
+	path := credentialsFilepath()
+	current, err := mfile.GetProfileMFADeviceIDFromFile(path, section)
+	if err != nil && !errors.Is(err, mfile.ErrMFADeviceIDNotFound) {
+		return newConfigError(err)
+	}
+	if current == mfaSerial {
+		fmt.Fprintf(w, "The [%s] section of %s already has the %s %s\n", section, path, mfile.MfaDeviceIDKey, mfaSerial)
+		return nil
+	}
+	if err = mfile.SaveProfileMFADeviceIDToFile(path, section, mfaSerial); err != nil {
+		return newConfigError(err)
+	}
+	fmt.Fprintf(w, "Set the %s of the [%s] section of %s to %s\n", mfile.MfaDeviceIDKey, section, mfile.WritableCredentialsPath(path), mfaSerial)
+	return nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the importserial.go functions.
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// An AWS config file with MFA serial numbers for the AWS CLI to use
+	importSerialConfig = `[default]
+mfa_serial = arn:aws:iam::210987654321:mfa/pat
+
+[profile admin]
+role_arn = arn:aws:iam::210987654321:role/admin
+source_profile = default
+mfa_serial = arn:aws:iam::210987654321:mfa/admin
+
+[profile plain]
+region = us-east-1
+`
+)
+
+// TestImportSerial confirms that the AWS CLI's mfa_serial is copied to the credentials file,
+// to the source profile for a role profile, and not needlessly rewritten.
+func TestImportSerial(t *testing.T) {
+
+	// Wash the faces of our muddy children and tidy up before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(fakeConfigFilePath)
+
+	writeFakeCredentials("")
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(importSerialConfig), 0600), "could not write the fake config file")
+
+	output := executeCommand("import-serial", "--from-cli")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "Set the mfa_device_id of the [default] section")
+	mfaDeviceID, err := mfile.GetProfileMFADeviceIDFromFile(fakeCredentialsFilePath, mfile.DefaultSectionName)
+	require.Nil(t, err, "the MFA device ID should have been written")
+	require.Equal(t, "arn:aws:iam::210987654321:mfa/pat", mfaDeviceID)
+
+	output = executeCommand("import-serial", "--from-cli")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "already has the mfa_device_id arn:aws:iam::210987654321:mfa/pat")
+
+	// A role profile's serial belongs with its source profile's long term credentials
+	executeCommand("import-serial", "--from-cli", "--profile", "admin")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	mfaDeviceID, _ = mfile.GetProfileMFADeviceIDFromFile(fakeCredentialsFilePath, mfile.DefaultSectionName)
+	require.Equal(t, "arn:aws:iam::210987654321:mfa/admin", mfaDeviceID)
+}
+
+// TestImportSerialErrors confirms that there must be a serial number to import, and that
+// --from-cli must say where from.
+func TestImportSerialErrors(t *testing.T) {
+
+	// Wash the faces of our muddy children and tidy up before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(fakeConfigFilePath)
+
+	writeFakeCredentials("")
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(importSerialConfig), 0600), "could not write the fake config file")
+	for _, args := range [][]string{
+		{"import-serial"},
+		{"import-serial", "--from-cli", "--profile", "plain"},
+		{"import-serial", "--from-cli", "--profile", "missing"},
+	} {
+		executeCommand(args...)
+		require.NotNil(t, executeError, "there should have been an error for %v", args)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error for %v", args)
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the application of the --ini-... flags, which describe credentials and
+// config files that do not follow the usual key = value layout.
+
+import (
+	"fmt"
+
+	"github.com/mikebway/mafia/mfile"
+)
+
+// applyFileFormat tells the mfile package what conventions the credentials and config
+// files follow, as the --ini-... flags describe them, returning a configuration error if
+// they describe files that could not be read back.
+func applyFileFormat() error {
+	err := mfile.SetFileFormat(mfile.FileFormat{
+		KeyValueDelimiters:       iniDelimiters,
+		DelimiterOnWrite:         iniWriteDelimiter,
+		NoSpaces:                 iniNoSpaces,
+		SpaceBeforeInlineComment: iniSpaceBeforeComment,
+	})
+	if err != nil {
+		return newConfigError(fmt.Errorf("the --ini-... flags: %v", err))
+	}
+	return nil
+}
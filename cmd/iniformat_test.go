@@ -0,0 +1,39 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the iniformat.go functions.
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestINIFormat confirms that a credentials file in the key:value style is read, and that
+// the session section added to it follows that style, and that impossible styles are refused.
+func TestINIFormat(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// A credentials file with colons and without spaces
+	mockChildPackages()
+	content := "[default]\naws_access_key_id:key\naws_secret_access_key:secret\nmfa_device_id:" + fakeMFADeviceID + "\n"
+	require.Nil(t, ioutil.WriteFile(fakeCredentialsFilePath, []byte(content), 0600), "could not write the fake credentials file")
+
+	// Save a session to it in the same style
+	executeCommandCapturingStdout("123456", "--save", "--ini-delimiters", ":", "--ini-write-delimiter", ":", "--ini-no-spaces")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	saved, err := ioutil.ReadFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not read the fake credentials file")
+	require.Contains(t, string(saved), content+"\n[default-session]\naws_access_key_id:"+accessKey+"\naws_secret_access_key:"+secret+"\n", "the section should have been added in the file's own style")
+
+	// A delimiter that is not read back is refused before anything is done
+	executeCommandCapturingStdout("123456", "--save", "--ini-delimiters", ":", "--ini-write-delimiter", "=")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+	require.Contains(t, executeError.Error(), "the --ini-... flags", "not the expected error")
+}
@@ -0,0 +1,80 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the structured event logging written to stderr for log shippers to collect.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// The values accepted by the --log-format flag
+const (
+	logFormatText = "text" // No structured event log, the default
+	logFormatJSON = "json" // One JSON object per event, per line, on stderr
+)
+
+// The names of the events that are logged
+const (
+	eventAuthAttempt = "auth_attempt" // About to ask AWS for session credentials
+	eventAuthSuccess = "auth_success" // AWS handed over session credentials
+	eventAuthFailure = "auth_failure" // No session credentials were obtained
+	eventSave        = "save"         // Session credentials were saved to the credentials file
+	eventLogout      = "logout"       // A saved session was removed from the credentials file
+)
+
+var (
+	// Where structured events are written; unit tests substitute their own writer for os.Stderr
+	logOutput io.Writer = os.Stderr
+)
+
+// logRecord is the JSON form of a logged event. It must never be given secrets to carry.
+type logRecord struct {
+	Timestamp  string `json:"timestamp"`            // When the event happened, RFC 3339 in UTC
+	Event      string `json:"event"`                // One of the event... constants
+	Profile    string `json:"profile,omitempty"`    // The credentials file section involved
+	RoleARN    string `json:"role_arn,omitempty"`   // The role being assumed, if any
+	Expiration string `json:"expiration,omitempty"` // When the session credentials expire, RFC 3339 in UTC
+	File       string `json:"file,omitempty"`       // The credentials file written, if any
+	Outcome    string `json:"outcome,omitempty"`    // Whether a saved section was created or updated
+	Class      string `json:"class,omitempty"`      // The exit class name of a failure
+	Error      string `json:"error,omitempty"`      // The error message of a failure
+}
+
+// validateLogFormat returns a configuration error if the --log-format flag value is not
+// one that we recognize.
+func validateLogFormat() error {
+	if logFormat != logFormatText && logFormat != logFormatJSON {
+		return newConfigError(fmt.Errorf("--log-format must be %s or %s, not %q", logFormatText, logFormatJSON, logFormat))
+	}
+	return nil
+}
+
+// logEvent writes the given record to the structured event log, filling in its timestamp
+// and masking any known secrets in its error, provided that --log-format json was
+// requested. Otherwise it does nothing.
+func logEvent(record logRecord) {
+	if logFormat != logFormatJSON {
+		return
+	}
+	record.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	record.Error = maskSecrets(record.Error)
+	line, _ := json.Marshal(record) // Cannot fail for a struct of strings
+	fmt.Fprintln(logOutput, string(line))
+}
+
+// logTime formats an optional time for a logRecord, returning an empty string if there
+// is no time to format.
+func logTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
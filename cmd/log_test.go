@@ -0,0 +1,107 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the log.go functions.
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLogFormatJSON confirms that a successful save is logged as a series of JSON
+// events on stderr that carry no secrets.
+func TestLogFormatJSON(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { logOutput = os.Stderr }()
+
+	// Configure our child packages to pretend and return happy answers and capture the log
+	mockChildPackages()
+	var log bytes.Buffer
+	logOutput = &log
+
+	// Save with JSON logging
+	executeCommandCapturingStdout("123456", "--save", "--log-format", "json")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// Decode the events and confirm that we got what we expected
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	require.Len(t, lines, 3, "expected three events: %s", log.String())
+	var events []logRecord
+	for _, line := range lines {
+		var record logRecord
+		require.Nil(t, json.Unmarshal([]byte(line), &record), "could not decode the event: %s", line)
+		require.NotEmpty(t, record.Timestamp, "every event should have a timestamp")
+		events = append(events, record)
+	}
+	require.Equal(t, eventAuthAttempt, events[0].Event)
+	require.Equal(t, mfile.DefaultSectionName, events[0].Profile)
+	require.Equal(t, eventAuthSuccess, events[1].Event)
+	require.Equal(t, eventSave, events[2].Event)
+	require.Equal(t, mfile.SessionSectionName, events[2].Profile)
+
+	// Above all, there must be no secrets
+	require.NotContains(t, log.String(), secret, "the secret access key must not be logged")
+	require.NotContains(t, log.String(), token, "the session token must not be logged")
+}
+
+// TestLogFormatJSONFailure confirms that a failure to obtain credentials is logged with its class.
+func TestLogFormatJSONFailure(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { logOutput = os.Stderr }()
+
+	// Configure our child packages to pretend and capture the log, then fail on a flag check
+	mockChildPackages()
+	var log bytes.Buffer
+	logOutput = &log
+	executeCommandCapturingStdout("123456", "--in-place", "--log-format", "json")
+	require.NotNil(t, executeError, "there should have been an error")
+
+	// The last event should be the failure
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	var record logRecord
+	require.Nil(t, json.Unmarshal([]byte(lines[len(lines)-1]), &record), "could not decode the event")
+	require.Equal(t, eventAuthFailure, record.Event)
+	require.Equal(t, exitClassNames[exitConfigError], record.Class)
+	require.Contains(t, record.Error, "--in-place requires --save")
+}
+
+// TestLogFormatText confirms that nothing is logged by default.
+func TestLogFormatText(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { logOutput = os.Stderr }()
+
+	// Configure our child packages to pretend and capture the log
+	mockChildPackages()
+	var log bytes.Buffer
+	logOutput = &log
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, log.String(), "nothing should have been logged")
+}
+
+// TestBadLogFormat confirms that an unknown --log-format value is a configuration error.
+func TestBadLogFormat(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	executeCommandCapturingStdout("123456", "--log-format", "xml")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the logout subcommand.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+)
+
+// logoutCmd represents the logout subcommand
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the profile's saved session from the credentials file",
+	Long: `Removes the -session section saved for the profile, e.g. [work-session] for the work
+profile, from the credentials file, however long its session has left to run, so that
+nothing is left able to use it when you switch contexts or finish work. The profile's
+own section, with its long term credentials and MFA device ID, is left alone. AWS is
+not called; the session itself stays valid until it expires for anyone who already
+holds a copy of it. For example:
+
+   mafia logout --profile work`,
+	Args: cobra.NoArgs,
+
+	// RunE removes the section and says whether there was one
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateLogFormat(); err != nil {
+			return err
+		}
+		if storeName != storeFile {
+			return newConfigError(errors.New("logout only removes sessions from the credentials file, not from --store " + storeName))
+		}
+		path, section := mfile.WritableCredentialsPath(credentialsFilepath()), mfile.SessionSectionNameFor(profile)
+		removed, err := mfile.RemoveSessionSectionFromFile(path, profile)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Fprintf(cmd.OutOrStdout(), "There is no [%s] section in %s\n", section, path)
+			return nil
+		}
+		logEvent(logRecord{Event: eventLogout, Profile: section, File: path})
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed the [%s] section from %s\n", section, path)
+		return nil
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the logout subcommand to the root command
+	rootCmd.AddCommand(logoutCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the logout.go functions.
+
+import (
+	"testing"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+// TestLogout confirms that logout removes the profile's saved session, however long it
+// has to run, leaving the long term credentials alone, and that there may be none.
+func TestLogout(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Save a session, then log out of it
+	mockChildPackages()
+	executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	output := executeCommand("logout")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "Removed the [default-session] section from "+fakeCredentialsFilePath+"\n", output)
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	_, err = cfg.GetSection(mfile.SessionSectionName)
+	require.NotNil(t, err, "the session section should have gone")
+	require.Equal(t, fakeMFADeviceID, cfg.Section(mfile.DefaultSectionName).Key(mfile.MfaDeviceIDKey).Value(), "the default section should have been left alone")
+
+	// Once gone, there is nothing more to remove
+	output = executeCommand("logout")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "There is no [default-session] section in "+fakeCredentialsFilePath+"\n", output)
+
+	// And the keychain is another matter
+	executeCommand("logout", "--store", "keychain")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
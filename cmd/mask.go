@@ -0,0 +1,88 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the masking of secrets in error messages, should a library ever include one.
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+)
+
+const (
+	// What a secret value is replaced by in an error message
+	secretMask = "****"
+
+	// Values shorter than this are not masked, real keys and tokens being far longer and
+	// shorter values being as likely to be ordinary words of the message
+	minMaskedLength = 8
+)
+
+var (
+	// The secret values that this run has come by, to be masked in error messages, held
+	// as Secrets so that forgetSecrets() can wipe them once the run is over
+	knownSecrets []*creds.Secret
+)
+
+// rememberSecrets notes the values of the given session credentials so that they can be
+// masked in any error message that is reported.
+func rememberSecrets(credentials *creds.SessionCredentials) {
+	if credentials == nil {
+		return
+	}
+	if credentials.AccessKeyID != nil {
+		rememberValues(*credentials.AccessKeyID)
+	}
+	rememberValues(credentials.SecretAccessKey.Value(), credentials.SessionToken.Value())
+}
+
+// rememberProfileSecrets notes the long term credentials of the given profile of the
+// credentials file so that they can be masked in any error message that is reported.
+func rememberProfileSecrets(profile string) {
+	keys, err := mfile.GetProfileKeysFromFile(credentialsFilepath(), profile)
+	if err != nil {
+		return
+	}
+	rememberValues(keys[mfile.AccessKeyIDKey], keys[mfile.SecretAccessKeyKey], keys[mfile.SessionTokenKey])
+}
+
+// rememberValues notes the given secret values so that they can be masked in any error
+// message that is reported.
+func rememberValues(values ...string) {
+	for _, value := range values {
+		knownSecrets = append(knownSecrets, creds.NewSecret(value))
+	}
+}
+
+// forgetSecrets wipes the secret values that this run has noted, there being no more
+// error messages to mask them in.
+func forgetSecrets() {
+	for _, secret := range knownSecrets {
+		secret.Wipe()
+	}
+	knownSecrets = nil
+}
+
+// maskSecrets returns the given error message with every known secret value in it, be
+// it one of the session credentials that this run obtained, one of the long term
+// credentials that it used, or one of the credentials in our environment, replaced by a
+// mask. It is a defense in depth measure: neither the AWS SDK nor the ini library is
+// known to include such values in their errors.
+func maskSecrets(message string) string {
+	secrets := []string{os.Getenv(accessKeyIDEnvVar), os.Getenv(secretAccessKeyEnvVar), os.Getenv(sessionTokenEnvVar), os.Getenv(passphraseEnvVar)}
+	for _, secret := range knownSecrets {
+		secrets = append(secrets, secret.Value())
+	}
+	for _, secret := range secrets {
+		if len(secret) >= minMaskedLength {
+			message = strings.ReplaceAll(message, secret, secretMask)
+		}
+	}
+	return message
+}
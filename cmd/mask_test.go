@@ -0,0 +1,65 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the mask.go functions.
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMaskSecrets confirms that known secrets, and those in the environment, are masked
+// wherever they appear in a message, while values too short to be real keys are not.
+func TestMaskSecrets(t *testing.T) {
+	defer forgetSecrets()
+	defer os.Setenv(sessionTokenEnvVar, os.Getenv(sessionTokenEnvVar))
+
+	rememberValues("wJalrXUtnFEMI/K7MDENG", "key", "")
+	os.Setenv(sessionTokenEnvVar, "FwoGZXIvYXdzEBYaDH")
+	message := maskSecrets("signing with wJalrXUtnFEMI/K7MDENG failed for key FwoGZXIvYXdzEBYaDH, wJalrXUtnFEMI/K7MDENG")
+	require.Equal(t, "signing with **** failed for key ****, ****", message)
+
+	// Once forgotten, the values are wiped and no longer masked
+	held := knownSecrets[0]
+	forgetSecrets()
+	require.Empty(t, knownSecrets, "the secrets should have been forgotten")
+	require.Empty(t, held.Value(), "the secret should have been wiped")
+	require.Contains(t, maskSecrets("signing with wJalrXUtnFEMI/K7MDENG failed"), "wJalrXUtnFEMI/K7MDENG")
+}
+
+// TestMaskSecretsInErrors confirms that a long term secret that finds its way into an AWS
+// error is masked in the structured log.
+func TestMaskSecretsInErrors(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { logOutput = os.Stderr }()
+
+	// Have the fake AWS be indiscreet
+	mockChildPackages()
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		return nil, awserr.New("SignatureDoesNotMatch", "bad signature from "+fakeSecretAccessKey, nil)
+	}
+	var log bytes.Buffer
+	logOutput = &log
+	executeCommandCapturingStdout("123456", "--max-retries", "0", "--log-format", "json")
+	require.NotNil(t, executeError, "there should have been an error")
+
+	// The failure should be logged without the secret
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	var record logRecord
+	require.Nil(t, json.Unmarshal([]byte(lines[len(lines)-1]), &record), "could not decode the event")
+	require.Equal(t, eventAuthFailure, record.Event)
+	require.Contains(t, record.Error, "bad signature from ****")
+	require.NotContains(t, log.String(), fakeSecretAccessKey, "the secret access key must not be logged")
+}
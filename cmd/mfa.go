@@ -0,0 +1,97 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the functions that establish which MFA device to authenticate with.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+)
+
+var (
+	// Where the answer to the MFA device selection prompt is read from; unit
+	// tests substitute their own reader for os.Stdin.
+	promptInput io.Reader = os.Stdin
+)
+
+// resolveMFADeviceID returns the MFA device ID / serial number to authenticate with. If
+// given, the --mfa-serial flag value is used; otherwise this is normally read from the
+// named profile section of the AWS credentials file but, if the file does not have one,
+// the --mfa-index flag was given, or envMode is true, the devices registered to the IAM
+// user are listed and one chosen. Unless envMode is true or the --no-cache flag was
+// given, the chosen device is written to the credentials file so that neither the choice
+// nor the IAM call that lists the devices has to be made again next time.
+func resolveMFADeviceID(envMode bool, sourceProfile string) (string, error) {
+
+	// An explicit serial number trumps everything
+	if len(mfaSerial) != 0 {
+		return mfaSerial, nil
+	}
+
+	// Unless we have been told to choose afresh or keep away from the credentials
+	// file, see what the file has to say
+	if mfaIndex == 0 && !envMode {
+		mfaDeviceID, err := mfile.GetProfileMFADeviceIDFromFile(credentialsFilepath(), sourceProfile)
+		if !errors.Is(err, mfile.ErrMFADeviceIDNotFound) {
+			return mfaDeviceID, newConfigError(err)
+		}
+	}
+
+	// Ask AWS what devices the user has and pick one of them
+	mfaDeviceIDs, err := creds.ListMFADeviceIDs()
+	if err != nil {
+		return "", err
+	}
+	mfaDeviceID, err := chooseMFADeviceID(mfaDeviceIDs)
+	if err != nil {
+		return "", err
+	}
+
+	// Remember the choice for next time, if we are allowed to touch the file. Not being
+	// able to do so is no reason not to carry on authenticating but the user should know.
+	if envMode || noCache {
+		return mfaDeviceID, nil
+	}
+	if err = mfile.SaveProfileMFADeviceIDToFile(credentialsFilepath(), sourceProfile, mfaDeviceID); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not record the chosen MFA device ID: %v\n", err)
+	}
+	return mfaDeviceID, nil
+}
+
+// chooseMFADeviceID picks one of the given MFA device IDs, either as directed by the
+// --mfa-index flag, because there is only one to choose from, or by prompting the user.
+func chooseMFADeviceID(mfaDeviceIDs []string) (string, error) {
+
+	// See if we can decide without asking
+	switch {
+	case len(mfaDeviceIDs) == 0:
+		return "", newConfigError(errors.New("no MFA devices are registered to the IAM user"))
+	case mfaIndex < 0 || mfaIndex > len(mfaDeviceIDs):
+		return "", newConfigError(fmt.Errorf("--mfa-index %d is out of range, there are %d MFA devices", mfaIndex, len(mfaDeviceIDs)))
+	case mfaIndex > 0:
+		return mfaDeviceIDs[mfaIndex-1], nil
+	case len(mfaDeviceIDs) == 1:
+		return mfaDeviceIDs[0], nil
+	}
+
+	// Present a numbered list and ask the user to choose
+	fmt.Fprintln(os.Stderr, "Several MFA devices are registered to the IAM user:")
+	for i, mfaDeviceID := range mfaDeviceIDs {
+		fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, mfaDeviceID)
+	}
+	fmt.Fprintf(os.Stderr, "Choose a device [1-%d]: ", len(mfaDeviceIDs))
+	var choice int
+	if _, err := fmt.Fscanln(promptInput, &choice); err != nil || choice < 1 || choice > len(mfaDeviceIDs) {
+		return "", newConfigError(errors.New("no valid MFA device was chosen"))
+	}
+	return mfaDeviceIDs[choice-1], nil
+}
@@ -0,0 +1,156 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the mfa.go functions.
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Two MFA devices for the mock IAM to list
+	fakePhoneMFADeviceID   = "arn:aws:iam::123456789012:mfa/phone"
+	fakeYubikeyMFADeviceID = "arn:aws:iam::123456789012:mfa/yubikey"
+)
+
+// TestMFAIndexChoice confirms that, with no MFA device ID in the credentials file,
+// the --mfa-index flag chooses from the listed devices and the choice is remembered.
+func TestMFAIndexChoice(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Happy mocks, a credentials file without an MFA device, and two devices to choose from
+	mockChildPackages()
+	writeFakeCredentials("")
+	mockMFADevices(fakePhoneMFADeviceID, fakeYubikeyMFADeviceID)
+
+	// Choose the second device
+	executeCommandCapturingStdout("123456", "--mfa-index", "2")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// That choice should now be in the file
+	mfaDeviceID, err := mfile.GetMFADeviceID()
+	require.Nil(t, err, "the MFA device ID should have been saved")
+	require.Equal(t, fakeYubikeyMFADeviceID, mfaDeviceID, "the wrong MFA device was saved")
+}
+
+// TestMFAIndexOutOfRange confirms that an --mfa-index beyond the listed devices is rejected.
+func TestMFAIndexOutOfRange(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Happy mocks, but only two devices to choose from
+	mockChildPackages()
+	mockMFADevices(fakePhoneMFADeviceID, fakeYubikeyMFADeviceID)
+
+	// Ask for a third
+	executeCommand("123456", "--mfa-index", "3")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, "--mfa-index 3 is out of range, there are 2 MFA devices", executeError.Error(), "not the expected error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+}
+
+// TestMFAPromptedChoice confirms that, with several devices and no --mfa-index flag, the
+// user is asked to choose one.
+func TestMFAPromptedChoice(t *testing.T) {
+
+	// Wash the faces of our muddy children and restore stdin before we leave the function
+	defer resetChildPackages()
+	defer func() { promptInput = os.Stdin }()
+
+	// Happy mocks, a credentials file without an MFA device, and two devices to choose from
+	mockChildPackages()
+	writeFakeCredentials("")
+	mockMFADevices(fakePhoneMFADeviceID, fakeYubikeyMFADeviceID)
+
+	// Answer the prompt with the first device
+	promptInput = strings.NewReader("1\n")
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	mfaDeviceID, err := mfile.GetMFADeviceID()
+	require.Nil(t, err, "the MFA device ID should have been saved")
+	require.Equal(t, fakePhoneMFADeviceID, mfaDeviceID, "the wrong MFA device was saved")
+
+	// Now try again, this time with a nonsense answer
+	writeFakeCredentials("")
+	promptInput = strings.NewReader("nonsense\n")
+	executeCommand("123456")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, "no valid MFA device was chosen", executeError.Error(), "not the expected error")
+}
+
+// TestMFASingleDevice confirms that a lone registered device is used without asking.
+func TestMFASingleDevice(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Happy mocks, a credentials file without an MFA device, and one device to find
+	mockChildPackages()
+	writeFakeCredentials("")
+	mockMFADevices(fakePhoneMFADeviceID)
+
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	mfaDeviceID, _ := mfile.GetMFADeviceID()
+	require.Equal(t, fakePhoneMFADeviceID, mfaDeviceID, "the lone MFA device should have been saved")
+}
+
+// TestMFANoCache confirms that --no-cache leaves a discovered device out of the file, so
+// that it is discovered again next time.
+func TestMFANoCache(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Happy mocks, a credentials file without an MFA device, and one device to find
+	mockChildPackages()
+	writeFakeCredentials("")
+	mockMFADevices(fakePhoneMFADeviceID)
+
+	executeCommandCapturingStdout("123456", "--no-cache")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	_, err := mfile.GetMFADeviceID()
+	require.True(t, errors.Is(err, mfile.ErrMFADeviceIDNotFound), "the MFA device should not have been saved")
+}
+
+// TestMFANoDevices confirms that a user with no registered devices is told so.
+func TestMFANoDevices(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Happy mocks, but no MFA device in the file or registered with IAM
+	mockChildPackages()
+	writeFakeCredentials("")
+	mockMFADevices()
+
+	executeCommand("123456")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, "no MFA devices are registered to the IAM user", executeError.Error(), "not the expected error")
+}
+
+// mockMFADevices has the creds package list the given MFA device IDs rather than ask AWS IAM.
+func mockMFADevices(mfaDeviceIDs ...string) {
+	creds.SetListMFADevicesFunc(func(awsService *iam.IAM, input *iam.ListMFADevicesInput) (*iam.ListMFADevicesOutput, error) {
+		output := &iam.ListMFADevicesOutput{IsTruncated: aws.Bool(false)}
+		for _, mfaDeviceID := range mfaDeviceIDs {
+			output.MFADevices = append(output.MFADevices, &iam.MFADevice{SerialNumber: aws.String(mfaDeviceID)})
+		}
+		return output, nil
+	})
+}
@@ -0,0 +1,47 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the choice between a plain session and an assumed role.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mikebway/mafia/mfile"
+)
+
+// The values accepted by the --mode flag
+const (
+	modeAuto    = "auto"    // Assume a role if --role-arn or the profile names one, otherwise obtain a plain session
+	modeSession = "session" // Obtain a plain session with GetSessionToken, insisting that no role is named
+	modeRole    = "role"    // Assume a role with AssumeRole, insisting that one is named
+)
+
+// validateMode returns a configuration error if the --mode flag value is not one that we
+// recognize or, for session and role, if the --role-arn flag and the given role profile,
+// which may be nil, do not call for what the mode insists on.
+func validateMode(roleProfile *mfile.RoleProfile) error {
+	assumesRole := len(roleARN) != 0 || roleProfile != nil
+	switch mode {
+	case modeAuto:
+		return nil
+	case modeSession:
+		if len(roleARN) != 0 {
+			return newConfigError(errors.New("--mode " + modeSession + " cannot be used with --role-arn"))
+		}
+		if assumesRole {
+			return newConfigError(fmt.Errorf("--mode %s cannot be used with the %s profile, which assumes the %s role", modeSession, profile, roleProfile.RoleARN))
+		}
+		return nil
+	case modeRole:
+		if !assumesRole {
+			return newConfigError(fmt.Errorf("--mode %s requires --role-arn or a profile with a role_arn, and the %s profile has none", modeRole, profile))
+		}
+		return nil
+	}
+	return newConfigError(fmt.Errorf("--mode must be %s, %s, or %s, not %q", modeAuto, modeSession, modeRole, mode))
+}
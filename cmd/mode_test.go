@@ -0,0 +1,70 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the mode.go functions.
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMode confirms that --mode auto picks the STS call from the profile and flags, and
+// that session and role refuse to go ahead without what they insist on.
+func TestMode(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(fakeConfigFilePath)
+
+	// Configure our child packages to pretend and return happy answers, counting the
+	// session and the assume role requests
+	mockChildPackages()
+	content := "[profile admin]\nrole_arn = arn:aws:iam::210987654321:role/admin\nsource_profile = default\n"
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+	sessions := countSTSCalls()
+	roles := 0
+	fakeAWS().assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		roles++
+		return &sts.AssumeRoleOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+	}
+
+	// By default, a profile without a role gets a plain session
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 1, *sessions, "GetSessionToken should have been called")
+	require.Equal(t, 0, roles, "AssumeRole should not have been called")
+
+	// And the profile with a role, or --role-arn, assumes it, just as --mode role insists
+	for _, args := range [][]string{
+		{"123456", "--profile", "admin"},
+		{"123456", "--mode", modeRole, "--profile", "admin"},
+		{"123456", "--mode", modeRole, "--role-arn", "arn:aws:iam::210987654321:role/other"},
+	} {
+		roles = 0
+		executeCommandCapturingStdout(args...)
+		require.Nil(t, executeError, "there should not have been an error with %v: %v", args, executeError)
+		require.Equal(t, 1, roles, "AssumeRole should have been called with %v", args)
+	}
+
+	// The explicit modes refuse what they do not call for
+	for _, args := range [][]string{
+		{"123456", "--mode", modeSession, "--profile", "admin"},
+		{"123456", "--mode", modeSession, "--role-arn", "arn:aws:iam::210987654321:role/other"},
+		{"123456", "--mode", modeRole},
+		{"123456", "--mode", "sometimes"},
+	} {
+		*sessions, roles = 0, 0
+		executeCommandCapturingStdout(args...)
+		require.NotNil(t, executeError, "there should have been an error with %v", args)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code with %v", args)
+		require.Equal(t, 0, *sessions+roles, "AWS should not have been called with %v", args)
+	}
+	require.Contains(t, executeError.Error(), `--mode must be auto, session, or role, not "sometimes"`, "not the expected error")
+}
@@ -0,0 +1,28 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the handling of sessions obtained without MFA.
+
+import (
+	"errors"
+)
+
+// validateNoMFA returns a configuration error if --no-mfa was given alongside an MFA code,
+// or any other flag that says how to find or use an MFA device.
+func validateNoMFA(args []string) error {
+	switch {
+	case !noMFA:
+		return nil
+	case len(args) != 0:
+		return newConfigError(errors.New("no MFA code is needed with --no-mfa"))
+	case len(mfaSerial) != 0 || mfaIndex != 0:
+		return newConfigError(errors.New("--no-mfa cannot be used with --mfa-serial or --mfa-index"))
+	case len(samlAssertionFile) != 0:
+		return newConfigError(errors.New("--no-mfa cannot be used with --saml-assertion-file"))
+	}
+	return nil
+}
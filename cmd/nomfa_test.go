@@ -0,0 +1,53 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the nomfa.go functions.
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestNoMFA confirms that --no-mfa obtains a session without an MFA code, asking AWS for
+// one without a serial number or code, even from a profile that has no MFA device ID.
+func TestNoMFA(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, capturing the session request, with no
+	// MFA device in the credentials file
+	mockChildPackages()
+	writeFakeCredentials("")
+	input := mockSTSCapturingInput()
+
+	_, stdout := executeCommandCapturingStdout("--no-mfa")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "export AWS_ACCESS_KEY_ID=key")
+	require.Nil(t, input.SerialNumber, "no MFA serial number should have been sent")
+	require.Nil(t, input.TokenCode, "no MFA code should have been sent")
+	require.Equal(t, int64(3600), *input.DurationSeconds, "the duration should still have been sent")
+}
+
+// TestNoMFAConflicts confirms that --no-mfa is refused alongside anything that would have
+// used an MFA device.
+func TestNoMFAConflicts(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	for _, args := range [][]string{
+		{"123456", "--no-mfa"},
+		{"--no-mfa", "--mfa-serial", "arn:aws:iam::210987654321:mfa/pat"},
+		{"--no-mfa", "--mfa-index", "2"},
+	} {
+		executeCommand(args...)
+		require.NotNil(t, executeError, "there should have been an error for %v", args)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error for %v", args)
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the path subcommand.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// How each of the places that the credentials file path may come from is described
+	pathSourceDescriptions = map[string]string{
+		mfile.PathFromFlag:      "from --credentials-file",
+		mfile.PathFromEnv:       "from the " + mfile.SharedCredentialsFileEnvVar + " environment variable",
+		mfile.PathFromConfigDir: "from the OS specific configuration directory",
+		mfile.PathFromDefault:   "the default",
+	}
+)
+
+// pathCmd represents the path subcommand
+var pathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Display the path of the credentials file in use and whether it can be read",
+	Long: `Displays the fully resolved path of the AWS credentials file, or of each of the
+files if several are listed, after the --credentials-file flag, the
+AWS_SHARED_CREDENTIALS_FILE environment variable, and the defaults have had
+their say, along with where the path came from and whether each file exists
+and can be read. The path of the AWS config file is displayed too. Fails with
+a configuration error if a credentials file is missing or cannot be read.`,
+	Args: cobra.NoArgs,
+
+	// RunE describes the files
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return writeCredentialsPaths(cmd.OutOrStdout())
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the path subcommand to the root command
+	rootCmd.AddCommand(pathCmd)
+}
+
+// writeCredentialsPaths writes the path of the credentials file, with where it came from
+// and the state of each file that it lists, and that of the AWS config file, to the given
+// writer, returning a configuration error if any of the credentials files is unusable.
+func writeCredentialsPaths(w io.Writer) error {
+
+	// Without a home directory, there may be no path at all
+	path, source := mfile.ResolveCredentialsPathWithSource(strings.Join(credentialsFiles, string(os.PathListSeparator)))
+	if len(path) == 0 {
+		return newConfigError(mfile.ErrNoHomeDirectory)
+	}
+
+	// Describe each of the credentials files, noting which is written to
+	fmt.Fprintf(w, "Credentials file: %s (%s)\n", path, pathSourceDescriptions[source])
+	paths := mfile.SplitCredentialsPaths(path)
+	var failed error
+	for i, p := range paths {
+		state, err := fileState(p)
+		if len(paths) > 1 && i == len(paths)-1 {
+			state += ", written to"
+		}
+		fmt.Fprintf(w, "  %s: %s\n", p, state)
+		if err != nil && failed == nil {
+			failed = newConfigError(fmt.Errorf("the credentials file %s %v", p, err))
+		}
+	}
+
+	// And the config file, which need not exist at all
+	if configPath := mfile.ResolveConfigPath(); len(configPath) != 0 {
+		state, _ := fileState(configPath)
+		fmt.Fprintf(w, "Config file: %s\n  %s: %s\n", configPath, configPath, state)
+	}
+	return failed
+}
+
+// fileState describes whether the named file exists and can be read, returning an error
+// saying what is wrong with it if it cannot.
+func fileState(path string) (string, error) {
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		return "does not exist", errors.New("does not exist")
+	case err != nil:
+		return "cannot be examined: " + err.Error(), fmt.Errorf("cannot be examined: %v", err)
+	case info.IsDir():
+		return "is a directory, not a file", errors.New("is a directory, not a file")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "exists but cannot be read: " + err.Error(), fmt.Errorf("cannot be read: %v", err)
+	}
+	file.Close()
+	return "exists and can be read", nil
+}
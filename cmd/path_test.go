@@ -0,0 +1,46 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the path.go functions.
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPath confirms that the resolved credentials file path is displayed with where it
+// came from and whether the files exist, and that a missing file is an error.
+func TestPath(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// The default file, which the fakes have written
+	mockChildPackages()
+	output := executeCommand("path")
+	require.Nil(t, executeError, "there should not have been an error: %v\n%s", executeError, output)
+	require.Contains(t, output, "Credentials file: "+fakeCredentialsFilePath+" (the default)\n")
+	require.Contains(t, output, "  "+fakeCredentialsFilePath+": exists and can be read\n")
+
+	// A second file from the flag, which does not exist, is the one written to
+	missingPath := "./missing.test"
+	output = executeCommand("path", "--credentials-file", fakeCredentialsFilePath, "--credentials-file", missingPath)
+	require.NotNil(t, executeError, "a missing credentials file should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+	require.Contains(t, output, "(from --credentials-file)")
+	require.Contains(t, output, "  "+missingPath+": does not exist, written to\n")
+
+	// A directory is no good either
+	dir, err := ioutil.TempDir("", "mafia-path")
+	require.Nil(t, err, "could not create a temporary directory")
+	defer os.RemoveAll(dir)
+	output = executeCommand("path", "--credentials-file", dir)
+	require.NotNil(t, executeError, "a directory should have been an error")
+	require.Contains(t, output, "  "+dir+": is a directory, not a file\n")
+}
@@ -0,0 +1,48 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the ping subcommand.
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/spf13/cobra"
+)
+
+// pingCmd represents the ping subcommand
+var pingCmd = &cobra.Command{
+	Use:   "ping",
+	Short: "Check that the STS endpoint can be reached, without authenticating",
+	Long: `Sends an unauthenticated request to the STS endpoint that mafia would ask for
+session credentials, through the same proxy, and reports whether and how quickly it
+answered. No credentials or MFA code are needed, so this tells network, proxy, and
+endpoint problems apart from problems with the credentials. The --region, --sts-endpoint,
+--fips, and --proxy flags are honored as they are when authenticating.`,
+	Args: cobra.NoArgs,
+
+	// RunE knocks on the endpoint's door and reports the answer
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := configureCreds(profile); err != nil {
+			return err
+		}
+		result, err := creds.Ping()
+		if err != nil {
+			return fmt.Errorf("could not reach the STS endpoint %s: %w", creds.STSEndpointURL(), err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Reached the STS endpoint %s in %v (HTTP %d)\n", result.Endpoint, result.Latency.Round(time.Millisecond), result.StatusCode)
+		return nil
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the ping subcommand to the root command
+	rootCmd.AddCommand(pingCmd)
+}
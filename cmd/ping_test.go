@@ -0,0 +1,60 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the ping.go functions.
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPing confirms that ping reports an STS endpoint that answers, and that one that
+// does not is a network error.
+func TestPing(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	mockChildPackages()
+	output := executeCommand("ping", "--sts-endpoint", server.URL)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "Reached the STS endpoint "+server.URL+" in ")
+	require.Contains(t, output, "(HTTP 400)")
+
+	server.Close()
+	executeCommand("ping", "--sts-endpoint", server.URL)
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Contains(t, executeError.Error(), "could not reach the STS endpoint "+server.URL)
+	require.Equal(t, exitNetworkError, exitCode, "expected a network error")
+}
+
+// TestPingUserAgent confirms that requests to AWS identify themselves as mafia and its
+// version, unless --user-agent says otherwise.
+func TestPingUserAgent(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	var agent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+	mockChildPackages()
+	executeCommand("ping", "--sts-endpoint", server.URL)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "mafia/"+version, agent, "unexpected default user agent")
+
+	executeCommand("ping", "--sts-endpoint", server.URL, "--user-agent", "build-agent/7")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "build-agent/7", agent, "the user agent should have been overridden")
+}
@@ -0,0 +1,96 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the credential_process output and the cache that spares it from asking for MFA codes.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mikebway/mafia/cache"
+	"github.com/mikebway/mafia/creds"
+)
+
+const (
+	// How many bytes of the --role-arn digest qualify a credential_process cache key
+	processCacheDigestLength = 8
+)
+
+// processCacheKey returns the key that credential_process credentials are cached under:
+// the profile, qualified by a digest of the --role-arn flag value if there is one so
+// that sessions for different roles do not get mixed up.
+func processCacheKey() string {
+	if len(roleARN) == 0 {
+		return profile
+	}
+	digest := sha256.Sum256([]byte(roleARN))
+	return profile + "-" + hex.EncodeToString(digest[:processCacheDigestLength])
+}
+
+// cachedProcessCredentials returns the credentials cached by an earlier
+// --credential-process run for the same profile, if the --credential-process flag was
+// given and they have at least --min-remaining left to run. Otherwise nil is returned.
+func cachedProcessCredentials() *creds.SessionCredentials {
+
+	// Only if we have been asked to, and there is something good in the cache
+	if !credentialProcess {
+		return nil
+	}
+	entry, err := cache.Load(processCacheKey())
+	if err != nil || entry == nil {
+		return nil
+	}
+	credentials := &creds.SessionCredentials{
+		AccessKeyID:     &entry.AccessKeyID,
+		SecretAccessKey: creds.NewSecret(entry.SecretAccessKey),
+		SessionToken:    creds.NewSecret(entry.SessionToken),
+		Expiration:      entry.Expiration,
+	}
+	if credentials.Remaining() < minRemaining {
+		credentials.Wipe()
+		return nil
+	}
+	return credentials
+}
+
+// cacheProcessCredentials caches freshly obtained credentials for later
+// --credential-process runs, if the --credential-process flag was given. Failing to do
+// so costs no more than another MFA code later, so it is only warned about.
+func cacheProcessCredentials(credentials *creds.SessionCredentials) {
+	if !credentialProcess {
+		return
+	}
+	if err := cache.Store(processCacheKey(), processEntry(credentials)); err != nil {
+		fmt.Fprintf(warningOutput, "warning: could not cache the session credentials: %v\n", err)
+	}
+}
+
+// writeProcessCredentials writes the session credentials as the JSON object that the AWS
+// SDKs and CLI expect a credential_process to print, and nothing else.
+func writeProcessCredentials(w io.Writer, credentials *creds.SessionCredentials) error {
+	output, err := json.Marshal(processEntry(credentials))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(output))
+	return err
+}
+
+// processEntry returns the credential_process form of the given session credentials,
+// declaring the Version given by the --process-version flag.
+func processEntry(credentials *creds.SessionCredentials) *cache.Entry {
+	return &cache.Entry{
+		Version:         processVersion,
+		AccessKeyID:     *credentials.AccessKeyID,
+		SecretAccessKey: credentials.SecretAccessKey.Value(),
+		SessionToken:    credentials.SessionToken.Value(),
+		Expiration:      credentials.Expiration,
+	}
+}
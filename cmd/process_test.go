@@ -0,0 +1,133 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the process.go functions.
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikebway/mafia/cache"
+	"github.com/stretchr/testify/require"
+)
+
+// useTempCache points the cache package at a fresh temporary directory, returning a
+// function that removes it.
+func useTempCache(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "mafia-cache")
+	require.Nil(t, err, "could not create a temporary cache directory")
+	cache.SetDir(dir)
+	return func() { os.RemoveAll(dir) }
+}
+
+// TestCredentialProcess confirms that --credential-process displays nothing but the
+// credential_process JSON, and serves later runs from the cache until it expires.
+func TestCredentialProcess(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer useTempCache(t)()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Configure our child packages to pretend and return happy answers for another hour
+	mockChildPackages()
+	expiration = time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	calls := countSTSCalls()
+
+	// The first run needs an MFA code
+	_, stdout := executeCommandCapturingStdout("123456", "--credential-process")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 1, *calls, "AWS should have been called")
+	var entry cache.Entry
+	require.Nil(t, json.Unmarshal([]byte(stdout), &entry), "the output should have been JSON: %s", stdout)
+	require.Equal(t, cache.Entry{Version: 1, AccessKeyID: accessKey, SecretAccessKey: secret, SessionToken: token, Expiration: &expiration}, entry)
+	require.Contains(t, stdout, `"AccessKeyId":"key"`, "the SDK expects AccessKeyId")
+
+	// Later runs do not, nor do they call AWS
+	_, cached := executeCommandCapturingStdout("--credential-process")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 1, *calls, "AWS should not have been called again")
+	require.Equal(t, stdout, cached, "the cached credentials should have been displayed")
+
+	// Unless they have less left to run than we insist on
+	executeCommandCapturingStdout("--credential-process", "--min-remaining", "2h")
+	require.NotNil(t, executeError, "the cached credentials should have been too close to expiry")
+
+	// Another role is another matter
+	executeCommandCapturingStdout("--credential-process", "--role-arn", "arn:aws:iam::123456789012:role/other")
+	require.NotNil(t, executeError, "there should have been no cached credentials for another role")
+	require.Equal(t, exitConfigError, exitCode, "a cache miss should be a configuration error")
+
+	// Saving should not pollute the JSON with a comfort signal
+	_, stdout = executeCommandCapturingStdout("123456", "--credential-process", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Nil(t, json.Unmarshal([]byte(stdout), &entry), "the output should have been nothing but JSON: %s", stdout)
+}
+
+// TestCredentialProcessExpired confirms that expired cached credentials are not served.
+func TestCredentialProcessExpired(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer useTempCache(t)()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Cache credentials that have already expired
+	mockChildPackages()
+	expiration = time.Now().Add(-time.Second)
+	executeCommandCapturingStdout("123456", "--credential-process")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// Without an MFA code, there is nothing to be had
+	executeCommandCapturingStdout("--credential-process")
+	require.NotNil(t, executeError, "expired credentials should not have been served")
+	require.Contains(t, executeError.Error(), "no cached session credentials")
+}
+
+// TestProcessVersion confirms that --json is another name for --credential-process, that
+// the JSON keeps the SDK's field names, and that its Version can be overridden.
+func TestProcessVersion(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer useTempCache(t)()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+	expiration = time.Date(2030, time.April, 1, 12, 0, 0, 0, time.UTC)
+
+	_, stdout := executeCommandCapturingStdout("123456", "--json")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, `{"Version":1,"AccessKeyId":"key","SecretAccessKey":"secret","SessionToken":"token","Expiration":"2030-04-01T12:00:00Z"}`+"\n", stdout)
+
+	_, stdout = executeCommandCapturingStdout("123456", "--json", "--process-version", "2")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.True(t, strings.HasPrefix(stdout, `{"Version":2,`), "the Version should have been overridden: %s", stdout)
+
+	executeCommandCapturingStdout("123456", "--json", "--process-version", "0")
+	require.NotNil(t, executeError, "a zero version should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
+
+// TestCredentialProcessConflicts confirms that --credential-process will not share stdout.
+func TestCredentialProcessConflicts(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer useTempCache(t)()
+
+	mockChildPackages()
+	for _, args := range [][]string{{"--export"}, {"--format", "{{.AccessKeyID}}"}} {
+		executeCommandCapturingStdout(append([]string{"123456", "--credential-process"}, args...)...)
+		require.NotNil(t, executeError, "%v should have been rejected", args)
+		require.Equal(t, exitConfigError, exitCode, "the conflict should be a configuration error")
+	}
+}
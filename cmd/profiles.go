@@ -0,0 +1,133 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the profiles subcommand.
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+)
+
+// profilesCmd represents the profiles subcommand
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List the profiles in the credentials file and their MFA status",
+	Long: `Lists every profile section of the credentials file, noting which have an MFA
+device ID configured, which have a saved session that has not yet expired, and
+which hold nothing but long term credentials. Session sections are reported
+against the profile that they belong to rather than listed separately; with
+--trim-session-suffix, even those whose profile is not in the file are listed
+under the profile name, without the -session suffix. AWS is not called.`,
+	Args: cobra.NoArgs,
+
+	// RunE takes the inventory
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listProfiles(cmd.OutOrStdout())
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the profiles subcommand to the root command
+	rootCmd.AddCommand(profilesCmd)
+}
+
+// listProfiles writes a line for each profile in the credentials file to the given
+// writer, describing its MFA and session status.
+func listProfiles(w io.Writer) error {
+
+	// Find out what sections there are
+	path := credentialsFilepath()
+	names, err := mfile.GetProfileNamesFromFile(path)
+	if err != nil {
+		return newConfigError(err)
+	}
+
+	// Session sections are reported with their profiles, if they have one
+	isSession := make(map[string]bool, len(names))
+	for _, name := range names {
+		isSession[mfile.SessionSectionNameFor(name)] = true
+	}
+
+	// Describe each profile in a neat table. With --trim-session-suffix, a session section
+	// without a profile of its own is shown under the name of the profile that it is for.
+	tw, paint := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0), painterFor(w)
+	for _, name := range names {
+		if isSession[name] {
+			continue
+		}
+		displayName, status := name, ""
+		if profile := mfile.ProfileNameForSection(name); trimSessionSuffix && profile != name {
+			displayName = profile
+			status, err = sessionStatus(path, profile, paint)
+		} else {
+			status, err = profileStatus(path, name, paint)
+		}
+		if err != nil {
+			return newConfigError(err)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", displayName, status)
+	}
+	return tw.Flush()
+}
+
+// sessionStatus describes the session saved for the named profile in the given credentials
+// file, e.g. "session active until 2020-04-01T13:00:00Z", painted green if it is active and
+// red if it has expired, or returns an empty string if there is none.
+func sessionStatus(path, name string, paint painter) (string, error) {
+	saved, err := mfile.GetSavedSessionFromFile(path, &mfile.SaveOptions{Profile: name, KeyNames: saveOptions().KeyNames})
+	if err != nil || saved == nil {
+		return "", err
+	}
+	switch {
+	case saved.Expiration == nil:
+		return "session saved, expiration unknown", nil
+	case time.Now().Before(*saved.Expiration):
+		return paint(colorGreen, "session active until "+saved.Expiration.UTC().Format(time.RFC3339)), nil
+	}
+	return paint(colorRed, "session expired"), nil
+}
+
+// profileStatus describes the MFA and session status of the named profile in the given
+// credentials file, e.g. "mfa_device_id, session active until 2020-04-01T13:00:00Z", with
+// the session's status painted as by sessionStatus(..).
+func profileStatus(path, name string, paint painter) (string, error) {
+
+	// Does the profile have an MFA device?
+	var marks []string
+	keys, err := mfile.GetProfileKeysFromFile(path, name)
+	if err != nil {
+		return "", err
+	}
+	if len(keys[mfile.MfaDeviceIDKey]) != 0 {
+		marks = append(marks, mfile.MfaDeviceIDKey)
+	} else if len(keys[mfile.MfaSerialKey]) != 0 {
+		marks = append(marks, mfile.MfaSerialKey)
+	}
+
+	// And a saved session that is still good?
+	session, err := sessionStatus(path, name, paint)
+	if err != nil {
+		return "", err
+	}
+	if len(session) != 0 {
+		marks = append(marks, session)
+	}
+
+	// If neither, it can only be plain long term credentials
+	if len(marks) == 0 {
+		return "long term only", nil
+	}
+	return strings.Join(marks, ", "), nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the profiles.go functions.
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+// TestProfiles confirms that each profile is listed with its MFA and session status, and
+// that session sections are folded into their profiles.
+func TestProfiles(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Build a credentials file with a profile of every kind
+	mockChildPackages()
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not load the fake credentials file")
+	addSession := func(name string, expiration time.Time) {
+		section, _ := cfg.NewSection(name)
+		section.NewKey(mfile.AccessKeyIDKey, accessKey)
+		section.NewKey(mfile.SecretAccessKeyKey, secret)
+		section.NewKey(mfile.SessionTokenKey, token)
+		section.NewKey(mfile.SessionExpirationKey, expiration.UTC().Format(time.RFC3339))
+	}
+	active := time.Now().Add(time.Hour).Truncate(time.Second)
+	addSession(mfile.SessionSectionName, active)
+	stale, _ := cfg.NewSection("stale")
+	stale.NewKey(mfile.MfaDeviceIDKey, fakeMFADeviceID)
+	addSession("stale-session", time.Now().Add(-time.Hour))
+	plain, _ := cfg.NewSection("plain")
+	plain.NewKey(mfile.AccessKeyIDKey, fakeAccessKeyID)
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "could not write the fake credentials file")
+
+	// List them
+	output := executeCommand("profiles")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 3, "expected three profiles: %s", output)
+	require.Regexp(t, `^default +mfa_device_id, session active until `+active.UTC().Format(time.RFC3339)+`$`, lines[0])
+	require.Regexp(t, `^stale +mfa_device_id, session expired$`, lines[1])
+	require.Regexp(t, `^plain +long term only$`, lines[2])
+}
+
+// TestProfilesTrimSessionSuffix confirms that a session section without a profile of its
+// own is listed under its real name unless --trim-session-suffix is given.
+func TestProfilesTrimSessionSuffix(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Build a credentials file with a session whose profile lives elsewhere
+	mockChildPackages()
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not load the fake credentials file")
+	active := time.Now().Add(time.Hour).Truncate(time.Second)
+	section, _ := cfg.NewSection("work-session")
+	section.NewKey(mfile.AccessKeyIDKey, accessKey)
+	section.NewKey(mfile.SecretAccessKeyKey, secret)
+	section.NewKey(mfile.SessionTokenKey, token)
+	section.NewKey(mfile.SessionExpirationKey, active.UTC().Format(time.RFC3339))
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "could not write the fake credentials file")
+
+	// Without the flag the section is listed as it is
+	output := executeCommand("profiles")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Regexp(t, `(?m)^work-session +`, output)
+
+	// With it, the session is shown for the work profile
+	output = executeCommand("profiles", "--trim-session-suffix")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Regexp(t, `(?m)^work +session active until `+active.UTC().Format(time.RFC3339)+`$`, output)
+	require.NotContains(t, output, "work-session", "the suffix should have been trimmed")
+}
+
+// TestProfilesMissingFile confirms that a missing credentials file is reported as such.
+func TestProfilesMissingFile(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mfile.OverrideDefaultCredentialsFilepath("./missing.test")
+	executeCommand("profiles")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
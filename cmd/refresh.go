@@ -0,0 +1,64 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the handling of --refresh-existing, which renews whichever session is active.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+)
+
+// resolveRefreshExisting, if --refresh-existing was given, finds the one session section
+// of the credentials file holding an active session, as told by its aws_session_token and
+// its expiration, and points the --profile and --save flags at it so that a new session
+// is obtained for its profile and written over it. A session saved with --in-place cannot
+// be found this way since its section no longer holds the long term credentials needed
+// to obtain another.
+func resolveRefreshExisting(cmd *cobra.Command) error {
+	if !refreshExisting {
+		return nil
+	}
+	if cmd.Flags().Changed("profile") || inPlace || reuse {
+		return newConfigError(errors.New("--refresh-existing finds the profile for itself, so cannot be used with --profile, --in-place, or --reuse"))
+	}
+
+	// Look through the session sections, in the order that the file has them
+	path := credentialsFilepath()
+	names, err := mfile.GetProfileNamesFromFile(path)
+	if err != nil {
+		return newConfigError(err)
+	}
+	var active []string
+	for _, name := range names {
+		candidate := mfile.ProfileNameForSection(name)
+		if candidate == name {
+			continue
+		}
+		saved, err := mfile.GetSavedSessionFromFile(path, &mfile.SaveOptions{Profile: candidate, KeyNames: saveOptions().KeyNames})
+		if err != nil {
+			return newConfigError(err)
+		}
+		if saved != nil && (saved.Expiration == nil || time.Now().Before(*saved.Expiration)) {
+			active = append(active, candidate)
+		}
+	}
+
+	// There must be exactly one to refresh
+	switch len(active) {
+	case 0:
+		return newConfigError(fmt.Errorf("--refresh-existing found no active session in %s", path))
+	case 1:
+		profile, saveCredentials = active[0], true
+		return nil
+	}
+	return newConfigError(fmt.Errorf("--refresh-existing found active sessions for the %s profiles in %s; refresh one with --save --profile", strings.Join(active, ", "), path))
+}
@@ -0,0 +1,77 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the refresh.go functions.
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRefreshCredentials writes a fake credentials file with default and work profiles,
+// the given session sections following them.
+func writeRefreshCredentials(t *testing.T, sessions ...string) {
+	longTerm := "aws_access_key_id = " + fakeAccessKeyID + "\naws_secret_access_key = " + fakeSecretAccessKey + "\nmfa_device_id = " + fakeMFADeviceID + "\n"
+	content := "[default]\n" + longTerm + "\n[work]\n" + longTerm
+	for _, section := range sessions {
+		content += "\n[" + section + "]\naws_access_key_id = old\naws_secret_access_key = old\naws_session_token = old\n" +
+			mfile.SessionExpirationKey + " = " + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + "\n"
+	}
+	require.Nil(t, ioutil.WriteFile(fakeCredentialsFilePath, []byte(content), 0600), "could not write the fake credentials file")
+}
+
+// TestRefreshExisting confirms that --refresh-existing saves the new session over the one
+// active session section, for its own profile.
+func TestRefreshExisting(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	writeRefreshCredentials(t, "work-session")
+	output, _ := executeCommandCapturingStdout("123456", "--refresh-existing")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, output, "there should not have been any help output: %s", output)
+	saved, err := mfile.GetSavedSessionFromFile(fakeCredentialsFilePath, &mfile.SaveOptions{Profile: "work"})
+	require.Nil(t, err, "could not read the saved session")
+	require.Equal(t, accessKey, saved.AccessKeyID, "the work session should have been refreshed")
+	content, _ := ioutil.ReadFile(fakeCredentialsFilePath)
+	require.False(t, strings.Contains(string(content), "[default-session]"), "no other session should have been saved")
+}
+
+// TestRefreshExistingProblems confirms that --refresh-existing needs exactly one active
+// session, and does not take a profile of its own.
+func TestRefreshExistingProblems(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	for sessions, expected := range map[string]string{
+		"":                             "found no active session",
+		"default-session,work-session": "found active sessions for the default, work profiles",
+	} {
+		names := []string{}
+		if len(sessions) != 0 {
+			names = strings.Split(sessions, ",")
+		}
+		writeRefreshCredentials(t, names...)
+		executeCommandCapturingStdout("123456", "--refresh-existing")
+		require.NotNil(t, executeError, "there should have been an error for %q", sessions)
+		require.Contains(t, executeError.Error(), expected)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+	}
+
+	writeRefreshCredentials(t, "work-session")
+	executeCommandCapturingStdout("123456", "--refresh-existing", "--profile", "work")
+	require.NotNil(t, executeError, "--profile should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
@@ -0,0 +1,83 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the remaining subcommand.
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/spf13/cobra"
+)
+
+// remainingCmd represents the remaining subcommand
+var remainingCmd = &cobra.Command{
+	Use:   "remaining",
+	Short: "Print how long the saved session has left to run, for use in a shell prompt",
+	Long: `Prints how long the session saved for the profile has left to run in a compact
+form, e.g. 1h05m, 59m, or 30s, and nothing at all if there is no saved session or it
+has expired. Only the saved expiration time is read and AWS is not called, so it is
+cheap enough to run every time that a shell prompt is drawn. For example, in .bashrc:
+
+   PS1='[aws $(mafia remaining)] \$ '`,
+	Args: cobra.NoArgs,
+
+	// RunE prints the time remaining, if any. Being meant for a prompt, it stays quiet
+	// rather than report a file that cannot be read.
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if expiration := savedExpiration(); expiration != nil {
+			session := &creds.SessionCredentials{Expiration: expiration}
+			if remaining := compactDuration(session.Remaining()); len(remaining) != 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), remaining)
+			}
+		}
+		return nil
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the remaining subcommand to the root command
+	rootCmd.AddCommand(remainingCmd)
+}
+
+// savedExpiration returns the expiration time of the session saved for the profile, in the
+// credentials file or the keychain, or nil if there is no saved session, it has no known
+// expiration, or it cannot be read.
+func savedExpiration() *time.Time {
+	if storeName == storeKeychain {
+		saved := loadFromKeychain()
+		if saved == nil {
+			return nil
+		}
+		defer saved.Wipe()
+		return saved.Expiration
+	}
+	if saved, err := mfile.GetSavedSessionFromFile(credentialsFilepath(), saveOptions()); err == nil && saved != nil {
+		return saved.Expiration
+	}
+	return nil
+}
+
+// compactDuration returns the given duration, rounded down, in as few characters as
+// will do: hours and minutes, minutes alone, or seconds alone. An empty string is
+// returned for a duration that is not positive.
+func compactDuration(d time.Duration) string {
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d >= time.Second:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	default:
+		return ""
+	}
+}
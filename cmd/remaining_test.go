@@ -0,0 +1,65 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the remaining.go functions.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRemaining confirms that the time left on the saved session is printed, and nothing
+// once there is none left.
+func TestRemaining(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Nothing saved, nothing printed
+	mockChildPackages()
+	output := executeCommand("remaining")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, output, "there should have been nothing to print")
+
+	// Save a session that is good for another hour and a half, give or take
+	expiration = time.Now().Add(90*time.Minute + 30*time.Second)
+	executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	calls := countSTSCalls()
+	output = executeCommand("remaining")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "1h30m\n", output, "unexpected time remaining")
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+
+	// Once expired, nothing again
+	expiration = time.Now().Add(-time.Minute)
+	executeCommandCapturingStdout("123456", "--save")
+	output = executeCommand("remaining")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, output, "an expired session should have printed nothing")
+
+	// A missing file is no more than nothing to report
+	output = executeCommand("remaining", "--credentials-file", "./missing.test")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Empty(t, output, "a missing file should have printed nothing")
+}
+
+// TestCompactDuration confirms that durations are trimmed to fit a prompt.
+func TestCompactDuration(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		2*time.Hour + 5*time.Minute + 59*time.Second: "2h05m",
+		59*time.Minute + 59*time.Second:              "59m",
+		time.Minute:                                  "1m",
+		30 * time.Second:                             "30s",
+		500 * time.Millisecond:                       "",
+		-time.Hour:                                   "",
+	} {
+		require.Equal(t, expected, compactDuration(d), "unexpected form of %v", d)
+	}
+}
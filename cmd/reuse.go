@@ -0,0 +1,64 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the functions that reuse previously saved session credentials.
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+)
+
+const (
+	// How long saved or cached session credentials must still have to run to be
+	// considered worth reusing, unless the --min-remaining flag says otherwise
+	defaultMinRemaining = 5 * time.Minute
+)
+
+// reusableSessionCredentials returns the session credentials saved to the credentials
+// file, or the secret store selected by --store, if the --reuse flag was given and they
+// have at least --min-remaining left to run. Otherwise, including when there is no saved
+// session, or it did not record its expiration, nil is returned and fresh credentials
+// must be obtained from AWS.
+func reusableSessionCredentials() *creds.SessionCredentials {
+
+	// Only if we have been asked to
+	if !reuse {
+		return nil
+	}
+
+	// See what the secret store or, if there is one to look in, the file has to offer
+	var credentials *creds.SessionCredentials
+	if storeName == storeKeychain {
+		credentials = loadFromKeychain()
+	} else if !useEnvironmentCredentials() {
+		saved, err := mfile.GetSavedSessionFromFile(credentialsFilepath(), saveOptions())
+		if err == nil && saved != nil && len(saved.SessionToken) != 0 {
+			credentials = &creds.SessionCredentials{
+				AccessKeyID:     &saved.AccessKeyID,
+				SecretAccessKey: creds.NewSecret(saved.SecretAccessKey),
+				SessionToken:    creds.NewSecret(saved.SessionToken),
+				Expiration:      saved.Expiration,
+			}
+		}
+	}
+	if credentials == nil || credentials.Expiration == nil || credentials.Remaining() < minRemaining {
+		return nil
+	}
+	return credentials
+}
+
+// validateMinRemaining returns a configuration error if the --min-remaining flag value
+// makes no sense.
+func validateMinRemaining() error {
+	if minRemaining < 0 {
+		return newConfigError(fmt.Errorf("--min-remaining cannot be negative: %v", minRemaining))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the reuse.go functions.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReuse confirms that --reuse leaves AWS alone, and the credentials file untouched,
+// while the saved session is good for a while yet.
+func TestReuse(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Save a session that is good for another hour
+	mockChildPackages()
+	expiration = time.Now().Add(time.Hour).Truncate(time.Second)
+	_, stdout := executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "Session credentials saved to file")
+
+	// Count the calls to AWS from now on
+	calls := countSTSCalls()
+
+	// Reusing the session should not call AWS nor rewrite the file
+	_, stdout = executeCommandCapturingStdout("123456", "--save", "--reuse")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+	require.NotContains(t, stdout, "Session credentials saved to file", "nothing should have been saved")
+
+	// Without --reuse, AWS is asked again but, since it gives the same answer, the file is left alone
+	_, stdout = executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 1, *calls, "AWS should have been called")
+	require.NotContains(t, stdout, "Session credentials saved to file", "nothing should have been saved")
+}
+
+// TestReuseExpiring confirms that --reuse goes back to AWS when the saved session is
+// about to expire.
+func TestReuseExpiring(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Save a session that is nearly done
+	mockChildPackages()
+	expiration = time.Now().Add(time.Minute).Truncate(time.Second)
+	executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// Reusing the session should be declined
+	calls := countSTSCalls()
+	executeCommandCapturingStdout("123456", "--save", "--reuse")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 1, *calls, "AWS should have been called")
+}
+
+// TestMinRemaining confirms that --min-remaining sets how long a saved session must have
+// left to run for --reuse to hand it back.
+func TestMinRemaining(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Save a session with eight minutes left to run
+	mockChildPackages()
+	expiration = time.Now().Add(8 * time.Minute).Truncate(time.Second)
+	executeCommandCapturingStdout("123456", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+
+	// That is good enough by default, but not when we ask for ten minutes
+	calls := countSTSCalls()
+	executeCommandCapturingStdout("123456", "--save", "--reuse")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+	executeCommandCapturingStdout("123456", "--save", "--reuse", "--min-remaining", "10m")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 1, *calls, "AWS should have been called")
+
+	// Negative durations make no sense
+	executeCommandCapturingStdout("123456", "--reuse", "--min-remaining", "-1m")
+	require.NotNil(t, executeError, "a negative --min-remaining should have been rejected")
+	require.Equal(t, exitConfigError, exitCode, "that should be a configuration error")
+}
+
+// countSTSCalls mocks the STS GetSessionToken(..) call to return the standard fake
+// credentials, counting how many times it is called.
+func countSTSCalls() *int {
+	calls := 0
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		calls++
+		return getSessionTokenOutput, nil
+	}
+	return &calls
+}
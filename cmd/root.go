@@ -8,19 +8,196 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/mikebway/mafia/cache"
 	"github.com/mikebway/mafia/creds"
 	"github.com/mikebway/mafia/mfile"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
 	unitTesting  = false // Set to true when running unit tests
 	executeError error   // The error value obtained by Execute(), captured for unit test purposes
+	exitCode     int     // The exit code derived from executeError, captured for unit test purposes
 
 	saveCredentials = false // True if update the $HOME/.aws/credentials file with the session credentionals obtained
+	inPlace         bool    // True to save the session credentials over the long term credentials in the default section
+	backup          bool    // True to back up the credentials file before overwriting the long term credentials
+	noBackup        bool    // True to override backup, since there is no other way to turn off a flag that defaults to true
+	copySettings    bool    // True to copy the profile's other settings, such as region, into the session section
+	showDiff        bool    // True to display the changes to the session section before saving them
+	roleARN         string  // The ARN of an IAM role to assume, if any
+	roleSessionName string  // The role session name to be recorded by CloudTrail when assuming a role
+	externalID      string  // The external ID demanded by the trust policy of the role to be assumed, if any
+	region          string  // The AWS region whose regional STS endpoint is to be called
+	stsEndpoint     string  // The URL of an STS endpoint to use in place of the standard AWS one
+	proxy           string  // The URL of a proxy to reach AWS through, overriding the proxy environment variables
+	mfaIndex        int     // The 1-based index of the MFA device to choose from those registered to the IAM user
+	mfaSerial       string  // An MFA device ID / serial number to use in place of any in the credentials file
+	noCache         bool    // True to not record a discovered MFA device ID in the credentials file
+	fromEnv         bool    // True to use long term credentials from the environment rather than the credentials file
+	maxRetries      int     // The number of times to retry STS requests that are throttled or fail with a server error
+	outputFile      string  // The path of a file to write the displayed credentials to in place of stdout
+	outputFD        int     // An inherited file descriptor to write the displayed credentials to in place of stdout
+	outputFIFO      string  // The path of a named pipe to write the displayed credentials to in place of stdout
+	reuse           bool    // True to reuse saved session credentials that have not yet expired
+	profile         string  // The credentials file section holding the long term credentials and MFA device ID
+	formatTemplate  string  // A text/template to render the session credentials through in place of the standard display
+	logFormat       string  // The format of the structured event log written to stderr, text meaning none
+
+	// How long the session credentials are to remain valid, whether to warn if AWS grants
+	// less than that, and the flag that says so
+	duration     time.Duration
+	verbose      bool
+	durationFlag *pflag.Flag // Consulted to tell whether --duration was given
+
+	// Whether to leave the STS endpoint to decide whether the duration is acceptable
+	noDurationCheck bool
+
+	// Whether to prove that new session credentials work before saving or displaying them
+	verify bool
+
+	// The conventions of credentials and config files that do not follow the usual
+	// key = value layout
+	iniDelimiters         string // The characters that may separate a key from its value
+	iniWriteDelimiter     string // The character that new key lines are written with
+	iniNoSpaces           bool   // True to write new key lines without spaces around the delimiter
+	iniSpaceBeforeComment bool   // True to only treat # and ; as beginning a comment after whitespace
+
+	// How long saved or cached session credentials must have left to run to be reused
+	minRemaining time.Duration
+
+	// How long the session credentials must have left to run for us to succeed
+	requireValidUntil time.Duration
+
+	// Where session credentials are saved to and reused from: the file or the keychain
+	storeName string
+
+	// Whether to display nothing but the statements that set the session credentials in
+	// the environment, the shell that those statements are written for, and the prefix
+	// of the environment variable names
+	export    bool
+	shell     string
+	envPrefix string
+
+	// Whether to display the credentials as a credential_process must, serving them from
+	// the cache while they last, and the Version that the JSON declares
+	credentialProcess bool
+	processVersion    int
+
+	// The file holding a SAML assertion to assume the --role-arn role with, and the ARN of
+	// the SAML provider that issued it
+	samlAssertionFile string
+	principalARN      string
+
+	// True to build the default role session name from the hostname rather than the IAM
+	// username, and how we learn the hostname; unit tests substitute their own function
+	sessionNameFromHostname bool
+	hostnameFunc            = os.Hostname
+
+	// True to remove the command line holding the MFA code from the shell history file
+	scrubHistory bool
+
+	// True to leave the displays meant for a person uncolored, even on a terminal
+	noColor bool
+
+	// True to write everything meant for a person, rather than the shell or the SDK, to stderr
+	humanToStderr bool
+
+	// True to list session sections without profiles of their own under the profile name
+	trimSessionSuffix bool
+
+	// True to include the keys and tokens of the sessions in the export-sessions output
+	includeSecrets bool
+
+	// True to save over whichever session section holds the one active session
+	refreshExisting bool
+
+	// Whether to assume a role or obtain a plain session: auto to decide by --role-arn and
+	// the profile, session or role to insist on one
+	mode string
+
+	// The product token that requests to AWS identify themselves with in their User-Agent
+	userAgent string
+
+	// True to show expiration times in UTC rather than on the local clock
+	utc bool
+
+	// A command to run through the shell with the session credentials in its environment
+	execCommand string
+
+	// True to only partly show the secret access key and session token that are displayed
+	redact bool
+
+	// True to add the matching ~/.aws/config stanza to the standard display
+	configSnippet bool
+
+	// True to save the session token encrypted with the MAFIA_PASSPHRASE passphrase
+	encrypt bool
+
+	// True to serve the session credentials as an ECS credential provider endpoint
+	serveECS bool
+
+	// True to obtain a session with the long term credentials alone, without MFA
+	noMFA bool
+
+	// True to take the long term identity from the AWS SSO role of the profile, as cached
+	// by aws sso login, rather than from the credentials file
+	sso bool
+
+	// True to wait for, and try, the next generated MFA code if AWS says that one was used
+	waitForNext bool
+
+	// True to have the import-serial subcommand read the AWS CLI's mfa_serial
+	fromCLI bool
+
+	// The otpauth URL holding the virtual MFA device secret that the configure subcommand stores
+	otpauthURL string
+
+	// True to call the FIPS validated STS endpoint of the region
+	fips bool
+
+	// The path of the file holding per-profile flag defaults, if not ~/.mafia.yaml
+	defaultsFile string
+
+	// The paths of the AWS credentials files, overriding all other ways of finding them
+	credentialsFiles []string
+
+	// The names of the keys that saved session credentials are written under
+	accessKeyName    string
+	secretKeyName    string
+	sessionTokenName string
+	expirationName   string
+)
+
+const (
+	// The environment variable that may name an STS endpoint when the --sts-endpoint flag is not given
+	stsEndpointEnvVar = "AWS_STS_ENDPOINT"
+
+	// The environment variable that may name the profile to use when the --profile flag is not given
+	defaultProfileEnvVar = "MAFIA_DEFAULT_PROFILE"
+
+	// The environment variables that may name the AWS region when the --region flag is not
+	// given, in order of precedence
+	regionEnvVar        = "AWS_REGION"
+	defaultRegionEnvVar = "AWS_DEFAULT_REGION"
+
+	// The environment variables that hold long term AWS credentials, and the session
+	// token that accompanies session credentials
+	accessKeyIDEnvVar     = "AWS_ACCESS_KEY_ID"
+	secretAccessKeyEnvVar = "AWS_SECRET_ACCESS_KEY"
+	sessionTokenEnvVar    = "AWS_SESSION_TOKEN"
+
+	// The environment variable that tells the AWS CLI and SDKs which profile to use
+	profileEnvVar = "AWS_PROFILE"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -38,24 +215,164 @@ aws_secret_access_key values, as follows:
    mfa_device_id = arn:aws:iam::999999999999:mfa/jane
 
 Replacing 999999999999 with your account number, and jane with your username.
+
+Alternatively, the --from-env flag takes the long term credentials from the
+AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables, with the MFA
+device given by --mfa-serial, and does not touch the ~/.aws/credentials file at
+all. This happens automatically if there is no credentials file but those
+environment variables are set.
+
+If you do not, and your IAM user is permitted to list its MFA devices, mafia will
+find the device for you and add it to the file. Where you have several devices,
+you will be asked to choose one, or can do so with the --mfa-index flag.
 `,
 
-	SilenceUsage:  true, // Only display help when explicitly requested, not on error
-	SilenceErrors: true, // Only display errors once (helpful when using RunE rathr than Run)
+	Args:          cobra.ArbitraryArgs, // The MFA token is an argument, not a subcommand
+	SilenceUsage:  true,                // Only display help when explicitly requested, not on error
+	SilenceErrors: true,                // Only display errors once (helpful when using RunE rathr than Run)
+
+	// PersistentPreRunE fills in the flags that were not given, for this command and every
+	// subcommand, from the profile's defaults in the ~/.mafia.yaml file
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyProfileDefaults(cmd); err != nil {
+			return err
+		}
+		return applyFileFormat()
+	},
 
 	// RunE is called after the command line has been successfully parsed if no sub-command
 	// has been specified. The 'E' indicates that an error (or nil) shall be returned; this
 	// cariation of Run is chosen to facilitate unit testing.
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		// If no MFA code was provided or help was requested, display the help
-		if len(args) != 1 || args[0] == "help" {
+		// If no MFA code was provided or help was requested, display the help. Only a
+		// credential_process, hoping that the cache can serve it, a profile that can
+		// generate its own MFA codes, a SAML assertion, or --no-mfa may go without.
+		if len(args) > 1 || (len(args) == 1 && args[0] == "help") || (len(args) == 0 && !credentialProcess && !hasTOTPSecret() && len(samlAssertionFile) == 0 && !noMFA) {
 			return cmd.Help()
 		}
 
-		// Do the work!
-		credentials, err := fetchSessionCredentials(args[0])
-		if err != nil {
+		// Make sure that we know how to log what happens and, before spending the MFA
+		// code, how to display the results
+		if err := validateLogFormat(); err != nil {
+			return err
+		}
+		if _, err := parseFormatTemplate(); err != nil {
+			return err
+		}
+		if err := validateShell(); err != nil {
+			return err
+		}
+		if err := validatePrefix(); err != nil {
+			return err
+		}
+		if err := validateMinRemaining(); err != nil {
+			return err
+		}
+		if err := validateRequireValidUntil(); err != nil {
+			return err
+		}
+		if err := validateStore(); err != nil {
+			return err
+		}
+		if err := validateEncrypt(); err != nil {
+			return err
+		}
+		if err := validateScrubHistory(); err != nil {
+			return err
+		}
+		if err := validateSAML(args); err != nil {
+			return err
+		}
+		if err := validateNoMFA(args); err != nil {
+			return err
+		}
+		if err := validateOutput(); err != nil {
+			return err
+		}
+		if err := validateExec(); err != nil {
+			return err
+		}
+		if err := validateECSServer(); err != nil {
+			return err
+		}
+		if err := validateSSO(); err != nil {
+			return err
+		}
+		if export && len(formatTemplate) != 0 {
+			return newConfigError(errors.New("--export and --format cannot be used together"))
+		}
+		if credentialProcess && (export || len(formatTemplate) != 0) {
+			return newConfigError(errors.New("--credential-process cannot be used with --export or --format"))
+		}
+		if configSnippet && (export || credentialProcess || len(formatTemplate) != 0) {
+			return newConfigError(errors.New("--config-snippet only applies to the standard display, not to --export, --credential-process, or --format"))
+		}
+		if redact && credentialProcess {
+			return newConfigError(errors.New("--redact cannot be used with --credential-process, whose output the SDK must be able to use"))
+		}
+		if processVersion < 1 {
+			return newConfigError(fmt.Errorf("--process-version must be at least 1, not %d", processVersion))
+		}
+
+		// Find the session to refresh, if asked to
+		if err := resolveRefreshExisting(cmd); err != nil {
+			return err
+		}
+
+		// The MFA code on the command line is no secret for long, but need not linger
+		if scrubHistory && len(args) == 1 {
+			scrubMFACodeFromHistory(args[0])
+		}
+
+		// Unless we can reuse a saved session that is still good, do the work!
+		var err error
+		credentials := reusableSessionCredentials()
+		if credentials == nil {
+			credentials = cachedProcessCredentials()
+		}
+		defer func() {
+			if credentials != nil {
+				credentials.Wipe()
+			}
+		}()
+		if credentials == nil && len(args) == 0 && !hasTOTPSecret() && len(samlAssertionFile) == 0 && !noMFA {
+			return newConfigError(fmt.Errorf("there are no cached session credentials for the %s profile; run mafia --credential-process with an MFA code first", profile))
+		}
+		if credentials == nil {
+			logEvent(logRecord{Event: eventAuthAttempt, Profile: profile, RoleARN: roleARN})
+			started := time.Now()
+			var mfaToken string
+			if len(args) != 0 {
+				mfaToken = args[0]
+			}
+			if len(samlAssertionFile) != 0 {
+				credentials, err = fetchSAMLCredentials()
+			} else {
+				credentials, err = fetchSessionCredentials(mfaToken)
+			}
+			if err != nil {
+				logEvent(logRecord{Event: eventAuthFailure, Profile: profile, RoleARN: roleARN,
+					Class: exitClassNames[exitCodeFor(err)], Error: err.Error()})
+				return err
+			}
+			logEvent(logRecord{Event: eventAuthSuccess, Profile: profile, RoleARN: roleARN,
+				Expiration: logTime(credentials.Expiration)})
+			if verbose {
+				warnIfShortened(started, credentials)
+			}
+			if verify {
+				if err = verifySessionCredentials(credentials); err != nil {
+					return err
+				}
+			}
+			cacheProcessCredentials(credentials)
+		}
+
+		// Fail now if the session will not last as long as it must, and keep whatever
+		// secrets go into the error messages from here on out of them
+		rememberSecrets(credentials)
+		if err = checkValidUntil(credentials); err != nil {
 			return err
 		}
 
@@ -63,18 +380,36 @@ Replacing 999999999999 with your account number, and jane with your username.
 		if saveCredentials {
 
 			// Try to the save the credentials
-			err = saveSessionCredentials(credentials)
-
-			// If that worked, give the user a comfort signal
-			fmt.Println("Session credentials saved to file")
-
-		} else {
+			result, err := saveSessionCredentials(credentials)
+			if err != nil {
+				return err
+			}
+
+			// That worked, give the user a comfort signal - unless there was nothing to save
+			if result.Written {
+				logEvent(logRecord{Event: eventSave, Profile: result.Section, Expiration: logTime(credentials.Expiration),
+					File: result.Filepath, Outcome: saveOutcome(result)})
+				writeSaveSignal(humanOutput(), result)
+			} else if verbose {
+				fmt.Fprintf(humanOutput(), "The [%s] section of %s already held these session credentials; the file was left alone\n", result.Section, result.Filepath)
+			}
+		}
 
-			// Not saving the credentials so show them in stdout
-			displaySessionCredentials(credentials)
+		// A command to run takes the credentials in place of any display of them
+		if len(execCommand) != 0 {
+			return runWithCredentials(credentials)
+		}
+		if serveECS {
+			return runECSServer(humanOutput(), credentials)
 		}
 
-		// All done - maybe not successfully; either way return the rror value that we have
+		// Unless we saved the credentials and were asked for neither an output file,
+		// descriptor, or pipe, export statements, nor credential_process output too, show
+		// them on stdout or wherever the output goes. All done - maybe not successfully;
+		// either way return the error value that we have
+		if !saveCredentials || outputRedirected() || export || credentialProcess {
+			err = outputSessionCredentials(credentials)
+		}
 		return err
 	},
 }
@@ -82,10 +417,21 @@ Replacing 999999999999 with your account number, and jane with your username.
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if executeError = rootCmd.Execute(); executeError != nil {
-		fmt.Println(executeError)
+	forgetSecrets()
+	defer forgetSecrets()
+	executeError = rootCmd.Execute()
+	exitCode = exitCodeFor(executeError)
+	if executeError != nil {
+
+		// Report the error on stderr as a single line, prefixed by its class name so
+		// that scripts can pick it apart, and exit with the corresponding code. An --exec
+		// command that failed has had its say already, and its exit status is passed on.
+		var commandErr *commandExitError
+		if !errors.As(executeError, &commandErr) {
+			fmt.Fprintf(os.Stderr, "error: %s: %s\n", exitClassNames[exitCode], maskSecrets(executeError.Error()))
+		}
 		if !unitTesting {
-			os.Exit(1)
+			os.Exit(exitCode)
 		}
 	}
 }
@@ -105,11 +451,80 @@ func initRootFlags() {
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
-	rootCmd.PersistentFlags().BoolVar(&saveCredentials, "save", false, "save the obtained credentials to the .aws/credentials file")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", defaultProfile(), "the .aws/credentials section holding the long term credentials and MFA device ID; sessions are saved to <profile>-session (defaults to "+defaultProfileEnvVar+" if set)")
+	rootCmd.PersistentFlags().StringVar(&defaultsFile, "defaults-file", "", "the path of the YAML file holding per-profile flag defaults (defaults to ~/"+defaultsFileName+")")
+	rootCmd.PersistentFlags().StringArrayVar(&credentialsFiles, "credentials-file", nil, "the path of the AWS credentials file (overrides "+mfile.SharedCredentialsFileEnvVar+"); give it more than once, or separate paths with "+string(os.PathListSeparator)+", to read several files as one, later files overriding earlier ones, with changes written to the last")
+	rootCmd.PersistentFlags().BoolVar(&saveCredentials, "save", false, "save the obtained credentials to the <profile>-session section of the .aws/credentials file, e.g. [default-session], to be used with "+profileEnvVar+"=default-session")
+	rootCmd.PersistentFlags().StringVar(&storeName, "store", storeFile, "where --save and --reuse keep session credentials: "+storeFile+" for the .aws/credentials file or "+storeKeychain+" for the macOS Keychain, Windows Credential Manager, or Secret Service")
+	rootCmd.PersistentFlags().BoolVar(&encrypt, "encrypt", false, "save the session token encrypted with the passphrase in the "+passphraseEnvVar+" environment variable, and decrypt it with that when --reuse reads it back")
+	rootCmd.PersistentFlags().BoolVar(&reuse, "reuse", false, "reuse the saved session credentials, rather than ask AWS for more, if they are good for a while yet")
+	rootCmd.PersistentFlags().DurationVar(&minRemaining, "min-remaining", defaultMinRemaining, "with --reuse or --credential-process, how long a saved or cached session must have left to run to be reused")
+	rootCmd.PersistentFlags().DurationVar(&requireValidUntil, "require-valid-until", 0, "fail, saving nothing, unless the session credentials remain valid for at least this long, e.g. 2h")
+	rootCmd.PersistentFlags().BoolVar(&refreshExisting, "refresh-existing", false, "save a new session over whichever -session section holds the one active session, for that section's profile")
+	rootCmd.PersistentFlags().BoolVar(&inPlace, "in-place", false, "with --save, write the session credentials over the long term credentials in the --profile section")
+	rootCmd.PersistentFlags().BoolVar(&backup, "backup", true, "with --in-place, first copy the credentials file to credentials"+mfile.BackupSuffix)
+	rootCmd.PersistentFlags().BoolVar(&noBackup, "no-backup", false, "with --in-place, do not back up the credentials file")
+	rootCmd.PersistentFlags().BoolVar(&copySettings, "copy-profile-settings", false, "with --save, also copy the profile's other settings, such as region, into the session section so that it is self-contained")
+	rootCmd.PersistentFlags().BoolVar(&showDiff, "show-diff", false, "with --save, display the old and new values of the keys that are about to change, secrets masked, before writing them")
+	rootCmd.PersistentFlags().StringVar(&accessKeyName, "access-key-name", mfile.AccessKeyIDKey, "the key name that a saved access key ID is written under")
+	rootCmd.PersistentFlags().StringVar(&secretKeyName, "secret-key-name", mfile.SecretAccessKeyKey, "the key name that a saved secret access key is written under")
+	rootCmd.PersistentFlags().StringVar(&sessionTokenName, "session-token-name", mfile.SessionTokenKey, "the key name that a saved session token is written under")
+	rootCmd.PersistentFlags().StringVar(&expirationName, "expiration-name", mfile.SessionExpirationKey, "the key name that a saved session's expiration is written under, e.g. x_security_token_expires as aws-vault has it")
+	rootCmd.PersistentFlags().BoolVar(&fromEnv, "from-env", false, "use the long term credentials in the "+accessKeyIDEnvVar+" and "+secretAccessKeyEnvVar+" environment variables, ignoring the .aws/credentials file")
+	rootCmd.PersistentFlags().StringVar(&mfaSerial, "mfa-serial", "", "the MFA device ID / serial number to authenticate with, overriding the .aws/credentials file")
+	rootCmd.PersistentFlags().BoolVar(&noMFA, "no-mfa", false, "obtain a session with the long term credentials alone, for IAM users without an MFA device; such a session has NO MFA protection")
+	rootCmd.PersistentFlags().IntVar(&mfaIndex, "mfa-index", 0, "choose the nth of the MFA devices registered to the IAM user, remembering the choice in the .aws/credentials file")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "do not record an MFA device ID found by listing the IAM user's devices in the credentials file")
+	rootCmd.PersistentFlags().BoolVar(&waitForNext, "wait-for-next", false, "if AWS says that an MFA code generated from the profile's "+mfile.MfaTOTPSecretKey+" was already used, wait for the next code and try again")
+	rootCmd.PersistentFlags().DurationVar(&duration, "duration", defaultDuration, "how long the session credentials are to remain valid, between "+minDuration.String()+" and "+maxDuration.String())
+	durationFlag = rootCmd.PersistentFlags().Lookup("duration")
+	rootCmd.PersistentFlags().BoolVar(&verify, "verify", false, "before saving or displaying new session credentials, prove that they work by asking STS who they belong to")
+	rootCmd.PersistentFlags().BoolVar(&noDurationCheck, "no-duration-check", false, "send --duration to the STS endpoint without checking it against, or capping it to, the limits that AWS applies, for STS compatible endpoints such as localstack that apply their own")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "report the account and user that the MFA device belongs to, and warn if AWS grants a shorter session than --duration asked for")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", creds.DefaultMaxRetries, "the number of times to retry, with exponential backoff, STS requests that are throttled or fail with a server error")
+	rootCmd.PersistentFlags().BoolVar(&export, "export", false, "display nothing but the statements that set the credentials as environment variables, for the shell to evaluate")
+	rootCmd.PersistentFlags().BoolVar(&credentialProcess, "credential-process", false, "display the credentials as the JSON that an AWS credential_process prints, caching them so that, until they expire, no MFA code is needed")
+	rootCmd.PersistentFlags().BoolVar(&credentialProcess, "json", false, "the same as --credential-process")
+	rootCmd.PersistentFlags().IntVar(&processVersion, "process-version", cache.ProcessVersion, "with --credential-process, the Version that the JSON declares")
+	rootCmd.PersistentFlags().BoolVar(&utc, "utc", false, "show when the session credentials expire in UTC rather than local time")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "do not color the displays meant for a person, as is already the case when they are not written to a terminal or "+noColorEnvVar+" is set")
+	rootCmd.PersistentFlags().BoolVar(&humanToStderr, "human-to-stderr", false, "write the standard display, when the session credentials expire, and other messages meant for a person to stderr, leaving stdout to --export, --credential-process, or --format output alone")
+	rootCmd.PersistentFlags().StringVar(&shell, "shell", defaultShell(), "the shell that --export and shellenv write for: "+strings.Join(supportedShells, ", "))
+	rootCmd.PersistentFlags().BoolVar(&scrubHistory, "scrub-history", false, "remove the mafia command lines holding the MFA code from the --shell's history file, "+histFileEnvVar+" or ~/.bash_history or ~/.zsh_history, rather than clear all history")
+	rootCmd.PersistentFlags().BoolVar(&sso, "sso", false, "authenticate as the AWS SSO role of the profile in the AWS config file, with the access token cached by aws sso login, rather than with the long term credentials of the credentials file; a role must then be assumed")
+	rootCmd.PersistentFlags().StringVar(&execCommand, "exec", "", "a command to run through the shell, once the session credentials are obtained, with them set in its environment in place of being displayed, e.g. 'aws s3 ls'")
+	rootCmd.PersistentFlags().BoolVar(&serveECS, "ecs-server", false, "serve the session credentials on 127.0.0.1 as an ECS credential provider endpoint, as aws-vault's server mode does, until interrupted or for as long as the --exec command runs, rather than display them")
+	rootCmd.PersistentFlags().BoolVar(&configSnippet, "config-snippet", false, "add the ~/.aws/config stanza, with the region in use, that makes a complete profile of the displayed credentials file section")
+	rootCmd.PersistentFlags().BoolVar(&redact, "redact", false, "show only the first and last four characters of the displayed secret access key and session token, e.g. for a screen share; anything saved still gets the real values")
+	rootCmd.PersistentFlags().StringVar(&envPrefix, "prefix", "", "a prefix for the displayed and exported environment variable names, e.g. MYAPP_ for MYAPP_"+accessKeyIDEnvVar)
+	rootCmd.PersistentFlags().BoolVar(&fromCLI, "from-cli", false, "have the import-serial subcommand copy the profile's mfa_serial from the AWS CLI's config file")
+	rootCmd.PersistentFlags().StringVar(&otpauthURL, "otpauth-url", "", "have the configure subcommand store the secret of the given otpauth://totp/ URL, from a virtual MFA device's QR code, as the profile's "+mfile.MfaTOTPSecretKey)
+	rootCmd.PersistentFlags().BoolVar(&includeSecrets, "include-secrets", false, "have the export-sessions subcommand include the keys and tokens of the sessions that it describes")
+	rootCmd.PersistentFlags().BoolVar(&trimSessionSuffix, "trim-session-suffix", false, "have the profiles subcommand list a session section without a profile of its own under the profile name, e.g. work for work-session")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormatText, "set to "+logFormatJSON+" to write JSON Lines events (never including secrets) to stderr")
+	rootCmd.PersistentFlags().StringVar(&outputFile, "output-file", "", "write the credentials display to the named file (created with 0600 permissions) rather than stdout")
+	rootCmd.PersistentFlags().StringVar(&iniDelimiters, "ini-delimiters", "", "the characters that may separate a key from its value in the credentials and config files (defaults to =:)")
+	rootCmd.PersistentFlags().StringVar(&iniWriteDelimiter, "ini-write-delimiter", "", "the character that keys added to the credentials file are separated from their values with (defaults to =)")
+	rootCmd.PersistentFlags().BoolVar(&iniNoSpaces, "ini-no-spaces", false, "write keys added to the credentials file as key=value, without spaces around the delimiter")
+	rootCmd.PersistentFlags().BoolVar(&iniSpaceBeforeComment, "ini-space-before-comment", false, "only treat # and ; as beginning a comment in the credentials and config files when whitespace precedes them, so that values may hold them")
+	rootCmd.PersistentFlags().IntVar(&outputFD, "output-fd", 0, "write the credentials display to the given file descriptor, 3 or more, inherited from the parent process, rather than stdout")
+	rootCmd.PersistentFlags().StringVar(&outputFIFO, "output-fifo", "", "write the credentials display to the named pipe, which must already exist, rather than stdout, so that the credentials never land in a regular file")
+	rootCmd.PersistentFlags().StringVar(&roleARN, "role-arn", "", "the ARN of an IAM role to assume with the MFA authenticated identity")
+	rootCmd.PersistentFlags().StringVar(&mode, "mode", modeAuto, "how to authenticate: "+modeAuto+" to assume a role if --role-arn or the profile's role_arn names one and obtain a plain session otherwise, "+modeSession+" or "+modeRole+" to insist on one or the other")
+	rootCmd.PersistentFlags().StringVar(&region, "region", "", "the AWS region whose regional STS endpoint is to be called (overrides "+regionEnvVar+", "+defaultRegionEnvVar+", and the profile's region)")
+	rootCmd.PersistentFlags().BoolVar(&fips, "fips", false, "call the FIPS validated STS endpoint of the region, e.g. sts-fips.us-east-1.amazonaws.com")
+	rootCmd.PersistentFlags().StringVar(&stsEndpoint, "sts-endpoint", "", "the URL of an STS endpoint to use in place of the AWS default (overrides "+stsEndpointEnvVar+")")
+	rootCmd.PersistentFlags().StringVar(&proxy, "proxy", "", "the URL of an http, https, or socks5 proxy to reach AWS through (overrides HTTPS_PROXY, HTTP_PROXY, and NO_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "user-agent", creds.DefaultUserAgent+"/"+version, "the product token that begins the User-Agent header of requests to AWS, ahead of the AWS SDK's own, for proxies and egress logs to identify them by")
+	rootCmd.PersistentFlags().StringVar(&samlAssertionFile, "saml-assertion-file", "", "the path of a file holding the SAML assertion, base64 encoded or not, issued by an identity provider, with which to assume the --role-arn role in place of an MFA code")
+	rootCmd.PersistentFlags().StringVar(&principalARN, "principal-arn", "", "with --saml-assertion-file, the ARN of the SAML provider in IAM that issued the assertion")
+	rootCmd.PersistentFlags().StringVar(&externalID, "external-id", "", "the external ID demanded by the trust policy of a role in another account (overrides the role profile's external_id)")
+	rootCmd.PersistentFlags().StringVar(&roleSessionName, "role-session-name", "", "the role session name recorded by CloudTrail (default mafia-<iam-username>-<timestamp>)")
+	rootCmd.PersistentFlags().BoolVar(&sessionNameFromHostname, "session-name-from-hostname", false, "default the role session name to mafia-<hostname>-<timestamp>, to trace which machine assumed the role")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	//  rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.Flags().StringVar(&formatTemplate, "format", "", "render the credentials through a Go text/template, e.g. '{{.AccessKeyID}} {{.SecretAccessKey}} {{.SessionToken}} {{.Expiration}}'")
 }
 
 // ============================================================================
@@ -159,46 +574,437 @@ func resetCommand() {
 	// Clear and then re-initialize all the flags definitions
 	rootCmd.ResetFlags()
 	initRootFlags()
+	checkCmd.ResetFlags()
+	initCheckFlags()
+	daemonCmd.ResetFlags()
+	initDaemonFlags()
+
+	// Subcommands that consult the root flags keep copies of them that must go too
+	cacheClearCmd.ResetFlags()
+	pathCmd.ResetFlags()
 }
 
 // fetchSessionCredentials orchestrates the work of obtaining, displaying, and
 // potentially saving AWS session credentials to the  ~/.aws/credentials file.
 func fetchSessionCredentials(mfaToken string) (*creds.SessionCredentials, error) {
 
-	// Obtain the MFA device ID / serial number as defined by AWS
-	mfaDeviceID, err := mfile.GetMFADeviceID()
+	// Find out whether the selected profile assumes a role defined in the AWS config file,
+	// and so which profile holds the long term credentials
+	sourceProfile, roleProfile, err := resolveProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure that we are about to make the STS call that --mode insists on
+	if err = validateMode(roleProfile); err != nil {
+		return nil, err
+	}
+
+	// AWS will not give a plain session for SSO role credentials, which are themselves
+	// short lived, so they can only assume a role
+	if sso && len(roleARN) == 0 && roleProfile == nil {
+		return nil, newConfigError(errors.New("--sso requires --role-arn or a role profile, since AWS gives no plain session for SSO credentials"))
+	}
+
+	// A role session name is meaningless unless we are assuming a role
+	if len(roleSessionName) != 0 && len(roleARN) == 0 && roleProfile == nil {
+		return nil, newConfigError(errors.New("--role-session-name requires --role-arn"))
+	}
+	if len(externalID) != 0 && len(roleARN) == 0 && roleProfile == nil {
+		return nil, newConfigError(errors.New("--external-id requires --role-arn"))
+	}
+
+	// Saving in place only makes sense if we are saving at all
+	if inPlace && !saveCredentials {
+		return nil, newConfigError(errors.New("--in-place requires --save"))
+	}
+
+	// AWS will not give us a session for less than 15 minutes or more than 36 hours
+	if err = validateDuration(); err != nil {
+		return nil, err
+	}
+
+	// Point the creds package at the right STS endpoint and long term credentials, and
+	// make sure that we won't be needing the file if those come from the environment
+	envMode, err := configureCreds(sourceProfile)
+	if err != nil {
+		return nil, err
+	}
+	if envMode && saveCredentials && storeName == storeFile {
+		return nil, newConfigError(errors.New("--save cannot be used with credentials from the environment"))
+	}
+
+	// Save the user from an opaque STS error if the profile has no long term keys to
+	// authenticate with
+	if !envMode && !sso {
+		if err = mfile.CheckLongTermKeysInFile(credentialsFilepath(), sourceProfile); err != nil {
+			return nil, newConfigError(err)
+		}
+		rememberProfileSecrets(sourceProfile)
+	}
+
+	// Present the external ID given on the command line or, failing that, by the role profile
+	if len(externalID) == 0 && roleProfile != nil {
+		creds.SetExternalID(roleProfile.ExternalID)
+	} else {
+		creds.SetExternalID(externalID)
+	}
+
+	// With --no-mfa, there is no MFA device to find and no code to go with it
+	if noMFA {
+		return requestSessionCredentials("", "", roleProfile)
+	}
+
+	// Without an MFA code, generate one from the profile's virtual MFA device secret
+	generated := len(mfaToken) == 0
+	if generated {
+		if mfaToken, err = generateMFACode(envMode, sourceProfile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Obtain the MFA device ID / serial number as defined by AWS, which a role profile
+	// may specify for itself
+	var mfaDeviceID string
+	if roleProfile != nil && len(roleProfile.MFASerial) != 0 && len(mfaSerial) == 0 {
+		mfaDeviceID = roleProfile.MFASerial
+	} else if sso && len(mfaSerial) == 0 {
+		return nil, newConfigError(errors.New("with --sso, the MFA device must be given by --mfa-serial or the role profile's mfa_serial, or --no-mfa used"))
+	} else if mfaDeviceID, err = resolveMFADeviceID(envMode, sourceProfile); err != nil {
+		return nil, err
+	}
+
+	// Save the user from confusing STS errors if they copied the documentation too literally
+	if err = mfile.ValidateMFASerial(mfaDeviceID); err != nil {
+		return nil, newConfigError(err)
+	}
+
+	// Confirm whose identity is about to be used, if we were asked to be chatty
+	if account, username, err := mfile.ParseMFASerial(mfaDeviceID); verbose && err == nil {
+		fmt.Fprintf(warningOutput, "Authenticating account %s as %s\n", account, username)
+	}
+
+	// Ask AWS for the credentials, trying the next code if we generated this one, AWS says
+	// that it has been used already, and --wait-for-next allows us to wait for another
+	credentials, err := requestSessionCredentials(mfaDeviceID, mfaToken, roleProfile)
+	if retryWithNextMFACode(generated, err) {
+		if mfaToken, err = generateMFACode(envMode, sourceProfile); err != nil {
+			return nil, err
+		}
+		credentials, err = requestSessionCredentials(mfaDeviceID, mfaToken, roleProfile)
+	}
+	return credentials, explainReusedMFACode(generated, err)
+}
+
+// requestSessionCredentials asks AWS for session credentials authenticated by the given
+// MFA device and code, assuming the role given by the --role-arn flag or the role profile,
+// if either, with them.
+func requestSessionCredentials(mfaDeviceID, mfaToken string, roleProfile *mfile.RoleProfile) (*creds.SessionCredentials, error) {
+	roleSessionDuration, err := requestedDuration(roleProfile)
 	if err != nil {
 		return nil, err
 	}
 
-	// Ask AWS for the credentials and return what we get
-	return creds.GetSessionCredentials(mfaDeviceID, mfaToken, 3600)
+	// If we have been asked to assume a role, do that with the MFA token rather than
+	// obtaining a plain session, asking for no more than the role allows
+	if len(roleARN) != 0 {
+		if !sso {
+			roleSessionDuration = roleDuration(roleARN, roleSessionDuration)
+		}
+		return creds.AssumeRoleCredentials(roleARN, sessionNameFor(mfaDeviceID), mfaDeviceID, mfaToken, durationSeconds(roleSessionDuration))
+	}
+
+	// Ask AWS for the credentials, unless they are SSO role credentials that AWS would
+	// refuse a plain session to and that can assume the role profile's role themselves
+	var credentials *creds.SessionCredentials
+	if !sso {
+		credentials, err = creds.GetSessionCredentials(mfaDeviceID, mfaToken, durationSeconds(duration))
+		if err != nil || roleProfile == nil {
+			return credentials, err
+		}
+	}
+
+	// Complete the chain of a role profile by assuming its role with the MFA session, or
+	// with the SSO role credentials and the MFA code, preferring the profile's own role
+	// session name to our default one. The MFA session is an IAM user's, not a role's, so
+	// its duration is bounded only by the role's maximum.
+	sessionName := sessionNameFor(mfaDeviceID)
+	if len(roleSessionName) == 0 && len(roleProfile.RoleSessionName) != 0 {
+		sessionName = roleProfile.RoleSessionName
+	}
+	if sso {
+		return creds.AssumeRoleCredentials(roleProfile.RoleARN, sessionName, mfaDeviceID, mfaToken, durationSeconds(roleSessionDuration))
+	}
+	roleSessionDuration = roleDuration(roleProfile.RoleARN, roleSessionDuration)
+	return creds.AssumeRoleWithSessionCredentials(credentials, roleProfile.RoleARN, sessionName, durationSeconds(roleSessionDuration))
+}
+
+// sessionNameFor returns the role session name given by the --role-session-name flag
+// or, by default, one that identifies the human behind the given MFA device or, with
+// --session-name-from-hostname, the machine that we are running on.
+func sessionNameFor(mfaDeviceID string) string {
+	if len(roleSessionName) != 0 {
+		return roleSessionName
+	}
+	if sessionNameFromHostname {
+		hostname, err := hostnameFunc()
+		if err == nil {
+			return creds.HostnameRoleSessionName(hostname)
+		}
+		fmt.Fprintf(warningOutput, "warning: could not name the role session after the hostname: %v\n", err)
+	}
+	return creds.DefaultRoleSessionName(mfaUsername(mfaDeviceID))
 }
 
-// displaySessionCredentials shows the, you guessed it, session credentials on stdout.
-// The display is given twice, once formated for use as environment variables and
-// once ready to copy-nd-paste into the  ~/.aws/credentials file.
-func displaySessionCredentials(credentials *creds.SessionCredentials) {
+// resolveProfiles looks in the AWS config file for a role profile of the name given by
+// the --profile flag. If there is one, the name of its source profile is returned with
+// it; otherwise, the --profile flag value is returned with a nil role profile.
+func resolveProfiles() (string, *mfile.RoleProfile, error) {
+
+	// See what the config file has to say
+	roleProfile, err := mfile.GetRoleProfileFromFile(mfile.ResolveConfigPath(), profile)
+	if err != nil {
+		return "", nil, newConfigError(err)
+	}
+	if roleProfile == nil {
+		return profile, nil, nil
+	}
 
-	// Display the results in a form that can be copy-and-pasted to set as environment variables
-	fmt.Printf("\nEnvironment Variables\n\n")
-	fmt.Printf("export AWS_ACCESS_KEY_ID=%s\n", *credentials.AccessKeyID)
-	fmt.Printf("export AWS_SECRET_ACCESS_KEY=%s\n", *credentials.SecretAccessKey)
-	fmt.Printf("export AWS_SESSION_TOKEN=%s\n", *credentials.SessionToken)
-	fmt.Println("history -c # clear shell history immediately after setting secrets")
+	// We can only assume the one role
+	if len(roleARN) != 0 {
+		return "", nil, newConfigError(fmt.Errorf("--role-arn cannot be used with the %s profile, which assumes a role of its own", profile))
+	}
+	return roleProfile.SourceProfile, roleProfile, nil
+}
 
-	// Display the results in a form that can be copy-and-pasted to set as environment variables
-	fmt.Printf("\nTo paste into ~/.aws/credentials\n\n")
-	fmt.Println("[default-session]")
-	fmt.Printf("aws_access_key_id = %s\n", *credentials.AccessKeyID)
-	fmt.Printf("aws_secret_access_key = %s\n", *credentials.SecretAccessKey)
-	fmt.Printf("aws_session_token = %s\n", *credentials.SessionToken)
-	fmt.Println()
+// fileCredentials returns the AWS SDK credentials that read the long term keys of the
+// named profile from the given credentials file or, since the SDK reads only one, from
+// whichever of the files that the path lists holds them.
+func fileCredentials(path, sourceProfile string) *credentials.Credentials {
+	return credentials.NewSharedCredentials(mfile.ProfileKeysPath(path, sourceProfile), sourceProfile)
+}
+
+// configureCreds tells the creds package which STS endpoint to call, how persistent to
+// be, and which long term credentials to authenticate with, returning true if those
+// credentials are to come from the environment rather than the named profile of the
+// credentials file.
+func configureCreds(sourceProfile string) (bool, error) {
+
+	// A negative number of retries makes no sense
+	if maxRetries < 0 {
+		return false, newConfigError(fmt.Errorf("--max-retries cannot be negative: %d", maxRetries))
+	}
+
+	// Point the creds package at the right STS endpoint, FIPS validated if need be, through
+	// the right proxy, and tell it how persistent to be
+	endpoint, stsRegion := resolveSTSEndpoint(), resolveRegion(sourceProfile)
+	if fips {
+		if len(endpoint) != 0 {
+			return false, newConfigError(errors.New("--fips cannot be used with --sts-endpoint or " + stsEndpointEnvVar))
+		}
+		var err error
+		if endpoint, err = creds.FIPSEndpoint(stsRegion); err != nil {
+			return false, newConfigError(err)
+		}
+	}
+	creds.SetSTSEndpoint(endpoint)
+	creds.SetRegion(stsRegion)
+	creds.SetMaxRetries(maxRetries)
+	creds.SetSkipParamValidation(noDurationCheck)
+	creds.SetUserAgent(userAgent)
+	if err := creds.SetProxy(proxy); err != nil {
+		return false, newConfigError(err)
+	}
+
+	// If the long term credentials are to come from the environment, tell the creds
+	// package to insist on that
+	envMode := !sso && useEnvironmentCredentials()
+	if sso {
+		ssoCredentials, err := ssoRoleCredentials(sourceProfile)
+		if err != nil {
+			return false, err
+		}
+		creds.SetLongTermCredentials(ssoCredentials)
+	} else if envMode {
+		creds.SetLongTermCredentials(credentials.NewEnvCredentials())
+	} else if path := credentialsFilepath(); path != mfile.DefaultCredentialsFilepath() || sourceProfile != mfile.DefaultSectionName {
+
+		// The AWS SDK only knows to look in the default profile of the default location, or
+		// wherever AWS_SHARED_CREDENTIALS_FILE points, so make sure it reads what we do
+		creds.SetLongTermCredentials(fileCredentials(path, sourceProfile))
+	} else {
+
+		// Otherwise the SDK default chain is right, whatever an earlier profile needed
+		creds.SetLongTermCredentials(nil)
+	}
+	return envMode, nil
+}
+
+// defaultProfile returns the profile to use when the --profile flag is not given: the
+// one named by the MAFIA_DEFAULT_PROFILE environment variable if that is set, otherwise
+// the default profile.
+func defaultProfile() string {
+	if p := os.Getenv(defaultProfileEnvVar); len(p) != 0 {
+		return p
+	}
+	return mfile.DefaultSectionName
+}
+
+// resolveRegion returns the AWS region to be used, taken from the first of the --region
+// flag, the AWS_REGION and AWS_DEFAULT_REGION environment variables, and the region key
+// of the selected or source profile in the credentials or config file. An empty string
+// means that no region is configured and the global STS endpoint is to be used.
+func resolveRegion(sourceProfile string) string {
+
+	// Flags and environment variables first
+	for _, r := range []string{region, os.Getenv(regionEnvVar), os.Getenv(defaultRegionEnvVar)} {
+		if len(r) != 0 {
+			return r
+		}
+	}
+
+	// Then the profile, in the case of a role profile, falling back on its source
+	credentialsPath, configPath := credentialsFilepath(), mfile.ResolveConfigPath()
+	if r := mfile.GetProfileRegion(credentialsPath, configPath, profile); len(r) != 0 || sourceProfile == profile {
+		return r
+	}
+	return mfile.GetProfileRegion(credentialsPath, configPath, sourceProfile)
+}
+
+// resolveSTSEndpoint returns the STS endpoint URL given by the --sts-endpoint flag or,
+// failing that, the AWS_STS_ENDPOINT environment variable. An empty string is returned
+// if neither has been set, signaling that the standard AWS endpoint should be used.
+func resolveSTSEndpoint() string {
+	if len(stsEndpoint) != 0 {
+		return stsEndpoint
+	}
+	return os.Getenv(stsEndpointEnvVar)
+}
+
+// useEnvironmentCredentials returns true if the long term credentials should be taken
+// from the environment rather than the credentials file, either because the --from-env
+// flag says so or because there is no credentials file but there are environment
+// variable credentials.
+func useEnvironmentCredentials() bool {
+
+	// If we were told, that's that
+	if fromEnv {
+		return true
+	}
+
+	// Otherwise, only if the file is absent and the environment has what we need
+	for _, path := range mfile.SplitCredentialsPaths(credentialsFilepath()) {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			return false
+		}
+	}
+	return len(os.Getenv(accessKeyIDEnvVar)) != 0 && len(os.Getenv(secretAccessKeyEnvVar)) != 0
+}
+
+// credentialsFilepath returns the path of the AWS credentials file, as given by the
+// --credentials-file flag or otherwise resolved by the mfile package. Several paths given
+// by the flag are joined into the list form that the mfile package understands.
+func credentialsFilepath() string {
+	return mfile.ResolveCredentialsPath(strings.Join(credentialsFiles, string(os.PathListSeparator)))
+}
+
+// mfaUsername extracts the IAM username from an MFA device serial number in the
+// form arn:aws:iam::999999999999:mfa/jane, returning an empty string if the serial
+// number is not in that form.
+func mfaUsername(mfaDeviceID string) string {
+
+	// The username follows the last slash of the mfa/ resource
+	index := strings.LastIndex(mfaDeviceID, "/")
+	if index < 0 || !strings.Contains(mfaDeviceID, ":mfa/") {
+		return ""
+	}
+	return mfaDeviceID[index+1:]
+}
+
+// writeSaveSignal writes the comfort signal that the session credentials were saved to the
+// given writer, naming the section of the credentials file that they were saved to and
+// how to use them, since they are not where the AWS CLI and SDKs look by default or, with
+// --encrypt, can be read by them at all. With --verbose, it also says whether the section
+// was added or updated and where any backup went.
+func writeSaveSignal(w io.Writer, result *mfile.SaveResult) {
+	if storeName != storeFile {
+		fmt.Fprintln(w, "Session credentials saved to "+storeName)
+		return
+	}
+	fmt.Fprintf(w, "Session credentials saved to file %s, in the [%s] section\n", result.Filepath, result.Section)
+	if verbose {
+		fmt.Fprintf(w, "The [%s] section was %s\n", result.Section, saveOutcome(result))
+		if len(result.Backup) != 0 {
+			fmt.Fprintln(w, "The previous file was backed up to "+result.Backup)
+		}
+	}
+	switch {
+	case encrypt:
+		// An encrypted session token is of no use to the AWS CLI, only to us
+		reuseCommand := "mafia --reuse --encrypt"
+		if profile != mfile.DefaultSectionName {
+			reuseCommand += " --profile " + profile
+		}
+		fmt.Fprintf(w, "The session token is encrypted, so AWS tools cannot read the section; to use them: %s --exec '<command>', or --export or --credential-process in place of --exec\n", reuseCommand)
+	case result.Section != mfile.DefaultSectionName:
+		fmt.Fprintln(w, "To use them: "+exportStatement(profileEnvVar, result.Section))
+	}
+}
+
+// saveOutcome describes what saving did to the section that the credentials went to.
+func saveOutcome(result *mfile.SaveResult) string {
+	if result.Created {
+		return "created"
+	}
+	return "updated"
 }
 
 // saveSessionCredentials attempts to svae the obtained session credentials to the
-// ~/.aws/credentials file.
-func saveSessionCredentials(credentials *creds.SessionCredentials) error {
+// ~/.aws/credentials file, describing what it did; the file is not written if the
+// credentials there are already the same. With --store keychain, they are saved to the
+// operating system's secret store instead, and always written.
+func saveSessionCredentials(credentials *creds.SessionCredentials) (*mfile.SaveResult, error) {
+
+	// The secret store is another matter entirely
+	if storeName == storeKeychain {
+		if err := saveToKeychain(credentials); err != nil {
+			return nil, err
+		}
+		return &mfile.SaveResult{Written: true}, nil
+	}
 
-	return mfile.SaveSessionCredentials(credentials.AccessKeyID, credentials.SecretAccessKey, credentials.SessionToken)
+	// Have the mfile package do the hard work
+	options := saveOptions()
+	options.Expiration = credentials.Expiration
+	secretAccessKey, sessionToken := credentials.SecretAccessKey.Value(), credentials.SessionToken.Value()
+	return mfile.SaveSessionCredentialsToFile(credentialsFilepath(), options,
+		credentials.AccessKeyID, &secretAccessKey, &sessionToken)
+}
+
+// saveOptions builds the options that direct where and how session credentials are
+// saved from the command line flags.
+func saveOptions() *mfile.SaveOptions {
+	return &mfile.SaveOptions{
+		Profile: profile,
+		KeyNames: mfile.KeyNames{
+			AccessKeyID:     accessKeyName,
+			SecretAccessKey: secretKeyName,
+			SessionToken:    sessionTokenName,
+			Expiration:      expirationName,
+		},
+		InPlace:             inPlace,
+		Backup:              backup && !noBackup,
+		CopyProfileSettings: copySettings,
+		Diff:                diffOutput(),
+		Passphrase:          sessionPassphrase(),
+	}
+}
+
+// diffOutput returns where the changes to the credentials file are to be displayed: nowhere
+// unless --show-diff was given, and wherever messages meant for a person go otherwise.
+func diffOutput() io.Writer {
+	if !showDiff {
+		return nil
+	}
+	return humanOutput()
 }
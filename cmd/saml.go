@@ -0,0 +1,72 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the functions that obtain session credentials with a SAML assertion.
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+)
+
+// validateSAML returns a configuration error if the SAML flags are given by halves,
+// alongside an MFA code that the SAML flow has no use for, or with a --mode that rules
+// out assuming a role.
+func validateSAML(args []string) error {
+	if len(samlAssertionFile) == 0 {
+		if len(principalARN) != 0 {
+			return newConfigError(errors.New("--principal-arn requires --saml-assertion-file"))
+		}
+		return nil
+	}
+	if len(principalARN) == 0 || len(roleARN) == 0 {
+		return newConfigError(errors.New("--saml-assertion-file requires --principal-arn and --role-arn"))
+	}
+	if len(args) != 0 {
+		return newConfigError(errors.New("no MFA code is needed with --saml-assertion-file"))
+	}
+	return validateMode(nil)
+}
+
+// fetchSAMLCredentials assumes the role given by the --role-arn flag with the SAML assertion
+// held in the file named by the --saml-assertion-file flag, in place of authenticating
+// with long term credentials and an MFA code.
+func fetchSAMLCredentials() (*creds.SessionCredentials, error) {
+
+	// The same flags make sense, or not, as for any other session
+	if inPlace && !saveCredentials {
+		return nil, newConfigError(errors.New("--in-place requires --save"))
+	}
+	if err := validateDuration(); err != nil {
+		return nil, err
+	}
+	if _, err := configureCreds(profile); err != nil {
+		return nil, err
+	}
+
+	// Read the assertion, which AWS wants base64 encoded as the identity provider posts it
+	// but which may have been saved as the XML that that encodes
+	content, err := ioutil.ReadFile(mfile.ExpandPath(samlAssertionFile))
+	if err != nil {
+		return nil, newConfigError(fmt.Errorf("could not read the SAML assertion: %v", err))
+	}
+	assertion := strings.TrimSpace(string(content))
+	if len(assertion) == 0 {
+		return nil, newConfigError(fmt.Errorf("the SAML assertion file %s is empty", samlAssertionFile))
+	}
+	if strings.HasPrefix(assertion, "<") {
+		assertion = base64.StdEncoding.EncodeToString([]byte(assertion))
+	}
+
+	// And ask AWS for the role session
+	return creds.AssumeRoleWithSAMLCredentials(roleARN, principalARN, assertion, durationSeconds(duration))
+}
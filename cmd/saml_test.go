@@ -0,0 +1,76 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the saml.go functions.
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Where the tests keep a fake SAML assertion
+	testSAMLAssertionFilePath = "./saml.test"
+
+	// The role and SAML provider that the tests assume and present
+	testSAMLRoleARN      = "arn:aws:iam::999999999999:role/admin"
+	testSAMLPrincipalARN = "arn:aws:iam::999999999999:saml-provider/corp"
+)
+
+// TestSAML confirms that, with a SAML assertion, the role is assumed with that rather than
+// an MFA code, and that an assertion saved as XML is base64 encoded for AWS.
+func TestSAML(t *testing.T) {
+
+	// Wash the faces of our muddy children and tidy up before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(testSAMLAssertionFilePath)
+
+	mockChildPackages()
+	var captured *sts.AssumeRoleWithSAMLInput
+	fakeAWS().assumeRoleWithSAML = func(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error) {
+		captured = input
+		return &sts.AssumeRoleWithSAMLOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+	}
+	require.Nil(t, ioutil.WriteFile(testSAMLAssertionFilePath, []byte("PHNhbWxwOlJlc3BvbnNlLz4=\n"), 0600), "could not write the assertion")
+
+	_, stdout := executeCommandCapturingStdout("--saml-assertion-file", testSAMLAssertionFilePath, "--principal-arn", testSAMLPrincipalARN, "--role-arn", testSAMLRoleARN)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "aws_session_token = token", "the credentials should have been displayed")
+	require.Equal(t, testSAMLRoleARN, *captured.RoleArn, "the role ARN was not passed on")
+	require.Equal(t, testSAMLPrincipalARN, *captured.PrincipalArn, "the principal ARN was not passed on")
+	require.Equal(t, "PHNhbWxwOlJlc3BvbnNlLz4=", *captured.SAMLAssertion, "the assertion should have been passed on as it was")
+
+	// The XML that the assertion encodes is encoded for AWS
+	require.Nil(t, ioutil.WriteFile(testSAMLAssertionFilePath, []byte("<samlp:Response/>"), 0600), "could not rewrite the assertion")
+	executeCommandCapturingStdout("--saml-assertion-file", testSAMLAssertionFilePath, "--principal-arn", testSAMLPrincipalARN, "--role-arn", testSAMLRoleARN)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, base64.StdEncoding.EncodeToString([]byte("<samlp:Response/>")), *captured.SAMLAssertion, "the XML should have been encoded")
+}
+
+// TestSAMLConfigErrors confirms that the SAML flags must be given together, without an MFA
+// code, and with an assertion that can be read.
+func TestSAMLConfigErrors(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	for _, args := range [][]string{
+		{"123456", "--principal-arn", testSAMLPrincipalARN},
+		{"--saml-assertion-file", testSAMLAssertionFilePath, "--role-arn", testSAMLRoleARN},
+		{"123456", "--saml-assertion-file", testSAMLAssertionFilePath, "--principal-arn", testSAMLPrincipalARN, "--role-arn", testSAMLRoleARN},
+		{"--saml-assertion-file", "./missing-saml.test", "--principal-arn", testSAMLPrincipalARN, "--role-arn", testSAMLRoleARN},
+	} {
+		executeCommandCapturingStdout(args...)
+		require.NotNil(t, executeError, "there should have been an error for %v", args)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error for %v", args)
+	}
+}
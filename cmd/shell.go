@@ -0,0 +1,169 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the shellenv subcommand and the shell statements written by the --export flag.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/spf13/cobra"
+)
+
+// The values accepted by the --shell flag
+const (
+	shellBash       = "bash"
+	shellZsh        = "zsh"
+	shellFish       = "fish"
+	shellPowerShell = "powershell"
+)
+
+// The name of the shell function printed by the shellenv subcommand
+const shellFunctionName = "mafia-auth"
+
+var (
+	// The shells that we know how to write statements for, in the order that we list them
+	supportedShells = []string{shellBash, shellZsh, shellFish, shellPowerShell}
+
+	// What a --prefix flag value must look like; empty is fine
+	validPrefix = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)?$`)
+)
+
+// shellenvCmd represents the shellenv subcommand
+var shellenvCmd = &cobra.Command{
+	Use:   "shellenv",
+	Short: "Print a shell function that sets session credentials in the current shell",
+	Long: `Prints the definition of a ` + shellFunctionName + ` shell function for your .bashrc, .zshrc,
+or equivalent. A program cannot change the environment of the shell that runs it,
+but a shell function can: calling "` + shellFunctionName + ` 123456" runs mafia with --export
+and evaluates the statements that it prints, setting AWS_ACCESS_KEY_ID,
+AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN in the current shell. Any other
+arguments are passed on to mafia. For example:
+
+   eval "$(mafia shellenv --shell bash)"     # in .bashrc
+   mafia shellenv --shell fish | source      # in config.fish`,
+	Args: cobra.NoArgs,
+
+	// RunE prints the function for the selected shell
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateShell(); err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), shellFunction(shell))
+		return nil
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the shellenv subcommand to the root command
+	rootCmd.AddCommand(shellenvCmd)
+}
+
+// defaultShell returns the shell named by the SHELL environment variable if it is one
+// that we support, and bash otherwise.
+func defaultShell() string {
+	name := filepath.Base(os.Getenv("SHELL"))
+	for _, s := range supportedShells {
+		if name == s {
+			return s
+		}
+	}
+	return shellBash
+}
+
+// validateShell returns a configuration error if the --shell flag value is not one that
+// we know how to write statements for.
+func validateShell() error {
+	for _, s := range supportedShells {
+		if shell == s {
+			return nil
+		}
+	}
+	return newConfigError(fmt.Errorf("--shell must be one of %s, not %q", strings.Join(supportedShells, ", "), shell))
+}
+
+// shellFunction returns the definition of the shellenv function for the given shell. The
+// function passes its arguments on to mafia --export and evaluates what it prints, unless
+// mafia fails.
+func shellFunction(s string) string {
+	switch s {
+	case shellFish:
+		return fmt.Sprintf(`function %[1]s
+    set -l out (mafia --export --shell fish $argv); or return
+    printf '%%s\n' $out | source
+end
+`, shellFunctionName)
+	case shellPowerShell:
+		return fmt.Sprintf(`function %[1]s {
+    $out = mafia --export --shell powershell @args
+    if ($LASTEXITCODE -eq 0) { $out | Out-String | Invoke-Expression }
+}
+`, shellFunctionName)
+	default:
+		return fmt.Sprintf(`%[1]s() {
+    local out
+    out="$(mafia --export --shell %[2]s "$@")" || return
+    eval "$out"
+}
+`, shellFunctionName, s)
+	}
+}
+
+// writeExportStatements writes the statements that set the session credentials as
+// environment variables, named with any --prefix, in the shell selected by the --shell
+// flag, and nothing else, so that the output can be evaluated by that shell.
+func writeExportStatements(w io.Writer, credentials *creds.SessionCredentials) {
+	vars := []struct{ name, value string }{
+		{envPrefix + accessKeyIDEnvVar, *credentials.AccessKeyID},
+		{envPrefix + secretAccessKeyEnvVar, credentials.SecretAccessKey.Value()},
+		{envPrefix + sessionTokenEnvVar, credentials.SessionToken.Value()},
+	}
+	for _, v := range vars {
+		fmt.Fprintln(w, exportStatement(v.name, v.value))
+	}
+}
+
+// exportStatement returns the statement that sets the named environment variable to the
+// given value in the shell selected by the --shell flag.
+func exportStatement(name, value string) string {
+	value = shellQuote(value)
+	switch shell {
+	case shellFish:
+		return fmt.Sprintf("set -gx %s %s", name, value)
+	case shellPowerShell:
+		return fmt.Sprintf("$env:%s = %s", name, value)
+	default:
+		return fmt.Sprintf("export %s=%s", name, value)
+	}
+}
+
+// validatePrefix returns a configuration error if the --prefix flag value would not make
+// for valid environment variable names in every shell: letters, digits, and underscores,
+// not starting with a digit.
+func validatePrefix() error {
+	if !validPrefix.MatchString(envPrefix) {
+		return newConfigError(fmt.Errorf("--prefix may only contain letters, digits, and underscores, and may not start with a digit: %q", envPrefix))
+	}
+	return nil
+}
+
+// shellQuote returns the given value in single quotes, escaping any single quotes within
+// it as the shell selected by the --shell flag expects, so that no character in it is
+// treated specially.
+func shellQuote(value string) string {
+	if shell == shellPowerShell {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
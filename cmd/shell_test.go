@@ -0,0 +1,119 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the shell.go functions.
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestExport confirms that --export displays nothing but the statements that set the
+// session credentials, in the syntax of the selected shell.
+func TestExport(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+
+	// Each shell has its own way of setting environment variables
+	expected := map[string]string{
+		shellBash:       "export AWS_ACCESS_KEY_ID='key'\nexport AWS_SECRET_ACCESS_KEY='secret'\nexport AWS_SESSION_TOKEN='token'\n",
+		shellZsh:        "export AWS_ACCESS_KEY_ID='key'\nexport AWS_SECRET_ACCESS_KEY='secret'\nexport AWS_SESSION_TOKEN='token'\n",
+		shellFish:       "set -gx AWS_ACCESS_KEY_ID 'key'\nset -gx AWS_SECRET_ACCESS_KEY 'secret'\nset -gx AWS_SESSION_TOKEN 'token'\n",
+		shellPowerShell: "$env:AWS_ACCESS_KEY_ID = 'key'\n$env:AWS_SECRET_ACCESS_KEY = 'secret'\n$env:AWS_SESSION_TOKEN = 'token'\n",
+	}
+	for s, statements := range expected {
+		_, stdout := executeCommandCapturingStdout("123456", "--export", "--shell", s)
+		require.Nil(t, executeError, "there should not have been an error: ", executeError)
+		require.Equal(t, statements, stdout, "unexpected statements for %s", s)
+	}
+
+	// Saving as well should not stop the statements from being displayed, nor pollute them
+	_, stdout := executeCommandCapturingStdout("123456", "--export", "--save", "--shell", shellBash)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, expected[shellBash], stdout, "saving should not have changed the statements")
+
+	// Nor are unknown shells or a --format template acceptable
+	executeCommand("123456", "--export", "--shell", "tcsh")
+	require.NotNil(t, executeError, "an unknown shell should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+	executeCommand("123456", "--export", "--format", "{{.AccessKeyID}}")
+	require.NotNil(t, executeError, "--export with --format should have been refused")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
+
+// TestExportPrefix confirms that --prefix namespaces the environment variables, and must
+// make for valid names.
+func TestExportPrefix(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend and return happy answers
+	mockChildPackages()
+
+	_, stdout := executeCommandCapturingStdout("123456", "--export", "--shell", shellBash, "--prefix", "MYAPP_")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "export MYAPP_AWS_ACCESS_KEY_ID='key'\nexport MYAPP_AWS_SECRET_ACCESS_KEY='secret'\nexport MYAPP_AWS_SESSION_TOKEN='token'\n", stdout)
+
+	// The standard display is prefixed too, but not the credentials file section
+	_, stdout = executeCommandCapturingStdout("123456", "--prefix", "MYAPP_")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "export MYAPP_AWS_SESSION_TOKEN=token\n")
+	require.Contains(t, stdout, "aws_session_token = token\n")
+
+	for _, prefix := range []string{"MY-APP_", "1APP_", "MY APP"} {
+		executeCommandCapturingStdout("123456", "--export", "--prefix", prefix)
+		require.NotNil(t, executeError, "the %q prefix should have been refused", prefix)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+	}
+}
+
+// TestShellQuote confirms that single quotes within values are escaped for each shell.
+func TestShellQuote(t *testing.T) {
+
+	// Put the flags back the way we found them
+	defer resetCommand()
+
+	shell = shellBash
+	require.Equal(t, `'it'\''s'`, shellQuote("it's"), "unexpected POSIX quoting")
+	shell = shellPowerShell
+	require.Equal(t, `'it''s'`, shellQuote("it's"), "unexpected PowerShell quoting")
+}
+
+// TestShellenv confirms that the shellenv subcommand prints a function for the selected
+// shell that evaluates the output of mafia --export.
+func TestShellenv(t *testing.T) {
+
+	output := executeCommand("shellenv", "--shell", shellZsh)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "mafia-auth() {", "expected a POSIX function definition")
+	require.Contains(t, output, `out="$(mafia --export --shell zsh "$@")" || return`, "expected the zsh statements to be asked for")
+
+	output = executeCommand("shellenv", "--shell", shellFish)
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "function mafia-auth\n", "expected a fish function definition")
+	require.Contains(t, output, "mafia --export --shell fish $argv", "expected the fish statements to be asked for")
+
+	executeCommand("shellenv", "--shell", "tcsh")
+	require.NotNil(t, executeError, "an unknown shell should have been refused")
+}
+
+// TestDefaultShell confirms that the default --shell follows the SHELL environment
+// variable where it names a shell that we support.
+func TestDefaultShell(t *testing.T) {
+
+	// Put the environment back the way we found it
+	defer setTestEnv("SHELL", "/usr/bin/fish")()
+	require.Equal(t, shellFish, defaultShell(), "expected the shell named by SHELL")
+	os.Setenv("SHELL", "/bin/tcsh")
+	require.Equal(t, shellBash, defaultShell(), "expected bash for an unsupported shell")
+}
@@ -0,0 +1,52 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the use of an AWS SSO role's credentials as the long term identity.
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+)
+
+// validateSSO returns a configuration error if --sso is given with --from-env, which
+// names another source of the long term identity.
+func validateSSO() error {
+	if sso && fromEnv {
+		return newConfigError(errors.New("--sso and --from-env cannot be used together"))
+	}
+	return nil
+}
+
+// ssoRoleCredentials returns the credentials of the AWS SSO role that the named profile
+// of the AWS config file signs in to, exchanging the access token that aws sso login
+// cached for them. The credentials are remembered so that they are masked in any error.
+func ssoRoleCredentials(sourceProfile string) (*credentials.Credentials, error) {
+
+	// Find the profile's SSO settings and the token cached for them
+	ssoProfile, err := mfile.GetSSOProfileFromFile(mfile.ResolveConfigPath(), sourceProfile)
+	if err != nil {
+		return nil, newConfigError(err)
+	}
+	token, err := mfile.GetSSOTokenFromDir(mfile.DefaultSSOCacheDir(), ssoProfile)
+	if err != nil {
+		return nil, newConfigError(err)
+	}
+	rememberValues(token.AccessToken)
+
+	// Trade the token for the role's credentials
+	ssoCredentials, err := creds.GetSSORoleCredentials(token.AccessToken, ssoProfile.Region, ssoProfile.AccountID, ssoProfile.RoleName)
+	if err != nil {
+		return nil, err
+	}
+	if value, err := ssoCredentials.Get(); err == nil {
+		rememberValues(value.AccessKeyID, value.SecretAccessKey, value.SessionToken)
+	}
+	return ssoCredentials, nil
+}
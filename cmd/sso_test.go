@@ -0,0 +1,98 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the sso.go functions.
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ssoapi "github.com/aws/aws-sdk-go/service/sso"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSSO is a fake AWS SSO client, handed to the creds package in place of the real one.
+type fakeSSO func(input *ssoapi.GetRoleCredentialsInput) (*ssoapi.GetRoleCredentialsOutput, error)
+
+// GetRoleCredentials calls the fake.
+func (f fakeSSO) GetRoleCredentials(input *ssoapi.GetRoleCredentialsInput) (*ssoapi.GetRoleCredentialsOutput, error) {
+	return f(input)
+}
+
+// TestSSO confirms that --sso trades the cached SSO access token for the role credentials
+// and assumes the role profile's role with them directly, never asking for a plain session.
+func TestSSO(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer os.Remove(fakeConfigFilePath)
+
+	// A role profile sourced from an SSO profile, and a cached token for the latter
+	mockChildPackages()
+	content := "[profile dev]\nsso_start_url = https://example.awsapps.com/start\nsso_region = eu-west-1\nsso_account_id = 210987654321\nsso_role_name = Developer\n\n" +
+		"[profile admin]\nrole_arn = arn:aws:iam::210987654321:role/admin\nsource_profile = dev\nmfa_serial = arn:aws:iam::210987654321:mfa/pat\n"
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+	dir, err := ioutil.TempDir("", "mafia-sso-cache")
+	require.Nil(t, err, "could not create a temporary directory")
+	defer os.RemoveAll(dir)
+	mfile.OverrideDefaultSSOCacheDir(dir)
+
+	// Fake SSO and STS, capturing what is asked of them
+	var ssoInput *ssoapi.GetRoleCredentialsInput
+	creds.SetSSOClient(fakeSSO(func(input *ssoapi.GetRoleCredentialsInput) (*ssoapi.GetRoleCredentialsOutput, error) {
+		ssoInput = input
+		return &ssoapi.GetRoleCredentialsOutput{RoleCredentials: &ssoapi.RoleCredentials{
+			AccessKeyId: aws.String("ASIASSO"), SecretAccessKey: aws.String("sso-secret"), SessionToken: aws.String("sso-token"),
+		}}, nil
+	}))
+	sessions := countSTSCalls()
+	var roleInput *sts.AssumeRoleInput
+	fakeAWS().assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		roleInput = input
+		return &sts.AssumeRoleOutput{Credentials: getSessionTokenOutput.Credentials}, nil
+	}
+
+	// Without a cached token, the user is told to sign in
+	executeCommandCapturingStdout("123456", "--sso", "--profile", "admin")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code")
+	require.Contains(t, executeError.Error(), "run aws sso login --profile dev", "not the expected error")
+
+	// With one, the role is assumed with the profile's MFA device
+	digest := sha1.Sum([]byte("https://example.awsapps.com/start"))
+	cached := `{"accessToken": "sso-access-token", "expiresAt": "2099-01-01T00:00:00Z"}`
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, hex.EncodeToString(digest[:])+".json"), []byte(cached), 0600), "could not cache the token")
+	executeCommandCapturingStdout("123456", "--sso", "--profile", "admin", "--save")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "sso-access-token", *ssoInput.AccessToken, "the cached token should have been used")
+	require.Equal(t, "Developer", *ssoInput.RoleName, "the SSO profile's role should have been asked for")
+	require.Equal(t, 0, *sessions, "no plain session should have been asked for")
+	require.NotNil(t, roleInput, "AssumeRole should have been called")
+	require.Equal(t, "arn:aws:iam::210987654321:role/admin", *roleInput.RoleArn, "role ARN was not passed on")
+	require.Equal(t, "arn:aws:iam::210987654321:mfa/pat", *roleInput.SerialNumber, "the role profile's MFA device should have been used")
+	require.Equal(t, "123456", *roleInput.TokenCode, "the MFA code should have gone with the role request")
+
+	// Or without MFA at all
+	executeCommandCapturingStdout("--sso", "--no-mfa", "--profile", "admin")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Nil(t, roleInput.SerialNumber, "no MFA device should have gone with the role request")
+
+	// SSO credentials must assume a role, and come from nowhere else
+	for _, args := range [][]string{
+		{"123456", "--sso", "--profile", "dev"},
+		{"123456", "--sso", "--from-env", "--profile", "admin"},
+	} {
+		executeCommandCapturingStdout(args...)
+		require.Equal(t, exitConfigError, exitCode, "expected a configuration error exit code with %v", args)
+	}
+}
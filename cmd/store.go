@@ -0,0 +1,72 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the functions that save session credentials to, and reuse them from, the OS secret store.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/store"
+)
+
+// The values accepted by the --store flag
+const (
+	storeFile     = "file"     // The AWS credentials file
+	storeKeychain = "keychain" // The operating system's secret store
+)
+
+// validateStore returns a configuration error if the --store flag value is not one that
+// we know, or asks for something that only the credentials file can do.
+func validateStore() error {
+	switch storeName {
+	case storeFile:
+		return nil
+	case storeKeychain:
+		if inPlace || copySettings || showDiff {
+			return newConfigError(errors.New("--in-place, --copy-profile-settings, and --show-diff cannot be used with --store " + storeKeychain))
+		}
+		return nil
+	default:
+		return newConfigError(fmt.Errorf("--store must be %s or %s, not %q", storeFile, storeKeychain, storeName))
+	}
+}
+
+// saveToKeychain saves the session credentials to the operating system's secret store,
+// keyed by the profile that they were obtained for.
+func saveToKeychain(credentials *creds.SessionCredentials) error {
+	keychain, err := store.Keychain()
+	if err != nil {
+		return newConfigError(err)
+	}
+	return keychain.Save(profile, &store.Credentials{
+		AccessKeyID:     *credentials.AccessKeyID,
+		SecretAccessKey: credentials.SecretAccessKey.Value(),
+		SessionToken:    credentials.SessionToken.Value(),
+		Expiration:      credentials.Expiration,
+	})
+}
+
+// loadFromKeychain returns the session credentials saved to the operating system's
+// secret store for the profile, or nil if there are none or they cannot be read.
+func loadFromKeychain() *creds.SessionCredentials {
+	keychain, err := store.Keychain()
+	if err != nil {
+		return nil
+	}
+	saved, err := keychain.Load(profile)
+	if err != nil || saved == nil {
+		return nil
+	}
+	return &creds.SessionCredentials{
+		AccessKeyID:     &saved.AccessKeyID,
+		SecretAccessKey: creds.NewSecret(saved.SecretAccessKey),
+		SessionToken:    creds.NewSecret(saved.SessionToken),
+		Expiration:      saved.Expiration,
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the store.go functions.
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/mikebway/mafia/store"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKeychain is an in memory stand in for the operating system's secret store.
+type fakeKeychain map[string]*store.Credentials
+
+// Save stores the credentials in the map.
+func (k fakeKeychain) Save(profile string, credentials *store.Credentials) error {
+	k[profile] = credentials
+	return nil
+}
+
+// Load returns the credentials from the map.
+func (k fakeKeychain) Load(profile string) (*store.Credentials, error) {
+	return k[profile], nil
+}
+
+// useFakeKeychain hands the store package an empty fakeKeychain, returning it.
+func useFakeKeychain() fakeKeychain {
+	keychain := fakeKeychain{}
+	store.SetKeychainFunc(func() (store.Store, error) { return keychain, nil })
+	return keychain
+}
+
+// TestStoreKeychain confirms that --store keychain saves to, and reuses from, the secret
+// store without touching the credentials file.
+func TestStoreKeychain(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func(e time.Time) { expiration = e }(expiration)
+
+	// Save a session that is good for another hour to the keychain
+	mockChildPackages()
+	keychain := useFakeKeychain()
+	expiration = time.Now().Add(time.Hour).Truncate(time.Second)
+	before, _ := ioutil.ReadFile(fakeCredentialsFilePath)
+	_, stdout := executeCommandCapturingStdout("123456", "--save", "--store", "keychain", "--profile", "default")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, stdout, "Session credentials saved to keychain")
+	require.Equal(t, &store.Credentials{AccessKeyID: accessKey, SecretAccessKey: secret, SessionToken: token, Expiration: &expiration},
+		keychain["default"], "the credentials should have been saved to the keychain")
+	after, _ := ioutil.ReadFile(fakeCredentialsFilePath)
+	require.Equal(t, string(before), string(after), "the credentials file should not have been touched")
+
+	// Reusing them should not trouble AWS
+	calls := countSTSCalls()
+	executeCommandCapturingStdout("123456", "--reuse", "--store", "keychain")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 0, *calls, "AWS should not have been called")
+}
+
+// TestStoreValidation confirms that --store must be one that we know, and that flags that
+// only make sense for the credentials file are not accepted with the keychain.
+func TestStoreValidation(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	mockChildPackages()
+	useFakeKeychain()
+	for _, args := range [][]string{
+		{"--store", "vault"},
+		{"--save", "--store", "keychain", "--in-place"},
+		{"--save", "--store", "keychain", "--copy-profile-settings"},
+	} {
+		executeCommandCapturingStdout(append([]string{"123456"}, args...)...)
+		require.NotNil(t, executeError, "%v should have been rejected", args)
+		require.Equal(t, exitConfigError, exitCode, "%v should be a configuration error", args)
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the functions that generate MFA codes from a profile's virtual MFA device secret.
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+)
+
+var (
+	// How we wait for the next MFA code; unit tests substitute their own function for time.Sleep
+	sleepFunc = time.Sleep
+)
+
+// hasTOTPSecret returns true if the profile, or the source profile of a role profile,
+// holds a virtual MFA device secret that MFA codes can be generated from, so that none
+// need be given on the command line.
+func hasTOTPSecret() bool {
+	sourceProfile, _, err := resolveProfiles()
+	if err != nil {
+		return false
+	}
+	secret, err := mfile.GetProfileTOTPSecretFromFile(credentialsFilepath(), sourceProfile)
+	return err == nil && len(secret) != 0
+}
+
+// generateMFACode returns the current MFA code of the virtual MFA device whose secret is
+// held in the named profile section of the credentials file.
+func generateMFACode(envMode bool, sourceProfile string) (string, error) {
+	if envMode {
+		return "", newConfigError(errors.New("an MFA code must be given with credentials from the environment"))
+	}
+	secret, err := mfile.GetProfileTOTPSecretFromFile(credentialsFilepath(), sourceProfile)
+	if err != nil {
+		return "", newConfigError(err)
+	}
+	if len(secret) == 0 {
+		return "", newConfigError(fmt.Errorf("no MFA code was given and the %s profile has no %s", sourceProfile, mfile.MfaTOTPSecretKey))
+	}
+	code, err := creds.GenerateTOTPCode(secret)
+	if err != nil {
+		return "", newConfigError(fmt.Errorf("the %s profile's %s: %w", sourceProfile, mfile.MfaTOTPSecretKey, err))
+	}
+	return code, nil
+}
+
+// retryWithNextMFACode returns true if, having generated the MFA code that AWS has just
+// turned away as already used, we were asked by --wait-for-next to try again with the
+// next one. If so, it waits for that code to come round.
+func retryWithNextMFACode(generated bool, err error) bool {
+	if !generated || !waitForNext || !errors.Is(err, creds.ErrMFACodeReused) {
+		return false
+	}
+	wait := creds.UntilNextTOTPCode()
+	fmt.Fprintf(warningOutput, "MFA code already used; waiting %v for the next one\n", wait.Round(time.Second))
+	sleepFunc(wait)
+	return true
+}
+
+// explainReusedMFACode returns the given error, adding when the next MFA code is due, and
+// how to have it waited for, if AWS turned away as already used a code that we generated
+// without being asked by --wait-for-next to wait for another.
+func explainReusedMFACode(generated bool, err error) error {
+	if !generated || waitForNext || !errors.Is(err, creds.ErrMFACodeReused) {
+		return err
+	}
+	return fmt.Errorf("%w; the next code is due in %v, or add --wait-for-next to wait for it automatically", err, creds.UntilNextTOTPCode().Round(time.Second))
+}
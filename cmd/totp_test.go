@@ -0,0 +1,127 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the totp.go functions.
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/mikebway/mafia/creds"
+	"github.com/mikebway/mafia/mfile"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// The RFC 6238 test secret, "12345678901234567890", in base32
+	fakeTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+)
+
+// useFakeTOTPSecret gives the default profile of the fake credentials file a virtual MFA
+// device secret, and freezes the time at which codes are generated from it.
+func useFakeTOTPSecret(t *testing.T) {
+	mockChildPackages()
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not load the fake credentials file")
+	cfg.Section(mfile.DefaultSectionName).NewKey(mfile.MfaTOTPSecretKey, fakeTOTPSecret)
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "could not write the fake credentials file")
+	creds.SetNowFunc(func() time.Time { return time.Unix(59, 0) })
+}
+
+// TestGeneratedMFACode confirms that, when no MFA code is given, one is generated from the
+// profile's virtual MFA device secret.
+func TestGeneratedMFACode(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	useFakeTOTPSecret(t)
+	captured := mockSTSCapturingInput()
+	output, _ := executeCommandCapturingStdout()
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.NotContains(t, output, "Usage:", "help should not have been displayed")
+	require.Equal(t, "287082", *captured.TokenCode, "the generated code should have been sent")
+
+	// A code given on the command line still wins
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "123456", *captured.TokenCode, "the given code should have been sent")
+}
+
+// TestWaitForNext confirms that, with --wait-for-next, a generated code that AWS says was
+// already used is followed by the next one, and that without it the failure stands.
+func TestWaitForNext(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { warningOutput = os.Stderr }()
+	defer func() { sleepFunc = time.Sleep }()
+
+	// Reject the first code as used, moving the clock on to the next window while we wait
+	useFakeTOTPSecret(t)
+	var warnings bytes.Buffer
+	warningOutput = &warnings
+	var slept time.Duration
+	sleepFunc = func(d time.Duration) {
+		slept = d
+		creds.SetNowFunc(func() time.Time { return time.Unix(60, 0) })
+	}
+	var codes []string
+	fakeAWS().getSessionToken = func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		codes = append(codes, *input.TokenCode)
+		if len(codes) == 1 {
+			return nil, awserr.New("AccessDenied", "MultiFactorAuthentication failed, unable to validate MFA code.", nil)
+		}
+		return getSessionTokenOutput, nil
+	}
+
+	executeCommandCapturingStdout("--wait-for-next")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, time.Second, slept, "should have waited for the next window")
+	require.Len(t, codes, 2, "the next code should have been tried")
+	require.Equal(t, "287082", codes[0])
+	require.NotEqual(t, codes[0], codes[1], "a fresh code should have been generated")
+	require.Contains(t, warnings.String(), "waiting 1s for the next one")
+
+	// Without the flag, there is no second attempt, but the user is told when to try again
+	creds.SetNowFunc(func() time.Time { return time.Unix(59, 0) })
+	codes = nil
+	executeCommandCapturingStdout()
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitAuthRejected, exitCode, "expected an authentication error exit code")
+	require.Len(t, codes, 1, "there should have been no second attempt")
+	require.Equal(t, "This MFA code was already used — wait for a new one; the next code is due in 1s, or add --wait-for-next to wait for it automatically", executeError.Error())
+
+	// Nor is there for a code that was given on the command line, which we cannot say more about
+	codes = nil
+	executeCommandCapturingStdout("123456", "--wait-for-next")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Len(t, codes, 1, "there should have been no second attempt")
+	require.Equal(t, "This MFA code was already used — wait for a new one", executeError.Error())
+}
+
+// TestInvalidTOTPSecret confirms that a secret that is not base32 is reported as a
+// configuration error.
+func TestInvalidTOTPSecret(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	useFakeTOTPSecret(t)
+	cfg, _ := ini.Load(fakeCredentialsFilePath)
+	cfg.Section(mfile.DefaultSectionName).Key(mfile.MfaTOTPSecretKey).SetValue("not base32!")
+	require.Nil(t, cfg.SaveTo(fakeCredentialsFilePath), "could not rewrite the credentials file")
+
+	executeCommandCapturingStdout()
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Contains(t, executeError.Error(), "the default profile's mfa_totp_secret")
+	require.Equal(t, exitConfigError, exitCode, "expected a configuration error")
+}
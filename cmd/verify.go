@@ -0,0 +1,29 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the proof, with --verify, that new session credentials work.
+
+import (
+	"fmt"
+
+	"github.com/mikebway/mafia/creds"
+)
+
+// verifySessionCredentials asks AWS STS who the given session credentials belong to,
+// returning an error, classified as AWS's refusal was, if they do not work. With
+// --verbose, the identity that they belong to is reported.
+func verifySessionCredentials(credentials *creds.SessionCredentials) error {
+	rememberSecrets(credentials)
+	identity, err := creds.GetSessionIdentity(credentials)
+	if err != nil {
+		return fmt.Errorf("the new session credentials do not work, so they were neither saved nor displayed: %w", err)
+	}
+	if verbose {
+		fmt.Fprintf(warningOutput, "Verified the session credentials, which belong to %s\n", identity.ARN)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the verify.go functions.
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+// TestVerify confirms that --verify asks STS who the new session credentials belong to
+// before saving them, and that credentials that do not work are neither saved nor shown.
+func TestVerify(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+	defer func() { warningOutput = os.Stderr }()
+
+	// Without the flag, nobody asks
+	mockChildPackages()
+	asked := 0
+	fakeAWS().getCallerIdentity = func(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+		asked++
+		return &sts.GetCallerIdentityOutput{Arn: aws.String("arn:aws:iam::210987654321:user/pat")}, nil
+	}
+	executeCommandCapturingStdout("123456")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 0, asked, "the credentials should not have been verified")
+
+	// With it, the working credentials are saved, and who they belong to can be reported
+	var warnings bytes.Buffer
+	warningOutput = &warnings
+	executeCommandCapturingStdout("123456", "--verify", "--save", "--verbose")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, 1, asked, "the credentials should have been verified")
+	require.Contains(t, warnings.String(), "Verified the session credentials, which belong to arn:aws:iam::210987654321:user/pat")
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not read the fake credentials file")
+	_, err = cfg.GetSection("default-session")
+	require.Nil(t, err, "the session should have been saved")
+
+	// But credentials that do not work go nowhere
+	writeFakeCredentials(fakeMFADeviceID)
+	fakeAWS().getCallerIdentity = func(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+		return nil, awserr.New("InvalidClientTokenId", "The security token included in the request is invalid.", nil)
+	}
+	_, stdout := executeCommandCapturingStdout("123456", "--verify", "--save")
+	require.Equal(t, exitAuthRejected, exitCode, "expected an authentication error exit code")
+	require.Contains(t, executeError.Error(), "the new session credentials do not work", "not the expected error")
+	require.NotContains(t, stdout, secret, "the credentials should not have been displayed")
+	cfg, err = ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not read the fake credentials file")
+	_, err = cfg.GetSection("default-session")
+	require.NotNil(t, err, "the session should not have been saved")
+}
@@ -0,0 +1,51 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the version subcommand and the build metadata that it reports.
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Build metadata, injected at build time with -ldflags as follows:
+	//
+	//	go build -ldflags "-X github.com/mikebway/mafia/cmd.version=1.2.3 \
+	//	    -X github.com/mikebway/mafia/cmd.commit=$(git rev-parse --short HEAD) \
+	//	    -X github.com/mikebway/mafia/cmd.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// versionCmd represents the version subcommand
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Display the mafia version, git commit, and build date",
+	Args:  cobra.NoArgs,
+
+	// Run simply prints the version information
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Fprintf(cmd.OutOrStdout(), "mafia version %s\n", versionString())
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the version subcommand to the root command and have the root command
+	// support a --version flag too
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.Version = versionString()
+}
+
+// versionString combines the build metadata into a single string.
+func versionString() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", version, commit, buildDate)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the version.go functions.
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVersionCommand confirms that the version subcommand reports the build metadata.
+func TestVersionCommand(t *testing.T) {
+
+	output := executeCommand("version")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "mafia version dev (commit unknown, built unknown)\n", output, "unexpected version output")
+}
+
+// TestVersionFlag confirms that the --version flag reports the build metadata.
+func TestVersionFlag(t *testing.T) {
+
+	output := executeCommand("--version")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Contains(t, output, "version dev (commit unknown, built unknown)", "unexpected version output")
+}
+
+// TestVersionString confirms that injected build metadata finds its way into the version string.
+func TestVersionString(t *testing.T) {
+
+	// Put the metadata back as it was when we are done
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+
+	version, commit, buildDate = "1.2.3", "abc1234", "2020-04-01T12:00:00Z"
+	require.Equal(t, "1.2.3 (commit abc1234, built 2020-04-01T12:00:00Z)", versionString())
+}
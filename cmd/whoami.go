@@ -0,0 +1,57 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See root.go for overall package documentation. This file contains
+// the whoami subcommand.
+
+import (
+	"fmt"
+
+	"github.com/mikebway/mafia/creds"
+	"github.com/spf13/cobra"
+)
+
+// whoamiCmd represents the whoami subcommand
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Display the IAM identity behind the long term credentials, without MFA",
+	Long: `Asks AWS STS who the long term credentials of the selected profile belong to,
+displaying the account number, user ID, and ARN. No MFA code is needed, making
+this a cheap way to confirm that mafia will be using the keys that you expect
+before spending a code on them.`,
+	Args: cobra.NoArgs,
+
+	// RunE asks AWS who we are and prints the answer
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Use the same long term credentials that the root command would
+		sourceProfile, _, err := resolveProfiles()
+		if err != nil {
+			return err
+		}
+		if _, err = configureCreds(sourceProfile); err != nil {
+			return err
+		}
+
+		// Ask and tell
+		identity, err := creds.GetCallerIdentity()
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "Account: %s\n", identity.Account)
+		fmt.Fprintf(out, "UserId:  %s\n", identity.UserID)
+		fmt.Fprintf(out, "Arn:     %s\n", identity.ARN)
+		return nil
+	},
+}
+
+// Load time initialization - called automatically
+func init() {
+
+	// Add the whoami subcommand to the root command
+	rootCmd.AddCommand(whoamiCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// Unit tests for the whoami.go functions.
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWhoami confirms that the whoami subcommand displays the caller identity.
+func TestWhoami(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, with a known identity
+	mockChildPackages()
+	fakeAWS().getCallerIdentity = func(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+		return &sts.GetCallerIdentityOutput{
+			Account: aws.String("123456789012"),
+			UserId:  aws.String("AIDAEXAMPLE"),
+			Arn:     aws.String("arn:aws:iam::123456789012:user/jane"),
+		}, nil
+	}
+
+	output := executeCommand("whoami")
+	require.Nil(t, executeError, "there should not have been an error: ", executeError)
+	require.Equal(t, "Account: 123456789012\nUserId:  AIDAEXAMPLE\nArn:     arn:aws:iam::123456789012:user/jane\n", output)
+}
+
+// TestWhoamiRejected confirms that unrecognized credentials give an auth_rejected exit code.
+func TestWhoamiRejected(t *testing.T) {
+
+	// Wash the faces of our muddy children before we leave the function
+	defer resetChildPackages()
+
+	// Configure our child packages to pretend, with credentials that AWS does not recognize
+	mockChildPackages()
+	fakeAWS().getCallerIdentity = func(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+		return nil, awserr.New("InvalidClientTokenId", "The security token included in the request is invalid.", nil)
+	}
+
+	executeCommand("whoami")
+	require.NotNil(t, executeError, "there should have been an error")
+	require.Equal(t, exitAuthRejected, exitCode, "expected an auth rejected exit code")
+}
@@ -6,37 +6,77 @@
 package creds
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sts"
 )
 
-// SessionCredentials wraps the AWS credentials obtained for and API authentication session
+// SessionCredentials wraps the AWS credentials obtained for and API authentication session.
+// The sensitive values are held as Secrets so that they can be wiped with Wipe() once they
+// have been displayed or saved.
 type SessionCredentials struct {
 	AccessKeyID     *string // The access key ID that identifies the temporary security credentials
-	SecretAccessKey *string
-	SessionToken    *string
+	SecretAccessKey *Secret
+	SessionToken    *Secret
+	Expiration      *time.Time // When the credentials stop working
 }
 
-// GetSessionTokenFunc is a function type that corresponds to the AWS STS function for obtaining
-// a session token. Rather than calling this function directly from GetSessionCredentials(..),
-// it is called via a function variable; when unit testing, this function variable can be
-// be overridden and point to a mock implementation.
-type GetSessionTokenFunc func(awsService *sts.STS, input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error)
+const (
+	// The region used to sign requests sent to an overridden STS endpoint when no
+	// region has been configured; this is the signing region of the global STS endpoint.
+	defaultSigningRegion = "us-east-1"
+)
+
+// stsAPI is the subset of the AWS STS client methods that this package calls. The package
+// depends on this interface rather than on *sts.STS so that unit tests can substitute a
+// single fake implementation of all of the calls via SetSTSClient(..).
+type stsAPI interface {
+	GetSessionToken(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error)
+	AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error)
+	AssumeRoleWithSAML(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error)
+	GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error)
+}
 
 var (
 
-	// A function variable that, normally, wraps the AWS STS GetSessionToken(..) function
-	// but can be overridden for unit testsing. This is initialied at load time via a call to
-	// the ResetPackageDefaults(..) function.
-	getSessionTokenFunc GetSessionTokenFunc
+	// The STS client to be used in place of a real AWS one, if any. Set by unit tests
+	// via SetSTSClient(..) and cleared by ResetPackageDefaults(..).
+	stsClient stsAPI
+
+	// The URL of the STS endpoint to be called in place of the standard AWS one, if any.
+	// Set via SetSTSEndpoint(..) and cleared by ResetPackageDefaults(..).
+	stsEndpoint string
+
+	// The AWS region whose STS endpoint is to be called, if any. If empty, the SDK default
+	// applies. Set via SetRegion(..) and cleared by ResetPackageDefaults(..).
+	region string
+
+	// The function that tells the package what time it is, overridden by unit tests that
+	// need to freeze time. Set via SetNowFunc(..) and restored by ResetPackageDefaults(..).
+	nowFunc func() time.Time
+
+	// The long term credentials used to authenticate requests for session credentials.
+	// If nil, the default AWS SDK credentials chain applies. Set via SetLongTermCredentials(..)
+	// and cleared by ResetPackageDefaults(..).
+	longTermCredentials *credentials.Credentials
+
+	// Whether STS requests skip the SDK's own checks of their parameters, such as the
+	// 900 second minimum duration, leaving the endpoint to decide. Set via
+	// SetSkipParamValidation(..) and cleared by ResetPackageDefaults(..).
+	skipParamValidation bool
 )
 
 // Load time initialization
 func init() {
 
-	// Configure the default state of this package, in particular, set the getSessionTokenFunc
-	// to reference a function that wraps the AWS STS GetSessionToken(..) function.
+	// Configure the default state of this package, in particular, set the listMFADevicesFunc
+	// to reference a function that wraps the AWS IAM ListMFADevices(..) function.
 	ResetPackageDefaults()
 }
 
@@ -49,38 +89,146 @@ func init() {
 //
 // Duration is a time period expressed as a number of seconds. AWS accepts values
 // between 900 seconds (15 minutes) to 129,600 seconds (36 hours).
+//
+// If the mfaSerialNumber is empty, the session is obtained with the long term credentials
+// alone, as IAM users without an MFA device may do. Such a session is short lived but has
+// no MFA protection whatsoever: anyone holding the long term credentials can obtain one.
 func GetSessionCredentials(mfaSerialNumber, mfaToken string, duration int64) (*SessionCredentials, error) {
+	return GetSessionCredentialsWithConfig(nil, mfaSerialNumber, mfaToken, duration)
+}
 
-	// Obtain an AWS STS client
-	svc := sts.New(session.New())
+// GetSessionCredentialsWithConfig is GetSessionCredentials(..) for library users who
+// already have an AWS configuration of their own, e.g. with a custom credentials provider,
+// HTTP client, or proxy. The given configuration is applied over that of the environment
+// and of this package, so its settings win; it may be nil. If it sets its own Retryer or
+// MaxRetries, the SDK is left to do the retrying and SetMaxRetries(..) does not apply.
+func GetSessionCredentialsWithConfig(cfg *aws.Config, mfaSerialNumber, mfaToken string, duration int64) (*SessionCredentials, error) {
+
+	// Obtain an AWS STS client, or the fake that unit tests have given us
+	svc := stsClientFor(nil, cfg)
 
 	// Prep the input structure for the get session request
-	input := &sts.GetSessionTokenInput{
-		DurationSeconds: aws.Int64(duration),
-		SerialNumber:    aws.String(mfaSerialNumber),
-		TokenCode:       aws.String(mfaToken),
-	}
+	input := &sts.GetSessionTokenInput{DurationSeconds: aws.Int64(duration)}
+	input.SerialNumber, input.TokenCode = mfaInput(mfaSerialNumber, mfaToken)
 
-	// Request a new session from AWS via our wrapper function variable.
-	// When unit testing, the
-	result, err := getSessionTokenFunc(svc, input)
+	// Request a new session from AWS, retrying if AWS is having a bad day and the
+	// configuration has not taken that job on itself
+	var result *sts.GetSessionTokenOutput
+	err := retrierFor(cfg)(func() (err error) {
+		result, err = svc.GetSessionToken(input)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	// Translate the result into our own format that does not require the caller
 	// to also import the AWS STS package and return that
+	return newSessionCredentials(result.Credentials), nil
+}
+
+// mfaInput returns the serial number and code to send AWS STS for the given MFA device and
+// code, or nil for both, so that neither is sent, if no MFA device is given.
+func mfaInput(mfaSerialNumber, mfaToken string) (*string, *string) {
+	if len(mfaSerialNumber) == 0 {
+		return nil, nil
+	}
+	return aws.String(mfaSerialNumber), aws.String(mfaToken)
+}
+
+// newSessionCredentials translates the credentials returned by AWS STS into our own format,
+// taking its own copies of the sensitive values.
+func newSessionCredentials(c *sts.Credentials) *SessionCredentials {
 	return &SessionCredentials{
-		AccessKeyID:     result.Credentials.AccessKeyId,
-		SecretAccessKey: result.Credentials.SecretAccessKey,
-		SessionToken:    result.Credentials.SessionToken,
-	}, nil
+		AccessKeyID:     c.AccessKeyId,
+		SecretAccessKey: NewSecret(aws.StringValue(c.SecretAccessKey)),
+		SessionToken:    NewSecret(aws.StringValue(c.SessionToken)),
+		Expiration:      c.Expiration,
+	}
+}
+
+// Wipe zeroes the secret access key and session token of the credentials, after which
+// they are useless. See the Secret type for the limits of what this can achieve.
+func (c *SessionCredentials) Wipe() {
+	c.SecretAccessKey.Wipe()
+	c.SessionToken.Wipe()
+}
+
+// Remaining returns how long the credentials have left to run before they expire. The
+// value is negative if they have already expired, and zero if their expiration time is
+// unknown.
+func (c *SessionCredentials) Remaining() time.Duration {
+	if c.Expiration == nil {
+		return 0
+	}
+	return c.Expiration.Sub(nowFunc())
+}
+
+// Expired returns true if the credentials have an expiration time and it has passed.
+func (c *SessionCredentials) Expired() bool {
+	return c.Expiration != nil && !nowFunc().Before(*c.Expiration)
+}
+
+// SetSTSEndpoint overrides the URL of the STS endpoint that will be called to obtain
+// credentials, e.g. for GovCloud or China partitions, or to test against localstack.
+// An empty string restores the standard AWS endpoint.
+func SetSTSEndpoint(endpoint string) {
+	stsEndpoint = endpoint
+}
+
+// FIPSEndpoint returns the URL of the FIPS 140-2 validated STS endpoint for the given
+// region, or for the signing region of the global endpoint if none is given. The STS
+// endpoints of the GovCloud regions are FIPS validated as they are; elsewhere, only the
+// regions that AWS offers a separate FIPS endpoint for have one.
+func FIPSEndpoint(r string) (string, error) {
+	if len(r) == 0 {
+		r = defaultSigningRegion
+	}
+	resolver := endpoints.DefaultResolver()
+	if e, err := resolver.EndpointFor(sts.EndpointsID, r+"-fips", endpoints.StrictMatchingOption); err == nil {
+		return e.URL, nil
+	}
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), r); ok && p.ID() == endpoints.AwsUsGovPartitionID {
+		if e, err := resolver.EndpointFor(sts.EndpointsID, r, endpoints.StrictMatchingOption); err == nil {
+			return e.URL, nil
+		}
+	}
+	return "", fmt.Errorf("AWS has no FIPS STS endpoint in the %s region", r)
 }
 
-// SetGetSessionTokenFunc allows unit tests to substitute a mock function in place of
-// the default AWS STS GetSessionToken(..) wrapper so that tests can control the responses.
-func SetGetSessionTokenFunc(f GetSessionTokenFunc) {
-	getSessionTokenFunc = f
+// SetRegion sets the AWS region whose regional STS endpoint is to be called, in place
+// of the global endpoint. An empty string restores the SDK default behavior.
+func SetRegion(r string) {
+	region = r
+}
+
+// SetLongTermCredentials sets the long term credentials that requests for session
+// credentials are authenticated with, e.g. credentials.NewEnvCredentials() to insist
+// on those found in the environment. Passing nil restores the default AWS SDK
+// credentials chain.
+func SetLongTermCredentials(c *credentials.Credentials) {
+	longTermCredentials = c
+}
+
+// SetSkipParamValidation has STS requests skip the AWS SDK's client side checks of their
+// parameters, e.g. the minimum session duration, so that an STS compatible endpoint such
+// as localstack, which may not enforce the same limits, has the last word. Passing false
+// restores the checks.
+func SetSkipParamValidation(skip bool) {
+	skipParamValidation = skip
+}
+
+// SetNowFunc allows unit tests to substitute a function of their own for time.Now(..)
+// so that time dependent behavior can be tested deterministically.
+func SetNowFunc(f func() time.Time) {
+	nowFunc = f
+}
+
+// SetSTSClient allows unit tests to substitute a fake STS client in place of the real
+// AWS one so that tests can control the responses to all of the STS calls made by the
+// package. Passing nil restores the real client.
+func SetSTSClient(client stsAPI) {
+	stsClient = client
 }
 
 // ResetPackageDefaults establishes or reestablishes the normal package global values.
@@ -88,8 +236,95 @@ func SetGetSessionTokenFunc(f GetSessionTokenFunc) {
 // leave the package as they found it.
 func ResetPackageDefaults() {
 
-	// Configure the function wrapper used to ask AWS STS for a session token
-	getSessionTokenFunc = func(awsService *sts.STS, input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
-		return awsService.GetSessionToken(input)
+	// Tell the real time, and use the standard AWS STS endpoint, credentials chain,
+	// proxy environment variables, user agent, and retry behavior, with no external ID
+	nowFunc = time.Now
+	stsEndpoint = ""
+	region = ""
+	longTermCredentials = nil
+	skipParamValidation = false
+	proxyURL = nil
+	externalID = ""
+	stsClient = nil
+	ssoClient = nil
+	userAgent = DefaultUserAgent
+	resetRetryDefaults()
+
+	// Configure the function wrapper used to ask AWS IAM for a user's MFA devices
+	listMFADevicesFunc = func(awsService *iam.IAM, input *iam.ListMFADevicesInput) (*iam.ListMFADevicesOutput, error) {
+		return awsService.ListMFADevices(input)
+	}
+
+	// And the one used to ask AWS IAM about a role
+	getRoleFunc = func(awsService *iam.IAM, input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+		return awsService.GetRole(input)
+	}
+}
+
+// stsClientFor returns the fake STS client that unit tests have set, if any, otherwise
+// a real AWS STS client as returned by newSTSClientWithConfig(..).
+func stsClientFor(c *credentials.Credentials, override *aws.Config) stsAPI {
+	if stsClient != nil {
+		return stsClient
+	}
+	return newSTSClientWithConfig(c, override)
+}
+
+// newSTSClient returns an AWS STS client configured from the environment and
+// any endpoint or credentials overrides that have been set for the package.
+func newSTSClient() *sts.STS {
+	return newSTSClientWithCredentials(nil)
+}
+
+// newSTSClientWithCredentials returns an AWS STS client configured as by newSTSClient(..)
+// but, if c is not nil, authenticating with the given credentials.
+func newSTSClientWithCredentials(c *credentials.Credentials) *sts.STS {
+	return newSTSClientWithConfig(c, nil)
+}
+
+// newSTSClientWithConfig returns an AWS STS client configured as by
+// newSTSClientWithCredentials(..) and then, if override is not nil, by override.
+func newSTSClientWithConfig(c *credentials.Credentials, override *aws.Config) *sts.STS {
+
+	// Start with the configuration that the environment gives us
+	sess := newSession()
+	cfg := clientConfig()
+	if c != nil {
+		cfg = cfg.WithCredentials(c)
+	}
+
+	// If the endpoint has been overridden, make sure we have a region to sign
+	// requests with because the SDK will not know how to derive one
+	if len(stsEndpoint) != 0 {
+		cfg = cfg.WithEndpoint(stsEndpoint)
+		if len(aws.StringValue(sess.Config.Region)) == 0 && len(region) == 0 {
+			cfg = cfg.WithRegion(defaultSigningRegion)
+		}
+	}
+
+	// Build the client from all that, letting any override have the last word, and
+	// leaving the endpoint to check the parameters if we have been told to
+	svc := sts.New(sess, cfg, override)
+	if skipParamValidation {
+		svc.Handlers.Validate.RemoveByName(corehandlers.ValidateParametersHandler.Name)
+	}
+	return svc
+}
+
+// clientConfig returns the AWS configuration common to all of the clients used by
+// this package, reflecting any overrides that have been set for the package.
+func clientConfig() *aws.Config {
+
+	// We do our own retrying, so the SDK must not, we go through any proxy that we have
+	// been told about, and apply the long term credentials if we have been given some
+	cfg := aws.NewConfig().WithMaxRetries(0).WithHTTPClient(httpClient())
+	if longTermCredentials != nil {
+		cfg = cfg.WithCredentials(longTermCredentials)
+	}
+
+	// If we have been given a region, use its regional STS endpoint
+	if len(region) != 0 {
+		cfg = cfg.WithRegion(region).WithSTSRegionalEndpoint(endpoints.RegionalSTSEndpoint)
 	}
+	return cfg
 }
@@ -8,15 +8,48 @@ package creds
 // unit tests for the creds.go functions.
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/stretchr/testify/require"
 
 	"github.com/aws/aws-sdk-go/service/sts"
 )
 
-// TestGetSessionCredentialsSuccess substitutes a mock wrapper function for the
+// fakeSTS is a fake implementation of the stsAPI interface whose responses are supplied by
+// the unit tests that use it. Only the calls that a test expects to be made need be given.
+type fakeSTS struct {
+	getSessionToken    func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error)
+	assumeRole         func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error)
+	assumeRoleWithSAML func(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error)
+	getCallerIdentity  func(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error)
+}
+
+// GetSessionToken is the fake of the AWS STS GetSessionToken(..) function.
+func (f *fakeSTS) GetSessionToken(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+	return f.getSessionToken(input)
+}
+
+// AssumeRole is the fake of the AWS STS AssumeRole(..) function.
+func (f *fakeSTS) AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	return f.assumeRole(input)
+}
+
+// AssumeRoleWithSAML is the fake of the AWS STS AssumeRoleWithSAML(..) function.
+func (f *fakeSTS) AssumeRoleWithSAML(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error) {
+	return f.assumeRoleWithSAML(input)
+}
+
+// GetCallerIdentity is the fake of the AWS STS GetCallerIdentity(..) function.
+func (f *fakeSTS) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return f.getCallerIdentity(input)
+}
+
+// TestGetSessionCredentialsSuccess substitutes a fake STS client for the
 // AWS STS GetSessionToken(..) call so that we can guarantee success and see what
 // happens.
 func TestGetSessionCredentialsSuccess(t *testing.T) {
@@ -24,12 +57,12 @@ func TestGetSessionCredentialsSuccess(t *testing.T) {
 	// Put the package back into its normal state after we are done with the test
 	defer ResetPackageDefaults()
 
-	// Set up a mock AWS STS wrapper function
+	// Set up a fake AWS STS client
 	accessKey := "key"
 	secret := "secret"
 	token := "token"
 	expiration := time.Now()
-	SetGetSessionTokenFunc(func(awsService *sts.STS, input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+	SetSTSClient(&fakeSTS{getSessionToken: func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
 		return &sts.GetSessionTokenOutput{
 				Credentials: &sts.Credentials{
 					AccessKeyId:     &accessKey,
@@ -39,18 +72,19 @@ func TestGetSessionCredentialsSuccess(t *testing.T) {
 				},
 			},
 			nil
-	})
+	}})
 
 	// Invoke our test target
 	credentials, err := GetSessionCredentials("mfa-device-id", "123456", 3600)
 	require.Nil(t, err, "there should have been no error")
 	require.Equal(t, accessKey, *credentials.AccessKeyID, "Access key did not match expected value")
-	require.Equal(t, secret, *credentials.SecretAccessKey, "Secret did not match expected value")
-	require.Equal(t, token, *credentials.SessionToken, "session token did not match expected value")
+	require.Equal(t, secret, credentials.SecretAccessKey.Value(), "Secret did not match expected value")
+	require.Equal(t, token, credentials.SessionToken.Value(), "session token did not match expected value")
+	require.Equal(t, expiration, *credentials.Expiration, "expiration did not match expected value")
 }
 
-// TestGetSessionCredentialsFailure invokes GetSessionCredentials(..) without mocking
-// the AWS STS GetSessionToken(..) call wrapper to test what happens when AWS is really
+// TestGetSessionCredentialsFailure invokes GetSessionCredentials(..) without faking
+// the AWS STS GetSessionToken(..) call to test what happens when AWS is really
 // called under circoumstances where we can be certain that the request will be rejected.
 func TestGetSessionCredentialsFailure(t *testing.T) {
 
@@ -59,3 +93,169 @@ func TestGetSessionCredentialsFailure(t *testing.T) {
 	require.NotNil(t, err, "there should have an error")
 	require.Nil(t, credentials, "no credentials should have been obtained")
 }
+
+// TestGetSessionCredentialsWithConfig confirms that a caller's own AWS configuration is
+// applied to the STS client, winning over the package's settings.
+func TestGetSessionCredentialsWithConfig(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// The caller's configuration should have the last word
+	SetRegion("eu-west-1")
+	httpClient := &http.Client{}
+	cfg := aws.NewConfig().WithRegion("ap-southeast-2").WithHTTPClient(httpClient)
+	svc := newSTSClientWithConfig(nil, cfg)
+	require.Equal(t, "ap-southeast-2", *svc.Config.Region, "the caller's region should have won")
+	require.Equal(t, httpClient, svc.Config.HTTPClient, "the caller's HTTP client should have been used")
+
+	// And the session request should be made with it
+	SetSTSClient(&fakeSTS{getSessionToken: func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		return successfulSessionTokenOutput(), nil
+	}})
+	credentials, err := GetSessionCredentialsWithConfig(cfg, "mfa-device-id", "123456", 3600)
+	require.Nil(t, err, "there should have been no error")
+	require.NotNil(t, credentials.SessionToken, "there should have been a session token")
+}
+
+// TestSTSEndpointOverride confirms that an overridden STS endpoint is applied to the
+// STS client, along with a signing region if the environment does not provide one.
+func TestSTSEndpointOverride(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Without an override, the client should be talking to AWS
+	svc := newSTSClient()
+	require.Contains(t, svc.Endpoint, "amazonaws.com", "expected the standard AWS endpoint")
+
+	// With an override, we should get what we asked for
+	SetSTSEndpoint("http://localhost:4566")
+	svc = newSTSClient()
+	require.Equal(t, "http://localhost:4566", svc.Endpoint, "the endpoint override was not applied")
+	require.NotEmpty(t, *svc.Config.Region, "a signing region should have been set")
+}
+
+// TestSkipParamValidation confirms that a session duration shorter than the SDK allows
+// is refused before it is sent, unless the checks are skipped, when it reaches the endpoint.
+func TestSkipParamValidation(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// An endpoint that notes the durations asked of it and refuses them all
+	var durations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		durations = append(durations, r.PostForm.Get("DurationSeconds"))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	SetSTSEndpoint(server.URL)
+	SetMaxRetries(0)
+	SetLongTermCredentials(credentials.NewStaticCredentials("key", "secret", ""))
+
+	// The SDK stops a 60 second session short
+	_, err := GetSessionCredentials("arn:aws:iam::210987654321:mfa/pat", "123456", 60)
+	require.NotNil(t, err, "the SDK should have refused the duration")
+	require.Empty(t, durations, "nothing should have reached the endpoint")
+
+	// Unless told not to
+	SetSkipParamValidation(true)
+	GetSessionCredentials("arn:aws:iam::210987654321:mfa/pat", "123456", 60)
+	require.Equal(t, []string{"60"}, durations, "the duration should have reached the endpoint")
+}
+
+// TestRegion confirms that a given region has the STS client call its regional endpoint.
+func TestRegion(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	SetRegion("eu-west-1")
+	svc := newSTSClient()
+	require.Equal(t, "https://sts.eu-west-1.amazonaws.com", svc.Endpoint, "expected the regional endpoint")
+	require.Equal(t, "eu-west-1", *svc.Config.Region, "the region was not applied")
+
+	// An endpoint override should sign with the given region rather than the default
+	SetSTSEndpoint("http://localhost:4566")
+	svc = newSTSClient()
+	require.Equal(t, "eu-west-1", *svc.Config.Region, "the region should have been used to sign")
+}
+
+// TestFIPSEndpoint confirms that the FIPS STS endpoints are found for the regions that
+// have them, and that the others are refused.
+func TestFIPSEndpoint(t *testing.T) {
+	for r, expected := range map[string]string{
+		"":              "https://sts-fips.us-east-1.amazonaws.com",
+		"us-west-2":     "https://sts-fips.us-west-2.amazonaws.com",
+		"us-gov-west-1": "https://sts.us-gov-west-1.amazonaws.com",
+	} {
+		endpoint, err := FIPSEndpoint(r)
+		require.Nil(t, err, "there should not have been an error for %q", r)
+		require.Equal(t, expected, endpoint, "unexpected endpoint for %q", r)
+	}
+	for _, r := range []string{"eu-west-1", "cn-north-1", "nowhere-1"} {
+		_, err := FIPSEndpoint(r)
+		require.NotNil(t, err, "%s should have had no FIPS endpoint", r)
+	}
+}
+
+// TestLongTermCredentialsOverride confirms that overridden long term credentials are
+// applied to the STS client.
+func TestLongTermCredentialsOverride(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// With an override, the client should be using what we gave it
+	longTerm := credentials.NewStaticCredentials("id", "secret", "")
+	SetLongTermCredentials(longTerm)
+	svc := newSTSClient()
+	require.Equal(t, longTerm, svc.Config.Credentials, "the credentials override was not applied")
+
+	// And not after a reset
+	ResetPackageDefaults()
+	svc = newSTSClient()
+	require.NotEqual(t, longTerm, svc.Config.Credentials, "the credentials override was not cleared")
+}
+
+// TestExpiration freezes time to deterministically examine the valid and expired
+// states of session credentials.
+func TestExpiration(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Freeze time half an hour before the credentials expire
+	expiration := time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	now := expiration.Add(-30 * time.Minute)
+	SetNowFunc(func() time.Time { return now })
+	credentials := &SessionCredentials{Expiration: &expiration}
+	require.False(t, credentials.Expired(), "the credentials should still be valid")
+	require.Equal(t, 30*time.Minute, credentials.Remaining(), "the credentials should be valid for another 30m")
+
+	// Move time on to the moment of expiration
+	now = expiration
+	require.True(t, credentials.Expired(), "the credentials should have expired")
+	require.Equal(t, time.Duration(0), credentials.Remaining(), "there should be no time remaining")
+
+	// Credentials with no known expiration never expire, as far as we know
+	credentials = &SessionCredentials{}
+	require.False(t, credentials.Expired(), "credentials without an expiration should not expire")
+	require.Equal(t, time.Duration(0), credentials.Remaining(), "credentials without an expiration have no known time remaining")
+}
+
+// TestSTSClientOverride confirms that a fake STS client is used in place of the real one
+// until the package defaults are reset.
+func TestSTSClientOverride(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	fake := &fakeSTS{}
+	SetSTSClient(fake)
+	require.Equal(t, fake, stsClientFor(nil, nil), "the fake client should have been used")
+	ResetPackageDefaults()
+	require.IsType(t, &sts.STS{}, stsClientFor(nil, nil), "the real client should have been restored")
+}
@@ -0,0 +1,187 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// the error types used to report why AWS did not hand over credentials.
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// AuthError reports that AWS rejected the identity or MFA token presented to it.
+type AuthError struct {
+	Err   error // The underlying error, either from the AWS SDK or one of the ErrMFA... errors
+	Cause error // The error returned by the AWS SDK if Err is one of the ErrMFA... errors
+}
+
+// NetworkError reports that AWS could not be reached, did not respond in time,
+// or was unable to service the request.
+type NetworkError struct {
+	Err error // The underlying error
+}
+
+// ConfigError reports that no request could be made of AWS because the local
+// configuration (credentials, region, etc) was missing or incomplete.
+type ConfigError struct {
+	Err error // The underlying error
+}
+
+var (
+	// ErrMFATokenRejected is wrapped by an AuthError when AWS says that the MFA token is wrong
+	ErrMFATokenRejected = errors.New("MFA code was rejected — check the digits")
+
+	// ErrMFACodeReused is wrapped by an AuthError when AWS says that the MFA code could not
+	// be validated, as it does when a code that was accepted moments ago is given again
+	ErrMFACodeReused = errors.New("This MFA code was already used — wait for a new one")
+
+	// ErrClockSkew is wrapped by an AuthError when AWS says that the request was signed at
+	// a time too far from its own, which also throws time-based MFA codes out
+	ErrClockSkew = errors.New("Your system clock may be out of sync — MFA codes are time-based; run `ntpdate` or enable NTP")
+
+	// Fragments of the AWS error messages that tell us what was wrong with an MFA token
+	mfaTokenRejectedMessages = []string{"invalid MFA one time pass code", "TokenCode"}
+	mfaCodeReusedMessages    = []string{"unable to validate MFA code"}
+
+	// AWS error codes, and fragments of AWS error messages, that tell us that the local
+	// clock is out of step with that of AWS
+	clockSkewErrorCodes = map[string]bool{
+		"RequestExpired":       true,
+		"RequestTimeTooSkewed": true,
+	}
+	clockSkewMessages = []string{"Signature expired", "Signature not yet current", "too skewed"}
+
+	// AWS error codes that indicate a rejected identity or MFA token
+	authErrorCodes = map[string]bool{
+		"AccessDenied":                true,
+		"ExpiredToken":                true,
+		"ForbiddenException":          true,
+		"InvalidClientTokenId":        true,
+		"InvalidSignatureException":   true,
+		"RequestExpired":              true,
+		"RequestTimeTooSkewed":        true,
+		"SignatureDoesNotMatch":       true,
+		"UnauthorizedException":       true,
+		"UnrecognizedClientException": true,
+	}
+
+	// AWS error codes that indicate a failure to communicate with AWS
+	networkErrorCodes = map[string]bool{
+		request.ErrCodeRequestError:    true,
+		request.ErrCodeResponseTimeout: true,
+		request.CanceledErrorCode:      true,
+	}
+
+	// AWS error codes that indicate a problem with the local configuration
+	configErrorCodes = map[string]bool{
+		"NoCredentialProviders": true,
+		"MissingRegion":         true,
+		"MissingEndpoint":       true,
+	}
+
+	// AWS error codes that say that a request parameter was not valid, a configuration
+	// problem unless the message names the MFA code parameter, which the SDK spells
+	// TokenCode and STS spells tokenCode, as the one at fault
+	validationErrorCodes = map[string]bool{
+		"ValidationError":               true,
+		request.InvalidParameterErrCode: true,
+	}
+	mfaTokenCodeParameter = "tokencode"
+)
+
+// Error returns the message of the underlying error.
+func (e *AuthError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the message of the underlying error.
+func (e *NetworkError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the message of the underlying error.
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// classifyError wraps an error returned by the AWS SDK in an AuthError, NetworkError,
+// or ConfigError if the cause of the error can be determined. Errors of unknown cause
+// are returned as they were given.
+func classifyError(err error) error {
+
+	// We can only classify errors that carry an AWS error code
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return err
+	}
+
+	// Server side failures are as good as AWS being unreachable
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() >= 500 {
+		return &NetworkError{Err: err}
+	}
+
+	// Otherwise look at the error code
+	code := aerr.Code()
+	switch {
+	case authErrorCodes[code]:
+		return classifyAuthError(aerr)
+	case validationErrorCodes[code] && strings.Contains(strings.ToLower(aerr.Message()), mfaTokenCodeParameter):
+		return &AuthError{Err: ErrMFATokenRejected, Cause: aerr}
+	case validationErrorCodes[code]:
+		return &ConfigError{Err: err}
+	case networkErrorCodes[code]:
+		return &NetworkError{Err: err}
+	case configErrorCodes[code]:
+		return &ConfigError{Err: err}
+	}
+	return err
+}
+
+// classifyAuthError returns an AuthError for an AWS authentication failure, replacing
+// the AWS error with a clearer explanation when the failure was caused by the MFA token
+// or the local clock.
+func classifyAuthError(aerr awserr.Error) *AuthError {
+
+	// Look for recognizable clock and MFA token complaints in the AWS error
+	message := aerr.Message()
+	switch {
+	case clockSkewErrorCodes[aerr.Code()], containsAny(message, clockSkewMessages):
+		return &AuthError{Err: ErrClockSkew, Cause: aerr}
+	case containsAny(message, mfaCodeReusedMessages):
+		return &AuthError{Err: ErrMFACodeReused, Cause: aerr}
+	case containsAny(message, mfaTokenRejectedMessages):
+		return &AuthError{Err: ErrMFATokenRejected, Cause: aerr}
+	}
+	return &AuthError{Err: aerr}
+}
+
+// containsAny returns true if the string s contains any of the given substrings.
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the errors.go functions.
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClassifyError runs a selection of AWS SDK errors through classifyError(..) to
+// confirm that each is wrapped in the appropriate type.
+func TestClassifyError(t *testing.T) {
+
+	// An access denied error is an authentication failure
+	var authErr *AuthError
+	err := classifyError(awserr.New("AccessDenied", "MultiFactorAuthentication failed", nil))
+	require.True(t, errors.As(err, &authErr), "expected an AuthError: %#v", err)
+	require.Equal(t, "AccessDenied: MultiFactorAuthentication failed", err.Error(), "the original message should be preserved")
+
+	// A request error is a network failure
+	var networkErr *NetworkError
+	err = classifyError(awserr.New("RequestError", "send request failed", errors.New("dial tcp: i/o timeout")))
+	require.True(t, errors.As(err, &networkErr), "expected a NetworkError: %#v", err)
+
+	// So is a server side failure, whatever its code
+	err = classifyError(awserr.NewRequestFailure(awserr.New("InternalFailure", "oops", nil), 503, "request-id"))
+	require.True(t, errors.As(err, &networkErr), "expected a NetworkError: %#v", err)
+
+	// Having no credentials is a configuration problem
+	var configErr *ConfigError
+	err = classifyError(awserr.New("NoCredentialProviders", "no valid providers in chain", nil))
+	require.True(t, errors.As(err, &configErr), "expected a ConfigError: %#v", err)
+
+	// So is a parameter that AWS will not accept, such as a bad role ARN or duration
+	for _, aerr := range []awserr.Error{
+		awserr.New("ValidationError", "1 validation error detected: Value 'not-an-arn' at 'roleArn' failed to satisfy constraint", nil),
+		awserr.New("InvalidParameter", "1 validation error(s) found.\n- minimum field value of 900, AssumeRoleInput.DurationSeconds.\n", nil),
+	} {
+		err = classifyError(aerr)
+		require.True(t, errors.As(err, &configErr), "expected a ConfigError for %v", aerr)
+	}
+
+	// Anything else should be returned as it came
+	original := awserr.New("SomethingElse", "who knows", nil)
+	require.Equal(t, original, classifyError(original), "unknown AWS errors should not be wrapped")
+	plain := errors.New("not an AWS error")
+	require.Equal(t, plain, classifyError(plain), "non-AWS errors should not be wrapped")
+}
+
+// TestClassifyMFATokenErrors confirms that AWS complaints about the MFA token are
+// replaced with clearer explanations, while keeping the AWS error as the cause.
+func TestClassifyMFATokenErrors(t *testing.T) {
+
+	// A wrong code
+	rejected := awserr.New("AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code.", nil)
+	err := classifyError(rejected)
+	require.True(t, errors.Is(err, ErrMFATokenRejected), "expected a rejected token error: %#v", err)
+	require.Equal(t, "MFA code was rejected — check the digits", err.Error(), "unexpected message")
+	require.Equal(t, rejected, err.(*AuthError).Cause, "the AWS error should be kept as the cause")
+
+	// A code that is too short to even send to AWS
+	err = classifyError(awserr.New("InvalidParameter", "1 validation error(s) found.\n- minimum field size of 6, GetSessionTokenInput.TokenCode.\n", nil))
+	require.True(t, errors.Is(err, ErrMFATokenRejected), "expected a rejected token error: %#v", err)
+	err = classifyError(awserr.New("ValidationError", "1 validation error detected: Value '12345' at 'tokenCode' failed to satisfy constraint: Member must have length greater than or equal to 6", nil))
+	require.True(t, errors.Is(err, ErrMFATokenRejected), "expected a rejected token error: %#v", err)
+
+	// A reused code
+	err = classifyError(awserr.New("AccessDenied", "MultiFactorAuthentication failed, unable to validate MFA code.", nil))
+	require.True(t, errors.Is(err, ErrMFACodeReused), "expected a reused code error: %#v", err)
+	require.Equal(t, "This MFA code was already used — wait for a new one", err.Error(), "unexpected message")
+
+	// Some other access problem should be left as AWS described it
+	other := awserr.New("AccessDenied", "User is not authorized to perform: sts:AssumeRole", nil)
+	err = classifyError(other)
+	require.Equal(t, other, errors.Unwrap(err), "a non-MFA error should be wrapped as it was")
+	require.Nil(t, err.(*AuthError).Cause, "there should be no separate cause")
+}
+
+// TestClassifyClockSkewErrors confirms that AWS complaints about the time of a request
+// are explained as a clock that is out of sync.
+func TestClassifyClockSkewErrors(t *testing.T) {
+	for _, aerr := range []awserr.Error{
+		awserr.New("SignatureDoesNotMatch", "Signature expired: 20200401T120000Z is now earlier than 20200401T121500Z", nil),
+		awserr.New("RequestExpired", "Request has expired.", nil),
+		awserr.New("InvalidSignatureException", "Signature not yet current: 20200401T123000Z is still later than 20200401T121500Z", nil),
+	} {
+		err := classifyError(aerr)
+		require.True(t, errors.Is(err, ErrClockSkew), "expected a clock skew error for %v", aerr)
+		require.Contains(t, err.Error(), "system clock may be out of sync", "unexpected message")
+		require.Equal(t, aerr, err.(*AuthError).Cause, "the AWS error should be kept as the cause")
+	}
+
+	// A signature that is simply wrong is not the clock's fault
+	wrong := awserr.New("SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", nil)
+	require.False(t, errors.Is(classifyError(wrong), ErrClockSkew), "a bad signature is not clock skew")
+}
+
+// TestGetSessionCredentialsClassifiedFailure confirms that errors from AWS STS are
+// classified before being returned by GetSessionCredentials(..).
+func TestGetSessionCredentialsClassifiedFailure(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Have the fake STS client reject the token
+	SetSTSClient(&fakeSTS{getSessionToken: func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		return nil, awserr.New("AccessDenied", "MultiFactorAuthentication failed", nil)
+	}})
+
+	// Invoke our test target and check the error type
+	var authErr *AuthError
+	credentials, err := GetSessionCredentials("mfa-device-id", "123456", 3600)
+	require.Nil(t, credentials, "no credentials should have been obtained")
+	require.True(t, errors.As(err, &authErr), "expected an AuthError: %#v", err)
+}
@@ -0,0 +1,60 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// package methods related to discovering who a set of credentials belongs to.
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// Identity describes the IAM identity that a set of credentials belongs to.
+type Identity struct {
+	Account string // The AWS account number
+	UserID  string // The unique identifier of the IAM user or role
+	ARN     string // The ARN of the IAM user or role
+}
+
+// GetCallerIdentity asks AWS STS who the long term credentials belong to. No MFA token
+// is needed and no particular permissions are required for this to succeed.
+func GetCallerIdentity() (*Identity, error) {
+
+	// Obtain an AWS STS client, or the fake that unit tests have given us, and ask
+	return callerIdentity(stsClientFor(nil, nil))
+}
+
+// GetSessionIdentity asks AWS STS who the given session credentials belong to, which
+// proves that they work: AWS refuses the request if they do not, and no permissions are
+// needed for it to succeed if they do.
+func GetSessionIdentity(session *SessionCredentials) (*Identity, error) {
+
+	// Obtain an AWS STS client that authenticates with the session credentials, and ask
+	return callerIdentity(stsClientFor(credentials.NewStaticCredentials(
+		aws.StringValue(session.AccessKeyID), session.SecretAccessKey.Value(), session.SessionToken.Value()), nil))
+}
+
+// callerIdentity asks AWS STS, through the given client, who its credentials belong to.
+func callerIdentity(svc stsAPI) (*Identity, error) {
+
+	// Ask who we are, retrying if AWS is having a bad day
+	var result *sts.GetCallerIdentityOutput
+	err := withRetries(func() (err error) {
+		result, err = svc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+		return err
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	// Translate the result into our own format
+	return &Identity{
+		Account: aws.StringValue(result.Account),
+		UserID:  aws.StringValue(result.UserId),
+		ARN:     aws.StringValue(result.Arn),
+	}, nil
+}
@@ -0,0 +1,90 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the identity.go functions.
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetCallerIdentitySuccess substitutes a fake STS client for the AWS STS
+// GetCallerIdentity(..) call to confirm that the identity is translated faithfully.
+func TestGetCallerIdentitySuccess(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Set up a fake AWS STS client that returns a known identity
+	SetSTSClient(&fakeSTS{getCallerIdentity: func(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+		return &sts.GetCallerIdentityOutput{
+			Account: aws.String("123456789012"),
+			UserId:  aws.String("AIDAEXAMPLE"),
+			Arn:     aws.String("arn:aws:iam::123456789012:user/jane"),
+		}, nil
+	}})
+
+	// Invoke our test target
+	identity, err := GetCallerIdentity()
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, &Identity{Account: "123456789012", UserID: "AIDAEXAMPLE", ARN: "arn:aws:iam::123456789012:user/jane"}, identity)
+}
+
+// TestGetCallerIdentityRejected confirms that AWS refusing to recognize the credentials
+// is reported as an AuthError.
+func TestGetCallerIdentityRejected(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Set up a fake AWS STS client that does not recognize the credentials
+	SetSTSClient(&fakeSTS{getCallerIdentity: func(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+		return nil, awserr.New("InvalidClientTokenId", "The security token included in the request is invalid.", nil)
+	}})
+
+	// Invoke our test target
+	identity, err := GetCallerIdentity()
+	require.Nil(t, identity, "no identity should have been obtained")
+	var authErr *AuthError
+	require.True(t, errors.As(err, &authErr), "expected an AuthError, got %v", err)
+}
+
+// TestGetSessionIdentity confirms that the identity of session credentials is asked for
+// with the session credentials themselves, not the long term ones.
+func TestGetSessionIdentity(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// An endpoint that notes how it was called and says who the caller is
+	var authorization, token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization, token = r.Header.Get("Authorization"), r.Header.Get("X-Amz-Security-Token")
+		w.Write([]byte(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>` +
+			`<Arn>arn:aws:sts::210987654321:assumed-role/admin/pat</Arn><UserId>AROAEXAMPLE:pat</UserId><Account>210987654321</Account>` +
+			`</GetCallerIdentityResult></GetCallerIdentityResponse>`))
+	}))
+	defer server.Close()
+	SetSTSEndpoint(server.URL)
+	SetLongTermCredentials(credentials.NewStaticCredentials("AKIALONGTERM", "secret", ""))
+
+	// Ask about a session
+	session := &SessionCredentials{AccessKeyID: aws.String("ASIASESSION"), SecretAccessKey: NewSecret("session-secret"), SessionToken: NewSecret("session-token")}
+	identity, err := GetSessionIdentity(session)
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, &Identity{Account: "210987654321", UserID: "AROAEXAMPLE:pat", ARN: "arn:aws:sts::210987654321:assumed-role/admin/pat"}, identity)
+	require.Contains(t, authorization, "Credential=ASIASESSION/", "the request should have been signed with the session credentials")
+	require.Equal(t, "session-token", token, "the session token should have gone with the request")
+}
@@ -0,0 +1,57 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// package methods related to discovering the MFA devices registered to a user.
+
+import (
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// ListMFADevicesFunc is a function type that corresponds to the AWS IAM function for listing
+// the MFA devices of a user. It is called via a function variable so that unit tests can
+// substitute a mock implementation.
+type ListMFADevicesFunc func(awsService *iam.IAM, input *iam.ListMFADevicesInput) (*iam.ListMFADevicesOutput, error)
+
+var (
+
+	// A function variable that, normally, wraps the AWS IAM ListMFADevices(..) function
+	// but can be overridden for unit testing. This is initialized at load time via a call to
+	// the ResetPackageDefaults(..) function.
+	listMFADevicesFunc ListMFADevicesFunc
+)
+
+// ListMFADeviceIDs asks AWS IAM for the serial numbers of the MFA devices registered to
+// the IAM user whose long term credentials are found in the environment. The user must
+// have the iam:ListMFADevices permission for this to succeed.
+func ListMFADeviceIDs() ([]string, error) {
+
+	// Obtain an AWS IAM client
+	svc := iam.New(newSession(), clientConfig())
+
+	// Collect the serial numbers, page by page, until AWS tells us that we have them all
+	var ids []string
+	input := &iam.ListMFADevicesInput{}
+	for {
+		result, err := listMFADevicesFunc(svc, input)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		for _, device := range result.MFADevices {
+			ids = append(ids, *device.SerialNumber)
+		}
+		if result.IsTruncated == nil || !*result.IsTruncated {
+			return ids, nil
+		}
+		input.Marker = result.Marker
+	}
+}
+
+// SetListMFADevicesFunc allows unit tests to substitute a mock function in place of
+// the default AWS IAM ListMFADevices(..) wrapper so that tests can control the responses.
+func SetListMFADevicesFunc(f ListMFADevicesFunc) {
+	listMFADevicesFunc = f
+}
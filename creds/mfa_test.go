@@ -0,0 +1,57 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the mfa.go functions.
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListMFADeviceIDsSuccess substitutes a mock wrapper function for the AWS IAM
+// ListMFADevices(..) call, returning results over two pages, to confirm that all of
+// the serial numbers are collected.
+func TestListMFADeviceIDsSuccess(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Set up a mock AWS IAM wrapper that returns one device per page
+	pages := map[string]*iam.ListMFADevicesOutput{
+		"": {
+			MFADevices:  []*iam.MFADevice{{SerialNumber: aws.String("arn:aws:iam::999999999999:mfa/phone")}},
+			IsTruncated: aws.Bool(true),
+			Marker:      aws.String("page-2"),
+		},
+		"page-2": {
+			MFADevices:  []*iam.MFADevice{{SerialNumber: aws.String("arn:aws:iam::999999999999:mfa/yubikey")}},
+			IsTruncated: aws.Bool(false),
+		},
+	}
+	SetListMFADevicesFunc(func(awsService *iam.IAM, input *iam.ListMFADevicesInput) (*iam.ListMFADevicesOutput, error) {
+		return pages[aws.StringValue(input.Marker)], nil
+	})
+
+	// Invoke our test target
+	ids, err := ListMFADeviceIDs()
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, []string{"arn:aws:iam::999999999999:mfa/phone", "arn:aws:iam::999999999999:mfa/yubikey"}, ids, "unexpected device IDs")
+}
+
+// TestListMFADeviceIDsFailure invokes ListMFADeviceIDs(..) without mocking the AWS IAM
+// ListMFADevices(..) call wrapper under circumstances where we can be certain that the
+// request will be rejected.
+func TestListMFADeviceIDsFailure(t *testing.T) {
+
+	// Invoke our test target without any valid credentials in play
+	ids, err := ListMFADeviceIDs()
+	require.NotNil(t, err, "there should have an error")
+	require.Nil(t, ids, "no device IDs should have been obtained")
+}
@@ -0,0 +1,61 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// the reachability check of the STS endpoint, made without credentials.
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// How long Ping(..) waits for the STS endpoint to respond
+	pingTimeout = 10 * time.Second
+)
+
+// PingResult describes the response of the STS endpoint to Ping(..).
+type PingResult struct {
+	Endpoint   string        // The URL of the STS endpoint that responded
+	Latency    time.Duration // How long the endpoint took to respond
+	StatusCode int           // The HTTP status of the response, which matters only in that there was one
+}
+
+// STSEndpointURL returns the URL of the STS endpoint that requests for session credentials
+// are sent to, as the endpoint, region, and environment settings of the package dictate.
+func STSEndpointURL() string {
+	return newSTSClient().Endpoint
+}
+
+// Ping sends an unauthenticated request to the STS endpoint that requests for session
+// credentials are sent to, through the same proxy, and reports how long it took to get an
+// answer. Any answer at all, even a refusal, shows that the endpoint can be reached, so
+// that a failure to obtain credentials must lie with the credentials rather than with the
+// network, a proxy, or the endpoint; a NetworkError is returned if there is no answer.
+func Ping() (*PingResult, error) {
+	endpoint := STSEndpointURL()
+
+	// Do not follow STS's redirect of browsers to the documentation; it has answered
+	client := httpClient()
+	client.Timeout = pingTimeout
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	// Knock and time the answer
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	setUserAgent(request)
+	started := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+	response.Body.Close()
+	return &PingResult{Endpoint: endpoint, Latency: time.Since(started), StatusCode: response.StatusCode}, nil
+}
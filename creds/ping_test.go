@@ -0,0 +1,56 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the ping.go functions.
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPing confirms that any answer from the STS endpoint counts as reachable, without
+// following redirects, and that no answer is a NetworkError.
+func TestPing(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// An endpoint that sends browsers elsewhere, as STS does
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get("Authorization"), "the ping should not have been signed")
+		http.Redirect(w, r, "https://aws.amazon.com/iam", http.StatusFound)
+	}))
+	SetSTSEndpoint(server.URL)
+	result, err := Ping()
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, server.URL, result.Endpoint, "not the expected endpoint")
+	require.Equal(t, http.StatusFound, result.StatusCode, "the redirect should not have been followed")
+	require.True(t, result.Latency > 0, "the latency should have been measured")
+
+	// Once the endpoint has gone, there is no answer
+	server.Close()
+	_, err = Ping()
+	require.NotNil(t, err, "there should have been an error")
+	var networkErr *NetworkError
+	require.True(t, errors.As(err, &networkErr), "expected a NetworkError, not %v", err)
+}
+
+// TestSTSEndpointURL confirms that the endpoint reflects the package's settings.
+func TestSTSEndpointURL(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	SetRegion("eu-west-1")
+	require.Equal(t, "https://sts.eu-west-1.amazonaws.com", STSEndpointURL(), "expected the regional endpoint")
+	SetSTSEndpoint("https://localhost:4566")
+	require.Equal(t, "https://localhost:4566", STSEndpointURL(), "expected the overridden endpoint")
+}
@@ -0,0 +1,113 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// the AWS SDK credentials provider that library users can plug into SDK clients.
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+const (
+	// ProviderName is the name that SDK credentials values obtained by a SessionProvider
+	// report as their source
+	ProviderName = "MafiaSessionProvider"
+
+	// DefaultExpiryWindow is how long before the session credentials expire that a
+	// SessionProvider, unless told otherwise, counts them as expired and obtains more
+	DefaultExpiryWindow = time.Minute
+)
+
+// MFATokenFunc returns the current code of an MFA device, e.g. by prompting the user for it
+// or by calling GenerateTOTPCode(..), whenever a SessionProvider needs a new session.
+type MFATokenFunc func() (string, error)
+
+// SessionProvider is an AWS SDK credentials.Provider that obtains session credentials
+// authenticated with an MFA code, and obtains them again, with a fresh code from its
+// TokenFunc, whenever they are about to expire. Wrap one with credentials.NewCredentials(..),
+// or use NewSessionCredentialsProvider(..), to hand it to an SDK client configuration.
+type SessionProvider struct {
+	MFASerialNumber string        // The MFA device ID / serial number, as for GetSessionCredentials(..)
+	Duration        int64         // The session duration in seconds, as for GetSessionCredentials(..)
+	TokenFunc       MFATokenFunc  // Supplies the MFA code for each new session
+	RoleARN         string        // If not empty, the role to assume rather than obtain a plain session
+	RoleSessionName string        // The role session name, defaulting to FallbackRoleSessionName
+	ExpiryWindow    time.Duration // How long before expiry to obtain more, defaulting to DefaultExpiryWindow
+
+	credentials.Expiry
+}
+
+// NewSessionCredentialsProvider returns SDK credentials that are obtained, as need be, by a
+// SessionProvider for the given MFA device and duration, taking its MFA codes from the
+// given function.
+func NewSessionCredentialsProvider(mfaSerialNumber string, duration int64, tokenFunc MFATokenFunc) *credentials.Credentials {
+	return credentials.NewCredentials(&SessionProvider{
+		MFASerialNumber: mfaSerialNumber,
+		Duration:        duration,
+		TokenFunc:       tokenFunc,
+	})
+}
+
+// Retrieve obtains new session credentials from AWS STS with a code from the provider's
+// TokenFunc, satisfying the credentials.Provider interface.
+func (p *SessionProvider) Retrieve() (credentials.Value, error) {
+
+	// We cannot authenticate without an MFA code
+	if p.TokenFunc == nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.New("the SessionProvider has no TokenFunc to supply MFA codes")
+	}
+	mfaToken, err := p.TokenFunc()
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, err
+	}
+
+	// Ask for a plain session or a role session, as configured
+	var session *SessionCredentials
+	if len(p.RoleARN) != 0 {
+		name := p.RoleSessionName
+		if len(name) == 0 {
+			name = FallbackRoleSessionName
+		}
+		session, err = AssumeRoleCredentials(p.RoleARN, name, p.MFASerialNumber, mfaToken, p.Duration)
+	} else {
+		session, err = GetSessionCredentials(p.MFASerialNumber, mfaToken, p.Duration)
+	}
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, err
+	}
+
+	// Note when they must be replaced and hand them over
+	window := p.ExpiryWindow
+	if window == 0 {
+		window = DefaultExpiryWindow
+	}
+	if session.Expiration != nil {
+		p.SetExpiration(*session.Expiration, window)
+	}
+	value := session.Value()
+	session.Wipe()
+	return value, nil
+}
+
+// Value returns the session credentials as an AWS SDK credentials value.
+func (c *SessionCredentials) Value() credentials.Value {
+	return credentials.Value{
+		AccessKeyID:     aws.StringValue(c.AccessKeyID),
+		SecretAccessKey: c.SecretAccessKey.Value(),
+		SessionToken:    c.SessionToken.Value(),
+		ProviderName:    ProviderName,
+	}
+}
+
+// Credentials returns the session credentials as static AWS SDK credentials, for an SDK
+// client that need not outlive them.
+func (c *SessionCredentials) Credentials() *credentials.Credentials {
+	return credentials.NewStaticCredentialsFromCreds(c.Value())
+}
@@ -0,0 +1,87 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the provider.go functions.
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSessionProvider confirms that SDK credentials from a SessionProvider are obtained with
+// a code from its TokenFunc, kept while they are good, and obtained again once they expire.
+func TestSessionProvider(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Set up a fake AWS STS client that hands out an hour long session
+	var codes []string
+	SetSTSClient(&fakeSTS{getSessionToken: func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		codes = append(codes, *input.TokenCode)
+		return &sts.GetSessionTokenOutput{Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("key"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		}}, nil
+	}})
+	next := 0
+	provider := NewSessionCredentialsProvider("mfa-device-id", 3600, func() (string, error) {
+		next++
+		return []string{"", "111111", "222222"}[next], nil
+	})
+
+	// The first call authenticates, the second is served what we already have
+	for i := 0; i < 2; i++ {
+		value, err := provider.Get()
+		require.Nil(t, err, "there should have been no error")
+		require.Equal(t, "key", value.AccessKeyID, "Access key did not match expected value")
+		require.Equal(t, "secret", value.SecretAccessKey, "Secret did not match expected value")
+		require.Equal(t, "token", value.SessionToken, "session token did not match expected value")
+		require.Equal(t, ProviderName, value.ProviderName, "unexpected provider name")
+	}
+	require.Equal(t, []string{"111111"}, codes, "AWS should have been called the once")
+
+	// Once they expire, a fresh code is asked for
+	provider.Expire()
+	_, err := provider.Get()
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, []string{"111111", "222222"}, codes, "AWS should have been called again with the next code")
+}
+
+// TestSessionProviderErrors confirms that failing to get an MFA code, or to have AWS accept
+// it, is reported by the SDK credentials.
+func TestSessionProviderErrors(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	_, err := NewSessionCredentialsProvider("mfa-device-id", 3600, nil).Get()
+	require.NotNil(t, err, "there should have been an error without a TokenFunc")
+
+	_, err = NewSessionCredentialsProvider("mfa-device-id", 3600, func() (string, error) {
+		return "", errors.New("the user went to lunch")
+	}).Get()
+	require.EqualError(t, err, "the user went to lunch")
+}
+
+// TestSessionCredentialsAsSDKCredentials confirms that session credentials already obtained
+// can be handed to an SDK client as they are.
+func TestSessionCredentialsAsSDKCredentials(t *testing.T) {
+	session := &SessionCredentials{AccessKeyID: aws.String("key"), SecretAccessKey: NewSecret("secret"), SessionToken: NewSecret("token")}
+	value, err := session.Credentials().Get()
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, "key", value.AccessKeyID, "Access key did not match expected value")
+	require.Equal(t, "secret", value.SecretAccessKey, "Secret did not match expected value")
+	require.Equal(t, "token", value.SessionToken, "session token did not match expected value")
+}
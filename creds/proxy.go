@@ -0,0 +1,65 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// the HTTP client configuration that gets requests through corporate proxies.
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+var (
+	// The proxy that AWS requests are sent through in place of any named by the
+	// HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables, if any. Set via
+	// SetProxy(..) and cleared by ResetPackageDefaults(..).
+	proxyURL *url.URL
+)
+
+// SetProxy sets the URL of the proxy that requests to AWS are sent through, overriding
+// the HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables, which are otherwise
+// honored. The URL may use the http, https, or socks5 scheme and may carry a username
+// and password for proxies that require authentication. Requests to AWS are made over
+// TLS, so http and https proxies are asked to tunnel them with the CONNECT method, the
+// credentials being sent in the Proxy-Authorization header of the CONNECT request. An
+// empty string restores the environment variables.
+func SetProxy(proxy string) error {
+
+	// An empty string means no override
+	if len(proxy) == 0 {
+		proxyURL = nil
+		return nil
+	}
+
+	// Otherwise it had better be a URL that we know what to do with
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL %q: %v", proxy, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" {
+		return fmt.Errorf("invalid proxy URL %q: the scheme must be http, https, or socks5", proxy)
+	}
+	if len(u.Host) == 0 {
+		return fmt.Errorf("invalid proxy URL %q: there is no host", proxy)
+	}
+	proxyURL = u
+	return nil
+}
+
+// httpClient returns the HTTP client used for all requests to AWS: one with the standard
+// transport settings, sending requests through the proxy given to SetProxy(..) or, if
+// there is none, whatever the proxy environment variables say. A proxy asked to tunnel
+// a request is told our user agent, since it cannot see that of the request itself.
+func httpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ProxyConnectHeader = http.Header{userAgentHeader: []string{userAgent}}
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,74 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the proxy.go functions.
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetProxy confirms that proxy URLs that we cannot use are rejected and that the
+// override takes the place of the environment.
+func TestSetProxy(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Nonsense should be refused
+	require.NotNil(t, SetProxy("://nonsense"), "an unparseable URL should have been refused")
+	require.NotNil(t, SetProxy("ftp://proxy.example.com:21"), "an unsupported scheme should have been refused")
+	require.NotNil(t, SetProxy("http://"), "a URL without a host should have been refused")
+
+	// A good URL should be used for every request
+	require.Nil(t, SetProxy("http://proxy.example.com:3128"), "a good URL should have been accepted")
+	request, _ := http.NewRequest("POST", "https://sts.amazonaws.com/", nil)
+	used, err := httpClient().Transport.(*http.Transport).Proxy(request)
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, "proxy.example.com:3128", used.Host, "the proxy override was not applied")
+
+	// And an empty string should put things back the way they were
+	require.Nil(t, SetProxy(""), "an empty string should have been accepted")
+	require.Nil(t, proxyURL, "the proxy override should have been cleared")
+}
+
+// TestProxyConnect confirms that requests to AWS are tunneled through the proxy with the
+// CONNECT method, carrying the proxy credentials given in the URL and our user agent.
+func TestProxyConnect(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Stand up a proxy that remembers what it was asked and refuses to help
+	var method, host, authorization, agent string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, host, authorization, agent = r.Method, r.Host, r.Header.Get("Proxy-Authorization"), r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+
+	// Ask for a session through the proxy, with some credentials to sign the request with
+	proxyWithUser, _ := url.Parse(proxy.URL)
+	proxyWithUser.User = url.UserPassword("jane", "hunter2")
+	require.Nil(t, SetProxy(proxyWithUser.String()), "the proxy URL should have been accepted")
+	SetLongTermCredentials(credentials.NewStaticCredentials("id", "secret", ""))
+	SetMaxRetries(0)
+	_, err := GetSessionCredentials("mfa-device-id", "123456", 3600)
+	require.NotNil(t, err, "the proxy should have refused to help")
+
+	// The proxy should have been asked to tunnel, with our credentials
+	require.Equal(t, http.MethodConnect, method, "the proxy should have been asked to CONNECT")
+	require.Equal(t, "sts.amazonaws.com:443", host, "the proxy should have been asked for STS")
+	require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("jane:hunter2")), authorization, "the proxy credentials were not sent")
+	require.Equal(t, DefaultUserAgent, agent, "the proxy should have been told our user agent")
+}
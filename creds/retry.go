@@ -0,0 +1,91 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// the logic for retrying AWS requests that fail for transient reasons.
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	// DefaultMaxRetries is the number of times a throttled or failed request is retried
+	// unless SetMaxRetries(..) says otherwise
+	DefaultMaxRetries = 3
+
+	// The delay before the first retry; each subsequent retry waits twice as long as the last
+	defaultRetryBaseDelay = 500 * time.Millisecond
+)
+
+var (
+	// The number of times a throttled or failed request is retried. Set via
+	// SetMaxRetries(..) and restored by ResetPackageDefaults(..).
+	maxRetries int
+
+	// The delay before the first retry, overridden by unit tests that have no time to waste
+	retryBaseDelay time.Duration
+
+	// The function called to wait between retries, overridden by unit tests to observe
+	// the backoff without actually sleeping
+	sleepFunc func(time.Duration)
+)
+
+// SetMaxRetries sets the number of times that a request to AWS is retried, with
+// exponential backoff, when AWS throttles it or responds with a 5xx server error.
+// Requests rejected for any other reason, e.g. access denied or an invalid MFA
+// token, are never retried. Zero disables retries altogether.
+func SetMaxRetries(retries int) {
+	maxRetries = retries
+}
+
+// retrierFor returns withRetries(..), unless the given AWS configuration has its own
+// Retryer or MaxRetries, in which case the SDK does the retrying and the returned
+// function simply invokes the call once.
+func retrierFor(cfg *aws.Config) func(call func() error) error {
+	if cfg != nil && (cfg.Retryer != nil || cfg.MaxRetries != nil) {
+		return func(call func() error) error { return call() }
+	}
+	return withRetries
+}
+
+// withRetries invokes the given function, repeating the invocation with exponential
+// backoff for as long as it returns a retryable error and the retry limit has not
+// been reached. The error from the final invocation is returned.
+func withRetries(call func() error) error {
+
+	// Keep trying until we succeed, fail for good, or run out of retries
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= maxRetries || !isRetryable(err) {
+			return err
+		}
+		sleepFunc(delay)
+		delay *= 2
+	}
+}
+
+// isRetryable returns true if the given error indicates that AWS throttled the request
+// or suffered a server side failure, either of which might not happen next time.
+func isRetryable(err error) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	rerr, ok := err.(awserr.RequestFailure)
+	return ok && rerr.StatusCode() >= 500
+}
+
+// resetRetryDefaults establishes the default retry behavior; it is called by
+// ResetPackageDefaults(..).
+func resetRetryDefaults() {
+	maxRetries = DefaultMaxRetries
+	retryBaseDelay = defaultRetryBaseDelay
+	sleepFunc = time.Sleep
+}
@@ -0,0 +1,163 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the retry.go functions.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRetryThrottling confirms that throttled requests are retried with exponential
+// backoff until they succeed.
+func TestRetryThrottling(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+	sleeps := mockSleep()
+
+	// Have the fake STS client throttle us twice before relenting
+	calls := 0
+	SetSTSClient(&fakeSTS{getSessionToken: func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		calls++
+		if calls <= 2 {
+			return nil, awserr.New("Throttling", "Rate exceeded", nil)
+		}
+		return successfulSessionTokenOutput(), nil
+	}})
+
+	// Invoke our test target
+	credentials, err := GetSessionCredentials("mfa-device-id", "123456", 3600)
+	require.Nil(t, err, "there should have been no error")
+	require.NotNil(t, credentials, "credentials should have been obtained")
+	require.Equal(t, 3, calls, "expected two retries")
+	require.Equal(t, []time.Duration{defaultRetryBaseDelay, 2 * defaultRetryBaseDelay}, *sleeps, "expected exponential backoff")
+}
+
+// TestRetryServerErrorsExhausted confirms that 5xx errors are retried, but only as many
+// times as we have been told to.
+func TestRetryServerErrorsExhausted(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+	sleeps := mockSleep()
+
+	// Have the fake STS client fail every time
+	calls := 0
+	SetSTSClient(&fakeSTS{getSessionToken: func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		calls++
+		return nil, awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Try again later", nil), 503, "request-id")
+	}})
+
+	// Invoke our test target with a lower retry limit than the default
+	SetMaxRetries(2)
+	credentials, err := GetSessionCredentials("mfa-device-id", "123456", 3600)
+	require.NotNil(t, err, "there should have been an error")
+	require.Nil(t, credentials, "no credentials should have been obtained")
+	require.Equal(t, 3, calls, "expected one try and two retries")
+	require.Len(t, *sleeps, 2, "expected two sleeps")
+}
+
+// TestNoRetryAccessDenied confirms that a rejected token is not retried.
+func TestNoRetryAccessDenied(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+	sleeps := mockSleep()
+
+	// Have the fake STS client reject the token
+	calls := 0
+	SetSTSClient(&fakeSTS{getSessionToken: func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		calls++
+		return nil, awserr.NewRequestFailure(awserr.New("AccessDenied", "MultiFactorAuthentication failed", nil), 403, "request-id")
+	}})
+
+	// Invoke our test target
+	_, err := GetSessionCredentials("mfa-device-id", "123456", 3600)
+	require.NotNil(t, err, "there should have been an error")
+	require.Equal(t, 1, calls, "a rejected token should not be retried")
+	require.Empty(t, *sleeps, "there should have been no sleeping")
+}
+
+// TestRetriesDisabled confirms that zero retries means exactly that.
+func TestRetriesDisabled(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+	mockSleep()
+
+	// Have the fake STS client throttle us every time
+	calls := 0
+	SetSTSClient(&fakeSTS{assumeRole: func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		calls++
+		return nil, awserr.New("ThrottlingException", "Rate exceeded", nil)
+	}})
+
+	// Invoke our test target with retries disabled
+	SetMaxRetries(0)
+	_, err := AssumeRoleCredentials("arn:aws:iam::999999999999:role/admin", "mafia-test", "mfa-device-id", "123456", 3600)
+	require.NotNil(t, err, "there should have been an error")
+	require.Equal(t, 1, calls, "there should have been no retries")
+}
+
+// TestRetryLeftToConfig confirms that a caller's AWS configuration with retrying of its
+// own is not retried by the package as well.
+func TestRetryLeftToConfig(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+	mockSleep()
+
+	// Have the fake STS client throttle us every time
+	calls := 0
+	SetSTSClient(&fakeSTS{getSessionToken: func(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+		calls++
+		return nil, awserr.New("Throttling", "Rate exceeded", nil)
+	}})
+
+	// With the SDK doing the retrying, we should only try the once
+	_, err := GetSessionCredentialsWithConfig(aws.NewConfig().WithMaxRetries(5), "mfa-device-id", "123456", 3600)
+	require.NotNil(t, err, "there should have been an error")
+	require.Equal(t, 1, calls, "the package should not have retried")
+
+	// But a configuration without that should be retried as usual
+	calls = 0
+	_, err = GetSessionCredentialsWithConfig(aws.NewConfig(), "mfa-device-id", "123456", 3600)
+	require.NotNil(t, err, "there should have been an error")
+	require.Equal(t, DefaultMaxRetries+1, calls, "the package should have retried")
+}
+
+// mockSleep replaces the package sleep function with one that records the requested
+// delays, without actually sleeping, in the returned slice.
+func mockSleep() *[]time.Duration {
+	sleeps := &[]time.Duration{}
+	sleepFunc = func(d time.Duration) {
+		*sleeps = append(*sleeps, d)
+	}
+	return sleeps
+}
+
+// successfulSessionTokenOutput returns a GetSessionToken result for fake STS clients.
+func successfulSessionTokenOutput() *sts.GetSessionTokenOutput {
+	accessKey := "key"
+	secret := "secret"
+	token := "token"
+	expiration := time.Now()
+	return &sts.GetSessionTokenOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     &accessKey,
+			SecretAccessKey: &secret,
+			SessionToken:    &token,
+			Expiration:      &expiration,
+		},
+	}
+}
@@ -0,0 +1,199 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// package methods related to assuming an IAM role with MFA authentication.
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	// FallbackRoleSessionName is used as the role session name when none has been
+	// specified and no IAM username is available to build a more descriptive name.
+	FallbackRoleSessionName = "mafia-session"
+
+	// AWS will not accept role session names longer than this
+	maxRoleSessionNameLength = 64
+
+	// The layout of the timestamp embedded in default role session names
+	roleSessionTimestampLayout = "20060102T150405Z"
+)
+
+// GetRoleFunc is a function type that corresponds to the AWS IAM function for describing
+// a role. It is called via a function variable so that unit tests can substitute a mock
+// implementation.
+type GetRoleFunc func(awsService *iam.IAM, input *iam.GetRoleInput) (*iam.GetRoleOutput, error)
+
+var (
+	// The characters that AWS does not accept in a role session name
+	roleSessionNameUnwanted = regexp.MustCompile(`[^a-zA-Z0-9=,.@-]+`)
+
+	// The external ID that roles are assumed with, if any, as third party trust policies
+	// demand. Set via SetExternalID(..) and cleared by ResetPackageDefaults(..).
+	externalID string
+
+	// A function variable that, normally, wraps the AWS IAM GetRole(..) function but can be
+	// overridden for unit testing. Set via SetGetRoleFunc(..) and restored by
+	// ResetPackageDefaults(..).
+	getRoleFunc GetRoleFunc
+
+	// ErrNotRoleARN is returned when a role ARN does not identify an IAM role
+	ErrNotRoleARN = errors.New("not the ARN of an IAM role")
+)
+
+// SetGetRoleFunc allows unit tests to substitute a mock function in place of the default
+// AWS IAM GetRole(..) wrapper so that tests can control the responses.
+func SetGetRoleFunc(f GetRoleFunc) {
+	getRoleFunc = f
+}
+
+// RoleMaxSessionDuration asks AWS IAM for the maximum session duration of the role
+// identified by roleARN, beyond which AWS will refuse to assume it. This can only be
+// found for a role in the same account as the long term credentials, which must have
+// the iam:GetRole permission on it; callers unable to find out should assume no more
+// than the static range that AWS accepts.
+func RoleMaxSessionDuration(roleARN string) (time.Duration, error) {
+
+	// The role's name follows the last slash of its resource, after any path
+	parsed, err := arn.Parse(roleARN)
+	if err != nil || parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "role/") {
+		return 0, ErrNotRoleARN
+	}
+	name := parsed.Resource[strings.LastIndex(parsed.Resource, "/")+1:]
+
+	// Ask AWS IAM what it knows
+	svc := iam.New(newSession(), clientConfig())
+	result, err := getRoleFunc(svc, &iam.GetRoleInput{RoleName: aws.String(name)})
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	if result.Role == nil || result.Role.MaxSessionDuration == nil {
+		return 0, errors.New("AWS IAM did not say what the role's maximum session duration is")
+	}
+	return time.Duration(*result.Role.MaxSessionDuration) * time.Second, nil
+}
+
+// SetExternalID sets the external ID to be presented whenever a role is assumed, as
+// required by roles whose trust policies let a third party assume them. An empty string
+// means that no external ID is presented.
+func SetExternalID(id string) {
+	externalID = id
+}
+
+// AssumeRoleCredentials combines AWS credentials from the environment with a provided MFA
+// token to assume the IAM role identified by roleARN, returning the credentials for the
+// role session.
+//
+// The roleSessionName is recorded in CloudTrail against every action taken with the
+// returned credentials; see DefaultRoleSessionName(..) for a suitable default value.
+// The mfaSerialNumber, mfaToken, and duration values are as for GetSessionCredentials(..),
+// an empty mfaSerialNumber assuming the role without MFA.
+func AssumeRoleCredentials(roleARN, roleSessionName, mfaSerialNumber, mfaToken string, duration int64) (*SessionCredentials, error) {
+
+	// Obtain an AWS STS client, or the fake that unit tests have given us
+	svc := stsClientFor(nil, nil)
+
+	// Prep the input structure for the assume role request
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(roleARN),
+		RoleSessionName: aws.String(roleSessionName),
+		DurationSeconds: aws.Int64(duration),
+	}
+	input.SerialNumber, input.TokenCode = mfaInput(mfaSerialNumber, mfaToken)
+
+	// Have our sibling do the rest
+	return assumeRole(svc, input)
+}
+
+// AssumeRoleWithSessionCredentials assumes the given role using session credentials
+// previously obtained with an MFA token, rather than the long term credentials, so
+// completing a source_profile / role_arn chain. No MFA token is needed since the session
+// is already MFA authenticated. Note that AWS limits the duration of chained role
+// sessions to one hour.
+func AssumeRoleWithSessionCredentials(session *SessionCredentials, roleARN, roleSessionName string, duration int64) (*SessionCredentials, error) {
+
+	// Obtain an AWS STS client that authenticates with the session credentials
+	svc := stsClientFor(credentials.NewStaticCredentials(
+		aws.StringValue(session.AccessKeyID), session.SecretAccessKey.Value(), session.SessionToken.Value()), nil)
+
+	// Prep the input structure for the assume role request and have our sibling do the rest
+	return assumeRole(svc, &sts.AssumeRoleInput{
+		RoleArn:         aws.String(roleARN),
+		RoleSessionName: aws.String(roleSessionName),
+		DurationSeconds: aws.Int64(duration),
+	})
+}
+
+// assumeRole asks AWS STS to assume a role as described by the given input, with the
+// external ID given to SetExternalID(..) if any, translating the result into our own format.
+func assumeRole(svc stsAPI, input *sts.AssumeRoleInput) (*SessionCredentials, error) {
+
+	// Present the external ID, if the role demands one
+	if len(externalID) != 0 {
+		input.ExternalId = aws.String(externalID)
+	}
+
+	// Request the role session from AWS, retrying if AWS is having a bad day
+	var result *sts.AssumeRoleOutput
+	err := withRetries(func() (err error) {
+		result, err = svc.AssumeRole(input)
+		return err
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	// Translate the result into our own format
+	return newSessionCredentials(result.Credentials), nil
+}
+
+// DefaultRoleSessionName builds a role session name in the form mafia-<username>-<timestamp>
+// so that CloudTrail records can be traced back to the human that assumed the role and
+// when they did so. If the username is empty, FallbackRoleSessionName is returned.
+func DefaultRoleSessionName(username string) string {
+
+	// Without a username, there is nothing identifying that we can say
+	if len(username) == 0 {
+		return FallbackRoleSessionName
+	}
+	return timestampedRoleSessionName(username)
+}
+
+// HostnameRoleSessionName builds a role session name in the form mafia-<hostname>-<timestamp>
+// so that CloudTrail records can be traced back to the machine that assumed the role, as
+// is more useful than the username when a fleet of CI machines shares one IAM user. Any
+// character of the hostname that AWS does not accept in a session name is replaced with a
+// hyphen. If nothing of the hostname is left, FallbackRoleSessionName is returned.
+func HostnameRoleSessionName(hostname string) string {
+
+	// Keep only what AWS will accept
+	hostname = strings.Trim(roleSessionNameUnwanted.ReplaceAllString(hostname, "-"), "-")
+	if len(hostname) == 0 {
+		return FallbackRoleSessionName
+	}
+	return timestampedRoleSessionName(hostname)
+}
+
+// timestampedRoleSessionName assembles a role session name from the given identity and the
+// current time, trimming the identity so that the name fits the length that AWS will accept
+// with the timestamp, which tells one session from the next, left whole.
+func timestampedRoleSessionName(identity string) string {
+	prefix, suffix := "mafia-", "-"+nowFunc().UTC().Format(roleSessionTimestampLayout)
+	if room := maxRoleSessionNameLength - len(prefix) - len(suffix); len(identity) > room {
+		identity = identity[:room]
+	}
+	return prefix + identity + suffix
+}
@@ -0,0 +1,201 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the role.go functions.
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAssumeRoleCredentialsSuccess substitutes a fake STS client for the
+// AWS STS AssumeRole(..) call so that we can guarantee success and see what happens.
+func TestAssumeRoleCredentialsSuccess(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Set up a fake AWS STS client that remembers what it was asked for
+	accessKey := "key"
+	secret := "secret"
+	token := "token"
+	expiration := time.Now()
+	var captured *sts.AssumeRoleInput
+	SetSTSClient(&fakeSTS{assumeRole: func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		captured = input
+		return &sts.AssumeRoleOutput{
+				Credentials: &sts.Credentials{
+					AccessKeyId:     &accessKey,
+					SecretAccessKey: &secret,
+					SessionToken:    &token,
+					Expiration:      &expiration,
+				},
+			},
+			nil
+	}})
+
+	// Invoke our test target
+	credentials, err := AssumeRoleCredentials("arn:aws:iam::999999999999:role/admin", "mafia-test", "mfa-device-id", "123456", 3600)
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, accessKey, *credentials.AccessKeyID, "Access key did not match expected value")
+	require.Equal(t, secret, credentials.SecretAccessKey.Value(), "Secret did not match expected value")
+	require.Equal(t, token, credentials.SessionToken.Value(), "session token did not match expected value")
+	require.Equal(t, expiration, *credentials.Expiration, "expiration did not match expected value")
+
+	// Confirm that the request was populated as expected
+	require.Equal(t, "arn:aws:iam::999999999999:role/admin", *captured.RoleArn, "role ARN was not passed on")
+	require.Equal(t, "mafia-test", *captured.RoleSessionName, "role session name was not passed on")
+	require.Equal(t, "mfa-device-id", *captured.SerialNumber, "MFA serial number was not passed on")
+	require.Equal(t, "123456", *captured.TokenCode, "MFA token was not passed on")
+	require.Nil(t, captured.ExternalId, "there should have been no external ID")
+
+	// With an external ID, that should be passed on too
+	SetExternalID("ext-123")
+	_, err = AssumeRoleCredentials("arn:aws:iam::999999999999:role/admin", "mafia-test", "mfa-device-id", "123456", 3600)
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, "ext-123", *captured.ExternalId, "external ID was not passed on")
+}
+
+// TestAssumeRoleCredentialsFailure invokes AssumeRoleCredentials(..) without faking
+// the AWS STS AssumeRole(..) call to test what happens when AWS is really
+// called under circumstances where we can be certain that the request will be rejected.
+func TestAssumeRoleCredentialsFailure(t *testing.T) {
+
+	// Invoke our test target with an utterly bogus role, MFA device serial number and token
+	credentials, err := AssumeRoleCredentials("not-a-role", "mafia-test", "mfa-device-id", "123456", 3600)
+	require.NotNil(t, err, "there should have an error")
+	require.Nil(t, credentials, "no credentials should have been obtained")
+}
+
+// TestRoleMaxSessionDuration confirms that the role is named to AWS IAM without its path,
+// and that its maximum session duration is returned.
+func TestRoleMaxSessionDuration(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	var named string
+	SetGetRoleFunc(func(awsService *iam.IAM, input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+		named = *input.RoleName
+		return &iam.GetRoleOutput{Role: &iam.Role{MaxSessionDuration: aws.Int64(7200)}}, nil
+	})
+	max, err := RoleMaxSessionDuration("arn:aws:iam::999999999999:role/team/admin")
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, "admin", named, "the role should have been named without its path")
+	require.Equal(t, 2*time.Hour, max, "unexpected maximum session duration")
+
+	for _, roleARN := range []string{"not-a-role", "arn:aws:iam::999999999999:user/jane", "arn:aws:s3:::bucket"} {
+		_, err = RoleMaxSessionDuration(roleARN)
+		require.Equal(t, ErrNotRoleARN, err, "%s should have been rejected", roleARN)
+	}
+}
+
+// TestDefaultRoleSessionName confirms that the default session name identifies the user
+// and when the session was started.
+func TestDefaultRoleSessionName(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Freeze time so that we know what the timestamp will be
+	SetNowFunc(func() time.Time {
+		return time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	})
+	require.Equal(t, "mafia-jane-20200401T120000Z", DefaultRoleSessionName("jane"), "unexpected session name")
+}
+
+// TestDefaultRoleSessionNameNoUser confirms the fallback name when there is no username.
+func TestDefaultRoleSessionNameNoUser(t *testing.T) {
+	require.Equal(t, FallbackRoleSessionName, DefaultRoleSessionName(""), "expected the fallback session name")
+}
+
+// TestHostnameRoleSessionName confirms that the hostname session name identifies the machine,
+// keeping only the characters that AWS accepts, and falls back when nothing is left.
+func TestHostnameRoleSessionName(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Freeze time so that we know what the timestamp will be
+	SetNowFunc(func() time.Time {
+		return time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	})
+	require.Equal(t, "mafia-build-01.ci.example.com-20200401T120000Z", HostnameRoleSessionName("build-01.ci.example.com"), "unexpected session name")
+	require.Equal(t, "mafia-ci-runner-7-20200401T120000Z", HostnameRoleSessionName("ci runner_7"), "the hostname should have been sanitized")
+	require.Equal(t, FallbackRoleSessionName, HostnameRoleSessionName("__"), "expected the fallback session name")
+}
+
+// TestDefaultRoleSessionNameTooLong confirms that overly long names are trimmed to what AWS
+// accepts, at the expense of the identity rather than the timestamp.
+func TestDefaultRoleSessionNameTooLong(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Freeze time so that we know what the timestamp will be
+	SetNowFunc(func() time.Time {
+		return time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	})
+	name := DefaultRoleSessionName(strings.Repeat("x", 100))
+	require.Len(t, name, maxRoleSessionNameLength, "session name should have been trimmed")
+	require.True(t, strings.HasSuffix(name, "-20200401T120000Z"), "the timestamp should have survived: %s", name)
+	name = HostnameRoleSessionName(strings.Repeat("build-", 20))
+	require.Len(t, name, maxRoleSessionNameLength, "session name should have been trimmed")
+	require.True(t, strings.HasSuffix(name, "-20200401T120000Z"), "the timestamp should have survived: %s", name)
+}
+
+// TestAssumeRoleWithSessionCredentials confirms that a role can be assumed with session
+// credentials, and without an MFA token, to complete a role chain.
+func TestAssumeRoleWithSessionCredentials(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Set up a fake AWS STS client that remembers what it was asked for
+	roleToken := "role-token"
+	var captured *sts.AssumeRoleInput
+	SetSTSClient(&fakeSTS{assumeRole: func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		captured = input
+		return &sts.AssumeRoleOutput{Credentials: &sts.Credentials{
+			AccessKeyId: aws.String("role-key"), SecretAccessKey: aws.String("role-secret"), SessionToken: &roleToken,
+		}}, nil
+	}})
+
+	// Invoke our test target with some session credentials
+	session := &SessionCredentials{AccessKeyID: aws.String("session-key"), SecretAccessKey: NewSecret("session-secret"), SessionToken: NewSecret("session-token")}
+	credentials, err := AssumeRoleWithSessionCredentials(session, "arn:aws:iam::999999999999:role/admin", "mafia-test", 3600)
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, roleToken, credentials.SessionToken.Value(), "session token did not match expected value")
+
+	// There should have been no MFA
+	require.Equal(t, "arn:aws:iam::999999999999:role/admin", *captured.RoleArn, "role ARN was not passed on")
+	require.Nil(t, captured.SerialNumber, "no MFA serial number should have been passed")
+	require.Nil(t, captured.TokenCode, "no MFA token should have been passed")
+}
+
+// TestSTSClientWithCredentials confirms that the STS client used to complete a role chain
+// authenticates with the credentials that it is given.
+func TestSTSClientWithCredentials(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Even with long term credentials configured, the given credentials should win
+	SetLongTermCredentials(credentials.NewStaticCredentials("long-term-key", "long-term-secret", ""))
+	sessionCredentials := credentials.NewStaticCredentials("session-key", "session-secret", "session-token")
+	svc := newSTSClientWithCredentials(sessionCredentials)
+	value, err := svc.Config.Credentials.Get()
+	require.Nil(t, err, "the client should have had credentials")
+	require.Equal(t, "session-key", value.AccessKeyID, "the session credentials should authenticate the requests")
+}
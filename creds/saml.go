@@ -0,0 +1,50 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// package methods related to assuming an IAM role with a SAML assertion.
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// AssumeRoleWithSAMLCredentials presents a SAML assertion issued by an enterprise identity
+// provider to assume the IAM role identified by roleARN, returning the credentials for the
+// role session. No long term credentials or MFA token are needed; the identity provider
+// has already authenticated the user.
+//
+// The principalARN identifies the SAML provider, as registered in IAM, that issued the
+// assertion, which is the base64 encoded SAMLResponse that the provider posts to AWS.
+// The duration value is as for GetSessionCredentials(..), although AWS will not grant
+// more than the role's maximum session duration.
+func AssumeRoleWithSAMLCredentials(roleARN, principalARN, samlAssertion string, duration int64) (*SessionCredentials, error) {
+
+	// Obtain an AWS STS client, or the fake that unit tests have given us; the SDK sends
+	// this request unsigned, whatever credentials the client has
+	svc := stsClientFor(nil, nil)
+
+	// Prep the input structure for the assume role request
+	input := &sts.AssumeRoleWithSAMLInput{
+		RoleArn:         aws.String(roleARN),
+		PrincipalArn:    aws.String(principalARN),
+		SAMLAssertion:   aws.String(samlAssertion),
+		DurationSeconds: aws.Int64(duration),
+	}
+
+	// Request the role session from AWS, retrying if AWS is having a bad day
+	var result *sts.AssumeRoleWithSAMLOutput
+	err := withRetries(func() (err error) {
+		result, err = svc.AssumeRoleWithSAML(input)
+		return err
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	// Translate the result into our own format
+	return newSessionCredentials(result.Credentials), nil
+}
@@ -0,0 +1,73 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the saml.go functions.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAssumeRoleWithSAMLCredentials substitutes a fake STS client for the AWS STS
+// AssumeRoleWithSAML(..) call so that we can see what the request holds and what comes back.
+func TestAssumeRoleWithSAMLCredentials(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Set up a fake AWS STS client that remembers what it was asked for
+	accessKey := "key"
+	secret := "secret"
+	token := "token"
+	expiration := time.Now()
+	var captured *sts.AssumeRoleWithSAMLInput
+	SetSTSClient(&fakeSTS{assumeRoleWithSAML: func(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error) {
+		captured = input
+		return &sts.AssumeRoleWithSAMLOutput{
+				Credentials: &sts.Credentials{
+					AccessKeyId:     &accessKey,
+					SecretAccessKey: &secret,
+					SessionToken:    &token,
+					Expiration:      &expiration,
+				},
+			},
+			nil
+	}})
+
+	// Invoke our test target
+	credentials, err := AssumeRoleWithSAMLCredentials("arn:aws:iam::999999999999:role/admin", "arn:aws:iam::999999999999:saml-provider/corp", "PHNhbWxwOlJlc3BvbnNlLz4=", 3600)
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, accessKey, *credentials.AccessKeyID, "Access key did not match expected value")
+	require.Equal(t, secret, credentials.SecretAccessKey.Value(), "Secret did not match expected value")
+	require.Equal(t, token, credentials.SessionToken.Value(), "session token did not match expected value")
+	require.Equal(t, expiration, *credentials.Expiration, "expiration did not match expected value")
+
+	// Confirm that the request was populated as expected
+	require.Equal(t, "arn:aws:iam::999999999999:role/admin", *captured.RoleArn, "role ARN was not passed on")
+	require.Equal(t, "arn:aws:iam::999999999999:saml-provider/corp", *captured.PrincipalArn, "principal ARN was not passed on")
+	require.Equal(t, "PHNhbWxwOlJlc3BvbnNlLz4=", *captured.SAMLAssertion, "SAML assertion was not passed on")
+	require.Equal(t, int64(3600), *captured.DurationSeconds, "duration was not passed on")
+}
+
+// TestAssumeRoleWithSAMLCredentialsFailure confirms that an assertion that AWS turns away is
+// reported as an error, with no credentials.
+func TestAssumeRoleWithSAMLCredentialsFailure(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	SetSTSClient(&fakeSTS{assumeRoleWithSAML: func(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error) {
+		return nil, awserr.New("ExpiredTokenException", "Token must be redeemed within 5 minutes of issuance", nil)
+	}})
+	credentials, err := AssumeRoleWithSAMLCredentials("arn:aws:iam::999999999999:role/admin", "arn:aws:iam::999999999999:saml-provider/corp", "stale", 3600)
+	require.NotNil(t, err, "there should have an error")
+	require.Nil(t, credentials, "no credentials should have been obtained")
+}
@@ -0,0 +1,60 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// the Secret type that holds sensitive values in memory that can be wiped.
+
+const (
+	// What is displayed in place of a secret value
+	secretMask = "****"
+)
+
+// Secret holds a sensitive value, such as a secret access key or session token, in a
+// byte slice that can be zeroed with Wipe() once the value is no longer needed. A nil
+// *Secret behaves as an empty one.
+//
+// Wiping is a best effort to shorten the time that a secret lingers in memory, not a
+// guarantee that no copy of it remains. Go strings are immutable, so every string that
+// the value has been converted to by Value(), and the strings that the
+// AWS SDK built while decoding the response, are left to the garbage collector, which
+// does not zero the memory that it reclaims and may already have moved the value around.
+// The operating system may also have swapped the memory out to disk.
+type Secret struct {
+	value []byte
+}
+
+// NewSecret returns a Secret holding a copy of the given value.
+func NewSecret(value string) *Secret {
+	return &Secret{value: []byte(value)}
+}
+
+// Value returns the secret value, or an empty string if it has been wiped. The string
+// returned is a copy that Wipe() cannot reach, so callers should not keep it for longer
+// than they must.
+func (s *Secret) Value() string {
+	if s == nil {
+		return ""
+	}
+	return string(s.value)
+}
+
+// String returns a mask in place of the secret value, so that a Secret displayed with the
+// fmt or text/template packages, or written to a log, by accident does not give it away.
+// Call Value() where the value really is meant to be shown.
+func (s *Secret) String() string {
+	return secretMask
+}
+
+// Wipe overwrites the secret value with zeros and empties the Secret.
+func (s *Secret) Wipe() {
+	if s == nil {
+		return
+	}
+	for i := range s.value {
+		s.value[i] = 0
+	}
+	s.value = s.value[:0]
+}
@@ -0,0 +1,48 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the secret.go functions.
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSecret confirms that a secret gives up its value until it is wiped, but not when it
+// is formatted, and that wiping really does overwrite the bytes that held it.
+func TestSecret(t *testing.T) {
+
+	secret := NewSecret("hunter2")
+	require.Equal(t, "hunter2", secret.Value(), "the value should have been returned")
+	require.Equal(t, "****", fmt.Sprint(secret), "the value should have been masked")
+	require.Equal(t, "**** ****", fmt.Sprintf("%v %s", secret, secret), "the value should have been masked")
+
+	// Keep hold of the bytes so that we can see what becomes of them
+	held := secret.value
+	secret.Wipe()
+	require.Empty(t, secret.Value(), "the value should have gone")
+	require.Equal(t, make([]byte, len("hunter2")), held[:len("hunter2")], "the bytes should have been zeroed")
+
+	// A nil secret is just an empty one
+	var missing *Secret
+	require.Empty(t, missing.Value(), "a nil secret should have no value")
+	missing.Wipe()
+}
+
+// TestWipeSessionCredentials confirms that wiping session credentials wipes both of the
+// sensitive values but leaves the rest alone.
+func TestWipeSessionCredentials(t *testing.T) {
+
+	credentials := &SessionCredentials{AccessKeyID: aws.String("key"), SecretAccessKey: NewSecret("secret"), SessionToken: NewSecret("token")}
+	credentials.Wipe()
+	require.Equal(t, "key", *credentials.AccessKeyID, "the access key ID is not secret and should remain")
+	require.Empty(t, credentials.SecretAccessKey.Value(), "the secret access key should have been wiped")
+	require.Empty(t, credentials.SessionToken.Value(), "the session token should have been wiped")
+}
@@ -0,0 +1,67 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// package methods related to obtaining the credentials of an AWS SSO role.
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sso"
+)
+
+// ssoAPI is the subset of the AWS SSO client methods that this package calls, so that
+// unit tests can substitute a fake via SetSSOClient(..).
+type ssoAPI interface {
+	GetRoleCredentials(input *sso.GetRoleCredentialsInput) (*sso.GetRoleCredentialsOutput, error)
+}
+
+var (
+	// The SSO client to be used in place of a real AWS one, if any. Set by unit tests
+	// via SetSSOClient(..) and cleared by ResetPackageDefaults(..).
+	ssoClient ssoAPI
+)
+
+// GetSSORoleCredentials exchanges the given AWS SSO access token, as cached by aws sso
+// login, for the credentials of the named role in the given account, asking the AWS SSO
+// portal of the given region. The credentials returned are suitable for passing to
+// SetLongTermCredentials(..), though they are themselves short lived: AWS will not give
+// a plain session for them, but they can assume a role.
+func GetSSORoleCredentials(accessToken, ssoRegion, accountID, roleName string) (*credentials.Credentials, error) {
+
+	// Obtain an AWS SSO client, or the fake that unit tests have given us; the request is
+	// authenticated by the access token alone
+	svc := ssoClient
+	if svc == nil {
+		cfg := aws.NewConfig().WithMaxRetries(0).WithHTTPClient(httpClient()).WithRegion(ssoRegion).
+			WithCredentials(credentials.AnonymousCredentials)
+		svc = sso.New(newSession(), cfg)
+	}
+
+	// Ask for the role's credentials, retrying if AWS is having a bad day
+	input := &sso.GetRoleCredentialsInput{
+		AccessToken: aws.String(accessToken),
+		AccountId:   aws.String(accountID),
+		RoleName:    aws.String(roleName),
+	}
+	var result *sso.GetRoleCredentialsOutput
+	err := withRetries(func() (err error) {
+		result, err = svc.GetRoleCredentials(input)
+		return err
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	role := result.RoleCredentials
+	return credentials.NewStaticCredentials(aws.StringValue(role.AccessKeyId),
+		aws.StringValue(role.SecretAccessKey), aws.StringValue(role.SessionToken)), nil
+}
+
+// SetSSOClient allows unit tests to substitute a fake SSO client in place of the real AWS
+// one. Passing nil restores the real client.
+func SetSSOClient(client ssoAPI) {
+	ssoClient = client
+}
@@ -0,0 +1,77 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the sso.go functions.
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sso"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSSO is a fake AWS SSO client whose response is supplied by the unit tests.
+type fakeSSO func(input *sso.GetRoleCredentialsInput) (*sso.GetRoleCredentialsOutput, error)
+
+// GetRoleCredentials calls the fake.
+func (f fakeSSO) GetRoleCredentials(input *sso.GetRoleCredentialsInput) (*sso.GetRoleCredentialsOutput, error) {
+	return f(input)
+}
+
+// TestGetSSORoleCredentials confirms that the access token, account, and role are passed
+// on and the role credentials handed back.
+func TestGetSSORoleCredentials(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Set up a fake AWS SSO client that captures the request and returns known credentials
+	var captured *sso.GetRoleCredentialsInput
+	SetSSOClient(fakeSSO(func(input *sso.GetRoleCredentialsInput) (*sso.GetRoleCredentialsOutput, error) {
+		captured = input
+		return &sso.GetRoleCredentialsOutput{RoleCredentials: &sso.RoleCredentials{
+			AccessKeyId:     aws.String("ASIASSO"),
+			SecretAccessKey: aws.String("sso-secret"),
+			SessionToken:    aws.String("sso-token"),
+			Expiration:      aws.Int64(1900000000000),
+		}}, nil
+	}))
+
+	// Invoke our test target
+	c, err := GetSSORoleCredentials("access-token", "eu-west-1", "210987654321", "Developer")
+	require.Nil(t, err, "there should have been no error")
+	require.Equal(t, "access-token", *captured.AccessToken, "the access token was not passed on")
+	require.Equal(t, "210987654321", *captured.AccountId, "the account was not passed on")
+	require.Equal(t, "Developer", *captured.RoleName, "the role was not passed on")
+	value, err := c.Get()
+	require.Nil(t, err, "the credentials should have been usable")
+	require.Equal(t, "ASIASSO", value.AccessKeyID)
+	require.Equal(t, "sso-secret", value.SecretAccessKey)
+	require.Equal(t, "sso-token", value.SessionToken)
+}
+
+// TestGetSSORoleCredentialsRejected confirms that an expired access token is reported as
+// an AuthError.
+func TestGetSSORoleCredentialsRejected(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// Set up a fake AWS SSO client that no longer accepts the token
+	SetSSOClient(fakeSSO(func(input *sso.GetRoleCredentialsInput) (*sso.GetRoleCredentialsOutput, error) {
+		return nil, awserr.New(sso.ErrCodeUnauthorizedException, "Session token not found or invalid", nil)
+	}))
+
+	// Invoke our test target
+	c, err := GetSSORoleCredentials("access-token", "eu-west-1", "210987654321", "Developer")
+	require.Nil(t, c, "no credentials should have been obtained")
+	var authErr *AuthError
+	require.True(t, errors.As(err, &authErr), "expected an AuthError, got %v", err)
+}
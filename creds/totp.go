@@ -0,0 +1,115 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// the functions that generate time-based MFA codes, as a virtual MFA device does.
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPPeriod is how long each time-based MFA code is good for
+	TOTPPeriod = 30 * time.Second
+
+	// The number of digits in an MFA code, and ten raised to that power
+	totpDigits  = 6
+	totpModulus = 1000000
+)
+
+var (
+	// ErrInvalidTOTPSecret is returned when a TOTP secret is not valid base32
+	ErrInvalidTOTPSecret = errors.New("the TOTP secret is not valid base32")
+
+	// ErrInvalidOTPAuthURL is returned when an otpauth URL does not describe a virtual MFA
+	// device that we can generate codes for
+	ErrInvalidOTPAuthURL = errors.New("not a usable otpauth://totp/ URL")
+)
+
+// DecodeTOTPSecret decodes the base32 secret of a virtual MFA device, as shown by the AWS
+// console or held in an otpauth URL, forgiving lower case letters, spaces, and missing
+// padding.
+func DecodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidTOTPSecret
+	}
+	return key, nil
+}
+
+// ParseOTPAuthURL returns the base32 secret held in an otpauth://totp/ URL, as encoded in
+// the QR code shown when a virtual MFA device is set up. The URL is rejected if its secret
+// is missing or not valid base32, or if it asks for an algorithm, number of digits, or
+// period other than those that AWS uses, since the codes generated would be wrong.
+func ParseOTPAuthURL(rawURL string) (string, error) {
+
+	// It must be a TOTP URL
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || !strings.EqualFold(u.Scheme, "otpauth") || !strings.EqualFold(u.Host, "totp") {
+		return "", ErrInvalidOTPAuthURL
+	}
+
+	// The parameters that we cannot honor must be absent or say what we do anyway
+	query := u.Query()
+	if algorithm := query.Get("algorithm"); len(algorithm) != 0 && !strings.EqualFold(algorithm, "SHA1") {
+		return "", fmt.Errorf("%w: the %s algorithm is not supported", ErrInvalidOTPAuthURL, algorithm)
+	}
+	for name, want := range map[string]int{"digits": totpDigits, "period": int(TOTPPeriod / time.Second)} {
+		if value := query.Get(name); len(value) != 0 {
+			if n, err := strconv.Atoi(value); err != nil || n != want {
+				return "", fmt.Errorf("%w: %s must be %d, not %s", ErrInvalidOTPAuthURL, name, want, value)
+			}
+		}
+	}
+
+	// And the secret must be one that we can use
+	secret := query.Get("secret")
+	if len(secret) == 0 {
+		return "", fmt.Errorf("%w: there is no secret", ErrInvalidOTPAuthURL)
+	}
+	if _, err = DecodeTOTPSecret(secret); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidOTPAuthURL, err)
+	}
+	return secret, nil
+}
+
+// GenerateTOTPCode returns the MFA code that a virtual MFA device holding the given base32
+// secret would display now, following RFC 6238 as AWS does.
+func GenerateTOTPCode(secret string) (string, error) {
+	key, err := DecodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return totpCode(key, nowFunc()), nil
+}
+
+// UntilNextTOTPCode returns how long it will be until the current MFA code is replaced
+// by the next one.
+func UntilNextTOTPCode() time.Duration {
+	return TOTPPeriod - time.Duration(nowFunc().UnixNano())%TOTPPeriod
+}
+
+// totpCode returns the RFC 6238 code for the given key at the given time.
+func totpCode(key []byte, at time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/int64(TOTPPeriod/time.Second)))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulus)
+}
@@ -0,0 +1,96 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the totp.go functions.
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// The RFC 6238 test secret, "12345678901234567890", in base32
+	rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+)
+
+// TestGenerateTOTPCode confirms that the RFC 6238 SHA1 test vectors, cut to six digits,
+// are reproduced.
+func TestGenerateTOTPCode(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	for seconds, expected := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		SetNowFunc(func() time.Time { return time.Unix(seconds, 0) })
+		code, err := GenerateTOTPCode(rfcTOTPSecret)
+		require.Nil(t, err, "there should not have been an error")
+		require.Equal(t, expected, code, "unexpected code at %d", seconds)
+	}
+}
+
+// TestDecodeTOTPSecret confirms that secrets are forgiven their case, spaces, and padding,
+// but not for being something other than base32.
+func TestDecodeTOTPSecret(t *testing.T) {
+	expected := []byte("12345678901234567890")
+	for _, secret := range []string{rfcTOTPSecret, "gezd gnbv gy3t qojq gezd gnbv gy3t qojq", "MFRGG==="} {
+		key, err := DecodeTOTPSecret(secret)
+		require.Nil(t, err, "%s should have been decoded", secret)
+		if secret != "MFRGG===" {
+			require.Equal(t, expected, key, "unexpected key for %s", secret)
+		}
+	}
+	for _, secret := range []string{"", "not base32!", "18"} {
+		_, err := DecodeTOTPSecret(secret)
+		require.Equal(t, ErrInvalidTOTPSecret, err, "%q should have been rejected", secret)
+	}
+}
+
+// TestParseOTPAuthURL confirms that the secret is taken from a TOTP otpauth URL, and that
+// URLs that cannot give us the right codes are rejected.
+func TestParseOTPAuthURL(t *testing.T) {
+	secret, err := ParseOTPAuthURL("otpauth://totp/Amazon%20Web%20Services:jane@999999999999?secret=" + rfcTOTPSecret + "&issuer=Amazon%20Web%20Services")
+	require.Nil(t, err, "the URL should have been accepted")
+	require.Equal(t, rfcTOTPSecret, secret, "unexpected secret")
+
+	_, err = ParseOTPAuthURL("otpauth://totp/jane?secret=" + rfcTOTPSecret + "&algorithm=SHA1&digits=6&period=30")
+	require.Nil(t, err, "parameters that say what we do anyway should have been accepted")
+
+	for _, rawURL := range []string{
+		"https://example.com/?secret=" + rfcTOTPSecret,
+		"otpauth://hotp/jane?secret=" + rfcTOTPSecret,
+		"otpauth://totp/jane",
+		"otpauth://totp/jane?secret=not%20base32!",
+		"otpauth://totp/jane?secret=" + rfcTOTPSecret + "&algorithm=SHA256",
+		"otpauth://totp/jane?secret=" + rfcTOTPSecret + "&digits=8",
+		"otpauth://totp/jane?secret=" + rfcTOTPSecret + "&period=60",
+	} {
+		_, err = ParseOTPAuthURL(rawURL)
+		require.True(t, errors.Is(err, ErrInvalidOTPAuthURL), "%s should have been rejected, not %v", rawURL, err)
+	}
+}
+
+// TestUntilNextTOTPCode confirms that the wait is measured to the start of the next window.
+func TestUntilNextTOTPCode(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	SetNowFunc(func() time.Time { return time.Unix(1234567890, 0) })
+	require.Equal(t, 30*time.Second, UntilNextTOTPCode(), "a new window has just begun")
+	SetNowFunc(func() time.Time { return time.Unix(1234567899, 500000000) })
+	require.Equal(t, 20*time.Second+500*time.Millisecond, UntilNextTOTPCode(), "unexpected wait")
+}
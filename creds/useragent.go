@@ -0,0 +1,62 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// the User-Agent that requests to AWS identify themselves with.
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+const (
+	// DefaultUserAgent is the product token that requests to AWS identify themselves
+	// with unless SetUserAgent(..) says otherwise
+	DefaultUserAgent = "mafia"
+
+	// The name of the HTTP header that carries the user agent
+	userAgentHeader = "User-Agent"
+)
+
+var (
+	// The product token that begins the User-Agent header of every request to AWS. Set via
+	// SetUserAgent(..) and restored by ResetPackageDefaults(..).
+	userAgent string
+)
+
+// SetUserAgent sets the product token, e.g. mafia/1.2.3, that begins the User-Agent header
+// of every request made to AWS, ahead of the AWS SDK's own, so that proxies and egress
+// logs can tell who made them and filter on it. An empty string restores DefaultUserAgent.
+func SetUserAgent(ua string) {
+	if len(ua) == 0 {
+		ua = DefaultUserAgent
+	}
+	userAgent = ua
+}
+
+// newSession returns an AWS SDK session configured from the environment whose requests,
+// and those of the clients built from it, identify themselves with our user agent.
+func newSession() *session.Session {
+	sess := session.New()
+	sess.Handlers.Build.PushBackNamed(request.NamedHandler{
+		Name: "mafia.UserAgentHandler",
+		Fn: func(r *request.Request) {
+			setUserAgent(r.HTTPRequest)
+		},
+	})
+	return sess
+}
+
+// setUserAgent puts our user agent at the front of the given request's User-Agent header.
+func setUserAgent(r *http.Request) {
+	if existing := r.Header.Get(userAgentHeader); len(existing) != 0 {
+		r.Header.Set(userAgentHeader, userAgent+" "+existing)
+		return
+	}
+	r.Header.Set(userAgentHeader, userAgent)
+}
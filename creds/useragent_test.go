@@ -0,0 +1,54 @@
+package creds
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See creds.go for overall package documentation. This file contains
+// unit tests for the useragent.go functions.
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUserAgent confirms that STS requests and pings begin their User-Agent header with
+// ours, ahead of the AWS SDK's, and that it can be overridden.
+func TestUserAgent(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	// An endpoint that notes who called and refuses them all
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	SetSTSEndpoint(server.URL)
+	SetLongTermCredentials(credentials.NewStaticCredentials("key", "secret", ""))
+
+	// By default, we are mafia and then the SDK
+	GetSessionCredentials("arn:aws:iam::210987654321:mfa/pat", "123456", 3600)
+	_, err := Ping()
+	require.Nil(t, err, "there should not have been an error")
+	require.Len(t, agents, 2, "expected an STS request and a ping")
+	require.True(t, strings.HasPrefix(agents[0], DefaultUserAgent+" aws-sdk-go/"), "unexpected STS user agent: %s", agents[0])
+	require.Equal(t, DefaultUserAgent, agents[1], "unexpected ping user agent")
+
+	// Unless told otherwise
+	agents = nil
+	SetUserAgent("mafia/1.2.3")
+	GetSessionCredentials("arn:aws:iam::210987654321:mfa/pat", "123456", 3600)
+	require.True(t, strings.HasPrefix(agents[0], "mafia/1.2.3 aws-sdk-go/"), "unexpected STS user agent: %s", agents[0])
+
+	// And an empty string puts the default back
+	SetUserAgent("")
+	require.Equal(t, DefaultUserAgent, userAgent, "the default should have been restored")
+}
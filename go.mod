@@ -5,6 +5,9 @@ go 1.14
 require (
 	github.com/aws/aws-sdk-go v1.30.4
 	github.com/spf13/cobra v0.0.7
+	github.com/spf13/pflag v1.0.3
 	github.com/stretchr/testify v1.5.1
+	golang.org/x/crypto v0.0.0-20200406173513-056763e48d71
 	gopkg.in/ini.v1 v1.55.0
+	gopkg.in/yaml.v2 v2.2.2
 )
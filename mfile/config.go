@@ -0,0 +1,162 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// package methods related to reading role profiles from the AWS config file.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ConfigFileEnvVar names the environment variable that the AWS CLI and SDKs consult
+	// for the location of the config file
+	ConfigFileEnvVar = "AWS_CONFIG_FILE"
+
+	// The keys of a role profile section in the AWS config file
+	roleARNKey         = "role_arn"
+	sourceProfileKey   = "source_profile"
+	roleSessionNameKey = "role_session_name"
+	durationSecondsKey = "duration_seconds"
+	externalIDKey      = "external_id"
+
+	// The prefix of all but the default profile section names in the AWS config file
+	configProfilePrefix = "profile "
+)
+
+// RoleProfile describes a profile in the AWS config file that assumes a role using the
+// credentials of another, source, profile.
+type RoleProfile struct {
+	Name            string // The name of the profile
+	RoleARN         string // The ARN of the role to be assumed
+	SourceProfile   string // The profile whose credentials are used to assume the role
+	MFASerial       string // The MFA device ID / serial number to authenticate with, if given
+	RoleSessionName string // The role session name to be recorded by CloudTrail, if given
+	DurationSeconds int64  // How long the role session is to last, in seconds, if given; zero otherwise
+	ExternalID      string // The external ID demanded by the role's trust policy, if given
+}
+
+var (
+	// What the name says, filled in at load time. Like defaultCredentialsFilePath, this
+	// can be overridden by unit tests to better control outcomes.
+	defaultConfigFilePath string
+
+	// True if OverrideDefaultConfigFilepath(..) has been called, in which case the
+	// environment is not consulted for the location of the config file
+	defaultConfigPathOverridden bool
+)
+
+// ResolveConfigPath returns the path of the AWS config file: the value of the
+// AWS_CONFIG_FILE environment variable if that is set, expanded by ExpandPath(..),
+// otherwise $HOME/.aws/config. Where unit tests have overridden the default path, the
+// environment is ignored.
+func ResolveConfigPath() string {
+	if !defaultConfigPathOverridden {
+		if envPath := os.Getenv(ConfigFileEnvVar); len(envPath) != 0 {
+			return ExpandPath(envPath)
+		}
+	}
+	return defaultConfigFilePath
+}
+
+// GetRoleProfileFromFile returns the role profile of the given name from the given AWS
+// config file. If the file does not exist, has no such profile, or the profile does not
+// have both a role_arn and a source_profile, nil is returned without error. A
+// duration_seconds that is not a positive whole number is an error.
+func GetRoleProfileFromFile(filepath, profile string) (*RoleProfile, error) {
+
+	// Not having a config file at all is perfectly normal
+	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	// Load the file
+	cfg, err := loadINI(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read from config file %s: %v", filepath, err)
+	}
+
+	// Fetch the profile section - if there is one
+	section, err := cfg.GetSection(ConfigSectionName(profile))
+	if err != nil {
+		return nil, nil
+	}
+
+	// It is only a role profile if it says what role and what to assume it with
+	roleProfile := &RoleProfile{
+		Name:            profile,
+		RoleARN:         section.Key(roleARNKey).Value(),
+		SourceProfile:   section.Key(sourceProfileKey).Value(),
+		MFASerial:       section.Key(MfaSerialKey).Value(),
+		RoleSessionName: section.Key(roleSessionNameKey).Value(),
+		ExternalID:      section.Key(externalIDKey).Value(),
+	}
+	if len(roleProfile.RoleARN) == 0 || len(roleProfile.SourceProfile) == 0 {
+		return nil, nil
+	}
+
+	// A duration, if there is one, had better be a number
+	if key := section.Key(durationSecondsKey); len(key.Value()) != 0 {
+		if roleProfile.DurationSeconds, err = key.Int64(); err != nil || roleProfile.DurationSeconds <= 0 {
+			return nil, fmt.Errorf("invalid %s %q in the %s profile of %s", durationSecondsKey, key.Value(), profile, filepath)
+		}
+	}
+	return roleProfile, nil
+}
+
+// GetProfileMFASerialFromFile returns the mfa_serial that the AWS CLI would authenticate
+// the named profile of the given AWS config file with, along with the profile's
+// source_profile, if it has one, whose long term credentials the CLI would use with it.
+// Either is empty if the profile does not give it; a missing file or profile is an error.
+func GetProfileMFASerialFromFile(filepath, profile string) (string, string, error) {
+	if err := checkDefaultPath(filepath); err != nil {
+		return "", "", err
+	}
+
+	// Load the file
+	cfg, err := loadINI(filepath)
+	if err != nil {
+		return "", "", fmt.Errorf("Could not read from config file %s: %v", filepath, err)
+	}
+
+	// Fetch the profile section - if there is one
+	section, err := cfg.GetSection(ConfigSectionName(profile))
+	if err != nil {
+		return "", "", fmt.Errorf("%s profile not found in %s", profile, filepath)
+	}
+	return section.Key(MfaSerialKey).Value(), section.Key(sourceProfileKey).Value(), nil
+}
+
+// OverrideDefaultConfigFilepath is intended for use by unit tests that need to manage
+// the behavior of this package when reading the AWS config file, keeping them away from
+// the real one.
+func OverrideDefaultConfigFilepath(filepath string) {
+	defaultConfigFilePath = filepath
+	defaultConfigPathOverridden = true
+}
+
+// ConfigSectionName returns the name of the AWS config file section for the named profile;
+// all but the default profile have their names prefixed with "profile ".
+func ConfigSectionName(profile string) string {
+	if profile == DefaultSectionName {
+		return profile
+	}
+	return configProfilePrefix + profile
+}
+
+// resetConfigDefaults restores the default locations of the AWS config file and SSO
+// cache, alongside the credentials file in $HOME/.aws, or none if there is no home
+// directory for that.
+func resetConfigDefaults() {
+	defaultConfigFilePath, defaultSSOCacheDir = "", ""
+	if len(defaultCredentialsFilePath) != 0 {
+		defaultConfigFilePath = filepath.Join(filepath.Dir(defaultCredentialsFilePath), "config")
+		defaultSSOCacheDir = filepath.Join(filepath.Dir(defaultCredentialsFilePath), "sso", "cache")
+	}
+	defaultConfigPathOverridden = false
+}
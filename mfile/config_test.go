@@ -0,0 +1,119 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// unit tests for the config.go functions.
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Where unit tests write a fake AWS config file
+	fakeConfigFilePath = "./config.test"
+)
+
+// TestGetRoleProfile confirms that role profiles are recognized, and other profiles not.
+func TestGetRoleProfile(t *testing.T) {
+
+	// Write a config file with a role profile, a plain one, and a half finished one
+	content := `[default]
+region = us-east-1
+
+[profile admin]
+role_arn = arn:aws:iam::123456789012:role/admin
+source_profile = default
+mfa_serial = arn:aws:iam::123456789012:mfa/jane
+role_session_name = jane-admin
+duration_seconds = 1800
+external_id = ext-123
+
+[profile orphan]
+role_arn = arn:aws:iam::123456789012:role/orphan
+
+[profile forever]
+role_arn = arn:aws:iam::123456789012:role/forever
+source_profile = default
+duration_seconds = forever
+`
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+	defer os.Remove(fakeConfigFilePath)
+
+	// The role profile should be found in full
+	roleProfile, err := GetRoleProfileFromFile(fakeConfigFilePath, "admin")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, &RoleProfile{
+		Name:            "admin",
+		RoleARN:         "arn:aws:iam::123456789012:role/admin",
+		SourceProfile:   "default",
+		MFASerial:       "arn:aws:iam::123456789012:mfa/jane",
+		RoleSessionName: "jane-admin",
+		DurationSeconds: 1800,
+		ExternalID:      "ext-123",
+	}, roleProfile)
+
+	// A duration that is not a number is an error
+	_, err = GetRoleProfileFromFile(fakeConfigFilePath, "forever")
+	require.NotNil(t, err, "a nonsense duration_seconds should have been an error")
+
+	// The others are not role profiles, nor is one that does not exist
+	for _, profile := range []string{DefaultSectionName, "orphan", "missing"} {
+		roleProfile, err = GetRoleProfileFromFile(fakeConfigFilePath, profile)
+		require.Nil(t, err, "there should not have been an error for %s", profile)
+		require.Nil(t, roleProfile, "%s should not have been a role profile", profile)
+	}
+}
+
+// TestGetRoleProfileNoFile confirms that a missing config file is not an error.
+func TestGetRoleProfileNoFile(t *testing.T) {
+	roleProfile, err := GetRoleProfileFromFile("/you/got/no/skin/on/me-cos-i-do-not-exist", "admin")
+	require.Nil(t, err, "there should not have been an error")
+	require.Nil(t, roleProfile, "there should not have been a role profile")
+}
+
+// TestGetProfileMFASerial confirms that the mfa_serial and source_profile of a config file
+// profile are found, and that missing files and profiles are errors.
+func TestGetProfileMFASerial(t *testing.T) {
+	content := "[default]\nmfa_serial = arn:aws:iam::123456789012:mfa/jane\n\n[profile admin]\nsource_profile = default\nmfa_serial = arn:aws:iam::123456789012:mfa/admin\n\n[profile plain]\nregion = us-east-1\n"
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(content), 0600), "could not write the fake config file")
+	defer os.Remove(fakeConfigFilePath)
+
+	serial, source, err := GetProfileMFASerialFromFile(fakeConfigFilePath, DefaultSectionName)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "arn:aws:iam::123456789012:mfa/jane", serial)
+	require.Empty(t, source)
+
+	serial, source, err = GetProfileMFASerialFromFile(fakeConfigFilePath, "admin")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "arn:aws:iam::123456789012:mfa/admin", serial)
+	require.Equal(t, DefaultSectionName, source)
+
+	serial, _, err = GetProfileMFASerialFromFile(fakeConfigFilePath, "plain")
+	require.Nil(t, err, "there should not have been an error")
+	require.Empty(t, serial)
+
+	_, _, err = GetProfileMFASerialFromFile(fakeConfigFilePath, "missing")
+	require.NotNil(t, err, "a missing profile should have been an error")
+	_, _, err = GetProfileMFASerialFromFile("./missing-config.test", DefaultSectionName)
+	require.NotNil(t, err, "a missing file should have been an error")
+}
+
+// TestResolveConfigPath confirms that AWS_CONFIG_FILE is honored, except under test.
+func TestResolveConfigPath(t *testing.T) {
+
+	// Revert the package state and environment back to normal after the test has run
+	defer ResetPackageDefaults()
+	defer restoreEnv(ConfigFileEnvVar)()
+
+	os.Setenv(ConfigFileEnvVar, "/env/config")
+	require.Equal(t, "/env/config", ResolveConfigPath(), "the environment should have been honored")
+	OverrideDefaultConfigFilepath(fakeConfigFilePath)
+	require.Equal(t, fakeConfigFilePath, ResolveConfigPath(), "the override should have won")
+}
@@ -0,0 +1,201 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// the line based editing that changes the AWS credentials file without disturbing it.
+
+import (
+	"strings"
+)
+
+const (
+	// The permissions given to a credentials file if it has to be created; an existing
+	// file keeps its own
+	credentialsFileMode = 0600
+)
+
+// writeSection rewrites the given file, whose current content is given, with the named
+// section's keys set as editSection(..) sets them.
+func writeSection(filepath string, content []byte, section string, values []keyValue) error {
+	return credentialsStorage.WriteFile(filepath, editSection(content, section, values), credentialsFileMode)
+}
+
+// editSection returns the given ini file content with the keys of the named section set to
+// the given values, an empty value removing the key, and the section added at the end if
+// it is not there already. The ini library would reformat the whole file when saving it,
+// aligning values, moving inline comments, and dropping blank lines, so this edits the
+// lines of the section instead: every other line, comments and all, is left byte for byte
+// as it was, as are the spacing and the key = or key: style of the lines that change. New
+// lines follow the FileFormat set by SetFileFormat(..).
+func editSection(content []byte, section string, values []keyValue) []byte {
+
+	// Work line by line, ending new lines as the file already does
+	eol := "\n"
+	if strings.Contains(string(content), "\r\n") {
+		eol = "\r\n"
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// Find the lines of the section, from its header to the next header
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		if name, ok := sectionHeader(line); ok {
+			if start >= 0 {
+				end = i
+				break
+			}
+			if name == section {
+				start = i
+			}
+		}
+	}
+
+	// Without the section, add it to the end of the file
+	if start < 0 {
+		var added []string
+		if len(lines) != 0 {
+			if !strings.HasSuffix(lines[len(lines)-1], "\n") {
+				lines[len(lines)-1] += eol
+			}
+			added = append(added, eol)
+		}
+		added = append(added, "["+section+"]"+eol)
+		for _, kv := range values {
+			if len(kv.value) != 0 {
+				added = append(added, fileFormat.keyValueLine(kv.name, kv.value)+eol)
+			}
+		}
+		return []byte(strings.Join(append(lines, added...), ""))
+	}
+
+	// Change, or drop, the lines of the keys that are already there
+	wanted := make(map[string]string, len(values))
+	for _, kv := range values {
+		wanted[kv.name] = kv.value
+	}
+	set := make(map[string]bool, len(values))
+	edited := append([]string{}, lines[:start+1]...)
+	lastKey := start
+	for _, line := range lines[start+1 : end] {
+		name, prefix, ok := keyLine(line)
+		value, changing := wanted[name]
+		switch {
+		case !ok || !changing:
+			edited = append(edited, line)
+		case len(value) == 0 || set[name]:
+			continue
+		default:
+			edited = append(edited, prefix+value+line[len(strings.TrimRight(line, "\r\n")):])
+			set[name] = true
+		}
+		if ok {
+			lastKey = len(edited) - 1
+		}
+	}
+
+	// Add the keys that were not there after the last of those that were
+	var added []string
+	for _, kv := range values {
+		if len(kv.value) != 0 && !set[kv.name] {
+			added = append(added, fileFormat.keyValueLine(kv.name, kv.value)+eol)
+		}
+	}
+	if len(added) != 0 && !strings.HasSuffix(edited[lastKey], "\n") {
+		edited[lastKey] += eol
+	}
+	edited = append(edited[:lastKey+1], append(added, edited[lastKey+1:]...)...)
+	return []byte(strings.Join(append(edited, lines[end:]...), ""))
+}
+
+// removeSection returns the given ini file content without the named section, from its
+// header up to the comment and blank lines that introduce the next one, and true, or the
+// content as it was and false if there is no such section. Every other line is left byte
+// for byte as it was, except that a blank line that would double the one before the
+// section goes with it, as do the comment and blank lines after a section at the end of
+// the file and the blank lines that separated it from those before it.
+func removeSection(content []byte, section string) ([]byte, bool) {
+
+	// Find the lines of the section, from its header to the next header
+	lines := strings.SplitAfter(string(content), "\n")
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		if name, ok := sectionHeader(line); ok {
+			if start >= 0 {
+				end = i
+				break
+			}
+			if name == section {
+				start = i
+			}
+		}
+	}
+	if start < 0 {
+		return content, false
+	}
+
+	// Leave the comment and blank lines just above the next header to introduce it,
+	// less any blank lines that would double up with those before the section
+	kept := lines[:start]
+	if end < len(lines) {
+		for end > start+1 && isBlankOrComment(lines[end-1]) {
+			end--
+		}
+		if len(kept) == 0 || len(strings.TrimSpace(kept[len(kept)-1])) == 0 {
+			for end < len(lines) && len(strings.TrimSpace(lines[end])) == 0 {
+				end++
+			}
+		}
+		return []byte(strings.Join(append(kept, lines[end:]...), "")), true
+	}
+
+	// Drop the blank lines before a section that nothing follows
+	for len(kept) != 0 && len(strings.TrimSpace(kept[len(kept)-1])) == 0 {
+		kept = kept[:len(kept)-1]
+	}
+	return []byte(strings.Join(kept, "")), true
+}
+
+// isBlankOrComment returns true if the given ini file line is blank or a comment.
+func isBlankOrComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) == 0 || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#")
+}
+
+// sectionHeader returns the name of the section that the given line begins, and true,
+// or false if it is not a section header.
+func sectionHeader(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") {
+		return "", false
+	}
+	closing := strings.Index(line, "]")
+	if closing < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(line[1:closing]), true
+}
+
+// keyLine returns the name of the key that the given line sets, the part of the line that
+// precedes its value, and true, or false if the line is blank, a comment, or not a key.
+func keyLine(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) == 0 || trimmed[0] == '#' || trimmed[0] == ';' {
+		return "", "", false
+	}
+	delimiter := strings.IndexAny(line, fileFormat.delimiters())
+	if delimiter < 0 {
+		return "", "", false
+	}
+	name := strings.TrimSpace(line[:delimiter])
+	rest := strings.TrimRight(line[delimiter+1:], "\r\n")
+	if len(strings.TrimSpace(rest)) == 0 {
+		return name, strings.TrimRight(line[:delimiter], " \t") + fileFormat.delimiterWithSpacing(line[delimiter:delimiter+1]), true
+	}
+	return name, line[:len(line)-len(strings.TrimLeft(line[delimiter+1:], " \t"))], true
+}
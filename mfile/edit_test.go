@@ -0,0 +1,64 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// unit tests for the edit.go functions.
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestEditSection confirms that key lines are changed in their own style, that keys are
+// removed and added as the values say, and that nothing else is disturbed.
+func TestEditSection(t *testing.T) {
+	content := "[a]\nx=1\n\n[b]\n  x :  old   \ny = 2\ny = 3\nz=\n; [c] is next\n[c]\nx = 1\n"
+	edited := editSection([]byte(content), "b", []keyValue{{"x", "new"}, {"y", ""}, {"z", "set"}, {"w", "added"}})
+	require.Equal(t, "[a]\nx=1\n\n[b]\n  x :  new\nz = set\nw = added\n; [c] is next\n[c]\nx = 1\n", string(edited))
+}
+
+// TestEditSectionLineEndings confirms that a file's own line endings are kept, and that a
+// file without a final line ending gains only what it needs.
+func TestEditSectionLineEndings(t *testing.T) {
+	edited := editSection([]byte("[a]\r\nx = 1\r\n"), "a", []keyValue{{"x", "2"}, {"y", "3"}})
+	require.Equal(t, "[a]\r\nx = 2\r\ny = 3\r\n", string(edited))
+
+	edited = editSection([]byte("[a]\nx = 1"), "b", []keyValue{{"x", "2"}})
+	require.Equal(t, "[a]\nx = 1\n\n[b]\nx = 2\n", string(edited))
+
+	edited = editSection([]byte("[a]\nx = 1"), "a", []keyValue{{"y", "2"}})
+	require.Equal(t, "[a]\nx = 1\ny = 2\n", string(edited))
+
+	edited = editSection(nil, "a", []keyValue{{"x", "1"}, {"y", ""}})
+	require.Equal(t, "[a]\nx = 1\n", string(edited))
+}
+
+// TestRemoveSection confirms that a section is removed up to the comment that introduces
+// the next one, taking the blank lines before it only if it was the last, and that nothing
+// else is disturbed.
+func TestRemoveSection(t *testing.T) {
+	content := "[a]\nx = 1\n\n[b]\ny = 2\n; about c\n[c]\nz = 3\n\n[d]\nw = 4\n"
+	edited, removed := removeSection([]byte(content), "b")
+	require.True(t, removed, "the section should have been removed")
+	require.Equal(t, "[a]\nx = 1\n\n; about c\n[c]\nz = 3\n\n[d]\nw = 4\n", string(edited))
+
+	edited, removed = removeSection([]byte(content), "c")
+	require.True(t, removed, "the section should have been removed")
+	require.Equal(t, "[a]\nx = 1\n\n[b]\ny = 2\n; about c\n\n[d]\nw = 4\n", string(edited))
+
+	edited, removed = removeSection([]byte("[a]\nx = 1\n\n[b]\ny = 2\n\n# about c\n[c]\nz = 3\n"), "b")
+	require.True(t, removed, "the section should have been removed")
+	require.Equal(t, "[a]\nx = 1\n\n# about c\n[c]\nz = 3\n", string(edited))
+
+	edited, removed = removeSection([]byte(content), "d")
+	require.True(t, removed, "the section should have been removed")
+	require.Equal(t, "[a]\nx = 1\n\n[b]\ny = 2\n; about c\n[c]\nz = 3\n", string(edited))
+
+	edited, removed = removeSection([]byte(content), "e")
+	require.False(t, removed, "there was no section to remove")
+	require.Equal(t, content, string(edited))
+}
@@ -0,0 +1,105 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// the encryption of saved session tokens with a passphrase.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// SessionTokenEncryptedKey defines the name of the field, within a section holding session
+	// credentials, that holds the session token encrypted with a passphrase in place of the
+	// usual session token field
+	SessionTokenEncryptedKey = "mafia_session_token_encrypted"
+
+	// Marks, and versions, an encrypted value so that the scheme can be changed without
+	// mistaking an old value for a new one
+	encryptedPrefix = "v1:"
+
+	// The scrypt cost parameters, and the salt and key sizes, with which the AES-256-GCM
+	// key is derived from the passphrase
+	scryptN  = 32768
+	scryptR  = 8
+	scryptP  = 1
+	saltSize = 16
+	keySize  = 32
+)
+
+var (
+	// ErrWrongPassphrase is returned when an encrypted session token cannot be decrypted,
+	// because the passphrase is not the one that it was encrypted with or the value has been
+	// tampered with
+	ErrWrongPassphrase = errors.New("the saved session token could not be decrypted; the passphrase is wrong or the value has been altered")
+)
+
+// EncryptValue encrypts the given value with AES-256-GCM, under a key derived from the
+// passphrase and a random salt, returning the salt, nonce, and ciphertext, base64 encoded
+// behind a version prefix, as a single string fit for a credentials file value. Encrypting
+// the same value twice gives different results.
+func EncryptValue(passphrase, value string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	aead, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(append(salt, nonce...), nonce, []byte(value), nil)
+	return encryptedPrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptValue reverses EncryptValue(..), returning ErrWrongPassphrase if the value was
+// not encrypted with the given passphrase, or has been altered since.
+func DecryptValue(passphrase, encrypted string) (string, error) {
+	if !strings.HasPrefix(encrypted, encryptedPrefix) {
+		return "", ErrWrongPassphrase
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(encrypted, encryptedPrefix))
+	if err != nil || len(sealed) < saltSize {
+		return "", ErrWrongPassphrase
+	}
+	aead, err := passphraseCipher(passphrase, sealed[:saltSize])
+	if err != nil {
+		return "", err
+	}
+	sealed = sealed[saltSize:]
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrWrongPassphrase
+	}
+	value, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return string(value), nil
+}
+
+// passphraseCipher returns the AES-256-GCM cipher keyed with the key that scrypt derives
+// from the given passphrase and salt.
+func passphraseCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,44 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// unit tests for the encrypt.go functions.
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestEncryptValue confirms that an encrypted value decrypts with the passphrase that it
+// was encrypted with, only that passphrase, and not at all once altered.
+func TestEncryptValue(t *testing.T) {
+
+	// A round trip gives the value back, though no two encryptions are alike
+	encrypted, err := EncryptValue("correct horse", "token_1")
+	require.Nil(t, err, "there should not have been an error encrypting")
+	require.True(t, strings.HasPrefix(encrypted, encryptedPrefix), "the encrypted value should be versioned")
+	require.NotContains(t, encrypted, "token_1", "the value should not be visible")
+	again, err := EncryptValue("correct horse", "token_1")
+	require.Nil(t, err, "there should not have been an error encrypting")
+	require.NotEqual(t, encrypted, again, "each encryption should have its own salt and nonce")
+	decrypted, err := DecryptValue("correct horse", encrypted)
+	require.Nil(t, err, "there should not have been an error decrypting")
+	require.Equal(t, "token_1", decrypted, "the value did not survive the round trip")
+
+	// The wrong passphrase, an altered value, or nonsense, will not do
+	_, err = DecryptValue("battery staple", encrypted)
+	require.Equal(t, ErrWrongPassphrase, err, "the wrong passphrase should have been detected")
+	altered := []byte(encrypted)
+	altered[len(altered)-1] ^= 1
+	_, err = DecryptValue("correct horse", string(altered))
+	require.Equal(t, ErrWrongPassphrase, err, "the alteration should have been detected")
+	for _, nonsense := range []string{"", "token_1", encryptedPrefix, encryptedPrefix + "!!!", encryptedPrefix + "c2FsdA"} {
+		_, err = DecryptValue("correct horse", nonsense)
+		require.Equal(t, ErrWrongPassphrase, err, "%q should not have decrypted", nonsense)
+	}
+}
@@ -0,0 +1,106 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// the conventions that the AWS credentials and config files are read and
+// written with, for teams whose files do not follow the usual key = value.
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// The characters that separate a key from its value unless SetFileFormat(..) says
+	// otherwise, and the one that new key lines are written with
+	defaultKeyValueDelimiters = "=:"
+	defaultDelimiterOnWrite   = "="
+
+	// Characters that cannot separate a key from its value, since they already mean
+	// something else in an ini file
+	reservedDelimiters = " \t\r\n#;[]\"'`"
+)
+
+// FileFormat describes the conventions of the AWS credentials and config files, so that
+// files that use, say, key:value or key=value can be read, and edited, without breaking
+// them. The zero value describes the usual files: either = or : separate keys from values,
+// new keys are written as key = value, and # or ; begins a comment wherever it appears.
+type FileFormat struct {
+	KeyValueDelimiters       string // The characters that may separate a key from its value when reading; "=:" if empty
+	DelimiterOnWrite         string // The character that new key lines are written with; "=" if empty
+	NoSpaces                 bool   // True to write new key lines as key=value, without the spaces around the delimiter
+	SpaceBeforeInlineComment bool   // True to only treat # and ; as starting a comment after whitespace, so that values may hold them
+}
+
+var (
+	// The conventions that the files are read and written with. Set via
+	// SetFileFormat(..) and cleared by ResetPackageDefaults(..).
+	fileFormat FileFormat
+)
+
+// SetFileFormat sets the conventions that the AWS credentials and config files are read
+// and written with. Lines that mafia changes keep the delimiter and spacing that they
+// already have; the format applies to the lines that it adds. The delimiter written must
+// be one of those read, and none of them may be whitespace, a quote, or a character that
+// already means something in an ini file.
+func SetFileFormat(format FileFormat) error {
+	for _, c := range format.KeyValueDelimiters {
+		if strings.ContainsRune(reservedDelimiters, c) {
+			return fmt.Errorf("%q cannot separate a key from its value", c)
+		}
+	}
+	if len(format.DelimiterOnWrite) > 1 {
+		return fmt.Errorf("keys can only be written with a single delimiter character, not %q", format.DelimiterOnWrite)
+	}
+	if !strings.Contains(format.delimiters(), format.delimiterOnWrite()) {
+		return fmt.Errorf("keys cannot be written with %q when only %q are read as delimiters", format.delimiterOnWrite(), format.delimiters())
+	}
+	fileFormat = format
+	return nil
+}
+
+// delimiters returns the characters that may separate a key from its value when reading.
+func (f FileFormat) delimiters() string {
+	if len(f.KeyValueDelimiters) == 0 {
+		return defaultKeyValueDelimiters
+	}
+	return f.KeyValueDelimiters
+}
+
+// delimiterOnWrite returns the character that new key lines are written with.
+func (f FileFormat) delimiterOnWrite() string {
+	if len(f.DelimiterOnWrite) == 0 {
+		return defaultDelimiterOnWrite
+	}
+	return f.DelimiterOnWrite
+}
+
+// delimiterWithSpacing returns the given delimiter as it is written between a key and
+// its value: surrounded by spaces unless NoSpaces says otherwise.
+func (f FileFormat) delimiterWithSpacing(delimiter string) string {
+	if f.NoSpaces {
+		return delimiter
+	}
+	return " " + delimiter + " "
+}
+
+// keyValueLine returns the line, without its line ending, that sets the named key to the
+// given value.
+func (f FileFormat) keyValueLine(name, value string) string {
+	return name + f.delimiterWithSpacing(f.delimiterOnWrite()) + value
+}
+
+// loadINI loads ini file content, as ini.Load(..) does, with the conventions set by
+// SetFileFormat(..).
+func loadINI(source interface{}, others ...interface{}) (*ini.File, error) {
+	return ini.LoadSources(ini.LoadOptions{
+		KeyValueDelimiters:       fileFormat.delimiters(),
+		KeyValueDelimiterOnWrite: fileFormat.delimiterOnWrite(),
+		SpaceBeforeInlineComment: fileFormat.SpaceBeforeInlineComment,
+	}, source, others...)
+}
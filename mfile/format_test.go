@@ -0,0 +1,76 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// unit tests for the format.go functions.
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetFileFormat confirms that only formats that can be read back are accepted.
+func TestSetFileFormat(t *testing.T) {
+	defer ResetPackageDefaults()
+
+	require.Nil(t, SetFileFormat(FileFormat{}), "the usual format should have been accepted")
+	require.Nil(t, SetFileFormat(FileFormat{KeyValueDelimiters: ":", DelimiterOnWrite: ":", NoSpaces: true}), "a colon format should have been accepted")
+	require.Equal(t, FileFormat{KeyValueDelimiters: ":", DelimiterOnWrite: ":", NoSpaces: true}, fileFormat, "the format should have been set")
+
+	for _, format := range []FileFormat{
+		{KeyValueDelimiters: "= "},
+		{KeyValueDelimiters: "#"},
+		{DelimiterOnWrite: "=:"},
+		{KeyValueDelimiters: "=", DelimiterOnWrite: ":"},
+		{DelimiterOnWrite: "|"},
+	} {
+		require.NotNil(t, SetFileFormat(format), "%+v should have been refused", format)
+	}
+	require.Equal(t, FileFormat{KeyValueDelimiters: ":", DelimiterOnWrite: ":", NoSpaces: true}, fileFormat, "a refused format should not have been set")
+}
+
+// TestFileFormatReading confirms that the delimiters and inline comment rule are applied
+// when the files are read.
+func TestFileFormatReading(t *testing.T) {
+	defer ResetPackageDefaults()
+	useMemoryStorage()
+	require.Nil(t, credentialsStorage.WriteFile(fakeCredentialsFilePath, []byte("[default]\naws_access_key_id=key\naws_secret_access_key=abc#def\nmfa_device_id:arn:aws:iam::210987654321:mfa/pat\n"), 0600))
+
+	// By default, the # begins a comment and either delimiter will do
+	keys, err := GetProfileKeysFromFile(fakeCredentialsFilePath, "default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "abc", keys["aws_secret_access_key"], "the comment should have been dropped")
+	require.Equal(t, "arn:aws:iam::210987654321:mfa/pat", keys["mfa_device_id"], "the colon should have separated the key")
+
+	// A # only begins a comment after a space when told so
+	require.Nil(t, SetFileFormat(FileFormat{SpaceBeforeInlineComment: true}))
+	keys, err = GetProfileKeysFromFile(fakeCredentialsFilePath, "default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "abc#def", keys["aws_secret_access_key"], "the # should have been part of the value")
+
+	// And a colon can be part of a key name when only an = separates keys from values
+	require.Nil(t, credentialsStorage.WriteFile(fakeCredentialsFilePath, []byte("[default]\nteam:owner = pat\n"), 0600))
+	keys, err = GetProfileKeysFromFile(fakeCredentialsFilePath, "default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "owner = pat", keys["team"], "by default, the colon separates the key")
+	require.Nil(t, SetFileFormat(FileFormat{KeyValueDelimiters: "="}))
+	keys, err = GetProfileKeysFromFile(fakeCredentialsFilePath, "default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "pat", keys["team:owner"], "the colon should have been part of the key")
+}
+
+// TestFileFormatWriting confirms that new key lines are written in the format given, while
+// the lines that are already there keep their own.
+func TestFileFormatWriting(t *testing.T) {
+	defer ResetPackageDefaults()
+	require.Nil(t, SetFileFormat(FileFormat{DelimiterOnWrite: ":", NoSpaces: true}))
+
+	edited := editSection([]byte("[a]\nx = 1\ny=\n"), "a", []keyValue{{"x", "2"}, {"y", "3"}, {"z", "4"}})
+	require.Equal(t, "[a]\nx = 2\ny=3\nz:4\n", string(edited))
+	edited = editSection(nil, "b", []keyValue{{"x", "1"}})
+	require.Equal(t, "[b]\nx:1\n", string(edited))
+}
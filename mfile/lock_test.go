@@ -0,0 +1,64 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// unit tests for the lock_*.go functions.
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+// TestConcurrentSaves saves sessions for many profiles at once to confirm that none of
+// them is lost to another save clobbering the file.
+func TestConcurrentSaves(t *testing.T) {
+
+	// There is no locking on Windows to test
+	if runtime.GOOS == "windows" {
+		t.Skip("file locking is not supported on Windows")
+	}
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with known contents, on disk so that it
+	// is the real locking that is tested
+	setFakeCredentialsOnDisk(DefaultSectionName, fakeMFADeviceID)
+	defer os.Remove(fakeCredentialsFilePath)
+
+	// Save a session for each of a bunch of profiles, all at the same time
+	const profiles = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, profiles)
+	for i := 0; i < profiles; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := fmt.Sprintf("value-%d", i)
+			_, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, &SaveOptions{Profile: fmt.Sprintf("profile-%d", i)}, &value, &value, &value)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.Nil(t, err, "there should not have been an error")
+	}
+
+	// Every one of the sessions should have made it into the file
+	cfg, err := ini.Load(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	for i := 0; i < profiles; i++ {
+		section := cfg.Section(SessionSectionNameFor(fmt.Sprintf("profile-%d", i)))
+		require.Equal(t, fmt.Sprintf("value-%d", i), section.Key(SessionTokenKey).Value(), "session %d was lost", i)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+// +build !windows
+
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// the advisory file locking used on Unix like systems.
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive advisory lock on the given file, waiting for any other
+// holder to release it, and returns a function that releases the lock. The file is
+// locked in place, rather than by way of a separate lock file, which works because
+// writeSection(..) rewrites the file without replacing it.
+func lockFile(filepath string) (func(), error) {
+
+	// Open the file just to have something to lock
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait our turn
+	if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Closing the file releases the lock
+	return func() { file.Close() }, nil
+}
@@ -0,0 +1,148 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// package methods related to finding the AWS credentials file.
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SharedCredentialsFileEnvVar names the environment variable that the AWS CLI and SDKs
+	// consult for the location of the credentials file
+	SharedCredentialsFileEnvVar = "AWS_SHARED_CREDENTIALS_FILE"
+
+	// Which of the places that ResolveCredentialsPathWithSource(..) looks in a path came from
+	PathFromFlag      = "flag"             // The path given to it, normally by a --credentials-file flag
+	PathFromEnv       = "environment"      // The AWS_SHARED_CREDENTIALS_FILE environment variable
+	PathFromConfigDir = "config directory" // The OS specific user configuration directory
+	PathFromDefault   = "default"          // The traditional $HOME/.aws/credentials
+)
+
+var (
+	// The function that finds the OS specific user configuration directory, overridden by
+	// unit tests so that they do not depend on the machine they run on
+	userConfigDirFunc = os.UserConfigDir
+
+	// The function that finds the user's home directory for ExpandPath(..), overridden by
+	// unit tests for the same reason
+	userHomeDirFunc = os.UserHomeDir
+
+	// True if OverrideDefaultCredentialsFilepath(..) has been called, in which case only
+	// an explicitly given path may take the place of the default
+	defaultPathOverridden bool
+)
+
+// ResolveCredentialsPath returns the path of the AWS credentials file to use. The first
+// of the following to apply wins:
+//
+//  1. flagPath, if it is not empty (normally the value of a --credentials-file flag)
+//  2. the value of the AWS_SHARED_CREDENTIALS_FILE environment variable, if set
+//  3. aws/credentials in the OS specific user configuration directory, if such a file
+//     exists: $XDG_CONFIG_HOME (or $HOME/.config) on Linux and other Unix systems,
+//     $HOME/Library/Application Support on macOS, and %AppData% on Windows
+//  4. the default, $HOME/.aws/credentials
+//
+// Either of the first two may be a list of paths, separated by the OS path list separator
+// (a colon, or a semicolon on Windows), naming several files to be read as one; see
+// SplitCredentialsPaths(..). Paths from the first two sources are passed through
+// ExpandPath(..) since they may not have been expanded by a shell. Where unit tests have
+// overridden the default path, steps 2 and 3 are skipped so that the environment of the
+// machine running the tests cannot lead them to a real file.
+func ResolveCredentialsPath(flagPath string) string {
+	path, _ := ResolveCredentialsPathWithSource(flagPath)
+	return path
+}
+
+// ResolveCredentialsPathWithSource returns the path of the AWS credentials file to use, as
+// ResolveCredentialsPath(..) does, along with which of the places that it looks in the path
+// came from: one of the PathFrom... constants.
+func ResolveCredentialsPathWithSource(flagPath string) (string, string) {
+
+	// An explicit path trumps everything
+	if len(flagPath) != 0 {
+		return expandPathList(flagPath), PathFromFlag
+	}
+
+	// Otherwise, unless under test, look to the environment and then the OS conventions
+	if !defaultPathOverridden {
+		if envPath := os.Getenv(SharedCredentialsFileEnvVar); len(envPath) != 0 {
+			return expandPathList(envPath), PathFromEnv
+		}
+		if configDir, err := userConfigDirFunc(); err == nil {
+			osPath := filepath.Join(configDir, "aws", "credentials")
+			if _, err := os.Stat(osPath); err == nil {
+				return osPath, PathFromConfigDir
+			}
+		}
+	}
+
+	// Fall back on the traditional location
+	return defaultCredentialsFilePath, PathFromDefault
+}
+
+// ExpandPath expands $VAR and ${VAR} environment variable references anywhere in the
+// given path, and a leading ~ to the user's home directory, as a shell would have done.
+// A ~ followed by a username, e.g. ~jane/credentials, is left alone, as is a leading ~
+// if the home directory cannot be determined.
+func ExpandPath(path string) string {
+
+	// Expand environment variables first so that a variable holding ~/x works too
+	path = os.ExpandEnv(path)
+
+	// Then a leading ~ on its own or followed by a path separator
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		if home, err := userHomeDirFunc(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// SplitCredentialsPaths splits a credentials file path that lists several files, separated
+// by the OS path list separator, into its parts. The files are read as one, sections and
+// keys in later files overriding those in earlier ones, as the AWS SDKs do; changes are
+// written to the last of them, the one most specific to the user.
+func SplitCredentialsPaths(path string) []string {
+	return filepath.SplitList(path)
+}
+
+// WritableCredentialsPath returns the path of the file, of those that a credentials file
+// path lists, that changes are written to: the last.
+func WritableCredentialsPath(path string) string {
+	paths := SplitCredentialsPaths(path)
+	if len(paths) == 0 {
+		return path
+	}
+	return paths[len(paths)-1]
+}
+
+// ProfileKeysPath returns the path of the file, of those that a credentials file path
+// lists, that the named profile's long term access key ID comes from when they are read
+// merged: the last whose section for the profile has one. Tools that read a single file,
+// the AWS SDK among them, can be pointed at that. If none has one, the file that changes
+// are written to is returned, so that they can say what is missing from where.
+func ProfileKeysPath(path, profile string) string {
+	paths := SplitCredentialsPaths(path)
+	for i := len(paths) - 1; i >= 0; i-- {
+		if keys, err := GetProfileKeysFromFile(paths[i], profile); err == nil && len(keys[AccessKeyIDKey]) != 0 {
+			return paths[i]
+		}
+	}
+	return WritableCredentialsPath(path)
+}
+
+// expandPathList passes each of the paths that the given path lists through ExpandPath(..).
+func expandPathList(path string) string {
+	paths := SplitCredentialsPaths(path)
+	for i, p := range paths {
+		paths[i] = ExpandPath(p)
+	}
+	return strings.Join(paths, string(os.PathListSeparator))
+}
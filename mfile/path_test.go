@@ -0,0 +1,144 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// unit tests for the path.go functions.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestResolveCredentialsPath works down the precedence order of ResolveCredentialsPath(..),
+// removing the winner at each step to see who comes next.
+func TestResolveCredentialsPath(t *testing.T) {
+
+	// Revert the package state and environment back to normal after the test has run
+	defer ResetPackageDefaults()
+	defer restoreEnv(SharedCredentialsFileEnvVar)()
+
+	// Have an OS specific configuration directory with a credentials file in it
+	configDir, err := ioutil.TempDir("", "mafia-config")
+	require.Nil(t, err, "could not create a temporary configuration directory")
+	defer os.RemoveAll(configDir)
+	osPath := filepath.Join(configDir, "aws", "credentials")
+	require.Nil(t, os.MkdirAll(filepath.Dir(osPath), 0700), "could not create the aws directory")
+	require.Nil(t, ioutil.WriteFile(osPath, []byte("[default]\n"), 0600), "could not create the credentials file")
+	userConfigDirFunc = func() (string, error) { return configDir, nil }
+
+	// With everything in play, the flag wins, then the environment variable
+	os.Setenv(SharedCredentialsFileEnvVar, "/env/credentials")
+	require.Equal(t, "/flag/credentials", ResolveCredentialsPath("/flag/credentials"), "the flag path should win")
+	require.Equal(t, "/env/credentials", ResolveCredentialsPath(""), "the environment path should be next")
+	_, source := ResolveCredentialsPathWithSource("/flag/credentials")
+	require.Equal(t, PathFromFlag, source, "the flag should have been named as the source")
+	_, source = ResolveCredentialsPathWithSource("")
+	require.Equal(t, PathFromEnv, source, "the environment should have been named as the source")
+
+	// Then the OS specific file, provided that it exists
+	os.Unsetenv(SharedCredentialsFileEnvVar)
+	require.Equal(t, osPath, ResolveCredentialsPath(""), "the OS specific path should be next")
+	_, source = ResolveCredentialsPathWithSource("")
+	require.Equal(t, PathFromConfigDir, source, "the configuration directory should have been named as the source")
+	require.Nil(t, os.Remove(osPath), "could not remove the credentials file")
+	require.Equal(t, DefaultCredentialsFilepath(), ResolveCredentialsPath(""), "the default path should be last")
+	_, source = ResolveCredentialsPathWithSource("")
+	require.Equal(t, PathFromDefault, source, "the default should have been named as the source")
+}
+
+// TestResolveCredentialsPathOverridden confirms that, once unit tests have overridden the
+// default path, the environment cannot lead ResolveCredentialsPath(..) elsewhere.
+func TestResolveCredentialsPathOverridden(t *testing.T) {
+
+	// Revert the package state and environment back to normal after the test has run
+	defer ResetPackageDefaults()
+	defer restoreEnv(SharedCredentialsFileEnvVar)()
+
+	os.Setenv(SharedCredentialsFileEnvVar, "/env/credentials")
+	OverrideDefaultCredentialsFilepath(fakeCredentialsFilePath)
+	require.Equal(t, fakeCredentialsFilePath, ResolveCredentialsPath(""), "the overridden default should win")
+	require.Equal(t, "/flag/credentials", ResolveCredentialsPath("/flag/credentials"), "the flag path should still win")
+}
+
+// TestExpandPath confirms that a leading ~ and environment variable references are
+// expanded in the paths given to ResolveCredentialsPath(..).
+func TestExpandPath(t *testing.T) {
+
+	// Revert the package state and environment back to normal after the test has run
+	defer ResetPackageDefaults()
+	defer restoreEnv("MAFIA_TEST_DIR")()
+	home := filepath.FromSlash("/home/jane")
+	userHomeDirFunc = func() (string, error) { return home, nil }
+	os.Setenv("MAFIA_TEST_DIR", "/work")
+
+	require.Equal(t, filepath.Join(home, "work", ".aws", "credentials"), ExpandPath("~/work/.aws/credentials"), "~ should have been expanded")
+	require.Equal(t, home, ExpandPath("~"), "a lone ~ should have been expanded")
+	require.Equal(t, "/work/x/credentials", ExpandPath("$MAFIA_TEST_DIR/x/credentials"), "$VAR should have been expanded")
+	require.Equal(t, "/work/x/credentials", ExpandPath("${MAFIA_TEST_DIR}/x/credentials"), "${VAR} should have been expanded")
+	require.Equal(t, "~jane/credentials", ExpandPath("~jane/credentials"), "~user should have been left alone")
+	require.Equal(t, "/a/~/credentials", ExpandPath("/a/~/credentials"), "only a leading ~ should be expanded")
+
+	// And the flag path given to ResolveCredentialsPath(..) should be expanded too
+	OverrideDefaultCredentialsFilepath(fakeCredentialsFilePath)
+	require.Equal(t, filepath.Join(home, "credentials"), ResolveCredentialsPath("~/credentials"), "the flag path should have been expanded")
+}
+
+// TestCredentialsPathLists confirms that a list of credentials file paths is split, has
+// each of its paths expanded, and is written through its last path.
+func TestCredentialsPathLists(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+	home := filepath.FromSlash("/home/jane")
+	userHomeDirFunc = func() (string, error) { return home, nil }
+
+	list := strings.Join([]string{"~/shared", "/personal"}, string(os.PathListSeparator))
+	OverrideDefaultCredentialsFilepath(fakeCredentialsFilePath)
+	resolved := ResolveCredentialsPath(list)
+	require.Equal(t, []string{filepath.Join(home, "shared"), "/personal"}, SplitCredentialsPaths(resolved), "each path should have been expanded")
+	require.Equal(t, "/personal", WritableCredentialsPath(resolved), "the last path should be written to")
+	require.Equal(t, "/only", WritableCredentialsPath("/only"), "a lone path should be written to")
+}
+
+// restoreEnv returns a function that puts the named environment variable back the way
+// it was when restoreEnv(..) was called.
+func restoreEnv(name string) func() {
+	value, present := os.LookupEnv(name)
+	return func() {
+		if present {
+			os.Setenv(name, value)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+// TestProfileKeysPath confirms that, of several credentials files, the one holding the
+// profile's access key ID is found, the last winning as it does when they are merged.
+func TestProfileKeysPath(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Two files, each with keys for a profile of its own and both with some for the default
+	dir, err := ioutil.TempDir("", "mafia-keys-path")
+	require.Nil(t, err, "could not create a temporary directory")
+	defer os.RemoveAll(dir)
+	shared, personal := filepath.Join(dir, "shared"), filepath.Join(dir, "personal")
+	require.Nil(t, ioutil.WriteFile(shared, []byte("[default]\naws_access_key_id = SHARED\n\n[team]\naws_access_key_id = TEAM\n"), 0600))
+	require.Nil(t, ioutil.WriteFile(personal, []byte("[default]\naws_access_key_id = PERSONAL\n\n[work]\nmfa_device_id = x\n"), 0600))
+	path := shared + string(os.PathListSeparator) + personal
+
+	require.Equal(t, personal, ProfileKeysPath(path, DefaultSectionName), "the last file should win")
+	require.Equal(t, shared, ProfileKeysPath(path, "team"), "only the shared file has keys for the team")
+	require.Equal(t, personal, ProfileKeysPath(path, "work"), "without keys anywhere, the written file should be named")
+	require.Equal(t, shared, ProfileKeysPath(shared, "missing"), "a single file is its own answer")
+}
@@ -9,9 +9,12 @@ package mfile
 // defines the package constants and global variables.
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/user"
+	"strings"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
@@ -29,9 +32,21 @@ const (
 	// SessionTokenKey defines the name of any MFA authenticated temporary session token field within a configuration file section
 	SessionTokenKey = "aws_session_token"
 
+	// SessionExpirationKey defines the default name of the field, within a section holding
+	// session credentials, that records when they expire in RFC 3339 form
+	SessionExpirationKey = "aws_session_expiration"
+
 	// MfaDeviceIDKey defines the name of the MFA device ID field within a configuration file section
 	MfaDeviceIDKey = "mfa_device_id"
 
+	// MfaSerialKey defines the name that the AWS CLI gives the MFA device ID field, read in
+	// place of MfaDeviceIDKey when a section has no such field
+	MfaSerialKey = "mfa_serial"
+
+	// MfaTOTPSecretKey defines the name of the field, within a configuration file section, holding
+	// the base32 secret of a virtual MFA device from which MFA codes can be generated locally
+	MfaTOTPSecretKey = "mfa_totp_secret"
+
 	// Suffix appended to the non-session section name to name the correseponding
 	// MHF authenticated session credentials section
 	sessionSectionSuffix = "-session"
@@ -40,10 +55,43 @@ const (
 	SessionSectionName = DefaultSectionName + sessionSectionSuffix
 )
 
+// SavedSession holds session credentials previously saved to an AWS credentials file.
+type SavedSession struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string     // Empty if it was saved encrypted and no passphrase was given
+	Expiration      *time.Time // Nil if the file did not record when the credentials expire
+	Encrypted       bool       // True if the session token was saved encrypted
+}
+
 var (
+	// ErrMFADeviceIDNotFound is wrapped by the error returned when a credentials file section
+	// has no MFA device ID, allowing callers to detect that case with errors.Is(..)
+	ErrMFADeviceIDNotFound = errors.New(MfaDeviceIDKey + " key not found")
+
+	// ErrLongTermKeysNotFound is wrapped by the error returned when a credentials file
+	// section lacks an access key ID or secret access key, allowing callers to detect that
+	// case with errors.Is(..)
+	ErrLongTermKeysNotFound = errors.New("long term keys not found")
+
+	// ErrLongTermKeyEmpty is wrapped by the error returned when a credentials file profile
+	// has an access key ID or secret access key line but no value on it
+	ErrLongTermKeyEmpty = errors.New("long term key empty")
+
+	// ErrNoHomeDirectory is returned, when the default AWS credentials file is needed,
+	// if there is no home directory for it to be found in, as in a container without HOME
+	ErrNoHomeDirectory = errors.New("cannot find the home directory holding the default AWS credentials file; set HOME, or USERPROFILE on Windows, or name the file with " + SharedCredentialsFileEnvVar)
+
 	// What the name says, filled in at load time. As a global variable, this can be
 	// overridden by unit tests to better control outcomes.
 	defaultCredentialsFilePath string
+
+	// Why defaultCredentialsFilePath is empty, if it is, kept to report when the file is needed
+	defaultPathErr error
+
+	// The function that describes the current user, the last resort for finding the home
+	// directory, overridden by unit tests along with userHomeDirFunc
+	currentUserFunc = user.Current
 )
 
 // Load time initialization
@@ -54,43 +102,243 @@ func init() {
 	ResetPackageDefaults()
 }
 
-// GetMFADeviceID attempts to find an MFA device ID in the default section of
-// the AWS credentials file, returing either the ID or an error.
+// GetMFADeviceID attempts to find an MFA device ID in the default section of the
+// AWS credentials file found by ResolveCredentialsPath(..), returing either the ID
+// or an error.
 func GetMFADeviceID() (string, error) {
-	return GetMFADeviceIDFromFile(defaultCredentialsFilePath)
+	return GetMFADeviceIDFromFile(ResolveCredentialsPath(""))
 }
 
 // GetMFADeviceIDFromFile attempts to find an MFA device ID in the default section of
 // the given AWS credentials file, returing either the ID or an error.
 func GetMFADeviceIDFromFile(filepath string) (string, error) {
+	return GetProfileMFADeviceIDFromFile(filepath, DefaultSectionName)
+}
+
+// GetProfileMFADeviceIDFromFile attempts to find an MFA device ID in the named profile
+// section of the given AWS credentials file, returing either the ID or an error. The ID is
+// read from the mfa_device_id key or, if there is none, from the AWS CLI's mfa_serial, so
+// that one file can serve both. Environment variable references in the ID, e.g.
+// arn:aws:iam::${ACCOUNT_ID}:mfa/${USER}, are expanded; if nothing is left of it once they
+// are, that is the same as there being no ID at all.
+func GetProfileMFADeviceIDFromFile(filepath, profile string) (string, error) {
 
 	// Load the file
-	cfg, err := ini.Load(filepath)
+	cfg, err := loadCredentialsFile(filepath)
 	if err != nil {
 		return "", fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
 	}
 
-	// Fetch the default section - if there is one
-	defaultSection, err := cfg.GetSection(DefaultSectionName)
+	// Fetch the profile section - if there is one
+	profileSection, err := cfg.GetSection(profile)
 	if err != nil {
-		return "", fmt.Errorf("%s section not found in %s", DefaultSectionName, filepath)
+		return "", fmt.Errorf("%s section not found in %s", profile, filepath)
 	}
 
 	// Fetch the MFA device ID entry - if there is one
-	key := defaultSection.Key(MfaDeviceIDKey)
-	if len(key.Value()) == 0 {
-		return "", fmt.Errorf("%s key not found in default section of %s", MfaDeviceIDKey, filepath)
+	mfaDeviceID := MFADeviceIDFromKeys(profileSection.KeysHash())
+	if len(mfaDeviceID) == 0 {
+		return "", fmt.Errorf("%w in %s section of %s", ErrMFADeviceIDNotFound, profile, filepath)
+	}
+	return mfaDeviceID, nil
+}
+
+// MFADeviceIDFromKeys returns the MFA device ID given by the keys and values of a
+// credentials file section, as returned by GetProfileKeysFromFile(..): that of the
+// mfa_device_id key, or of the mfa_serial key if mfa_device_id is missing or empty, with any $VAR
+// or ${VAR} references expanded so that a templated file can serve several accounts. An
+// empty string is returned if there is no ID.
+func MFADeviceIDFromKeys(keys map[string]string) string {
+	mfaDeviceID := keys[MfaDeviceIDKey]
+	if len(mfaDeviceID) == 0 {
+		mfaDeviceID = keys[MfaSerialKey]
+	}
+	return os.ExpandEnv(mfaDeviceID)
+}
+
+// GetProfileTOTPSecretFromFile returns the virtual MFA device secret held in the named
+// profile section of the given AWS credentials file, or an empty string if the section
+// has none.
+func GetProfileTOTPSecretFromFile(filepath, profile string) (string, error) {
+
+	// Load the file
+	cfg, err := loadCredentialsFile(filepath)
+	if err != nil {
+		return "", fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
+	}
+
+	// Fetch the profile section - if there is one
+	profileSection, err := cfg.GetSection(profile)
+	if err != nil {
+		return "", fmt.Errorf("%s section not found in %s", profile, filepath)
+	}
+	return profileSection.Key(MfaTOTPSecretKey).String(), nil
+}
+
+// CheckLongTermKeysInFile confirms that the named profile section of the given AWS
+// credentials file holds both an access key ID and a secret access key, returning an
+// error naming the first that is missing, wrapping ErrLongTermKeysNotFound, if not. A key
+// that is there but empty, e.g. "aws_secret_access_key =", which AWS would only complain
+// of as a signing error, is reported as such, wrapping ErrLongTermKeyEmpty.
+func CheckLongTermKeysInFile(filepath, profile string) error {
+
+	// Load the file
+	cfg, err := loadCredentialsFile(filepath)
+	if err != nil {
+		return fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
+	}
+
+	// Fetch the profile section - if there is one
+	section, err := cfg.GetSection(profile)
+	if err != nil {
+		return fmt.Errorf("%s section not found in %s", profile, filepath)
+	}
+
+	// Both keys must have values
+	for _, key := range []string{AccessKeyIDKey, SecretAccessKeyKey} {
+		switch {
+		case !section.HasKey(key):
+			return fmt.Errorf("%w: profile %s has no %s in %s", ErrLongTermKeysNotFound, profile, key, filepath)
+		case len(section.Key(key).Value()) == 0:
+			return fmt.Errorf("%w: %s is empty in profile %s in %s", ErrLongTermKeyEmpty, key, profile, filepath)
+		}
+	}
+	return nil
+}
+
+// GetProfileKeysFromFile returns the keys and values of the named profile section of the
+// given AWS credentials file, or an error if the file cannot be read or has no such section.
+func GetProfileKeysFromFile(filepath, profile string) (map[string]string, error) {
+
+	// Load the file
+	cfg, err := loadCredentialsFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
 	}
 
-	// Return the value of the key
-	return key.String(), nil
+	// Fetch the profile section - if there is one
+	section, err := cfg.GetSection(profile)
+	if err != nil {
+		return nil, fmt.Errorf("%s section not found in %s", profile, filepath)
+	}
+	return section.KeysHash(), nil
+}
+
+// GetProfileNamesFromFile returns the names of all the sections of the given AWS
+// credentials file, in the order that they appear, or an error if the file cannot be read.
+func GetProfileNamesFromFile(filepath string) ([]string, error) {
+
+	// Load the file
+	cfg, err := loadCredentialsFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
+	}
+
+	// Collect the names, skipping the unnamed section that the ini library always has
+	var names []string
+	for _, name := range cfg.SectionStrings() {
+		if name != ini.DefaultSection {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// GetSavedSessionFromFile returns the session credentials saved to the given AWS
+// credentials file by SaveSessionCredentialsToFile(..) with the same options (which may
+// be nil). If there are no complete session credentials in the file, nil is returned
+// without error. A session token saved encrypted is decrypted with the options' passphrase
+// or, if there is none, left empty with the session marked Encrypted; ErrWrongPassphrase
+// is returned if it cannot be.
+func GetSavedSessionFromFile(filepath string, options *SaveOptions) (*SavedSession, error) {
+
+	// Load the file
+	cfg, err := loadCredentialsFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
+	}
+
+	// Fetch the session section - if there is one
+	section, err := cfg.GetSection(options.SectionName())
+	if err != nil {
+		return nil, nil
+	}
+
+	// Collect the credentials, giving up if any of them are missing
+	keyNames := options.ResolvedKeyNames()
+	saved := &SavedSession{
+		AccessKeyID:     section.Key(keyNames.AccessKeyID).Value(),
+		SecretAccessKey: section.Key(keyNames.SecretAccessKey).Value(),
+		SessionToken:    section.Key(keyNames.SessionToken).Value(),
+	}
+	if encrypted := section.Key(SessionTokenEncryptedKey).Value(); len(encrypted) != 0 && len(saved.SessionToken) == 0 {
+		saved.Encrypted = true
+		if options != nil && len(options.Passphrase) != 0 {
+			if saved.SessionToken, err = DecryptValue(options.Passphrase, encrypted); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(saved.AccessKeyID) == 0 || len(saved.SecretAccessKey) == 0 || (len(saved.SessionToken) == 0 && !saved.Encrypted) {
+		return nil, nil
+	}
+
+	// Add the expiration if there is one that we can make sense of
+	if expiration, err := time.Parse(time.RFC3339, section.Key(keyNames.Expiration).Value()); err == nil {
+		saved.Expiration = &expiration
+	}
+	return saved, nil
+}
+
+// loadCredentialsFile loads the given AWS credentials file or, if the path lists several
+// files, all of them merged as described by SplitCredentialsPaths(..).
+func loadCredentialsFile(filepath string) (*ini.File, error) {
+	if err := checkDefaultPath(filepath); err != nil {
+		return nil, err
+	}
+	paths := SplitCredentialsPaths(filepath)
+	contents := make([]interface{}, 0, len(paths))
+	for _, path := range paths {
+		content, err := credentialsStorage.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	return loadINI(contents[0], contents[1:]...)
+}
+
+// SessionSectionNameFor returns the name of the section that session credentials for the
+// named profile are saved to, e.g. default-session for the default profile.
+func SessionSectionNameFor(profile string) string {
+	return profile + sessionSectionSuffix
+}
+
+// ProfileNameForSection returns the name of the profile that the named section saves
+// session credentials for, e.g. default for default-session, or the section name
+// unchanged if it is not named as a session section.
+func ProfileNameForSection(section string) string {
+	if profile := strings.TrimSuffix(section, sessionSectionSuffix); len(profile) != 0 {
+		return profile
+	}
+	return section
+}
+
+// DefaultCredentialsFilepath returns the path of the default AWS credentials file,
+// typically $HOME/.aws/credentials, or an empty string if there is no home directory to
+// find it in; reading or writing the file at that empty path returns ErrNoHomeDirectory.
+func DefaultCredentialsFilepath() string {
+	return defaultCredentialsFilePath
 }
 
 // OverrideDefaultCredentialsFilepath is intended for use by unit tests that need to
 // manage the behavior of this package when loading and saving to the 'default'
 // AWS credentials file, protecting the real file from being damaged ny the tests.
+// Once overridden, ResolveCredentialsPath(..) no longer looks to the environment or
+// OS conventions for the file.
 func OverrideDefaultCredentialsFilepath(filepath string) {
 	defaultCredentialsFilePath = filepath
+	defaultPathOverridden = true
 }
 
 // ResetPackageDefaults ensures that the package is in its proper default state, ready
@@ -98,22 +346,51 @@ func OverrideDefaultCredentialsFilepath(filepath string) {
 // needing to restore initial conditions after a potentially destructive test run.
 func ResetPackageDefaults() {
 
-	// Set the path for the default AWS credentials file
-	defaultCredentialsFilePath = getDefaultCredentialsFilepath()
+	// Restore the means of finding the files
+	userConfigDirFunc = os.UserConfigDir
+	userHomeDirFunc = os.UserHomeDir
+	currentUserFunc = user.Current
+
+	// Go back to reading and writing the real files, in the usual format
+	credentialsStorage = fileStorage{}
+	fileFormat = FileFormat{}
+
+	// And find the default ones with them
+	resetDefaultPaths()
 }
 
-// getDefaultCredentialsFilepath obtains the home directory of the current
-// user and forms the full path to the default AWS credentials file from that.
-func getDefaultCredentialsFilepath() string {
+// resetDefaultPaths sets the paths of the default AWS credentials and config files, and
+// the means of finding others, as the user's home directory dictates.
+func resetDefaultPaths() {
+	defaultCredentialsFilePath, defaultPathErr = getDefaultCredentialsFilepath()
+	defaultPathOverridden = false
+	resetConfigDefaults()
+}
 
-	// Ask the OS for information about the current user. This should never fail
-	// but if it does, barf and kill the program here and now.
-	usr, err := user.Current()
-	if err != nil {
-		fmt.Printf("Aborting all tests. Failed to obtain credentials information: %v\n", err)
-		os.Exit(1)
+// getDefaultCredentialsFilepath obtains the home directory of the current user, from the
+// environment or failing that from the OS's record of the user, and forms the full path to
+// the default AWS credentials file from that. A program may have no home directory, e.g.
+// in a container without HOME, and only need the default file if no other is named, so
+// rather than give up then and there ErrNoHomeDirectory is returned to report later.
+func getDefaultCredentialsFilepath() (string, error) {
+	home, err := userHomeDirFunc()
+	if err != nil || len(home) == 0 {
+		usr, err := currentUserFunc()
+		if err != nil || len(usr.HomeDir) == 0 {
+			return "", ErrNoHomeDirectory
+		}
+		home = usr.HomeDir
 	}
 
 	// Configure the default AWS credentials path
-	return usr.HomeDir + "/.aws/credentials"
+	return home + "/.aws/credentials", nil
+}
+
+// checkDefaultPath returns ErrNoHomeDirectory if the given path is empty for want of a home
+// directory to find the default AWS credentials file in, and nil otherwise.
+func checkDefaultPath(filepath string) error {
+	if len(filepath) == 0 && defaultPathErr != nil {
+		return defaultPathErr
+	}
+	return nil
 }
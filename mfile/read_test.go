@@ -9,8 +9,10 @@ package mfile
 // tear down functions used by most package tests.
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/user"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -22,6 +24,9 @@ const (
 	// observe without damaging any geniune article
 	fakeCredentialsFilePath = "./credentials.test"
 
+	// A second credentials file, read before the first, for the tests of merged files
+	sharedCredentialsFilePath = "./shared-credentials.test"
+
 	// The AWS access ID value that we shall populate the fake credentials file with
 	fakeAccessKeyID = "FAKE_ACCESS_KEY_ID"
 
@@ -48,6 +53,52 @@ func TestGetMFADeviceID(t *testing.T) {
 	require.Equal(t, fakeMFADeviceID, id, "not the expected MFA device ID")
 }
 
+// TestGetMFADeviceIDExpandsEnv confirms that environment variable references in the MFA
+// device ID are expanded, and that one that expands to nothing counts as missing.
+func TestGetMFADeviceIDExpandsEnv(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+	defer os.Unsetenv("MAFIA_TEST_ACCOUNT_ID")
+	defer os.Unsetenv("MAFIA_TEST_USER")
+
+	os.Setenv("MAFIA_TEST_ACCOUNT_ID", "210987654321")
+	os.Setenv("MAFIA_TEST_USER", "pat")
+	setFakeCredentials(DefaultSectionName, "arn:aws:iam::${MAFIA_TEST_ACCOUNT_ID}:mfa/$MAFIA_TEST_USER")
+	id, err := GetMFADeviceID()
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "arn:aws:iam::210987654321:mfa/pat", id, "the environment variables should have been expanded")
+
+	setFakeCredentials(DefaultSectionName, "${MAFIA_TEST_UNSET}")
+	_, err = GetMFADeviceID()
+	require.True(t, errors.Is(err, ErrMFADeviceIDNotFound), "an ID that expands to nothing should be missing")
+}
+
+// TestGetMFADeviceIDFromMFASerial confirms that the AWS CLI's mfa_serial is read when
+// there is no mfa_device_id, and that mfa_device_id wins when there are both.
+func TestGetMFADeviceIDFromMFASerial(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Only the AWS CLI's key
+	setFakeCredentials(DefaultSectionName, "")
+	cfg, err := loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	cfg.Section(DefaultSectionName).NewKey(MfaSerialKey, "arn:aws:iam::210987654321:mfa/pat")
+	require.Nil(t, saveFakeFile(cfg, fakeCredentialsFilePath), "error writing the test credentials file")
+	id, err := GetMFADeviceID()
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "arn:aws:iam::210987654321:mfa/pat", id, "the mfa_serial should have been read")
+
+	// Both keys, with ours preferred
+	cfg.Section(DefaultSectionName).NewKey(MfaDeviceIDKey, fakeMFADeviceID)
+	require.Nil(t, saveFakeFile(cfg, fakeCredentialsFilePath), "error writing the test credentials file")
+	id, err = GetMFADeviceID()
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, fakeMFADeviceID, id, "the mfa_device_id should have been preferred")
+}
+
 // TestGetMFADeviceIDMissingKey examines the sad path where an MFA device serial number has not
 // been stored in the AWS credentials and can be retrieved successfully.
 func TestGetMFADeviceIDMissingKey(t *testing.T) {
@@ -62,6 +113,7 @@ func TestGetMFADeviceIDMissingKey(t *testing.T) {
 	id, err := GetMFADeviceID()
 	require.NotNil(t, err, "there should have been an error")
 	require.Equal(t, "mfa_device_id key not found in default section of ./credentials.test", err.Error(), "not the expected error")
+	require.True(t, errors.Is(err, ErrMFADeviceIDNotFound), "the error should be detectable as a missing MFA device ID")
 	require.Empty(t, id, "no MFA device ID should have been returned")
 }
 
@@ -99,11 +151,62 @@ func TestGetMFADeviceIDMissingFile(t *testing.T) {
 	require.Empty(t, id, "no MFA device ID should have been returned")
 }
 
-// setFakeCredentials populates a fake AWS credentials file in the current
-// working directory, with or without an MFA device serial number / ID. The
-// package globals are then manipulated such that this fake file will be used
-// any future test execution.
-func setFakeCredentials(sectionName, mfaDeviceID string) {
+// TestNoHomeDirectory confirms that having no home directory leaves the default paths
+// empty, rather than killing the program, and that ErrNoHomeDirectory is reported only
+// when the default credentials file is actually needed.
+func TestNoHomeDirectory(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Take away every way of finding the home directory
+	userHomeDirFunc = func() (string, error) { return "", errors.New("$HOME is not defined") }
+	currentUserFunc = func() (*user.User, error) { return nil, errors.New("no such user") }
+	userConfigDirFunc = func() (string, error) { return "", errors.New("neither $XDG_CONFIG_HOME nor $HOME are defined") }
+	resetDefaultPaths()
+	require.Empty(t, DefaultCredentialsFilepath(), "there should be no default credentials file")
+	require.Empty(t, ResolveConfigPath(), "there should be no default config file")
+
+	// The default file cannot be read or written
+	_, err := GetMFADeviceID()
+	require.NotNil(t, err, "there should have been an error")
+	require.Contains(t, err.Error(), ErrNoHomeDirectory.Error(), "not the expected error")
+	_, err = SaveSessionCredentialValuesToFile(DefaultCredentialsFilepath(), nil, "key", "secret", "token")
+	require.True(t, errors.Is(err, ErrNoHomeDirectory), "expected ErrNoHomeDirectory, not %v", err)
+
+	// But a file that is named works as ever
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	id, err := GetMFADeviceIDFromFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, fakeMFADeviceID, id, "not the expected MFA device ID")
+
+	// The home directory may come from the OS's record of the user instead of the environment
+	currentUserFunc = func() (*user.User, error) { return &user.User{HomeDir: "/home/pat"}, nil }
+	resetDefaultPaths()
+	require.Equal(t, "/home/pat/.aws/credentials", DefaultCredentialsFilepath(), "the user's home directory should have been used")
+}
+
+// setFakeCredentials populates a fake AWS credentials file, with or without an MFA
+// device serial number / ID, held in a new in-memory store rather than on disk. The
+// package globals are then manipulated such that this fake file will be used by any
+// future test execution. The store is returned for tests that want to look inside it.
+func setFakeCredentials(sectionName, mfaDeviceID string) *memoryStorage {
+	store := useMemoryStorage()
+	writeFakeCredentials(sectionName, mfaDeviceID)
+	return store
+}
+
+// setFakeCredentialsOnDisk populates the fake AWS credentials file as setFakeCredentials
+// does but writes it to the current working directory, for the few tests that need a
+// real file.
+func setFakeCredentialsOnDisk(sectionName, mfaDeviceID string) {
+	credentialsStorage = fileStorage{}
+	writeFakeCredentials(sectionName, mfaDeviceID)
+}
+
+// writeFakeCredentials writes the fake AWS credentials file for setFakeCredentials and
+// setFakeCredentialsOnDisk to wherever the package is writing credentials files.
+func writeFakeCredentials(sectionName, mfaDeviceID string) {
 
 	// Start with an empty configuration file content structure
 	cfg := ini.Empty()
@@ -119,7 +222,7 @@ func setFakeCredentials(sectionName, mfaDeviceID string) {
 	}
 
 	// Write the file
-	err = cfg.SaveTo(fakeCredentialsFilePath)
+	err = saveFakeFile(cfg, fakeCredentialsFilePath)
 
 	// That really should not faile to wrote, but if it did abort the tests
 	// cos nothing will work after this
@@ -131,3 +234,100 @@ func setFakeCredentials(sectionName, mfaDeviceID string) {
 	// All looks good - trick the package into using the fake file we just wrote
 	OverrideDefaultCredentialsFilepath(fakeCredentialsFilePath)
 }
+
+// TestCheckLongTermKeys confirms that a profile without an access key ID or secret access
+// key is reported as such.
+func TestCheckLongTermKeys(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	require.Nil(t, CheckLongTermKeysInFile(fakeCredentialsFilePath, DefaultSectionName), "the keys should have been found")
+
+	// Take the access key ID away
+	cfg, _ := loadFakeFile(fakeCredentialsFilePath)
+	cfg.Section(DefaultSectionName).DeleteKey(AccessKeyIDKey)
+	require.Nil(t, saveFakeFile(cfg, fakeCredentialsFilePath), "could not rewrite the credentials file")
+	err := CheckLongTermKeysInFile(fakeCredentialsFilePath, DefaultSectionName)
+	require.True(t, errors.Is(err, ErrLongTermKeysNotFound), "expected ErrLongTermKeysNotFound, not %v", err)
+	require.Contains(t, err.Error(), "profile default has no aws_access_key_id", "the error should name the missing key")
+
+	// Or leave it there with no value
+	cfg.Section(DefaultSectionName).NewKey(AccessKeyIDKey, fakeAccessKeyID)
+	cfg.Section(DefaultSectionName).Key(SecretAccessKeyKey).SetValue("")
+	require.Nil(t, saveFakeFile(cfg, fakeCredentialsFilePath), "could not rewrite the credentials file")
+	err = CheckLongTermKeysInFile(fakeCredentialsFilePath, DefaultSectionName)
+	require.True(t, errors.Is(err, ErrLongTermKeyEmpty), "expected ErrLongTermKeyEmpty, not %v", err)
+	require.Contains(t, err.Error(), "aws_secret_access_key is empty in profile default", "the error should name the empty key")
+
+	require.NotNil(t, CheckLongTermKeysInFile(fakeCredentialsFilePath, "missing"), "a missing section should have been an error")
+}
+
+// TestGetProfileTOTPSecret confirms that a profile's virtual MFA device secret is found
+// when there is one, and is empty when there is not.
+func TestGetProfileTOTPSecret(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	secret, err := GetProfileTOTPSecretFromFile(fakeCredentialsFilePath, DefaultSectionName)
+	require.Nil(t, err, "there should not have been an error")
+	require.Empty(t, secret, "there should not have been a secret")
+
+	cfg, _ := loadFakeFile(fakeCredentialsFilePath)
+	cfg.Section(DefaultSectionName).NewKey(MfaTOTPSecretKey, "GEZDGNBVGY3TQOJQ")
+	require.Nil(t, saveFakeFile(cfg, fakeCredentialsFilePath), "could not rewrite the credentials file")
+	secret, err = GetProfileTOTPSecretFromFile(fakeCredentialsFilePath, DefaultSectionName)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "GEZDGNBVGY3TQOJQ", secret, "unexpected secret")
+
+	_, err = GetProfileTOTPSecretFromFile(fakeCredentialsFilePath, "missing")
+	require.NotNil(t, err, "a missing section should have been an error")
+}
+
+// TestGetProfileKeys confirms that the keys of a profile section can be listed, and that
+// asking for a missing section is an error.
+func TestGetProfileKeys(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	keys, err := GetProfileKeysFromFile(fakeCredentialsFilePath, DefaultSectionName)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, fakeMFADeviceID, keys[MfaDeviceIDKey], "the MFA device ID should have been listed")
+	require.Contains(t, keys, AccessKeyIDKey, "the access key ID should have been listed")
+
+	_, err = GetProfileKeysFromFile(fakeCredentialsFilePath, "missing")
+	require.NotNil(t, err, "a missing section should have been an error")
+}
+
+// TestGetProfileNames confirms that the section names of the credentials file are listed
+// in order, without the unnamed section that the ini library invents.
+func TestGetProfileNames(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	value := "session-value"
+	_, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, nil, &value, &value, &value)
+	require.Nil(t, err, "could not save a session")
+	names, err := GetProfileNamesFromFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, []string{DefaultSectionName, SessionSectionName}, names, "unexpected section names")
+
+	_, err = GetProfileNamesFromFile("./missing.test")
+	require.NotNil(t, err, "a missing file should have been an error")
+}
+
+// TestProfileNameForSection confirms that the session suffix is trimmed from section names
+// that have one, and that other names are left alone.
+func TestProfileNameForSection(t *testing.T) {
+	require.Equal(t, DefaultSectionName, ProfileNameForSection(SessionSectionName))
+	require.Equal(t, "work", ProfileNameForSection(SessionSectionNameFor("work")))
+	require.Equal(t, "work", ProfileNameForSection("work"))
+	require.Equal(t, sessionSectionSuffix, ProfileNameForSection(sessionSectionSuffix))
+}
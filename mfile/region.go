@@ -0,0 +1,36 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// package methods related to finding the AWS region of a profile.
+
+const (
+	// RegionKey defines the name of the AWS region field within a profile section of
+	// either the credentials or the config file
+	RegionKey = "region"
+)
+
+// GetProfileRegion returns the region configured for the named profile, looking first
+// in the given AWS credentials file and then in the given AWS config file as the AWS
+// SDKs do. An empty string is returned if neither has a region for the profile, including
+// when the files are missing or cannot be read.
+func GetProfileRegion(credentialsPath, configPath, profile string) string {
+
+	// The credentials file is read as it always is, as one of several files if the path
+	// lists them
+	if cfg, err := loadCredentialsFile(credentialsPath); err == nil {
+		if region := cfg.Section(profile).Key(RegionKey).Value(); len(region) != 0 {
+			return region
+		}
+	}
+
+	// The config file is read straight from disk
+	cfg, err := loadINI(configPath)
+	if err != nil {
+		return ""
+	}
+	return cfg.Section(ConfigSectionName(profile)).Key(RegionKey).Value()
+}
@@ -0,0 +1,37 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// unit tests for the region.go functions.
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetProfileRegion confirms that the credentials file region trumps the config file
+// region, which is used when the credentials file has none.
+func TestGetProfileRegion(t *testing.T) {
+
+	// Tidy up after the test has run
+	defer os.Remove(fakeConfigFilePath)
+	defer os.Remove(fakeCredentialsFilePath)
+
+	// A credentials file with a region for the default profile only, and a config file
+	// with regions for both the default and another profile
+	cfg := "[default]\nregion = us-west-2\n\n[profile other]\nregion = eu-west-1\n"
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(cfg), 0600), "could not write the fake config file")
+	creds := "[default]\nregion = ap-southeast-2\n"
+	require.Nil(t, ioutil.WriteFile(fakeCredentialsFilePath, []byte(creds), 0600), "could not write the fake credentials file")
+
+	require.Equal(t, "ap-southeast-2", GetProfileRegion(fakeCredentialsFilePath, fakeConfigFilePath, DefaultSectionName))
+	require.Equal(t, "eu-west-1", GetProfileRegion(fakeCredentialsFilePath, fakeConfigFilePath, "other"))
+	require.Empty(t, GetProfileRegion(fakeCredentialsFilePath, fakeConfigFilePath, "missing"))
+	require.Empty(t, GetProfileRegion("/you/got/no/skin/on/me-cos-i-do-not-exist", "/nor/me", DefaultSectionName))
+}
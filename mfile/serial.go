@@ -0,0 +1,72 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// package methods related to checking MFA device serial numbers.
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	// The account number and username of the example MFA serial number given in the
+	// documentation, arn:aws:iam::999999999999:mfa/jane
+	exampleAccount  = "999999999999"
+	exampleUsername = "jane"
+)
+
+var (
+	// What the ARN of a virtual or hardware MFA device looks like, in any partition
+	mfaSerialPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:mfa/[\w+=,.@/-]+$`)
+
+	// ErrExampleMFASerial is returned by ValidateMFASerial(..) when it is given the MFA
+	// serial number from the documentation, or something very like it
+	ErrExampleMFASerial = errors.New("Looks like you pasted the example MFA serial — replace it with your own ARN.")
+
+	// ErrNotMFASerialARN is returned by ParseMFASerial(..) when it is given a serial number
+	// that is not an MFA device ARN
+	ErrNotMFASerialARN = errors.New("the MFA serial number is not an MFA device ARN")
+)
+
+// ValidateMFASerial returns ErrExampleMFASerial if the given MFA device serial number is,
+// or is derived from, the example given in the documentation: that is, if its account
+// number is 999999999999 or its username is jane. Serial numbers not in the form
+// arn:aws:iam::<account>:mfa/<username> are left for AWS to judge.
+func ValidateMFASerial(mfaSerial string) error {
+
+	// Pick out the account number and username, if we can
+	account, username, err := ParseMFASerial(mfaSerial)
+	if err != nil {
+		return nil
+	}
+
+	// Compare those with the documentation's example
+	if account == exampleAccount || username == exampleUsername {
+		return ErrExampleMFASerial
+	}
+	return nil
+}
+
+// ParseMFASerial returns the account number and username encoded in an MFA device serial
+// number of the form arn:aws:iam::<account>:mfa/<username>, with any path before the
+// username dropped, or ErrNotMFASerialARN if the serial number is not in that form.
+func ParseMFASerial(mfaSerial string) (account, username string, err error) {
+	fields := strings.Split(mfaSerial, ":")
+	if len(fields) != 6 || fields[0] != "arn" || !strings.HasPrefix(fields[5], "mfa/") {
+		return "", "", ErrNotMFASerialARN
+	}
+	return fields[4], fields[5][strings.LastIndex(fields[5], "/")+1:], nil
+}
+
+// IsMFASerialARN returns true if the given string has the form of an MFA device ARN,
+// i.e. arn:aws:iam::<12 digit account>:mfa/<username>. U2F security keys and some older
+// hardware tokens have serial numbers that are not ARNs, so a false return is not proof
+// that AWS will reject a serial number.
+func IsMFASerialARN(mfaSerial string) bool {
+	return mfaSerialPattern.MatchString(mfaSerial)
+}
@@ -0,0 +1,66 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// unit tests for the serial.go functions.
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateMFASerial confirms that the documentation's example MFA serial, and partial
+// edits of it, are rejected while real looking serial numbers are not.
+func TestValidateMFASerial(t *testing.T) {
+
+	// The example itself and half edited versions of it
+	for _, serial := range []string{
+		"arn:aws:iam::999999999999:mfa/jane",
+		"arn:aws:iam::999999999999:mfa/bob",
+		"arn:aws:iam::123456789012:mfa/jane",
+	} {
+		require.Equal(t, ErrExampleMFASerial, ValidateMFASerial(serial), "%s should have been rejected", serial)
+	}
+
+	// Serial numbers that are nothing like it, including ones that we cannot parse
+	for _, serial := range []string{
+		"arn:aws:iam::123456789012:mfa/bob",
+		"arn:aws-us-gov:iam::123456789012:mfa/some/path/bob",
+		"GAHT12345678",
+		"",
+	} {
+		require.Nil(t, ValidateMFASerial(serial), "%s should have been accepted", serial)
+	}
+}
+
+// TestIsMFASerialARN confirms that MFA device ARNs are recognized as such.
+func TestIsMFASerialARN(t *testing.T) {
+	require.True(t, IsMFASerialARN("arn:aws:iam::123456789012:mfa/bob"))
+	require.True(t, IsMFASerialARN("arn:aws-us-gov:iam::123456789012:mfa/some/path/bob"))
+	require.False(t, IsMFASerialARN("arn:aws:iam::123456789012:user/bob"), "a user ARN is not an MFA device ARN")
+	require.False(t, IsMFASerialARN("arn:aws:iam::1234:mfa/bob"), "account numbers have 12 digits")
+	require.False(t, IsMFASerialARN("GAHT12345678"))
+}
+
+// TestParseMFASerial confirms that the account number and username are picked out of MFA
+// device ARNs, and that serial numbers of other forms are reported as such.
+func TestParseMFASerial(t *testing.T) {
+	account, username, err := ParseMFASerial("arn:aws:iam::123456789012:mfa/bob")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "123456789012", account, "unexpected account number")
+	require.Equal(t, "bob", username, "unexpected username")
+
+	account, username, err = ParseMFASerial("arn:aws-us-gov:iam::123456789012:mfa/some/path/bob")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "123456789012", account, "unexpected account number")
+	require.Equal(t, "bob", username, "the path should have been dropped")
+
+	for _, serial := range []string{"GAHT12345678", "arn:aws:iam::123456789012:user/bob", ""} {
+		_, _, err = ParseMFASerial(serial)
+		require.Equal(t, ErrNotMFASerialARN, err, "%s should not have been parsed", serial)
+	}
+}
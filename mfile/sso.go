@@ -0,0 +1,170 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// package methods related to AWS SSO profiles and the access tokens that
+// aws sso login caches for them.
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// The keys of an SSO profile section in the AWS config file, the first two of which
+	// may instead be in an sso-session section that the profile names
+	ssoStartURLKey  = "sso_start_url"
+	ssoRegionKey    = "sso_region"
+	ssoAccountIDKey = "sso_account_id"
+	ssoRoleNameKey  = "sso_role_name"
+	ssoSessionKey   = "sso_session"
+
+	// The prefix of the sso-session section names in the AWS config file
+	ssoSessionPrefix = "sso-session "
+
+	// The layout of the expiresAt times cached by version 1 of the AWS CLI; version 2
+	// caches RFC 3339 times
+	ssoLegacyExpiresAtLayout = "2006-01-02T15:04:05UTC"
+)
+
+// SSOProfile describes a profile in the AWS config file that signs in through AWS SSO.
+type SSOProfile struct {
+	Name      string // The name of the profile
+	StartURL  string // The AWS SSO start URL, the portal that the user signs in to
+	Region    string // The region of the AWS SSO portal
+	AccountID string // The account of the role whose credentials the profile uses
+	RoleName  string // The name of that role, its permission set
+	Session   string // The name of the sso-session section giving the start URL and region, if any
+}
+
+// SSOToken is an AWS SSO access token, as cached by aws sso login.
+type SSOToken struct {
+	AccessToken string    // The token itself
+	ExpiresAt   time.Time // When the token stops working
+}
+
+// ssoCacheEntry is the JSON form of a cached access token.
+type ssoCacheEntry struct {
+	StartURL    string `json:"startUrl"`
+	Region      string `json:"region"`
+	AccessToken string `json:"accessToken"`
+	ExpiresAt   string `json:"expiresAt"`
+}
+
+var (
+	// Where aws sso login caches access tokens, alongside the config file, filled in at
+	// load time. Like defaultConfigFilePath, this can be overridden by unit tests.
+	defaultSSOCacheDir string
+)
+
+// GetSSOProfileFromFile returns the SSO profile of the given name from the given AWS
+// config file, taking its start URL and region from the sso-session section that it
+// names, if it names one. A missing file or profile, or a profile missing any of the
+// settings, is an error.
+func GetSSOProfileFromFile(filepath, profile string) (*SSOProfile, error) {
+	if err := checkDefaultPath(filepath); err != nil {
+		return nil, err
+	}
+
+	// Load the file
+	cfg, err := loadINI(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read from config file %s: %v", filepath, err)
+	}
+
+	// Fetch the profile section - if there is one
+	section, err := cfg.GetSection(ConfigSectionName(profile))
+	if err != nil {
+		return nil, fmt.Errorf("%s profile not found in %s", profile, filepath)
+	}
+	ssoProfile := &SSOProfile{
+		Name:      profile,
+		StartURL:  section.Key(ssoStartURLKey).Value(),
+		Region:    section.Key(ssoRegionKey).Value(),
+		AccountID: section.Key(ssoAccountIDKey).Value(),
+		RoleName:  section.Key(ssoRoleNameKey).Value(),
+		Session:   section.Key(ssoSessionKey).Value(),
+	}
+
+	// The start URL and region may come from an sso-session section
+	if len(ssoProfile.Session) != 0 {
+		session, err := cfg.GetSection(ssoSessionPrefix + ssoProfile.Session)
+		if err != nil {
+			return nil, fmt.Errorf("the %s profile names the %s sso-session, which is not in %s", profile, ssoProfile.Session, filepath)
+		}
+		ssoProfile.StartURL = session.Key(ssoStartURLKey).Value()
+		ssoProfile.Region = session.Key(ssoRegionKey).Value()
+	}
+
+	// It is no use without all four settings
+	for _, kv := range []keyValue{
+		{ssoStartURLKey, ssoProfile.StartURL},
+		{ssoRegionKey, ssoProfile.Region},
+		{ssoAccountIDKey, ssoProfile.AccountID},
+		{ssoRoleNameKey, ssoProfile.RoleName},
+	} {
+		if len(kv.value) == 0 {
+			return nil, fmt.Errorf("the %s profile in %s has no %s, so it is not an AWS SSO profile", profile, filepath, kv.name)
+		}
+	}
+	return ssoProfile, nil
+}
+
+// GetSSOTokenFromDir returns the access token that aws sso login cached in the given
+// directory for the given SSO profile: in a file named by the SHA-1 digest of its
+// sso-session name or, without one, of its start URL. A missing or expired token is an
+// error that tells the user to sign in again.
+func GetSSOTokenFromDir(dir string, ssoProfile *SSOProfile) (*SSOToken, error) {
+
+	// Find the cached token
+	key := ssoProfile.StartURL
+	if len(ssoProfile.Session) != 0 {
+		key = ssoProfile.Session
+	}
+	digest := sha1.Sum([]byte(key))
+	path := filepath.Join(dir, hex.EncodeToString(digest[:])+".json")
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("there is no cached AWS SSO access token for the %s profile in %s; run aws sso login --profile %s", ssoProfile.Name, dir, ssoProfile.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the AWS SSO cache file %s: %v", path, err)
+	}
+
+	// Make sense of it
+	var entry ssoCacheEntry
+	if err = json.Unmarshal(content, &entry); err != nil || len(entry.AccessToken) == 0 {
+		return nil, fmt.Errorf("the AWS SSO cache file %s does not hold an access token", path)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, entry.ExpiresAt)
+	if err != nil {
+		if expiresAt, err = time.Parse(ssoLegacyExpiresAtLayout, entry.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("the AWS SSO cache file %s has an invalid expiresAt %q", path, entry.ExpiresAt)
+		}
+	}
+	if !time.Now().Before(expiresAt) {
+		return nil, fmt.Errorf("the cached AWS SSO access token for the %s profile expired at %s; run aws sso login --profile %s", ssoProfile.Name, expiresAt.Format(time.RFC3339), ssoProfile.Name)
+	}
+	return &SSOToken{AccessToken: entry.AccessToken, ExpiresAt: expiresAt}, nil
+}
+
+// DefaultSSOCacheDir returns the directory that aws sso login caches access tokens in,
+// typically $HOME/.aws/sso/cache, or an empty string if there is no home directory.
+func DefaultSSOCacheDir() string {
+	return defaultSSOCacheDir
+}
+
+// OverrideDefaultSSOCacheDir is intended for use by unit tests that need to keep away
+// from the real AWS SSO cache.
+func OverrideDefaultSSOCacheDir(dir string) {
+	defaultSSOCacheDir = dir
+}
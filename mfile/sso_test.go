@@ -0,0 +1,89 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// unit tests for the sso.go functions.
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// A config file with SSO profiles of both the older and the sso-session sort
+	ssoConfig = "[profile legacy]\nsso_start_url = https://example.awsapps.com/start\nsso_region = eu-west-1\nsso_account_id = 210987654321\nsso_role_name = Developer\n\n" +
+		"[profile modern]\nsso_session = corp\nsso_account_id = 210987654321\nsso_role_name = Admin\n\n" +
+		"[sso-session corp]\nsso_start_url = https://corp.awsapps.com/start\nsso_region = us-east-2\n\n" +
+		"[profile lost]\nsso_session = nowhere\n\n[profile plain]\nregion = us-east-1\n"
+)
+
+// TestGetSSOProfile confirms that SSO profiles are read with or without an sso-session,
+// and that profiles without the settings are errors.
+func TestGetSSOProfile(t *testing.T) {
+	require.Nil(t, ioutil.WriteFile(fakeConfigFilePath, []byte(ssoConfig), 0600), "could not write the fake config file")
+	defer os.Remove(fakeConfigFilePath)
+
+	ssoProfile, err := GetSSOProfileFromFile(fakeConfigFilePath, "legacy")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, &SSOProfile{Name: "legacy", StartURL: "https://example.awsapps.com/start", Region: "eu-west-1", AccountID: "210987654321", RoleName: "Developer"}, ssoProfile)
+
+	ssoProfile, err = GetSSOProfileFromFile(fakeConfigFilePath, "modern")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, &SSOProfile{Name: "modern", StartURL: "https://corp.awsapps.com/start", Region: "us-east-2", AccountID: "210987654321", RoleName: "Admin", Session: "corp"}, ssoProfile)
+
+	_, err = GetSSOProfileFromFile(fakeConfigFilePath, "lost")
+	require.NotNil(t, err, "a missing sso-session should have been an error")
+	_, err = GetSSOProfileFromFile(fakeConfigFilePath, "plain")
+	require.NotNil(t, err, "a profile without SSO settings should have been an error")
+	require.Contains(t, err.Error(), "has no sso_start_url", "not the expected error")
+	_, err = GetSSOProfileFromFile(fakeConfigFilePath, "missing")
+	require.NotNil(t, err, "a missing profile should have been an error")
+}
+
+// TestGetSSOToken confirms that cached access tokens are found by the digest of the
+// sso-session name or start URL, in either expiresAt layout, and that missing and
+// expired tokens are errors.
+func TestGetSSOToken(t *testing.T) {
+
+	// Work in a cache directory of our own
+	dir, err := ioutil.TempDir("", "mafia-sso-cache")
+	require.Nil(t, err, "could not create a temporary directory")
+	defer os.RemoveAll(dir)
+	cache := func(key, content string) {
+		digest := sha1.Sum([]byte(key))
+		require.Nil(t, ioutil.WriteFile(filepath.Join(dir, hex.EncodeToString(digest[:])+".json"), []byte(content), 0600))
+	}
+	legacy := &SSOProfile{Name: "legacy", StartURL: "https://example.awsapps.com/start"}
+	modern := &SSOProfile{Name: "modern", StartURL: "https://corp.awsapps.com/start", Session: "corp"}
+
+	// Nothing cached yet
+	_, err = GetSSOTokenFromDir(dir, legacy)
+	require.NotNil(t, err, "a missing token should have been an error")
+	require.Contains(t, err.Error(), "run aws sso login --profile legacy", "the user should have been told what to do")
+
+	// One of each layout
+	cache(legacy.StartURL, `{"startUrl": "https://example.awsapps.com/start", "region": "eu-west-1", "accessToken": "legacy-token", "expiresAt": "2099-01-02T03:04:05UTC"}`)
+	cache("corp", `{"startUrl": "https://corp.awsapps.com/start", "region": "us-east-2", "accessToken": "modern-token", "expiresAt": "2099-01-02T03:04:05Z"}`)
+	token, err := GetSSOTokenFromDir(dir, legacy)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, &SSOToken{AccessToken: "legacy-token", ExpiresAt: time.Date(2099, time.January, 2, 3, 4, 5, 0, time.UTC)}, token)
+	token, err = GetSSOTokenFromDir(dir, modern)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "modern-token", token.AccessToken, "the sso-session's token should have been found")
+
+	// An expired token is no use
+	cache(legacy.StartURL, `{"accessToken": "legacy-token", "expiresAt": "2020-01-02T03:04:05Z"}`)
+	_, err = GetSSOTokenFromDir(dir, legacy)
+	require.NotNil(t, err, "an expired token should have been an error")
+	require.Contains(t, err.Error(), "expired at 2020-01-02T03:04:05Z", "not the expected error")
+}
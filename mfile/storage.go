@@ -0,0 +1,75 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// the storage that the AWS credentials file is read from and written to.
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// storage is where credentials files are read from and written to. The package uses
+// fileStorage, the real file system, but unit tests substitute an in-memory store so
+// that they need not write credentials files to disk.
+type storage interface {
+
+	// ReadFile returns the content of the named file, or an error for which
+	// os.IsNotExist(..) is true if there is no such file.
+	ReadFile(filepath string) ([]byte, error)
+
+	// WriteFile replaces the content of the named file, creating it with the given
+	// permissions if need be; an existing file keeps its own.
+	WriteFile(filepath string, content []byte, perm os.FileMode) error
+
+	// Perm returns the permissions of the named file.
+	Perm(filepath string) (os.FileMode, error)
+
+	// Chmod changes the permissions of the named file.
+	Chmod(filepath string, perm os.FileMode) error
+
+	// Lock takes an exclusive lock on the named file, waiting for any other holder to
+	// release it, and returns a function that releases the lock.
+	Lock(filepath string) (func(), error)
+}
+
+var (
+	// Where credentials files are read from and written to; unit tests substitute an
+	// in-memory store
+	credentialsStorage storage = fileStorage{}
+)
+
+// fileStorage is the storage of the real file system.
+type fileStorage struct{}
+
+// ReadFile reads the named file from disk.
+func (fileStorage) ReadFile(filepath string) ([]byte, error) {
+	return ioutil.ReadFile(filepath)
+}
+
+// WriteFile writes the named file to disk.
+func (fileStorage) WriteFile(filepath string, content []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(filepath, content, perm)
+}
+
+// Perm returns the permissions of the named file on disk.
+func (fileStorage) Perm(filepath string) (os.FileMode, error) {
+	info, err := os.Stat(filepath)
+	if err != nil {
+		return 0, err
+	}
+	return info.Mode().Perm(), nil
+}
+
+// Chmod changes the permissions of the named file on disk.
+func (fileStorage) Chmod(filepath string, perm os.FileMode) error {
+	return os.Chmod(filepath, perm)
+}
+
+// Lock locks the named file on disk as lockFile(..) does.
+func (fileStorage) Lock(filepath string) (func(), error) {
+	return lockFile(filepath)
+}
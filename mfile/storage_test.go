@@ -0,0 +1,172 @@
+package mfile
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See doc.go for other overall package documentation. This file contains
+// the in-memory storage that most package tests use in place of the file
+// system, and unit tests for both it and the storage.go functions.
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+// memoryStorage is a storage that keeps its files in memory, so that tests can read and
+// write credentials files without touching the disk.
+type memoryStorage struct {
+	mutex  sync.Mutex             // Guards the maps
+	files  map[string][]byte      // The content of each file
+	perms  map[string]os.FileMode // The permissions of each file
+	lock   sync.Mutex             // Held by whoever has locked a file, there being one lock for all
+	writes int                    // The number of writes made
+}
+
+// newMemoryStorage returns an empty memoryStorage.
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{files: map[string][]byte{}, perms: map[string]os.FileMode{}}
+}
+
+// ReadFile returns a copy of the content of the named file.
+func (m *memoryStorage) ReadFile(filepath string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	content, ok := m.files[filepath]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: filepath, Err: os.ErrNotExist}
+	}
+	return append([]byte{}, content...), nil
+}
+
+// WriteFile keeps a copy of the given content as that of the named file.
+func (m *memoryStorage) WriteFile(filepath string, content []byte, perm os.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.files[filepath]; !ok {
+		m.perms[filepath] = perm
+	}
+	m.files[filepath] = append([]byte{}, content...)
+	m.writes++
+	return nil
+}
+
+// Perm returns the permissions of the named file.
+func (m *memoryStorage) Perm(filepath string) (os.FileMode, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	perm, ok := m.perms[filepath]
+	if !ok {
+		return 0, &os.PathError{Op: "stat", Path: filepath, Err: os.ErrNotExist}
+	}
+	return perm, nil
+}
+
+// Chmod changes the permissions of the named file.
+func (m *memoryStorage) Chmod(filepath string, perm os.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.perms[filepath]; !ok {
+		return &os.PathError{Op: "chmod", Path: filepath, Err: os.ErrNotExist}
+	}
+	m.perms[filepath] = perm
+	return nil
+}
+
+// Lock takes the store's one lock, provided that the named file exists.
+func (m *memoryStorage) Lock(filepath string) (func(), error) {
+	if _, err := m.ReadFile(filepath); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	return m.lock.Unlock, nil
+}
+
+// useMemoryStorage has the package read and write credentials files in a new, empty
+// memoryStorage, which it returns; ResetPackageDefaults() goes back to the file system.
+func useMemoryStorage() *memoryStorage {
+	m := newMemoryStorage()
+	credentialsStorage = m
+	return m
+}
+
+// loadFakeFile loads the named ini file from wherever the package is reading credentials
+// files.
+func loadFakeFile(filepath string) (*ini.File, error) {
+	content, err := credentialsStorage.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return ini.Load(content)
+}
+
+// saveFakeFile saves the given ini file to wherever the package is writing credentials
+// files, under the given name.
+func saveFakeFile(cfg *ini.File, filepath string) error {
+	var content bytes.Buffer
+	if _, err := cfg.WriteTo(&content); err != nil {
+		return err
+	}
+	return credentialsStorage.WriteFile(filepath, content.Bytes(), credentialsFileMode)
+}
+
+// TestFileStorage confirms that fileStorage reads, writes, and locks real files.
+func TestFileStorage(t *testing.T) {
+
+	// Work in a directory of our own
+	dir, err := ioutil.TempDir("", "mafia-storage")
+	require.Nil(t, err, "could not create a temporary directory")
+	defer os.RemoveAll(dir)
+	path := dir + "/credentials"
+
+	// A file that is not there cannot be read
+	store := fileStorage{}
+	_, err = store.ReadFile(path)
+	require.True(t, os.IsNotExist(err), "expected a not exist error, not %v", err)
+
+	// One that is written can be, and locked
+	require.Nil(t, store.WriteFile(path, []byte("[default]\n"), 0600), "could not write the file")
+	content, err := store.ReadFile(path)
+	require.Nil(t, err, "could not read the file")
+	require.Equal(t, "[default]\n", string(content), "not the content that was written")
+	unlock, err := store.Lock(path)
+	require.Nil(t, err, "could not lock the file")
+	unlock()
+
+	// The permissions can be read and changed
+	if runtime.GOOS != "windows" {
+		require.Nil(t, store.Chmod(path, 0640), "could not change the permissions")
+		perm, err := store.Perm(path)
+		require.Nil(t, err, "could not read the permissions")
+		require.Equal(t, os.FileMode(0640), perm, "not the permissions that were set")
+	}
+}
+
+// TestMemoryStorage confirms that the memoryStorage that the other tests rely on behaves
+// as fileStorage does.
+func TestMemoryStorage(t *testing.T) {
+	store := newMemoryStorage()
+	_, err := store.ReadFile("missing")
+	require.True(t, os.IsNotExist(err), "expected a not exist error, not %v", err)
+	_, err = store.Lock("missing")
+	require.True(t, os.IsNotExist(err), "a missing file should not have been locked")
+
+	// A new file takes the permissions given, an existing one keeps its own
+	require.Nil(t, store.WriteFile("file", []byte("one"), 0600))
+	require.Nil(t, store.Chmod("file", 0640))
+	require.Nil(t, store.WriteFile("file", []byte("two"), 0600))
+	content, err := store.ReadFile("file")
+	require.Nil(t, err, "could not read the file")
+	require.Equal(t, "two", string(content), "not the content last written")
+	perm, err := store.Perm("file")
+	require.Nil(t, err, "could not read the permissions")
+	require.Equal(t, os.FileMode(0640), perm, "the file should have kept its permissions")
+	require.Equal(t, 2, store.writes, "both writes should have been counted")
+}
@@ -2,6 +2,8 @@ package mfile
 
 import (
 	"fmt"
+	"io"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
@@ -13,33 +15,425 @@ import (
 // See doc.go for other overall package documentation. This file contains
 // package methods related to updating the AWS credentials file.
 
+// KeyNames defines the names of the keys that session credentials are written under.
+// Any name left empty defaults to the standard AWS key name.
+type KeyNames struct {
+	AccessKeyID     string // Defaults to aws_access_key_id
+	SecretAccessKey string // Defaults to aws_secret_access_key
+	SessionToken    string // Defaults to aws_session_token
+	Expiration      string // Defaults to aws_session_expiration
+}
+
+// SaveOptions controls how session credentials are written to an AWS credentials file.
+// A nil *SaveOptions is equivalent to a zero value SaveOptions, meaning that the standard
+// AWS key names are used and the credentials are written to the default-session section.
+type SaveOptions struct {
+	Profile  string   // The profile whose session is being saved, defaults to the default profile
+	KeyNames KeyNames // The names of the keys that the credentials are written under
+	InPlace  bool     // True to overwrite the long term credentials in the profile section
+	Backup   bool     // True to copy the file to BackupSuffix before an InPlace write
+
+	// True to copy the profile's other settings, e.g. region, into the session section so
+	// that it is self-contained; credentials and the MFA device ID are never copied
+	CopyProfileSettings bool
+
+	// When the credentials expire, recorded under the expiration key name if known
+	Expiration *time.Time
+
+	// If not nil, the changes to the section are written here, before the file is, with
+	// the secret access key and session token masked
+	Diff io.Writer
+
+	// If not empty, the session token is saved encrypted with this passphrase, under
+	// SessionTokenEncryptedKey in place of the usual key, and decrypted with it when read
+	Passphrase string
+}
+
+// SaveResult describes what SaveSessionCredentialsToFile(..) did, for callers that want
+// to report more than that the credentials were saved.
+type SaveResult struct {
+	Filepath string // The file written to, the last of several if the path listed more
+	Section  string // The section that the credentials were saved to
+	Created  bool   // True if the section was added to the file, false if it was already there
+	Written  bool   // False if the section already held the very same values and the file was left alone
+	Backup   string // The path of the copy taken before an InPlace write, if one was
+}
+
+// BackupSuffix is appended to the credentials file path to name the backup copy taken
+// before the long term credentials are overwritten.
+const BackupSuffix = ".bak"
+
 // SaveSessionCredentials writes the given credentials to a "session" section of the
-// default AWS credentials file, i.e. $HOME/.aws/credentials.
-func SaveSessionCredentials(accessKeyID, secretAccessKey, sessionToken *string) error {
+// AWS credentials file found by ResolveCredentialsPath(..), normally $HOME/.aws/credentials.
+// The file is only written if the credentials differ from those already there; the result
+// returned reports whether it was.
+func SaveSessionCredentials(accessKeyID, secretAccessKey, sessionToken *string) (*SaveResult, error) {
 
 	// Have our siblings do all the work!
-	return SaveSessionCredentialsToFile(defaultCredentialsFilePath,
+	return SaveSessionCredentialsToFile(ResolveCredentialsPath(""), nil,
 		accessKeyID, secretAccessKey, sessionToken)
 }
 
+// SaveSessionCredentialValues is SaveSessionCredentials(..) for callers holding the
+// credentials as plain strings, e.g. ones obtained by some means other than Mafia's.
+func SaveSessionCredentialValues(accessKeyID, secretAccessKey, sessionToken string) (*SaveResult, error) {
+	return SaveSessionCredentials(&accessKeyID, &secretAccessKey, &sessionToken)
+}
+
+// SaveSessionCredentialValuesToFile is SaveSessionCredentialsToFile(..) for callers holding
+// the credentials as plain strings, e.g. ones obtained by some means other than Mafia's.
+func SaveSessionCredentialValuesToFile(filepath string, options *SaveOptions, accessKeyID, secretAccessKey, sessionToken string) (*SaveResult, error) {
+	return SaveSessionCredentialsToFile(filepath, options, &accessKeyID, &secretAccessKey, &sessionToken)
+}
+
 // SaveSessionCredentialsToFile saves the given credentials to a "session" section of the
-// the given AWS credentials file.
-func SaveSessionCredentialsToFile(filepath string, accessKeyID, secretAccessKey, sessionToken *string) error {
+// the given AWS credentials file, or in place of the long term credentials in the profile
+// section, as directed by the given options (which may be nil). If the path lists several
+// files, the last is written to, though settings to be copied may come from any of them.
+// Other keys in the section, such as the MFA device ID, are left untouched. The file is
+// locked while it is read and rewritten so that concurrent saves do not clobber each
+// other. If the section already holds the very same credentials, expiration, and copied
+// settings, the file is not written at all, sparing file watchers from needless churn.
+// The result returned says which file and section the credentials went to, whether the
+// section is new, whether the file was written, and where any backup was put.
+func SaveSessionCredentialsToFile(filepath string, options *SaveOptions, accessKeyID, secretAccessKey, sessionToken *string) (*SaveResult, error) {
+
+	// If the path lists several files, it is the last that we write to
+	merged, filepath := filepath, WritableCredentialsPath(filepath)
+	if err := checkDefaultPath(filepath); err != nil {
+		return nil, err
+	}
+
+	// Make sure that nobody else changes the file between our loading and saving it
+	unlock, err := credentialsStorage.Lock(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not lock credentials file %s: %v", filepath, err)
+	}
+	defer unlock()
+
+	// Load the current file contents, keeping hold of them as they are to edit later
+	content, err := credentialsStorage.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
+	}
+	cfg, err := loadINI(content)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
+	}
+
+	// Either load any previously existing section or create a new one with the required name
+	result := &SaveResult{Filepath: filepath, Section: options.SectionName()}
+	_, err = cfg.GetSection(result.Section)
+	result.Created = err != nil
+	sessionSection := cfg.Section(result.Section)
+
+	// Work out what the section should end up holding and leave well alone if it already does.
+	// The keys are listed in the order, and are written in the key = value form, that
+	// aws configure set gives them, so that the two do not fight over the file's layout
+	keyNames := options.ResolvedKeyNames()
+	values := []keyValue{
+		{keyNames.AccessKeyID, *accessKeyID},
+		{keyNames.SecretAccessKey, *secretAccessKey},
+		{keyNames.SessionToken, *sessionToken},
+		{keyNames.Expiration, ""},
+		{SessionTokenEncryptedKey, ""},
+	}
+	if options != nil && options.Expiration != nil {
+		values[3].value = options.Expiration.UTC().Format(time.RFC3339)
+	}
+	if options != nil && len(options.Passphrase) != 0 {
+		if values[2].value, values[4].value, err = encryptedSessionToken(sessionSection, options.Passphrase, *sessionToken); err != nil {
+			return nil, fmt.Errorf("Could not encrypt the session token: %v", err)
+		}
+	}
+	if options != nil && options.CopyProfileSettings && !options.InPlace {
+		all := cfg
+		if merged != filepath {
+			if all, err = loadCredentialsFile(merged); err != nil {
+				return nil, fmt.Errorf("Could not read from credentials file %s: %v", merged, err)
+			}
+		}
+		values = append(values, profileSettings(all, options.profileName(), keyNames)...)
+	}
+	if sectionHolds(sessionSection, values) {
+		return result, nil
+	}
+
+	// Show what is about to change, if asked to
+	if options != nil && options.Diff != nil {
+		writeDiff(options.Diff, filepath, sessionSection, values, keyNames)
+	}
+
+	// Take a copy of the file before we destroy the long term credentials, if asked to
+	if options != nil && options.InPlace && options.Backup {
+		if err = backupFile(filepath); err != nil {
+			return nil, fmt.Errorf("Could not back up credentials file %s: %v", filepath, err)
+		}
+		result.Backup = filepath + BackupSuffix
+	}
+
+	// Set the section key/values under whatever names we have been asked to use, removing
+	// any stale expiration if we do not know when these credentials expire, and leaving
+	// the rest of the file as it was. Save the file and we are done.
+	if err = writeSection(filepath, content, result.Section, values); err != nil {
+		return nil, err
+	}
+	result.Written = true
+	return result, nil
+}
+
+// RemoveSessionSectionFromFile removes the session section of the given profile, e.g.
+// [default-session] for the default profile, from the given credentials file, however
+// much longer its session has to run, returning true if there was one to remove. The
+// profile's own section, and with it the long term credentials and MFA device ID, is never
+// touched, nor is the rest of the file. The file is locked while it is read and rewritten,
+// and not written at all if there is no such section.
+func RemoveSessionSectionFromFile(filepath, profile string) (bool, error) {
+
+	// If the path lists several files, it is the last that we write to
+	filepath = WritableCredentialsPath(filepath)
+	if err := checkDefaultPath(filepath); err != nil {
+		return false, err
+	}
 
-	// Load the current file contents
-	cfg, err := ini.Load(filepath)
+	// Make sure that nobody else changes the file between our reading and writing it
+	unlock, err := credentialsStorage.Lock(filepath)
+	if err != nil {
+		return false, fmt.Errorf("Could not lock credentials file %s: %v", filepath, err)
+	}
+	defer unlock()
+	content, err := credentialsStorage.ReadFile(filepath)
+	if err != nil {
+		return false, fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
+	}
+
+	// Drop the section if it is there
+	edited, removed := removeSection(content, SessionSectionNameFor(profile))
+	if !removed {
+		return false, nil
+	}
+	if err = credentialsStorage.WriteFile(filepath, edited, credentialsFileMode); err != nil {
+		return false, fmt.Errorf("Could not write to credentials file %s: %v", filepath, err)
+	}
+	return true, nil
+}
+
+// keyValue pairs a key name with the value to be written under it, kept in a slice
+// rather than a map so that keys are always written in the same order.
+type keyValue struct {
+	name  string
+	value string
+}
+
+// profileSettings returns the keys and values of the named profile section of the given
+// file other than its credentials, under the standard or the given key names, and its
+// MFA device ID. If there is no such section, there are no settings.
+func profileSettings(cfg *ini.File, profile string, keyNames KeyNames) []keyValue {
+
+	// No profile, no settings
+	section, err := cfg.GetSection(profile)
+	if err != nil {
+		return nil
+	}
+
+	// Collect everything that is neither secret nor specific to the long term credentials
+	excluded := map[string]bool{
+		AccessKeyIDKey: true, SecretAccessKeyKey: true, SessionTokenKey: true, SessionExpirationKey: true, SessionTokenEncryptedKey: true, MfaDeviceIDKey: true, MfaSerialKey: true, MfaTOTPSecretKey: true,
+		keyNames.AccessKeyID: true, keyNames.SecretAccessKey: true, keyNames.SessionToken: true, keyNames.Expiration: true,
+	}
+	var settings []keyValue
+	for _, key := range section.Keys() {
+		if !excluded[key.Name()] && len(key.Value()) != 0 {
+			settings = append(settings, keyValue{key.Name(), key.Value()})
+		}
+	}
+	return settings
+}
+
+// encryptedSessionToken returns the values of the usual session token key, empty so as to
+// remove it, and of SessionTokenEncryptedKey that save the given session token encrypted
+// with the passphrase. If the section already holds the same token, encrypted with the same
+// passphrase, that encryption is kept, since a new one would differ and rewrite the file.
+func encryptedSessionToken(section *ini.Section, passphrase, sessionToken string) (string, string, error) {
+	existing := section.Key(SessionTokenEncryptedKey).Value()
+	if decrypted, err := DecryptValue(passphrase, existing); err == nil && decrypted == sessionToken {
+		return "", existing, nil
+	}
+	encrypted, err := EncryptValue(passphrase, sessionToken)
+	return "", encrypted, err
+}
+
+// sectionHolds returns true if the given section has exactly the given key values, an
+// empty value meaning that the key should be absent.
+func sectionHolds(section *ini.Section, values []keyValue) bool {
+	for _, kv := range values {
+		if section.HasKey(kv.name) != (len(kv.value) != 0) || section.Key(kv.name).Value() != kv.value {
+			return false
+		}
+	}
+	return true
+}
+
+// writeDiff writes the changes that setting the given key values will make to the given
+// section: the old value of each key that is to change, prefixed with -, and the new
+// value, prefixed with +. Secret access keys and session tokens are masked, leaving only
+// their last four characters, so that the record is safe to keep.
+func writeDiff(w io.Writer, filepath string, section *ini.Section, values []keyValue, keyNames KeyNames) {
+	secret := map[string]bool{keyNames.SecretAccessKey: true, keyNames.SessionToken: true, SessionTokenEncryptedKey: true}
+	show := func(name, value string) string {
+		if secret[name] {
+			return maskValue(value)
+		}
+		return value
+	}
+	fmt.Fprintf(w, "Changes to [%s] in %s:\n", section.Name(), filepath)
+	for _, kv := range values {
+		had, old := section.HasKey(kv.name), ""
+		if had {
+			old = section.Key(kv.name).Value()
+		}
+		if had == (len(kv.value) != 0) && old == kv.value {
+			continue
+		}
+		if had {
+			fmt.Fprintf(w, "- %s = %s\n", kv.name, show(kv.name, old))
+		}
+		if len(kv.value) != 0 {
+			fmt.Fprintf(w, "+ %s = %s\n", kv.name, show(kv.name, kv.value))
+		}
+	}
+}
+
+// maskValue returns the given secret with all but its last four characters replaced by
+// asterisks, or entirely replaced if it is too short to give even that much away.
+func maskValue(value string) string {
+	if len(value) <= 8 {
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
+
+// SaveMFADeviceID writes the given MFA device ID / serial number to the default section
+// of the AWS credentials file found by ResolveCredentialsPath(..), normally
+// $HOME/.aws/credentials, so that it can be found there the next time it is needed.
+func SaveMFADeviceID(mfaDeviceID string) error {
+	return SaveMFADeviceIDToFile(ResolveCredentialsPath(""), mfaDeviceID)
+}
+
+// SaveMFADeviceIDToFile writes the given MFA device ID / serial number to the default
+// section of the given AWS credentials file.
+func SaveMFADeviceIDToFile(filepath, mfaDeviceID string) error {
+	return SaveProfileMFADeviceIDToFile(filepath, DefaultSectionName, mfaDeviceID)
+}
+
+// SaveProfileMFADeviceIDToFile writes the given MFA device ID / serial number to the
+// named profile section of the given AWS credentials file or, if the path lists several
+// files, the last of them.
+func SaveProfileMFADeviceIDToFile(filepath, profile, mfaDeviceID string) error {
+	return saveProfileKey(filepath, profile, MfaDeviceIDKey, mfaDeviceID)
+}
+
+// SaveProfileTOTPSecretToFile writes the given base32 secret of a virtual MFA device to
+// the named profile section of the given AWS credentials file or, if the path lists
+// several files, the last of them, so that MFA codes can be generated from it.
+func SaveProfileTOTPSecretToFile(filepath, profile, secret string) error {
+	return saveProfileKey(filepath, profile, MfaTOTPSecretKey, secret)
+}
+
+// saveProfileKey writes the given key value to the named profile section of the given AWS
+// credentials file or, if the path lists several files, the last of them.
+func saveProfileKey(filepath, profile, name, value string) error {
+
+	// If the path lists several files, it is the last that we write to
+	filepath = WritableCredentialsPath(filepath)
+	if err := checkDefaultPath(filepath); err != nil {
+		return err
+	}
+
+	// Make sure that nobody else changes the file between our loading and saving it
+	unlock, err := credentialsStorage.Lock(filepath)
+	if err != nil {
+		return fmt.Errorf("Could not lock credentials file %s: %v", filepath, err)
+	}
+	defer unlock()
+
+	// Load the current file contents, making sure that they are a file that we can edit
+	content, err := credentialsStorage.ReadFile(filepath)
+	if err == nil {
+		_, err = loadINI(content)
+	}
 	if err != nil {
 		return fmt.Errorf("Could not read from credentials file %s: %v", filepath, err)
 	}
 
-	// Either load any previously existing session or create a new one with the required name
-	sessionSection := cfg.Section(SessionSectionName)
+	// Set the key in the profile section, replacing any previous value, and save the file
+	return writeSection(filepath, content, profile, []keyValue{{name, value}})
+}
+
+// backupFile copies the given file to a file of the same name with BackupSuffix appended,
+// replacing any previous backup. The copy is given the same permissions as the original.
+func backupFile(filepath string) error {
 
-	// Set the section key/values
-	sessionSection.NewKey(AccessKeyIDKey, *accessKeyID)
-	sessionSection.NewKey(SecretAccessKeyKey, *secretAccessKey)
-	sessionSection.NewKey(SessionTokenKey, *sessionToken)
+	// Find out what permissions the original has and read its contents
+	perm, err := credentialsStorage.Perm(filepath)
+	if err != nil {
+		return err
+	}
+	contents, err := credentialsStorage.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+
+	// Write the copy, making sure that an existing backup ends up with the right permissions
+	backupPath := filepath + BackupSuffix
+	if err = credentialsStorage.WriteFile(backupPath, contents, perm); err != nil {
+		return err
+	}
+	return credentialsStorage.Chmod(backupPath, perm)
+}
 
-	// Save the file and we are done
-	return cfg.SaveTo(filepath)
+// SectionName returns the name of the section that session credentials are to be saved to.
+func (options *SaveOptions) SectionName() string {
+	profile := options.profileName()
+	if options != nil && options.InPlace {
+		return profile
+	}
+	return SessionSectionNameFor(profile)
+}
+
+// profileName returns the name of the profile whose session is being saved.
+func (options *SaveOptions) profileName() string {
+	if options != nil && len(options.Profile) != 0 {
+		return options.Profile
+	}
+	return DefaultSectionName
+}
+
+// ResolvedKeyNames returns the key names to be used when saving credentials, filling in
+// the standard AWS names for any that have not been given.
+func (options *SaveOptions) ResolvedKeyNames() KeyNames {
+
+	// Start with the standard names and override them with any given to us
+	keyNames := KeyNames{
+		AccessKeyID:     AccessKeyIDKey,
+		SecretAccessKey: SecretAccessKeyKey,
+		SessionToken:    SessionTokenKey,
+		Expiration:      SessionExpirationKey,
+	}
+	if options == nil {
+		return keyNames
+	}
+	if len(options.KeyNames.AccessKeyID) != 0 {
+		keyNames.AccessKeyID = options.KeyNames.AccessKeyID
+	}
+	if len(options.KeyNames.SecretAccessKey) != 0 {
+		keyNames.SecretAccessKey = options.KeyNames.SecretAccessKey
+	}
+	if len(options.KeyNames.SessionToken) != 0 {
+		keyNames.SessionToken = options.KeyNames.SessionToken
+	}
+	if len(options.KeyNames.Expiration) != 0 {
+		keyNames.Expiration = options.KeyNames.Expiration
+	}
+	return keyNames
 }
@@ -8,10 +8,13 @@ package mfile
 // unit tests for the write.go functions.
 
 import (
+	"bytes"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
-	"gopkg.in/ini.v1"
 )
 
 // TestSaveSessionCredentials examines the happy path where session credentials are stored
@@ -28,7 +31,7 @@ func TestSaveSessionCredentials(t *testing.T) {
 	firstAccessKey := "key_1"
 	firstSecret := "secret_1"
 	firstToken := "token_1"
-	err := SaveSessionCredentials(&firstAccessKey, &firstSecret, &firstToken)
+	_, err := SaveSessionCredentials(&firstAccessKey, &firstSecret, &firstToken)
 	require.Nil(t, err, "there should not have been an error (first save)")
 
 	// Confirm that the session values were written
@@ -39,13 +42,437 @@ func TestSaveSessionCredentials(t *testing.T) {
 	secondAccessKey := "key_1"
 	secondSecret := "secret_1"
 	secondToken := "token_1"
-	err = SaveSessionCredentials(&secondAccessKey, &secondSecret, &secondToken)
+	_, err = SaveSessionCredentials(&secondAccessKey, &secondSecret, &secondToken)
 	require.Nil(t, err, "there should not have been an error (second save)")
 
 	// Confirm that the session values were written
 	verifyConfiguration(t, secondAccessKey, secondSecret, secondToken)
 }
 
+// TestSaveSessionCredentialValues confirms that credentials held as plain strings can be
+// saved, with options, just as those held by pointer can.
+func TestSaveSessionCredentialValues(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with known contents
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+
+	result, err := SaveSessionCredentialValues("key_1", "secret_1", "token_1")
+	require.Nil(t, err, "there should not have been an error")
+	require.True(t, result.Written, "the file should have been written")
+	verifyConfiguration(t, "key_1", "secret_1", "token_1")
+
+	expiration := time.Date(2020, 4, 1, 13, 0, 0, 0, time.UTC)
+	result, err = SaveSessionCredentialValuesToFile(fakeCredentialsFilePath, &SaveOptions{Expiration: &expiration}, "key_2", "secret_2", "token_2")
+	require.Nil(t, err, "there should not have been an error")
+	require.True(t, result.Written, "the file should have been written")
+	verifyConfiguration(t, "key_2", "secret_2", "token_2")
+	saved, err := GetSavedSessionFromFile(fakeCredentialsFilePath, nil)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, expiration, *saved.Expiration, "the expiration should have been saved")
+}
+
+// TestSaveMergedFiles confirms that, given several credentials files, they are read as
+// one with later files winning, and that only the last is written to.
+func TestSaveMergedFiles(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// The MFA device ID and region come from a shared file, the keys from the personal one
+	setFakeCredentials(DefaultSectionName, "")
+	shared := "[default]\n" + MfaDeviceIDKey + " = " + fakeMFADeviceID + "\nregion = eu-west-1\n" + AccessKeyIDKey + " = SHARED\n"
+	require.Nil(t, credentialsStorage.WriteFile(sharedCredentialsFilePath, []byte(shared), 0600), "could not write the shared file")
+	list := sharedCredentialsFilePath + string(os.PathListSeparator) + fakeCredentialsFilePath
+
+	mfaDeviceID, err := GetProfileMFADeviceIDFromFile(list, DefaultSectionName)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, fakeMFADeviceID, mfaDeviceID, "the MFA device ID should have come from the shared file")
+	keys, err := GetProfileKeysFromFile(list, DefaultSectionName)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, fakeAccessKeyID, keys[AccessKeyIDKey], "the personal file should have won")
+
+	// Save, copying the settings
+	_, err = SaveSessionCredentialValuesToFile(list, &SaveOptions{CopyProfileSettings: true}, "key_1", "secret_1", "token_1")
+	require.Nil(t, err, "there should not have been an error")
+	verifyConfiguration(t, "key_1", "secret_1", "token_1")
+	cfg, err := loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not load the personal file")
+	require.Equal(t, "eu-west-1", cfg.Section(SessionSectionName).Key("region").Value(), "the shared region should have been copied")
+	content, err := credentialsStorage.ReadFile(sharedCredentialsFilePath)
+	require.Nil(t, err, "could not read the shared file")
+	require.Equal(t, shared, string(content), "the shared file should not have been touched")
+}
+
+// TestSavePreservesLayout confirms that saving a session, and then saving it again over the
+// top, leaves the order, comments, and spacing of the rest of the file byte for byte alone.
+func TestSavePreservesLayout(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	setFakeCredentials(DefaultSectionName, "")
+	organized := "# My credentials, in the order that I like them\n\n; work comes first\n[work]\naws_access_key_id=AKIAWORK\naws_secret_access_key = work-secret # rotated in May\nregion  =  eu-west-1\n\n[default]\n# the sandbox account\n" +
+		AccessKeyIDKey + " = " + fakeAccessKeyID + "\n" + SecretAccessKeyKey + " = " + fakeSecretAccessKey + "\n\n# keep these last\n[zeta]\nx=1\n"
+	require.Nil(t, credentialsStorage.WriteFile(fakeCredentialsFilePath, []byte(organized), 0600), "could not write the credentials file")
+
+	_, err := SaveSessionCredentialValuesToFile(fakeCredentialsFilePath, nil, "key_1", "secret_1", "token_1")
+	require.Nil(t, err, "there should not have been an error")
+	content, err := credentialsStorage.ReadFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not read the credentials file")
+	session := "\n[" + SessionSectionName + "]\n" + AccessKeyIDKey + " = key_1\n" + SecretAccessKeyKey + " = secret_1\n" + SessionTokenKey + " = token_1\n"
+	require.Equal(t, organized+session, string(content), "the file should only have gained the session section")
+	verifyConfiguration(t, "key_1", "secret_1", "token_1")
+
+	// Saving over the top changes nothing but the values
+	_, err = SaveSessionCredentialValuesToFile(fakeCredentialsFilePath, nil, "key_2", "secret_2", "token_2")
+	require.Nil(t, err, "there should not have been an error")
+	content, _ = credentialsStorage.ReadFile(fakeCredentialsFilePath)
+	require.Equal(t, organized+strings.Replace(session, "_1", "_2", -1), string(content), "only the session values should have changed")
+
+	// As does recording an MFA device ID
+	require.Nil(t, SaveProfileMFADeviceIDToFile(fakeCredentialsFilePath, "work", fakeMFADeviceID), "there should not have been an error")
+	content, _ = credentialsStorage.ReadFile(fakeCredentialsFilePath)
+	require.Equal(t, strings.Replace(organized, "region  =  eu-west-1\n", "region  =  eu-west-1\n"+MfaDeviceIDKey+" = "+fakeMFADeviceID+"\n", 1)+strings.Replace(session, "_1", "_2", -1), string(content),
+		"the MFA device ID should have been added after the work profile's keys")
+}
+
+// TestSaveKeyOrder confirms that a session section is written with its keys in the order,
+// and the key = value form, that aws configure set writes them in, and that keys missing
+// from an existing section are added after those it has, as aws configure set adds them.
+func TestSaveKeyOrder(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	setFakeCredentials(DefaultSectionName, "")
+	original, err := credentialsStorage.ReadFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "could not read the credentials file")
+	expiration := time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	_, err = SaveSessionCredentialValuesToFile(fakeCredentialsFilePath, &SaveOptions{Expiration: &expiration}, "key_1", "secret_1", "token_1")
+	require.Nil(t, err, "there should not have been an error")
+	content, _ := credentialsStorage.ReadFile(fakeCredentialsFilePath)
+	require.Equal(t, string(original)+"\n["+SessionSectionName+"]\n"+
+		AccessKeyIDKey+" = key_1\n"+
+		SecretAccessKeyKey+" = secret_1\n"+
+		SessionTokenKey+" = token_1\n"+
+		SessionExpirationKey+" = 2020-04-01T12:00:00Z\n", string(content), "the session keys are not in the aws configure set order")
+
+	// A section that aws configure set has started keeps its keys where they are
+	started := "[" + SessionSectionName + "]\n" + SecretAccessKeyKey + " = old\nregion = us-east-1\n"
+	require.Nil(t, credentialsStorage.WriteFile(fakeCredentialsFilePath, []byte(started), 0600), "could not write the credentials file")
+	_, err = SaveSessionCredentialValuesToFile(fakeCredentialsFilePath, nil, "key_2", "secret_2", "token_2")
+	require.Nil(t, err, "there should not have been an error")
+	content, _ = credentialsStorage.ReadFile(fakeCredentialsFilePath)
+	require.Equal(t, "["+SessionSectionName+"]\n"+
+		SecretAccessKeyKey+" = secret_2\n"+
+		"region = us-east-1\n"+
+		AccessKeyIDKey+" = key_2\n"+
+		SessionTokenKey+" = token_2\n", string(content), "the missing keys should have been added after the others")
+}
+
+// TestSaveWithCustomKeyNames confirms that the session credentials can be written under
+// key names other than the AWS standard ones, with any not given left as standard.
+func TestSaveWithCustomKeyNames(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with known contents
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+
+	// Write the credentials under two custom names and one standard one
+	accessKey := "key_1"
+	secret := "secret_1"
+	token := "token_1"
+	expiration := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	options := &SaveOptions{KeyNames: KeyNames{AccessKeyID: "my_key", SessionToken: "my_token", Expiration: "x_security_token_expires"}, Expiration: &expiration}
+	_, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+
+	// Confirm that the values were written under the expected names
+	cfg, err := loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	sessionSection := cfg.Section(SessionSectionName)
+	require.Equal(t, accessKey, sessionSection.Key("my_key").Value(), "access key not written under custom name")
+	require.Equal(t, secret, sessionSection.Key(SecretAccessKeyKey).Value(), "secret not written under standard name")
+	require.Equal(t, token, sessionSection.Key("my_token").Value(), "token not written under custom name")
+	require.False(t, sessionSection.HasKey(AccessKeyIDKey), "access key should not have been written under the standard name")
+	require.Equal(t, "2020-04-01T12:00:00Z", sessionSection.Key("x_security_token_expires").Value(), "expiration not written under custom name")
+	require.False(t, sessionSection.HasKey(SessionExpirationKey), "expiration should not have been written under the standard name")
+
+	// And they are read back from under those names too
+	saved, err := GetSavedSessionFromFile(fakeCredentialsFilePath, options)
+	require.Nil(t, err, "there should not have been an error reading")
+	require.Equal(t, token, saved.SessionToken, "token not read from under custom name")
+	require.True(t, expiration.Equal(*saved.Expiration), "expiration not read from under custom name")
+}
+
+// TestSaveEncrypted confirms that, given a passphrase, the session token is saved encrypted
+// in place of the usual key, read back with the passphrase, and left encrypted without it.
+func TestSaveEncrypted(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with known contents
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+
+	// Save the credentials with a passphrase
+	accessKey := "key_1"
+	secret := "secret_1"
+	token := "token_1"
+	options := &SaveOptions{Passphrase: "correct horse"}
+	_, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+
+	// Only the session token is hidden
+	cfg, err := loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	sessionSection := cfg.Section(SessionSectionName)
+	require.Equal(t, accessKey, sessionSection.Key(AccessKeyIDKey).Value(), "access key not written")
+	require.False(t, sessionSection.HasKey(SessionTokenKey), "the session token should not have been written as is")
+	require.NotEmpty(t, sessionSection.Key(SessionTokenEncryptedKey).Value(), "the encrypted session token was not written")
+
+	// The same credentials again leave the file alone, for all that a new encryption differs
+	result, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+	require.False(t, result.Written, "the file should not have been rewritten")
+
+	// Read back with the passphrase, without it, and with the wrong one
+	saved, err := GetSavedSessionFromFile(fakeCredentialsFilePath, options)
+	require.Nil(t, err, "there should not have been an error reading")
+	require.Equal(t, token, saved.SessionToken, "the session token was not decrypted")
+	require.True(t, saved.Encrypted, "the session should be marked as encrypted")
+	saved, err = GetSavedSessionFromFile(fakeCredentialsFilePath, nil)
+	require.Nil(t, err, "there should not have been an error reading")
+	require.Empty(t, saved.SessionToken, "the session token cannot be decrypted without the passphrase")
+	require.True(t, saved.Encrypted, "the session should be marked as encrypted")
+	_, err = GetSavedSessionFromFile(fakeCredentialsFilePath, &SaveOptions{Passphrase: "battery staple"})
+	require.Equal(t, ErrWrongPassphrase, err, "the wrong passphrase should have been reported")
+
+	// Saving without a passphrase puts the token back as is
+	_, err = SaveSessionCredentialsToFile(fakeCredentialsFilePath, nil, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+	verifyConfiguration(t, accessKey, secret, token)
+	cfg, err = loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	require.False(t, cfg.Section(SessionSectionName).HasKey(SessionTokenEncryptedKey), "the encrypted session token should have been removed")
+}
+
+// TestSaveCopyingProfileSettings confirms that the profile's settings, but not its
+// credentials or MFA device ID, are copied into the session section when asked for.
+func TestSaveCopyingProfileSettings(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with a region and output format in the profile
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	cfg, err := loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	cfg.Section(DefaultSectionName).NewKey(RegionKey, "eu-west-1")
+	cfg.Section(DefaultSectionName).NewKey("output", "json")
+	require.Nil(t, saveFakeFile(cfg, fakeCredentialsFilePath), "error writing the test credentials file")
+
+	// Write the credentials, copying the settings
+	accessKey := "key_1"
+	secret := "secret_1"
+	token := "token_1"
+	options := &SaveOptions{CopyProfileSettings: true}
+	result, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+	require.True(t, result.Written, "the file should have been written")
+
+	// Confirm that the session section is self-contained
+	cfg, err = loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	sessionSection := cfg.Section(SessionSectionName)
+	require.Equal(t, "eu-west-1", sessionSection.Key(RegionKey).Value(), "the region should have been copied")
+	require.Equal(t, "json", sessionSection.Key("output").Value(), "the output format should have been copied")
+	require.Equal(t, secret, sessionSection.Key(SecretAccessKeyKey).Value(), "the session secret should not have been replaced")
+	require.False(t, sessionSection.HasKey(MfaDeviceIDKey), "the MFA device ID should not have been copied")
+
+	// And saving the same again should leave the file alone
+	result, err = SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+	require.False(t, result.Written, "the file should not have been written again")
+}
+
+// TestSaveWithDiff confirms that the changes to the section are shown before it is
+// written, with secrets masked, and that nothing is shown when nothing changes.
+func TestSaveWithDiff(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Save a first session, remembering when it expires
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	accessKey, secret, token := "key_1", "secret_value_1", "token_value_1"
+	expiration := time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	var diff bytes.Buffer
+	options := &SaveOptions{Expiration: &expiration, Diff: &diff}
+	_, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "Changes to [default-session] in "+fakeCredentialsFilePath+":\n"+
+		"+ aws_access_key_id = key_1\n"+
+		"+ aws_secret_access_key = ****ue_1\n"+
+		"+ aws_session_token = ****ue_1\n"+
+		"+ aws_session_expiration = 2020-04-01T12:00:00Z\n", diff.String(), "unexpected diff for a new section")
+
+	// Replace the session token and drop the expiration
+	diff.Reset()
+	token2 := "token_value_2"
+	options.Expiration = nil
+	_, err = SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token2)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "Changes to [default-session] in "+fakeCredentialsFilePath+":\n"+
+		"- aws_session_token = ****ue_1\n"+
+		"+ aws_session_token = ****ue_2\n"+
+		"- aws_session_expiration = 2020-04-01T12:00:00Z\n", diff.String(), "unexpected diff for a changed section")
+
+	// No changes, no diff
+	diff.Reset()
+	_, err = SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token2)
+	require.Nil(t, err, "there should not have been an error")
+	require.Empty(t, diff.String(), "there should have been nothing to show")
+	require.Equal(t, "****", maskValue("short"), "short secrets should be masked entirely")
+}
+
+// TestSaveInPlace confirms that session credentials can be written over the long term
+// credentials in the default section, leaving the MFA device ID in place.
+func TestSaveInPlace(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with known contents
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+
+	// Write the credentials in place
+	accessKey := "key_1"
+	secret := "secret_1"
+	token := "token_1"
+	_, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, &SaveOptions{InPlace: true}, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+
+	// Confirm that the default section now holds the session credentials and nothing else changed
+	cfg, err := loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	defaultSection := cfg.Section(DefaultSectionName)
+	require.Equal(t, accessKey, defaultSection.Key(AccessKeyIDKey).Value(), "access key not written in place")
+	require.Equal(t, secret, defaultSection.Key(SecretAccessKeyKey).Value(), "secret not written in place")
+	require.Equal(t, token, defaultSection.Key(SessionTokenKey).Value(), "token not written in place")
+	require.Equal(t, fakeMFADeviceID, defaultSection.Key(MfaDeviceIDKey).Value(), "the MFA device ID should have been preserved")
+	_, err = cfg.GetSection(SessionSectionName)
+	require.NotNil(t, err, "no session section should have been created")
+}
+
+// TestSaveInPlaceWithBackup confirms that the credentials file is copied, long term
+// credentials and all, before an in place write when a backup is asked for.
+func TestSaveInPlaceWithBackup(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with known contents and no stale backup
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	backupPath := fakeCredentialsFilePath + BackupSuffix
+	original, err := credentialsStorage.ReadFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+
+	// Write the credentials in place with a backup
+	accessKey := "key_1"
+	secret := "secret_1"
+	token := "token_1"
+	options := &SaveOptions{InPlace: true, Backup: true}
+	_, err = SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+
+	// The backup should hold the original contents
+	backup, err := credentialsStorage.ReadFile(backupPath)
+	require.Nil(t, err, "the backup file should have been created")
+	require.Equal(t, original, backup, "the backup should match the original file")
+}
+
+// TestSaveInPlaceWithoutBackup confirms that no backup is taken unless asked for.
+func TestSaveInPlaceWithoutBackup(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with known contents and no stale backup
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	backupPath := fakeCredentialsFilePath + BackupSuffix
+
+	// Write the credentials in place without a backup
+	accessKey := "key_1"
+	secret := "secret_1"
+	token := "token_1"
+	_, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, &SaveOptions{InPlace: true}, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error")
+	_, err = credentialsStorage.ReadFile(backupPath)
+	require.True(t, os.IsNotExist(err), "no backup file should have been created")
+}
+
+// TestSaveUnchanged confirms that the file is only written when the session credentials
+// or their expiration change, and that they can be read back.
+func TestSaveUnchanged(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with known contents
+	store := setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+
+	// The first save has to write the file
+	accessKey := "key_1"
+	secret := "secret_1"
+	token := "token_1"
+	expiration := time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	options := &SaveOptions{Expiration: &expiration}
+	result, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error (first save)")
+	require.True(t, result.Written, "the first save should have written the file")
+	require.True(t, result.Created, "the first save should have created the section")
+	require.Equal(t, fakeCredentialsFilePath, result.Filepath, "the result should name the file")
+	require.Equal(t, SessionSectionName, result.Section, "the result should name the section")
+
+	// What was saved can be read back
+	saved, err := GetSavedSessionFromFile(fakeCredentialsFilePath, options)
+	require.Nil(t, err, "there should not have been an error reading the saved session")
+	require.Equal(t, &SavedSession{AccessKeyID: accessKey, SecretAccessKey: secret, SessionToken: token, Expiration: &expiration}, saved)
+
+	// Saving the same thing again should not write to the file at all
+	before := store.writes
+	result, err = SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error (second save)")
+	require.False(t, result.Written, "an unchanged save should not have written the file")
+	require.Equal(t, before, store.writes, "the file should not have been touched")
+
+	// But a change of expiration alone is worth writing
+	later := expiration.Add(time.Hour)
+	options.Expiration = &later
+	result, err = SaveSessionCredentialsToFile(fakeCredentialsFilePath, options, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error (third save)")
+	require.True(t, result.Written, "a changed expiration should have been written")
+	require.False(t, result.Created, "a changed expiration should have updated the existing section")
+}
+
+// TestGetSavedSessionMissing confirms that no saved session is found in a file without one.
+func TestGetSavedSessionMissing(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	saved, err := GetSavedSessionFromFile(fakeCredentialsFilePath, nil)
+	require.Nil(t, err, "there should not have been an error")
+	require.Nil(t, saved, "there should have been no saved session")
+}
+
 // TestSaveToNonExistentFile looks at the sad path where the supposedly pre-existing
 // AWS credentials file does not, in fact, exist
 func TestSaveToNonExistentFile(t *testing.T) {
@@ -60,7 +487,59 @@ func TestSaveToNonExistentFile(t *testing.T) {
 	firstAccessKey := "key_1"
 	firstSecret := "secret_1"
 	firstToken := "token_1"
-	err := SaveSessionCredentials(&firstAccessKey, &firstSecret, &firstToken)
+	_, err := SaveSessionCredentials(&firstAccessKey, &firstSecret, &firstToken)
+	require.NotNil(t, err, "saving to a non-existent file should have failed")
+}
+
+// TestSaveMFADeviceID confirms that an MFA device ID can be added to a file that does not
+// have one, and can then be read back.
+func TestSaveMFADeviceID(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a fake credentials file without an MFA device ID
+	setFakeCredentials(DefaultSectionName, "")
+
+	// Save an ID and read it back
+	err := SaveMFADeviceID(fakeMFADeviceID)
+	require.Nil(t, err, "there should not have been an error")
+	id, err := GetMFADeviceID()
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, fakeMFADeviceID, id, "not the expected MFA device ID")
+
+	// The long term credentials should have been left alone
+	cfg, err := loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	require.Equal(t, fakeAccessKeyID, cfg.Section(DefaultSectionName).Key(AccessKeyIDKey).Value(), "the access key ID should not have changed")
+}
+
+// TestSaveProfileTOTPSecret confirms that a virtual MFA device secret is saved to the named
+// profile and can be read back.
+func TestSaveProfileTOTPSecret(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	require.Nil(t, SaveProfileTOTPSecretToFile(fakeCredentialsFilePath, DefaultSectionName, "GEZDGNBVGY3TQOJQ"), "there should not have been an error")
+	secret, err := GetProfileTOTPSecretFromFile(fakeCredentialsFilePath, DefaultSectionName)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "GEZDGNBVGY3TQOJQ", secret, "unexpected secret")
+
+	require.NotNil(t, SaveProfileTOTPSecretToFile("./missing.test", DefaultSectionName, "GEZDGNBVGY3TQOJQ"), "a missing file should have been an error")
+}
+
+// TestSaveMFADeviceIDToNonExistentFile looks at the sad path where the supposedly
+// pre-existing AWS credentials file does not, in fact, exist
+func TestSaveMFADeviceIDToNonExistentFile(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Have the package think the credentials file is in a place where there are no files
+	OverrideDefaultCredentialsFilepath("/you/got/no/skin/on/me-cos-i-do-not-exist")
+	err := SaveMFADeviceID(fakeMFADeviceID)
 	require.NotNil(t, err, "saving to a non-existent file should have failed")
 }
 
@@ -69,7 +548,7 @@ func TestSaveToNonExistentFile(t *testing.T) {
 func verifyConfiguration(t *testing.T, accessKeyID, secretAccessKey, sessionToken string) {
 
 	// Load the current file contents
-	cfg, err := ini.Load(fakeCredentialsFilePath)
+	cfg, err := loadFakeFile(fakeCredentialsFilePath)
 	require.Nil(t, err, "error reading the test credentials file")
 
 	// Confirm thet the default credentials are set as expected
@@ -85,3 +564,58 @@ func verifyConfiguration(t *testing.T, accessKeyID, secretAccessKey, sessionToke
 	require.Equal(t, sessionSection.Key(SecretAccessKeyKey).Value(), secretAccessKey, "default-session section, unexpected secret key value: [%s]", defaultSection.Key(SecretAccessKeyKey).Value())
 	require.Equal(t, sessionSection.Key(SessionTokenKey).Value(), sessionToken, "default-session section, unexpected session token value: [%s]", defaultSection.Key(SessionTokenKey).Value())
 }
+
+// TestProfileSections confirms that MFA device IDs and sessions can be saved for, and read
+// from, profiles other than the default.
+func TestProfileSections(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with known contents
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+
+	// The MFA device ID of another profile goes to that profile alone
+	err := SaveProfileMFADeviceIDToFile(fakeCredentialsFilePath, "other", "arn:aws:iam::123456789012:mfa/other")
+	require.Nil(t, err, "there should not have been an error saving the MFA device ID")
+	id, err := GetProfileMFADeviceIDFromFile(fakeCredentialsFilePath, "other")
+	require.Nil(t, err, "there should not have been an error reading the MFA device ID")
+	require.Equal(t, "arn:aws:iam::123456789012:mfa/other", id, "not the expected MFA device ID")
+	id, err = GetMFADeviceIDFromFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "there should not have been an error reading the default MFA device ID")
+	require.Equal(t, fakeMFADeviceID, id, "the default MFA device ID should not have changed")
+
+	// Sessions for another profile are saved to that profile's session section
+	require.Equal(t, "other-session", (&SaveOptions{Profile: "other"}).SectionName())
+	require.Equal(t, "other", (&SaveOptions{Profile: "other", InPlace: true}).SectionName())
+	require.Equal(t, SessionSectionName, (*SaveOptions)(nil).SectionName())
+}
+
+// TestRemoveSessionSection confirms that a profile's session section can be removed,
+// leaving its long term credentials alone, and that removing it twice does no harm.
+func TestRemoveSessionSection(t *testing.T) {
+
+	// Revert the package state back to normal after the test has run
+	defer ResetPackageDefaults()
+
+	// Establish a virgin fake credentials file with a session saved in it
+	setFakeCredentials(DefaultSectionName, fakeMFADeviceID)
+	accessKey, secret, token := "key_1", "secret_1", "token_1"
+	_, err := SaveSessionCredentialsToFile(fakeCredentialsFilePath, nil, &accessKey, &secret, &token)
+	require.Nil(t, err, "there should not have been an error saving the session")
+
+	// Remove it, and it is gone while the default section remains
+	removed, err := RemoveSessionSectionFromFile(fakeCredentialsFilePath, DefaultSectionName)
+	require.Nil(t, err, "there should not have been an error")
+	require.True(t, removed, "the session section should have been removed")
+	cfg, err := loadFakeFile(fakeCredentialsFilePath)
+	require.Nil(t, err, "error reading the test credentials file")
+	_, err = cfg.GetSection(SessionSectionName)
+	require.NotNil(t, err, "the session section should have gone")
+	require.Equal(t, fakeMFADeviceID, cfg.Section(DefaultSectionName).Key(MfaDeviceIDKey).Value(), "the default section should have been left alone")
+
+	// There is nothing to remove a second time
+	removed, err = RemoveSessionSectionFromFile(fakeCredentialsFilePath, DefaultSectionName)
+	require.Nil(t, err, "there should not have been an error")
+	require.False(t, removed, "there should have been nothing to remove")
+}
@@ -0,0 +1,131 @@
+package store
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See store.go for overall package documentation. This file contains
+// the stores that are reached through the command line tools of the operating systems
+// that provide them: security on macOS and secret-tool on Linux.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// The function that runs a command, feeding it the given standard input and returning
+	// its standard output, overridden by unit tests. Set via SetRunCommandFunc(..) and
+	// restored by ResetPackageDefaults(..).
+	runCommandFunc func(stdin string, name string, args ...string) (string, error)
+
+	// errNotFound is returned by a runCommandFunc when the command reports that there is
+	// no such item
+	errNotFound = errors.New("item not found")
+)
+
+// SetRunCommandFunc allows unit tests to substitute a function of their own for the one
+// that runs the security and secret-tool commands.
+func SetRunCommandFunc(f func(stdin string, name string, args ...string) (string, error)) {
+	runCommandFunc = f
+}
+
+// MacOSKeychain is the macOS Keychain, reached through the security command. Credentials
+// are kept as generic passwords, the service being mafia and the account the profile.
+type MacOSKeychain struct{}
+
+// Save stores the given credentials for the profile, replacing any already there. The
+// command is fed to security on its standard input, rather than given as arguments, so
+// that the secret is not on display to anyone listing processes.
+func (MacOSKeychain) Save(profile string, credentials *Credentials) error {
+	secret, err := encode(credentials)
+	if err != nil {
+		return err
+	}
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		securityQuote(ServiceName), securityQuote(profile), securityQuote(secret))
+	if _, err = runCommandFunc(command, "security", "-i"); err != nil {
+		return fmt.Errorf("could not save the session credentials to the keychain: %v", err)
+	}
+	return nil
+}
+
+// Load returns the credentials stored for the profile, or nil without error if there
+// are none.
+func (MacOSKeychain) Load(profile string) (*Credentials, error) {
+	secret, err := runCommandFunc("", "security", "find-generic-password", "-s", ServiceName, "-a", profile, "-w")
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the session credentials from the keychain: %v", err)
+	}
+	return decode(strings.TrimSpace(secret))
+}
+
+// SecretService is the freedesktop.org Secret Service of GNOME Keyring, KWallet, and the
+// like, reached through the secret-tool command. Credentials are kept with the attributes
+// service=mafia and profile=<profile>.
+type SecretService struct{}
+
+// Save stores the given credentials for the profile, replacing any already there. The
+// secret is fed to secret-tool on its standard input, so that it is not on display to
+// anyone listing processes.
+func (SecretService) Save(profile string, credentials *Credentials) error {
+	secret, err := encode(credentials)
+	if err != nil {
+		return err
+	}
+	_, err = runCommandFunc(secret, "secret-tool", "store", "--label", ServiceName+" "+profile,
+		"service", ServiceName, "profile", profile)
+	if err != nil {
+		return fmt.Errorf("could not save the session credentials to the secret service: %v", err)
+	}
+	return nil
+}
+
+// Load returns the credentials stored for the profile, or nil without error if there
+// are none.
+func (SecretService) Load(profile string) (*Credentials, error) {
+	secret, err := runCommandFunc("", "secret-tool", "lookup", "service", ServiceName, "profile", profile)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the session credentials from the secret service: %v", err)
+	}
+	return decode(strings.TrimSpace(secret))
+}
+
+// runCommand runs the named command with the given standard input, returning its
+// standard output. Both security and secret-tool exit with a status of 1, and say nothing
+// or next to nothing, when there is no such item; that is reported as errNotFound.
+func runCommand(stdin string, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		message := strings.TrimSpace(stderr.String())
+		if exitErr.ExitCode() == 1 && (len(message) == 0 || strings.Contains(message, "could not be found")) {
+			return "", errNotFound
+		}
+		return "", fmt.Errorf("%s failed: %s", name, message)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not run %s: %v", name, err)
+	}
+	return stdout.String(), nil
+}
+
+// securityQuote returns the given value in double quotes, with any double quotes and
+// backslashes within it escaped, as the interactive mode of the security command expects.
+func securityQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
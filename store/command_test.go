@@ -0,0 +1,110 @@
+package store
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See store.go for overall package documentation. This file contains
+// unit tests for the command.go functions.
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCommand records the commands that it is asked to run, mimicking the security and
+// secret-tool commands with a single stored secret.
+type fakeCommand struct {
+	commands []string // One line per command run: the name, arguments, and standard input
+	secret   string   // The secret stored, empty for none
+}
+
+// run is the fake runCommandFunc.
+func (f *fakeCommand) run(stdin string, name string, args ...string) (string, error) {
+	f.commands = append(f.commands, strings.Join(append([]string{name}, args...), " ")+" <"+stdin)
+	switch {
+	case name == "secret-tool" && args[0] == "store":
+		f.secret = stdin
+	case name == "security" && args[0] == "-i":
+		f.secret = stdin[strings.Index(stdin, "-w ")+3 : len(stdin)-1]
+	case len(f.secret) == 0:
+		return "", errNotFound
+	default:
+		return f.secret + "\n", nil
+	}
+	return "", nil
+}
+
+// TestSecretService confirms that the Secret Service is given the secret on standard
+// input and that it can be read back.
+func TestSecretService(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+	fake := &fakeCommand{}
+	SetRunCommandFunc(fake.run)
+
+	// Nothing there to begin with
+	loaded, err := SecretService{}.Load("default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Nil(t, loaded, "there should have been nothing stored")
+
+	// Save and load
+	expiration := time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	credentials := &Credentials{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token", Expiration: &expiration}
+	require.Nil(t, SecretService{}.Save("default", credentials), "there should not have been an error")
+	require.True(t, strings.HasPrefix(fake.commands[1], "secret-tool store --label mafia default service mafia profile default <{"),
+		"unexpected command: %s", fake.commands[1])
+	loaded, err = SecretService{}.Load("default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, credentials, loaded, "the credentials should have been read back")
+}
+
+// TestMacOSKeychain confirms that security is given the secret in a command on standard
+// input, rather than as an argument, and that it can be read back.
+func TestMacOSKeychain(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+	fake := &fakeCommand{}
+	SetRunCommandFunc(fake.run)
+
+	// Save, with a quote to escape
+	credentials := &Credentials{AccessKeyID: "key", SecretAccessKey: `sec"ret`, SessionToken: "token"}
+	require.Nil(t, MacOSKeychain{}.Save("default", credentials), "there should not have been an error")
+	require.Equal(t, `security -i <add-generic-password -U -s "mafia" -a "default" -w "{\"AccessKeyId\":\"key\",\"SecretAccessKey\":\"sec\\\"ret\",\"SessionToken\":\"token\"}"`+"\n",
+		fake.commands[0], "unexpected command")
+
+	// Reading it back is done with arguments alone
+	fake.secret = `{"AccessKeyId":"key","SecretAccessKey":"sec\"ret","SessionToken":"token"}`
+	loaded, err := MacOSKeychain{}.Load("default")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, credentials, loaded, "the credentials should have been read back")
+	require.Equal(t, "security find-generic-password -s mafia -a default -w <", fake.commands[1], "unexpected command")
+}
+
+// TestRunCommand confirms that commands are fed their standard input and that an exit
+// status of 1 without explanation means that there is no such item.
+func TestRunCommand(t *testing.T) {
+
+	// We lean on the shell for our test commands
+	if _, err := exec.LookPath("sh"); err != nil || runtime.GOOS == "windows" {
+		t.Skip("there is no shell to run test commands with")
+	}
+
+	output, err := runCommand("hello", "sh", "-c", "cat")
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, "hello", output, "the standard input should have been echoed")
+
+	_, err = runCommand("", "sh", "-c", "exit 1")
+	require.Equal(t, errNotFound, err, "a silent exit status of 1 should mean not found")
+
+	_, err = runCommand("", "sh", "-c", "echo broken >&2; exit 2")
+	require.NotNil(t, err, "there should have been an error")
+	require.Contains(t, err.Error(), "broken", "the error should explain itself")
+}
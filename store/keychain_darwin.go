@@ -0,0 +1,16 @@
+//go:build darwin
+// +build darwin
+
+package store
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See store.go for overall package documentation. This file selects
+// the macOS Keychain as the secret store.
+
+// platformKeychain returns the macOS Keychain.
+func platformKeychain() (Store, error) {
+	return MacOSKeychain{}, nil
+}
@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package store
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See store.go for overall package documentation. This file selects
+// the Secret Service as the secret store on Linux.
+
+// platformKeychain returns the Secret Service.
+func platformKeychain() (Store, error) {
+	return SecretService{}, nil
+}
@@ -0,0 +1,21 @@
+//go:build !darwin && !linux && !windows
+// +build !darwin,!linux,!windows
+
+package store
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See store.go for overall package documentation. This file covers
+// the operating systems whose secret stores we do not know how to use.
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// platformKeychain returns an error; there is no secret store that we know of here.
+func platformKeychain() (Store, error) {
+	return nil, fmt.Errorf("there is no supported secret store on %s", runtime.GOOS)
+}
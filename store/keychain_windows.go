@@ -0,0 +1,106 @@
+//go:build windows
+// +build windows
+
+package store
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See store.go for overall package documentation. This file contains
+// the Windows Credential Manager store, reached through the advapi32 credential functions.
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// The values of the Windows constants that we need
+	credTypeGeneric         = 1    // CRED_TYPE_GENERIC
+	credPersistLocalMachine = 2    // CRED_PERSIST_LOCAL_MACHINE
+	errorNotFound           = 1168 // ERROR_NOT_FOUND
+)
+
+var (
+	// The credential functions of advapi32.dll
+	advapi32      = syscall.NewLazyDLL("advapi32.dll")
+	procCredWrite = advapi32.NewProc("CredWriteW")
+	procCredRead  = advapi32.NewProc("CredReadW")
+	procCredFree  = advapi32.NewProc("CredFree")
+)
+
+// credential mirrors the Windows CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// CredentialManager is the Windows Credential Manager. Credentials are kept as generic
+// credentials with the target name mafia:<profile>.
+type CredentialManager struct{}
+
+// platformKeychain returns the Windows Credential Manager.
+func platformKeychain() (Store, error) {
+	return CredentialManager{}, nil
+}
+
+// Save stores the given credentials for the profile, replacing any already there.
+func (CredentialManager) Save(profile string, credentials *Credentials) error {
+	secret, err := encode(credentials)
+	if err != nil {
+		return err
+	}
+	target, err := syscall.UTF16PtrFromString(ServiceName + ":" + profile)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(profile)
+	if err != nil {
+		return err
+	}
+	blob := []byte(secret)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     &blob[0],
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if ok, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(&cred)), 0); ok == 0 {
+		return fmt.Errorf("could not save the session credentials to the credential manager: %v", err)
+	}
+	return nil
+}
+
+// Load returns the credentials stored for the profile, or nil without error if there
+// are none.
+func (CredentialManager) Load(profile string) (*Credentials, error) {
+	target, err := syscall.UTF16PtrFromString(ServiceName + ":" + profile)
+	if err != nil {
+		return nil, err
+	}
+	var cred *credential
+	ok, _, err := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ok == 0 {
+		if errno, isErrno := err.(syscall.Errno); isErrno && errno == errorNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read the session credentials from the credential manager: %v", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	blob := (*[1 << 20]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize]
+	return decode(string(blob))
+}
@@ -0,0 +1,94 @@
+// Package store keeps session credentials in the operating system's secret store, as an
+// alternative to writing them in plain text to the AWS credentials file: the Keychain on
+// macOS, the Credential Manager on Windows, and the Secret Service on Linux.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// ServiceName is the service, or target prefix, that credentials are filed under in
+	// the secret store, alongside the profile that they belong to
+	ServiceName = "mafia"
+)
+
+// Credentials holds the session credentials kept in a store for a profile.
+type Credentials struct {
+	AccessKeyID     string     `json:"AccessKeyId"`
+	SecretAccessKey string     `json:"SecretAccessKey"`
+	SessionToken    string     `json:"SessionToken"`
+	Expiration      *time.Time `json:"Expiration,omitempty"` // Nil if the expiration is not known
+}
+
+// Store is implemented by each of the places that session credentials can be kept, keyed
+// by profile name.
+type Store interface {
+
+	// Save stores the given credentials for the profile, replacing any already there
+	Save(profile string, credentials *Credentials) error
+
+	// Load returns the credentials stored for the profile, or nil without error if
+	// there are none
+	Load(profile string) (*Credentials, error)
+}
+
+var (
+	// The function that returns the operating system's secret store, overridden by unit
+	// tests that must not touch the real one. Set via SetKeychainFunc(..) and restored by
+	// ResetPackageDefaults(..).
+	keychainFunc func() (Store, error)
+)
+
+// Load time initialization
+func init() {
+	ResetPackageDefaults()
+}
+
+// Keychain returns the secret store of the operating system that we are running on, or
+// an error if we do not know how to use one here.
+func Keychain() (Store, error) {
+	return keychainFunc()
+}
+
+// SetKeychainFunc allows unit tests to substitute a store of their own for the operating
+// system's secret store.
+func SetKeychainFunc(f func() (Store, error)) {
+	keychainFunc = f
+}
+
+// ResetPackageDefaults establishes or reestablishes the normal package global values.
+// This is called during package initialization and also by unit tests needing to
+// leave the package as they found it.
+func ResetPackageDefaults() {
+	keychainFunc = platformKeychain
+	runCommandFunc = runCommand
+}
+
+// encode returns the form in which credentials are kept as a single secret.
+func encode(credentials *Credentials) (string, error) {
+	secret, err := json.Marshal(credentials)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// decode recovers the credentials kept as a single secret, returning nil if they are
+// incomplete.
+func decode(secret string) (*Credentials, error) {
+	var credentials Credentials
+	if err := json.Unmarshal([]byte(secret), &credentials); err != nil {
+		return nil, fmt.Errorf("the stored credentials could not be read: %v", err)
+	}
+	if len(credentials.AccessKeyID) == 0 || len(credentials.SecretAccessKey) == 0 || len(credentials.SessionToken) == 0 {
+		return nil, nil
+	}
+	return &credentials, nil
+}
@@ -0,0 +1,47 @@
+package store
+
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+//
+// See store.go for overall package documentation. This file contains
+// unit tests for the store.go functions.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestEncodeDecode confirms that credentials survive being kept as a single secret.
+func TestEncodeDecode(t *testing.T) {
+	expiration := time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	credentials := &Credentials{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token", Expiration: &expiration}
+	secret, err := encode(credentials)
+	require.Nil(t, err, "there should not have been an error")
+	decoded, err := decode(secret)
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, credentials, decoded, "the credentials should have survived intact")
+
+	// Incomplete credentials are no credentials
+	decoded, err = decode(`{"AccessKeyId":"key"}`)
+	require.Nil(t, err, "there should not have been an error")
+	require.Nil(t, decoded, "incomplete credentials should not have been returned")
+
+	// Garbage is an error
+	_, err = decode("garbage")
+	require.NotNil(t, err, "garbage should have been an error")
+}
+
+// TestSetKeychainFunc confirms that unit tests can substitute a store of their own.
+func TestSetKeychainFunc(t *testing.T) {
+
+	// Put the package back into its normal state after we are done with the test
+	defer ResetPackageDefaults()
+
+	SetKeychainFunc(func() (Store, error) { return SecretService{}, nil })
+	s, err := Keychain()
+	require.Nil(t, err, "there should not have been an error")
+	require.Equal(t, SecretService{}, s, "the substitute store should have been returned")
+}